@@ -326,4 +326,98 @@ type HostedLink struct {
 	URL      string
 	Embed    string
 	FileCode string
-}
\ No newline at end of file
+}
+
+// NotifyAlert envoie une alerte d'exploitation à Discord (lien mort, quota atteint...)
+func (d *DiscordWebhook) NotifyAlert(title, description string, fields []DiscordEmbedField) error {
+	// Créer l'embed
+	embed := DiscordEmbed{
+		Title:       title,
+		Description: description,
+		Color:       15158332, // Rouge
+		Timestamp:   time.Now().Format(time.RFC3339),
+		Fields:      fields,
+		Footer: &DiscordEmbedFooter{
+			Text: "Media Upload System",
+		},
+	}
+
+	// Créer le payload
+	payload := DiscordWebhookPayload{
+		Username:  "Media Upload Bot",
+		AvatarURL: "https://cdn-icons-png.flaticon.com/512/2503/2503508.png",
+		Embeds:    []DiscordEmbed{embed},
+	}
+
+	// Sérialiser le payload
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la sérialisation du payload: %w", err)
+	}
+
+	// Envoyer la requête
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+
+	resp, err := client.Post(d.WebhookURL, "application/json", bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'envoi de la requête: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Discord a retourné un code non-2xx: %d", resp.StatusCode)
+	}
+
+	log.Printf("Alerte Discord envoyée avec succès")
+	return nil
+}
+
+// NotifySeasonComplete signale à Discord qu'une saison d'une série est entièrement disponible
+func (d *DiscordWebhook) NotifySeasonComplete(title string, tmdbID, season, episodes int) error {
+	// Créer l'embed
+	embed := DiscordEmbed{
+		Title:       fmt.Sprintf("✅ Saison complète: %s saison %d", title, season),
+		Description: fmt.Sprintf("Les %d épisodes de la saison %d sont disponibles.", episodes, season),
+		Color:       3066993, // Vert
+		Timestamp:   time.Now().Format(time.RFC3339),
+		Fields: []DiscordEmbedField{
+			{Name: "TMDB ID", Value: fmt.Sprintf("%d", tmdbID), Inline: true},
+		},
+		Footer: &DiscordEmbedFooter{
+			Text: "Media Upload System",
+		},
+	}
+
+	// Créer le payload
+	payload := DiscordWebhookPayload{
+		Username:  "Media Upload Bot",
+		AvatarURL: "https://cdn-icons-png.flaticon.com/512/2503/2503508.png",
+		Embeds:    []DiscordEmbed{embed},
+	}
+
+	// Sérialiser le payload
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la sérialisation du payload: %w", err)
+	}
+
+	// Envoyer la requête
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+
+	resp, err := client.Post(d.WebhookURL, "application/json", bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'envoi de la requête: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Discord a retourné un code non-2xx: %d", resp.StatusCode)
+	}
+
+	log.Printf("Notification Discord envoyée avec succès")
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"media-upload-system/storage"
+)
+
+// backupPrefix et backupSuffix entourent la date dans le nom des sauvegardes planifiées
+const (
+	backupPrefix = "uploads-"
+	backupSuffix = ".db"
+)
+
+// startBackupSchedule sauvegarde la base SQLite au démarrage puis périodiquement, pendant
+// son utilisation
+func startBackupSchedule() {
+	if cfg.Database.Driver == storage.DriverPostgres {
+		log.Printf("Sauvegardes planifiées désactivées pour PostgreSQL, utilisez pg_dump")
+		return
+	}
+
+	interval := time.Duration(cfg.Backup.IntervalHours) * time.Hour
+
+	go func() {
+		// Une première sauvegarde évite d'attendre un intervalle complet après chaque redémarrage
+		if _, err := runBackup(db); err != nil {
+			log.Printf("Erreur lors de la sauvegarde au démarrage: %v", err)
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := runBackup(db); err != nil {
+				log.Printf("Erreur lors de la sauvegarde planifiée: %v", err)
+			}
+		}
+	}()
+
+	log.Printf("Sauvegardes planifiées démarrées (intervalle: %v, conservées: %d, dossier: %s)",
+		interval, cfg.Backup.Keep, cfg.Backup.Directory)
+}
+
+// runBackup copie la base dans le dossier des sauvegardes puis supprime les plus anciennes.
+// La copie est écrite sous un nom temporaire: une sauvegarde interrompue n'est jamais conservée.
+func runBackup(store storage.Store) (string, error) {
+	if err := os.MkdirAll(cfg.Backup.Directory, 0755); err != nil {
+		return "", fmt.Errorf("erreur lors de la création du dossier des sauvegardes: %w", err)
+	}
+
+	name := backupPrefix + time.Now().Format("20060102-150405") + backupSuffix
+	dest := filepath.Join(cfg.Backup.Directory, name)
+	tmp := dest + ".tmp"
+
+	os.Remove(tmp)
+	if err := store.Backup(tmp); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("erreur lors de l'enregistrement de la sauvegarde: %w", err)
+	}
+
+	log.Printf("Base de données sauvegardée: %s", dest)
+
+	if err := pruneBackups(cfg.Backup.Directory, cfg.Backup.Keep); err != nil {
+		log.Printf("Erreur lors de la suppression des anciennes sauvegardes: %v", err)
+	}
+
+	return dest, nil
+}
+
+// pruneBackups supprime les sauvegardes planifiées les plus anciennes au-delà de keep
+func pruneBackups(dir string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	// La date dans le nom trie les sauvegardes de la plus ancienne à la plus récente
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			backups = append(backups, name)
+		}
+	}
+	sort.Strings(backups)
+
+	for len(backups) > keep {
+		path := filepath.Join(dir, backups[0])
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		log.Printf("Ancienne sauvegarde supprimée: %s", path)
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+// runBackupCommand exécute la commande backup: sauvegarde la base dans le fichier indiqué,
+// ou dans le dossier des sauvegardes en appliquant la rétention
+func runBackupCommand(args []string) error {
+	database, err := openDatabase()
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'ouverture de la base de données: %w", err)
+	}
+	defer database.Close()
+
+	if len(args) > 0 {
+		if err := database.Backup(args[0]); err != nil {
+			return err
+		}
+		log.Printf("Base de données sauvegardée: %s", args[0])
+		return nil
+	}
+
+	_, err = runBackup(database)
+	return err
+}
+
+// exportFormat retourne le format demandé, ou celui de l'extension du fichier:
+// .json pour un document JSON, NDJSON sinon
+func exportFormat(path string, args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return storage.FormatJSON
+	}
+	return storage.FormatNDJSON
+}
+
+// runExportCommand exécute la commande export <fichier|-> [json|ndjson]: écrit les uploads,
+// leurs liens et la queue dans un fichier, ou sur la sortie standard avec "-"
+func runExportCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: export <fichier|-> [json|ndjson]")
+	}
+	path := args[0]
+
+	// Les journaux vont sur la sortie d'erreur pour ne pas se mêler à un export sur la sortie standard
+	if path == "-" {
+		log.SetOutput(os.Stderr)
+	}
+
+	database, err := openDatabase()
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'ouverture de la base de données: %w", err)
+	}
+	defer database.Close()
+
+	var w io.Writer = os.Stdout
+	if path != "-" {
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("erreur lors de la création du fichier d'export: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	stats, err := database.Export(w, exportFormat(path, args[1:]))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Export terminé: %d uploads, %d liens, %d tâches, %d médias, %d saisons, %d épisodes",
+		stats.Uploads, stats.Links, stats.Queue, stats.Media, stats.Seasons, stats.Episodes)
+	return nil
+}
+
+// runImportCommand exécute la commande import <fichier|-> [json|ndjson]: charge un export
+// dans une base vide, après avoir appliqué les migrations
+func runImportCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: import <fichier|-> [json|ndjson]")
+	}
+	path := args[0]
+
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("erreur lors de l'ouverture du fichier d'import: %w", err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	database, err := openDatabase()
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'ouverture de la base de données: %w", err)
+	}
+	defer database.Close()
+
+	if err := database.MigrateToLatest(); err != nil {
+		return err
+	}
+
+	stats, err := database.Import(r, exportFormat(path, args[1:]))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Import terminé: %d uploads, %d liens, %d tâches, %d médias, %d saisons, %d épisodes",
+		stats.Uploads, stats.Links, stats.Queue, stats.Media, stats.Seasons, stats.Episodes)
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"media-upload-system/config"
+	"media-upload-system/storage"
+)
+
+// TestBackupAtStartup vérifie qu'une sauvegarde est faite dès le démarrage, sans attendre
+// le premier intervalle
+func TestBackupAtStartup(t *testing.T) {
+	cfg = &config.Config{}
+	cfg.Database.Driver = storage.DriverSQLite
+	cfg.Backup.Directory = t.TempDir()
+	cfg.Backup.IntervalHours = 24
+	cfg.Backup.Keep = 7
+
+	database, err := storage.NewDatabase(filepath.Join(t.TempDir(), "uploads.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := database.MigrateToLatest(); err != nil {
+		t.Fatalf("MigrateToLatest: %v", err)
+	}
+	db = database
+
+	startBackupSchedule()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		backups, _ := filepath.Glob(filepath.Join(cfg.Backup.Directory, backupPrefix+"*"+backupSuffix))
+		if len(backups) == 1 {
+			if info, err := os.Stat(backups[0]); err != nil || info.Size() == 0 {
+				t.Fatalf("sauvegarde vide: %v", err)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("aucune sauvegarde au démarrage")
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"media-upload-system/config"
+	"media-upload-system/upload"
+	"media-upload-system/worker"
+)
+
+// bandwidthProfile représente un profil de débit dont la plage horaire a été analysée
+type bandwidthProfile struct {
+	name    string
+	window  worker.TimeWindow
+	global  int64
+	hosters map[string]int64
+}
+
+// startBandwidthSchedule applique les limites de débit puis les réévalue chaque minute.
+// Les changements de profil s'appliquent aussi aux uploads en cours.
+func startBandwidthSchedule(bandwidth *upload.Bandwidth) error {
+	var profiles []bandwidthProfile
+	for _, profile := range cfg.Bandwidth.Profiles {
+		window, err := worker.ParseTimeWindow(profile.Start, profile.End)
+		if err != nil {
+			return fmt.Errorf("profil de bande passante %s invalide: %w", profile.Name, err)
+		}
+
+		profiles = append(profiles, bandwidthProfile{
+			name:    profile.Name,
+			window:  window,
+			global:  megabytesPerSecond(profile.GlobalMBps),
+			hosters: hosterRates(profile.Hosters),
+		})
+	}
+
+	defaultProfile := bandwidthProfile{
+		name:    "défaut",
+		global:  megabytesPerSecond(cfg.Bandwidth.GlobalMBps),
+		hosters: hosterRates(cfg.Bandwidth.Hosters),
+	}
+
+	current := ""
+	apply := func(now time.Time) {
+		active := defaultProfile
+		for _, profile := range profiles {
+			if profile.window.Contains(now) {
+				active = profile
+				break
+			}
+		}
+
+		if active.name != current {
+			log.Printf("Application du profil de bande passante %s (global: %.1f MB/s)",
+				active.name, float64(active.global)/(1024*1024))
+			current = active.name
+		}
+
+		bandwidth.SetLimits(active.global, active.hosters)
+	}
+
+	apply(time.Now())
+
+	go func() {
+		ticker := time.NewTicker(1 * time.Minute)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			apply(now)
+		}
+	}()
+
+	return nil
+}
+
+// uploadWindows retourne les plages horaires pendant lesquelles les uploads peuvent démarrer
+func uploadWindows(windows []config.TimeWindow) ([]worker.TimeWindow, error) {
+	var parsed []worker.TimeWindow
+	for _, window := range windows {
+		timeWindow, err := worker.ParseTimeWindow(window.Start, window.End)
+		if err != nil {
+			return nil, fmt.Errorf("plage d'upload invalide: %w", err)
+		}
+		parsed = append(parsed, timeWindow)
+	}
+
+	return parsed, nil
+}
+
+// hosterRates convertit les limites par hébergeur en octets par seconde
+func hosterRates(limits map[string]float64) map[string]int64 {
+	rates := make(map[string]int64)
+	for hoster, limit := range limits {
+		rates[hoster] = megabytesPerSecond(limit)
+	}
+	return rates
+}
+
+// megabytesPerSecond convertit un débit en MB/s en octets par seconde
+func megabytesPerSecond(value float64) int64 {
+	return int64(value * 1024 * 1024)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"log"
+
+	"media-upload-system/model"
+	"media-upload-system/storage"
+	"media-upload-system/strapi"
+)
+
+// saveWebhookMedia enregistre le film ou la série d'un webhook avec ses identifiants TMDB,
+// TVDB et IMDb, ainsi que les épisodes reçus. Retourne l'ID du média, 0 en cas d'erreur.
+func saveWebhookMedia(webhook *model.RadarrWebhook) int64 {
+	var media storage.Media
+	switch {
+	case webhook.Movie != nil:
+		media = storage.Media{
+			Type:   storage.TypeMovie,
+			TmdbID: webhook.Movie.TmdbId,
+			ImdbID: webhook.Movie.ImdbId,
+			Title:  webhook.Movie.Title,
+			Year:   webhook.Movie.Year,
+		}
+	case webhook.Series != nil:
+		media = storage.Media{
+			Type:   storage.TypeSeries,
+			TmdbID: webhook.Series.TmdbId,
+			TvdbID: webhook.Series.TvdbId,
+			ImdbID: webhook.Series.ImdbId,
+			Title:  webhook.Series.Title,
+			Year:   webhook.Series.Year,
+		}
+	default:
+		return 0
+	}
+
+	mediaID, err := db.SaveMedia(&media)
+	if err != nil {
+		log.Printf("Erreur lors de l'enregistrement de %s: %v", media.Title, err)
+		return 0
+	}
+
+	for _, episode := range webhook.Episodes {
+		_, err := db.SaveEpisode(&storage.Episode{
+			MediaID:      mediaID,
+			SeasonNumber: episode.SeasonNumber,
+			Number:       episode.EpisodeNumber,
+			Title:        episode.Title,
+			AirDate:      episode.AirDate,
+			TvdbID:       episode.TvdbId,
+		})
+		if err != nil {
+			log.Printf("Erreur lors de l'enregistrement de l'épisode S%02dE%02d: %v",
+				episode.SeasonNumber, episode.EpisodeNumber, err)
+		}
+	}
+
+	return mediaID
+}
+
+// linkEpisode retourne la place du lien d'un épisode dans la fiche de sa série, ou nil
+// pour un film
+func linkEpisode(uploadID int64) *strapi.LinkEpisode {
+	upload, err := db.GetUpload(uploadID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération de l'upload %d: %v", uploadID, err)
+		return nil
+	}
+	if upload.Season == nil || upload.Episode == nil {
+		return nil
+	}
+
+	placement := &strapi.LinkEpisode{Season: *upload.Season, Episode: *upload.Episode}
+
+	episodes, err := db.GetEpisodes(upload.MediaID, *upload.Season)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des épisodes de %s: %v", upload.Title, err)
+	}
+	for _, episode := range episodes {
+		if episode.Number == *upload.Episode {
+			placement.Title = episode.Title
+		}
+	}
+
+	return placement
+}
+
+// publishLink publie dans Strapi le lien d'un upload avec ses sous-titres et les informations
+// de son fichier, et pour un épisode sa saison et son numéro dans la fiche de la série
+func publishLink(ficheID string, uploadID int64, embedURL, fileCode string) (string, error) {
+	subtitles := linkSubtitles(uploadID, fileCode)
+	media := linkMedia(uploadID)
+
+	if episode := linkEpisode(uploadID); episode != nil {
+		return strapiClient.CreateEpisodeLink(ficheID, embedURL, episode, subtitles, media)
+	}
+	return strapiClient.CreateLinkWithDetails(ficheID, embedURL, subtitles, media)
+}
+
+// checkSeasonCompletion vérifie si la saison d'un épisode uploadé est complète et le signale
+// une seule fois à Discord. Le nombre d'épisodes de la saison est demandé à TMDB la première fois.
+func checkSeasonCompletion(uploadID int64) {
+	upload, err := db.GetUpload(uploadID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération de l'upload %d: %v", uploadID, err)
+		return
+	}
+	if upload.Season == nil || upload.MediaID == 0 {
+		return
+	}
+
+	seasons, err := db.GetSeasons(upload.MediaID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des saisons de %s: %v", upload.Title, err)
+		return
+	}
+
+	var season *storage.Season
+	for i := range seasons {
+		if seasons[i].Number == *upload.Season {
+			season = &seasons[i]
+		}
+	}
+	if season == nil || season.CompletedAt != nil {
+		return
+	}
+
+	if season.EpisodeCount == 0 {
+		if cfg.TMDB.ApiKey == "" {
+			return
+		}
+
+		details, err := tmdbClient.GetSeasonDetails(upload.TmdbID, season.Number)
+		if err != nil {
+			log.Printf("Erreur lors de la récupération de la saison %d de %s: %v", season.Number, upload.Title, err)
+			return
+		}
+
+		season.EpisodeCount = len(details.Episodes)
+		if err := db.SetSeasonEpisodeCount(upload.MediaID, season.Number, season.EpisodeCount); err != nil {
+			log.Printf("Erreur lors de l'enregistrement de la saison %d de %s: %v", season.Number, upload.Title, err)
+		}
+	}
+
+	if !season.Complete() {
+		log.Printf("Saison %d de %s: %d/%d épisodes disponibles", season.Number, upload.Title, season.Uploaded, season.EpisodeCount)
+		return
+	}
+
+	first, err := db.MarkSeasonCompleted(season.ID)
+	if err != nil {
+		log.Printf("Erreur lors de la mise à jour de la saison %d de %s: %v", season.Number, upload.Title, err)
+		return
+	}
+	if !first {
+		return
+	}
+
+	log.Printf("Saison %d de %s complète (%d épisodes)", season.Number, upload.Title, season.Uploaded)
+
+	if err := discordClient.NotifySeasonComplete(upload.Title, upload.TmdbID, season.Number, season.Uploaded); err != nil {
+		log.Printf("Erreur lors de la notification à Discord: %v", err)
+	}
+}
@@ -11,6 +11,8 @@ type Config struct {
 	Server struct {
 		Port int    `json:"port"`
 		Host string `json:"host"`
+		// AdminToken protège les actions d'administration de l'API (désactivées s'il est vide)
+		AdminToken string `json:"adminToken"`
 	} `json:"server"`
 
 	Workers struct {
@@ -18,22 +20,85 @@ type Config struct {
 	} `json:"workers"`
 
 	Uploaders struct {
+		// Les URLs de base sont optionnelles: elles remplacent les URLs officielles,
+		// par exemple pour faire tourner le pipeline contre les faux serveurs de hostertest
 		Netu struct {
-			Enabled bool   `json:"enabled"`
-			ApiKey  string `json:"apiKey"`
+			Enabled       bool   `json:"enabled"`
+			ApiKey        string `json:"apiKey"`
+			MaxConcurrent int    `json:"maxConcurrent"`
+			APIURL        string `json:"apiURL,omitempty"`
+			PlayerURL     string `json:"playerURL,omitempty"`
 		} `json:"netu"`
 		MixDrop struct {
-			Enabled bool   `json:"enabled"`
-			Email   string `json:"email"`
-			ApiKey  string `json:"apiKey"`
+			Enabled       bool   `json:"enabled"`
+			Email         string `json:"email"`
+			ApiKey        string `json:"apiKey"`
+			MaxConcurrent int    `json:"maxConcurrent"`
+			UploadURL     string `json:"uploadURL,omitempty"`
+			APIURL        string `json:"apiURL,omitempty"`
+			PublicURL     string `json:"publicURL,omitempty"`
 		} `json:"mixdrop"`
+		// Tus: ingest du CDN auto-hébergé (protocole tus 1.0). PublicURL et
+		// EmbedURL sont des modèles où %s est remplacé par l'identifiant de l'upload.
+		Tus struct {
+			Enabled       bool              `json:"enabled"`
+			Endpoint      string            `json:"endpoint"`
+			Headers       map[string]string `json:"headers"`
+			ChunkSizeMB   int               `json:"chunkSizeMB"`
+			Checksum      bool              `json:"checksum"`
+			PublicURL     string            `json:"publicUrl"`
+			EmbedURL      string            `json:"embedUrl"`
+			MaxConcurrent int               `json:"maxConcurrent"`
+		} `json:"tus"`
+		// S3: stockage compatible S3 (AWS, MinIO, R2...) en upload multipart reprenable.
+		// PartSizeMB doit être d'au moins 5 Mo, minimum imposé par S3 sauf pour la dernière
+		// partie. PublicURL est un modèle où %s est remplacé par la clé de l'objet.
+		S3 struct {
+			Enabled       bool   `json:"enabled"`
+			Endpoint      string `json:"endpoint"`
+			Region        string `json:"region"`
+			Bucket        string `json:"bucket"`
+			AccessKey     string `json:"accessKey"`
+			SecretKey     string `json:"secretKey"`
+			Prefix        string `json:"prefix,omitempty"`
+			PartSizeMB    int    `json:"partSizeMB"`
+			PublicURL     string `json:"publicUrl,omitempty"`
+			MaxConcurrent int    `json:"maxConcurrent"`
+		} `json:"s3"`
+		// Parallel: le fichier est lu une seule fois et envoyé simultanément
+		// à tous les hébergeurs compatibles
+		Parallel struct {
+			Enabled bool `json:"enabled"`
+		} `json:"parallel"`
+		// Remote upload: après le premier upload réussi exposant une URL de
+		// téléchargement direct, les autres miroirs sont créés depuis cette URL.
+		// SourceBaseURL est l'URL publique du répertoire local SourceRoot, servi par un
+		// serveur web: les fichiers qu'il contient sont importés directement depuis cette URL.
+		RemoteUpload struct {
+			Enabled             bool   `json:"enabled"`
+			PollIntervalSeconds int    `json:"pollIntervalSeconds"`
+			TimeoutMinutes      int    `json:"timeoutMinutes"`
+			SourceBaseURL       string `json:"sourceBaseUrl,omitempty"`
+			SourceRoot          string `json:"sourceRoot,omitempty"`
+		} `json:"remoteUpload"`
 		// Vous pouvez ajouter d'autres uploaders ici
 	} `json:"uploaders"`
 
 	Database struct {
-		Path string `json:"path"`
+		Driver string `json:"driver"` // sqlite (par défaut) ou postgres
+		Path   string `json:"path"`   // fichier de la base SQLite
+		DSN    string `json:"dsn"`    // chaîne de connexion PostgreSQL
 	} `json:"database"`
 
+	// Backup copie à chaud la base SQLite dans Directory toutes les IntervalHours heures
+	// et conserve les Keep dernières copies
+	Backup struct {
+		Enabled       bool   `json:"enabled"`
+		Directory     string `json:"directory"`
+		IntervalHours int    `json:"intervalHours"`
+		Keep          int    `json:"keep"`
+	} `json:"backup"`
+
 	API struct {
 		Endpoint string `json:"endpoint"`
 	} `json:"api"`
@@ -52,6 +117,113 @@ type Config struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
 	} `json:"strapi"`
+
+	// Mirrors définit la politique de miroirs. Un upload est terminé quand tous les
+	// hébergeurs requis et au moins MinMirrors hébergeurs ont un miroir; sinon il passe
+	// au statut partial et seuls les miroirs manquants sont relancés toutes les
+	// RetryIntervalMinutes, au plus MaxRetries fois.
+	Mirrors struct {
+		MinMirrors           int      `json:"minMirrors"`
+		Required             []string `json:"required"`
+		RetryIntervalMinutes int      `json:"retryIntervalMinutes"`
+		MaxRetries           int      `json:"maxRetries"`
+	} `json:"mirrors"`
+
+	// CircuitBreaker ignore un hébergeur pendant CooldownMinutes après FailureThreshold
+	// échecs consécutifs, puis autorise un upload de test
+	CircuitBreaker struct {
+		FailureThreshold int `json:"failureThreshold"`
+		CooldownMinutes  int `json:"cooldownMinutes"`
+	} `json:"circuitBreaker"`
+
+	// LinkChecker vérifie périodiquement que les liens hébergés sont en ligne.
+	// Un lien est déclaré mort après FailureThreshold vérifications négatives.
+	// Avec Reupload, le fichier est renvoyé à l'hébergeur au plus MaxReuploadsPerDay fois par jour.
+	LinkChecker struct {
+		Enabled            bool `json:"enabled"`
+		IntervalMinutes    int  `json:"intervalMinutes"`
+		RecheckHours       int  `json:"recheckHours"`
+		FailureThreshold   int  `json:"failureThreshold"`
+		BatchSize          int  `json:"batchSize"`
+		Reupload           bool `json:"reupload"`
+		MaxReuploadsPerDay int  `json:"maxReuploadsPerDay"`
+	} `json:"linkChecker"`
+
+	// Bandwidth limite le débit des uploads (en MB/s, 0 = illimité). Le premier
+	// profil dont la plage horaire contient l'heure courante remplace les limites
+	// par défaut. En dehors des plages UploadWindows, aucun nouvel upload ne démarre.
+	Bandwidth struct {
+		GlobalMBps    float64            `json:"globalMBps"`
+		Hosters       map[string]float64 `json:"hosters"`
+		Profiles      []BandwidthProfile `json:"profiles"`
+		UploadWindows []TimeWindow       `json:"uploadWindows"`
+	} `json:"bandwidth"`
+
+	// FileManagement supprime les fichiers des hébergeurs quand le média est supprimé
+	// de Radarr/Sonarr (DeleteOnRemove). Une mise à niveau, ou un fichier de meilleure qualité,
+	// est uploadée comme nouvelle version (UploadUpgrades) qui remplace la précédente: les
+	// miroirs de l'ancienne version sont supprimés (DeleteReplaced) ou conservés et publiés
+	// après ceux de la meilleure version.
+	FileManagement struct {
+		DeleteOnRemove bool `json:"deleteOnRemove"`
+		UploadUpgrades bool `json:"uploadUpgrades"`
+		DeleteReplaced bool `json:"deleteReplaced"`
+	} `json:"fileManagement"`
+
+	// Fingerprints calcule à l'arrivée de chaque fichier une empreinte de sa taille et de
+	// trois extraits de SampleKB Ko (début, milieu et fin), et avec FullHash le SHA-256 complet
+	// avant l'upload. Un fichier déjà uploadé, même sous un autre média, réutilise les liens
+	// hébergés de cet upload (ReuseLinks) au lieu d'être uploadé une seconde fois.
+	Fingerprints struct {
+		Enabled    bool `json:"enabled"`
+		ReuseLinks bool `json:"reuseLinks"`
+		FullHash   bool `json:"fullHash"`
+		SampleKB   int  `json:"sampleKB"`
+	} `json:"fingerprints"`
+
+	// Folders range les fichiers uploadés dans un dossier par film ou série, et par saison,
+	// chez les hébergeurs qui gèrent les dossiers. Les modèles acceptent {title}, {tmdb_id}
+	// et {season}.
+	Folders struct {
+		Enabled        bool   `json:"enabled"`
+		MovieTemplate  string `json:"movieTemplate"`
+		SeriesTemplate string `json:"seriesTemplate"`
+		SeasonTemplate string `json:"seasonTemplate"`
+	} `json:"folders"`
+
+	// Subtitles envoie les fichiers de sous-titres trouvés à côté de la vidéo (même nom,
+	// suivi éventuellement de la langue, par exemple film.fr.srt) aux hébergeurs qui les gèrent
+	Subtitles struct {
+		Enabled    bool     `json:"enabled"`
+		Extensions []string `json:"extensions"`
+	} `json:"subtitles"`
+
+	// Quotas limite le volume stocké et envoyé chaque jour sur les comptes hébergeurs.
+	// Un upload qui dépasserait un quota est redirigé vers l'hébergeur Fallback, ou ignoré.
+	Quotas map[string]HosterQuota `json:"quotas"`
+}
+
+// HosterQuota représente les quotas du compte d'un hébergeur (en GB, 0 = illimité).
+// Sans StorageGB, la limite de stockage retournée par l'API de l'hébergeur est utilisée.
+type HosterQuota struct {
+	DailyUploadGB float64 `json:"dailyUploadGB"`
+	StorageGB     float64 `json:"storageGB"`
+	Fallback      string  `json:"fallback"`
+}
+
+// TimeWindow représente une plage horaire quotidienne au format "HH:MM"
+type TimeWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// BandwidthProfile représente des limites de débit appliquées pendant une plage horaire
+type BandwidthProfile struct {
+	Name       string             `json:"name"`
+	Start      string             `json:"start"`
+	End        string             `json:"end"`
+	GlobalMBps float64            `json:"globalMBps"`
+	Hosters    map[string]float64 `json:"hosters"`
 }
 
 // LoadConfig charge la configuration depuis un fichier JSON
@@ -78,9 +250,108 @@ func LoadConfig(path string) (*Config, error) {
 	if config.Workers.MaxConcurrent == 0 {
 		config.Workers.MaxConcurrent = 10
 	}
+	if config.Database.Driver == "" {
+		config.Database.Driver = "sqlite"
+	}
 	if config.Database.Path == "" {
 		config.Database.Path = "./uploads.db"
 	}
+	if config.Backup.Directory == "" {
+		config.Backup.Directory = "./backups"
+	}
+	if config.Backup.IntervalHours == 0 {
+		config.Backup.IntervalHours = 24
+	}
+	if config.Backup.Keep == 0 {
+		config.Backup.Keep = 7
+	}
+	if config.Uploaders.Netu.MaxConcurrent == 0 {
+		config.Uploaders.Netu.MaxConcurrent = 1
+	}
+	if config.Uploaders.MixDrop.MaxConcurrent == 0 {
+		config.Uploaders.MixDrop.MaxConcurrent = 1
+	}
+	if config.Uploaders.Tus.MaxConcurrent == 0 {
+		config.Uploaders.Tus.MaxConcurrent = 1
+	}
+	if config.Uploaders.Tus.ChunkSizeMB == 0 {
+		config.Uploaders.Tus.ChunkSizeMB = 64
+	}
+	if config.Uploaders.S3.MaxConcurrent == 0 {
+		config.Uploaders.S3.MaxConcurrent = 1
+	}
+	if config.Uploaders.S3.PartSizeMB == 0 {
+		config.Uploaders.S3.PartSizeMB = 64
+	}
+	if config.LinkChecker.IntervalMinutes == 0 {
+		config.LinkChecker.IntervalMinutes = 60
+	}
+	if config.LinkChecker.RecheckHours == 0 {
+		config.LinkChecker.RecheckHours = 24
+	}
+	if config.LinkChecker.FailureThreshold == 0 {
+		config.LinkChecker.FailureThreshold = 2
+	}
+	if config.LinkChecker.BatchSize == 0 {
+		config.LinkChecker.BatchSize = 100
+	}
+	if config.Folders.MovieTemplate == "" {
+		config.Folders.MovieTemplate = "{title}"
+	}
+	if config.Folders.SeriesTemplate == "" {
+		config.Folders.SeriesTemplate = "{title}"
+	}
+	if config.Folders.SeasonTemplate == "" {
+		config.Folders.SeasonTemplate = "Saison {season}"
+	}
+	if config.Fingerprints.SampleKB == 0 {
+		config.Fingerprints.SampleKB = 1024
+	}
+	if len(config.Subtitles.Extensions) == 0 {
+		config.Subtitles.Extensions = []string{".srt", ".ass", ".ssa", ".vtt"}
+	}
+	if config.Mirrors.MinMirrors == 0 {
+		config.Mirrors.MinMirrors = 1
+	}
+	if config.Mirrors.RetryIntervalMinutes == 0 {
+		config.Mirrors.RetryIntervalMinutes = 60
+	}
+	if config.Mirrors.MaxRetries == 0 {
+		config.Mirrors.MaxRetries = 5
+	}
+	if config.CircuitBreaker.FailureThreshold == 0 {
+		config.CircuitBreaker.FailureThreshold = 3
+	}
+	if config.CircuitBreaker.CooldownMinutes == 0 {
+		config.CircuitBreaker.CooldownMinutes = 15
+	}
+	if config.LinkChecker.MaxReuploadsPerDay == 0 {
+		config.LinkChecker.MaxReuploadsPerDay = 1
+	}
+	if config.Uploaders.RemoteUpload.PollIntervalSeconds == 0 {
+		config.Uploaders.RemoteUpload.PollIntervalSeconds = 30
+	}
+	if config.Uploaders.RemoteUpload.TimeoutMinutes == 0 {
+		config.Uploaders.RemoteUpload.TimeoutMinutes = 360
+	}
+
+	if config.Backup.Enabled {
+		if config.Backup.IntervalHours <= 0 {
+			return nil, fmt.Errorf("intervalle des sauvegardes invalide: %d heures", config.Backup.IntervalHours)
+		}
+		if config.Backup.Keep <= 0 {
+			return nil, fmt.Errorf("nombre de sauvegardes conservées invalide: %d", config.Backup.Keep)
+		}
+	}
+	if config.Mirrors.RetryIntervalMinutes <= 0 {
+		return nil, fmt.Errorf("intervalle de relance des miroirs invalide: %d minutes", config.Mirrors.RetryIntervalMinutes)
+	}
+	if config.LinkChecker.Enabled && config.LinkChecker.IntervalMinutes <= 0 {
+		return nil, fmt.Errorf("intervalle de vérification des liens invalide: %d minutes", config.LinkChecker.IntervalMinutes)
+	}
+	if config.Uploaders.RemoteUpload.Enabled && config.Uploaders.RemoteUpload.PollIntervalSeconds <= 0 {
+		return nil, fmt.Errorf("intervalle de suivi des remote uploads invalide: %d secondes", config.Uploaders.RemoteUpload.PollIntervalSeconds)
+	}
 
 	return &config, nil
 }
@@ -99,14 +370,47 @@ func CreateDefaultConfig(path string) error {
 	// Uploaders
 	config.Uploaders.Netu.Enabled = true
 	config.Uploaders.Netu.ApiKey = "d81d2161e383e64533b3e0015bfa6b9a"
+	config.Uploaders.Netu.MaxConcurrent = 1
 
 	config.Uploaders.MixDrop.Enabled = false
 	config.Uploaders.MixDrop.Email = "your-email@example.com"
 	config.Uploaders.MixDrop.ApiKey = "your-mixdrop-api-key"
+	config.Uploaders.MixDrop.MaxConcurrent = 1
+
+	config.Uploaders.Tus.Enabled = false
+	config.Uploaders.Tus.Endpoint = "https://cdn.example.com/files/"
+	config.Uploaders.Tus.ChunkSizeMB = 64
+	config.Uploaders.Tus.Checksum = true
+	config.Uploaders.Tus.PublicURL = "https://cdn.example.com/files/%s"
+	config.Uploaders.Tus.EmbedURL = "https://cdn.example.com/embed/%s"
+	config.Uploaders.Tus.MaxConcurrent = 1
+
+	config.Uploaders.S3.Enabled = false
+	config.Uploaders.S3.Endpoint = "https://s3.example.com"
+	config.Uploaders.S3.Region = "us-east-1"
+	config.Uploaders.S3.Bucket = "media"
+	config.Uploaders.S3.AccessKey = "your-access-key"
+	config.Uploaders.S3.SecretKey = "your-secret-key"
+	config.Uploaders.S3.PartSizeMB = 64
+	config.Uploaders.S3.PublicURL = "https://media.example.com/%s"
+	config.Uploaders.S3.MaxConcurrent = 1
+
+	config.Uploaders.Parallel.Enabled = false
+
+	config.Uploaders.RemoteUpload.Enabled = true
+	config.Uploaders.RemoteUpload.PollIntervalSeconds = 30
+	config.Uploaders.RemoteUpload.TimeoutMinutes = 360
 
 	// Database
+	config.Database.Driver = "sqlite"
 	config.Database.Path = "./uploads.db"
 
+	// Sauvegardes de la base
+	config.Backup.Enabled = true
+	config.Backup.Directory = "./backups"
+	config.Backup.IntervalHours = 24
+	config.Backup.Keep = 7
+
 	// API
 	config.API.Endpoint = "https://your-site.com/api/media"
 
@@ -123,6 +427,56 @@ func CreateDefaultConfig(path string) error {
 	config.Strapi.Username = "admin"
 	config.Strapi.Password = "Clement123!"
 
+	// Politique de miroirs
+	config.Mirrors.MinMirrors = 1
+	config.Mirrors.RetryIntervalMinutes = 60
+	config.Mirrors.MaxRetries = 5
+
+	// Disjoncteurs des hébergeurs
+	config.CircuitBreaker.FailureThreshold = 3
+	config.CircuitBreaker.CooldownMinutes = 15
+
+	// Vérification des liens
+	config.LinkChecker.Enabled = true
+	config.LinkChecker.IntervalMinutes = 60
+	config.LinkChecker.RecheckHours = 24
+	config.LinkChecker.FailureThreshold = 2
+	config.LinkChecker.BatchSize = 100
+	config.LinkChecker.Reupload = true
+	config.LinkChecker.MaxReuploadsPerDay = 1
+
+	// Gestion des fichiers hébergés
+	config.FileManagement.DeleteOnRemove = false
+	config.FileManagement.UploadUpgrades = true
+	config.FileManagement.DeleteReplaced = true
+
+	// Empreintes des fichiers
+	config.Fingerprints.Enabled = true
+	config.Fingerprints.ReuseLinks = true
+	config.Fingerprints.FullHash = false
+	config.Fingerprints.SampleKB = 1024
+
+	// Dossiers des hébergeurs
+	config.Folders.Enabled = true
+	config.Folders.MovieTemplate = "{title}"
+	config.Folders.SeriesTemplate = "{title}"
+	config.Folders.SeasonTemplate = "Saison {season}"
+
+	// Sous-titres
+	config.Subtitles.Enabled = true
+	config.Subtitles.Extensions = []string{".srt", ".ass", ".ssa", ".vtt"}
+
+	// Quotas des comptes hébergeurs
+	config.Quotas = map[string]HosterQuota{
+		"netu":    {DailyUploadGB: 100},
+		"mixdrop": {DailyUploadGB: 100, Fallback: "netu"},
+	}
+
+	// Bande passante: limitée en soirée pour ne pas gêner le streaming
+	config.Bandwidth.Profiles = []BandwidthProfile{
+		{Name: "soirée", Start: "18:00", End: "23:00", GlobalMBps: 5},
+	}
+
 	// Sérialiser en JSON
 	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfig écrit un fichier de configuration temporaire
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigBackupDefaults(t *testing.T) {
+	config, err := LoadConfig(writeConfig(t, `{"backup": {"enabled": true}}`))
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if config.Backup.IntervalHours != 24 || config.Backup.Keep != 7 {
+		t.Fatalf("valeurs par défaut inattendues: %+v", config.Backup)
+	}
+}
+
+func TestLoadConfigRejectsInvalidBackup(t *testing.T) {
+	for _, content := range []string{
+		`{"backup": {"enabled": true, "intervalHours": -1}}`,
+		`{"backup": {"enabled": true, "keep": -3}}`,
+	} {
+		if _, err := LoadConfig(writeConfig(t, content)); err == nil {
+			t.Fatalf("configuration acceptée: %s", content)
+		}
+	}
+
+	// Les sauvegardes désactivées ne sont pas validées
+	if _, err := LoadConfig(writeConfig(t, `{"backup": {"intervalHours": -1}}`)); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+}
+
+func TestLoadConfigRejectsInvalidMirrorRetries(t *testing.T) {
+	if _, err := LoadConfig(writeConfig(t, `{"mirrors": {"retryIntervalMinutes": -5}}`)); err == nil {
+		t.Fatalf("intervalle de relance négatif accepté")
+	}
+}
+
+func TestLoadConfigRejectsInvalidLinkChecker(t *testing.T) {
+	if _, err := LoadConfig(writeConfig(t, `{"linkChecker": {"enabled": true, "intervalMinutes": -1}}`)); err == nil {
+		t.Fatalf("intervalle de vérification négatif accepté")
+	}
+
+	// La vérification désactivée n'est pas validée
+	if _, err := LoadConfig(writeConfig(t, `{"linkChecker": {"intervalMinutes": -1}}`)); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+}
+
+func TestLoadConfigRejectsInvalidRemoteUpload(t *testing.T) {
+	if _, err := LoadConfig(writeConfig(t, `{"uploaders": {"remoteUpload": {"enabled": true, "pollIntervalSeconds": -30}}}`)); err == nil {
+		t.Fatalf("intervalle de suivi négatif accepté")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"media-upload-system/storage"
+)
+
+// newCorrelationID crée l'identifiant qui relie les événements d'un webhook ou d'une requête
+func newCorrelationID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("Erreur lors de la génération d'un identifiant de corrélation: %v", err)
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
+
+// requestCorrelationID retourne l'identifiant de corrélation fourni par le client dans
+// l'en-tête X-Request-ID, ou en crée un
+func requestCorrelationID(r *http.Request) string {
+	if id := r.Header.Get("X-Request-ID"); id != "" {
+		return id
+	}
+	return newCorrelationID()
+}
+
+// runEventsCommand affiche l'historique des changements:
+//
+//	events upload <id> [limite]  événements d'un upload et de ses liens
+//	events link <id> [limite]    événements d'un lien
+//	events tmdb <id> [limite]    événements de tous les uploads d'un média
+func runEventsCommand(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: events upload|link|tmdb <id> [limite]")
+	}
+
+	id, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || id <= 0 {
+		return fmt.Errorf("id invalide: %s", args[1])
+	}
+
+	filter := storage.EventFilter{Limit: 100}
+	switch args[0] {
+	case "upload":
+		filter.UploadID = id
+	case "link":
+		filter.LinkID = id
+	case "tmdb":
+		filter.TmdbID = int(id)
+	default:
+		return fmt.Errorf("entité inconnue: %s (upload, link, tmdb)", args[0])
+	}
+
+	if len(args) > 2 {
+		filter.Limit, err = strconv.Atoi(args[2])
+		if err != nil || filter.Limit <= 0 {
+			return fmt.Errorf("limite invalide: %s", args[2])
+		}
+	}
+
+	database, err := openDatabase()
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'ouverture de la base de données: %w", err)
+	}
+	defer database.Close()
+
+	events, err := database.GetEvents(filter)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%d événements", len(events))
+	for _, event := range events {
+		change := event.NewValue
+		if event.OldValue != "" {
+			change = event.OldValue + " -> " + event.NewValue
+		}
+		log.Printf("  %s  %-6s %-6d %-10s %-9s %-16s %s",
+			event.CreatedAt.Format("2006-01-02 15:04:05"), event.Entity, event.EntityID,
+			event.Action, event.Actor, event.CorrelationID, change)
+	}
+
+	return nil
+}
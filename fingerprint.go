@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+
+	"media-upload-system/storage"
+)
+
+// fileFingerprint calcule l'empreinte rapide d'un fichier: le SHA-256 de sa taille et de trois
+// extraits de sampleSize octets au début, au milieu et à la fin. Un fichier trop petit pour
+// trois extraits est lu en entier.
+func fileFingerprint(path string, sampleSize int64) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	size := info.Size()
+
+	hash := sha256.New()
+	binary.Write(hash, binary.LittleEndian, size)
+
+	if size <= 3*sampleSize {
+		if _, err := io.Copy(hash, file); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	for _, offset := range []int64{0, (size - sampleSize) / 2, size - sampleSize} {
+		if _, err := io.Copy(hash, io.NewSectionReader(file, offset, sampleSize)); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// fileSHA256 calcule le SHA-256 de tout le contenu d'un fichier
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ingestFingerprint retourne l'empreinte rapide du fichier reçu par webhook, ou une chaîne
+// vide si les empreintes sont désactivées ou si le fichier n'est pas lisible
+func ingestFingerprint(path string) string {
+	if !cfg.Fingerprints.Enabled || path == "" {
+		return ""
+	}
+
+	fingerprint, err := fileFingerprint(path, int64(cfg.Fingerprints.SampleKB)*1024)
+	if err != nil {
+		log.Printf("Erreur lors du calcul de l'empreinte de %s: %v", path, err)
+		return ""
+	}
+
+	return fingerprint
+}
+
+// reuseFingerprintLinks ajoute à un upload les liens actifs de l'upload terminé qui a la même
+// empreinte, pour que seuls les hébergeurs sans miroir reçoivent le fichier. Avec FullHash,
+// le SHA-256 complet du fichier de l'upload est calculé et doit aussi correspondre quand les
+// deux uploads l'ont. S'il ne peut pas être calculé, aucun lien n'est réutilisé. Les liens
+// ajoutés sont rattachés à correlationID dans le journal.
+func reuseFingerprintLinks(uploadID int64, correlationID string) {
+	if !cfg.Fingerprints.Enabled || !cfg.Fingerprints.ReuseLinks {
+		return
+	}
+
+	existing, err := db.GetUpload(uploadID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération de l'upload %d: %v", uploadID, err)
+		return
+	}
+	if existing.Fingerprint == "" {
+		return
+	}
+
+	// Le SHA-256 est calculé sur le fichier dont l'empreinte a été prise à la réception
+	if cfg.Fingerprints.FullHash && existing.ContentSHA256 == "" {
+		sum, err := fileSHA256(existing.FilePath)
+		if err != nil {
+			log.Printf("Erreur lors du calcul du SHA-256 de %s, liens non réutilisés: %v", existing.FilePath, err)
+			return
+		}
+		if err := db.UpdateUploadHash(uploadID, sum); err != nil {
+			log.Printf("Erreur lors de l'enregistrement du SHA-256 de l'upload %d: %v", uploadID, err)
+		}
+		existing.ContentSHA256 = sum
+	}
+
+	match, err := db.FindUploadByFingerprint(existing.Fingerprint, uploadID)
+	if err != nil {
+		log.Printf("Erreur lors de la recherche d'un fichier identique: %v", err)
+		return
+	}
+	if match == nil {
+		return
+	}
+
+	if existing.ContentSHA256 != "" && match.ContentSHA256 != "" && existing.ContentSHA256 != match.ContentSHA256 {
+		log.Printf("Empreinte identique à l'upload %d mais SHA-256 différent, liens non réutilisés", match.ID)
+		return
+	}
+
+	links, err := db.GetUploadLinks(uploadID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des liens existants: %v", err)
+		return
+	}
+	mirrors := make(map[string]bool)
+	for _, link := range links {
+		mirrors[link.Hoster] = true
+	}
+
+	matchLinks, err := db.GetUploadLinks(match.ID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des liens de l'upload %d: %v", match.ID, err)
+		return
+	}
+
+	store := db.WithActor(storage.ActorWorker, correlationID)
+	reused := 0
+	for _, link := range matchLinks {
+		if link.Status != storage.LinkStatusActive || mirrors[link.Hoster] {
+			continue
+		}
+
+		err := store.AddUploadLink(uploadID, storage.HostedLink{
+			Hoster:   link.Hoster,
+			FileCode: link.FileCode,
+			URL:      link.URL,
+			Embed:    link.Embed,
+		})
+		if err != nil {
+			log.Printf("Erreur lors de la réutilisation du lien %s/%s: %v", link.Hoster, link.FileCode, err)
+			continue
+		}
+
+		mirrors[link.Hoster] = true
+		reused++
+	}
+
+	if reused > 0 {
+		log.Printf("Fichier identique à %s (upload %d): %d liens réutilisés pour l'upload %d",
+			match.Title, match.ID, reused, uploadID)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"media-upload-system/storage"
+	"media-upload-system/upload"
+)
+
+// folderMutex évite que deux uploads simultanés créent le même dossier chez un hébergeur
+var folderMutex sync.Mutex
+
+// folderNames retourne les noms des dossiers d'un upload, du plus haut au plus bas:
+// le film, ou la série puis la saison
+func folderNames(existing *storage.Upload) []string {
+	replacer := strings.NewReplacer(
+		"{title}", existing.Title,
+		"{tmdb_id}", strconv.Itoa(existing.TmdbID),
+	)
+
+	if existing.Type != storage.TypeSeries {
+		return []string{folderName(replacer.Replace(cfg.Folders.MovieTemplate))}
+	}
+
+	names := []string{folderName(replacer.Replace(cfg.Folders.SeriesTemplate))}
+	if existing.Season != nil {
+		season := strings.NewReplacer("{season}", strconv.Itoa(*existing.Season))
+		names = append(names, folderName(season.Replace(replacer.Replace(cfg.Folders.SeasonTemplate))))
+	}
+
+	return names
+}
+
+// folderName nettoie un nom de dossier: le / sépare les niveaux dans le cache des dossiers
+func folderName(name string) string {
+	return strings.TrimSpace(strings.ReplaceAll(name, "/", "-"))
+}
+
+// hosterFolder retourne l'ID du dossier le plus bas de names chez l'hébergeur, en créant
+// les dossiers manquants. Les IDs sont mis en cache dans la base.
+func hosterFolder(manager upload.FolderManager, names []string) (string, string, error) {
+	folderMutex.Lock()
+	defer folderMutex.Unlock()
+
+	parentID := ""
+	path := ""
+	for _, name := range names {
+		if path == "" {
+			path = name
+		} else {
+			path += "/" + name
+		}
+
+		folderID, err := db.GetHosterFolder(manager.Name(), path)
+		if err != nil {
+			return "", path, err
+		}
+
+		if folderID == "" {
+			folderID, err = manager.EnsureFolder(name, parentID)
+			if err != nil {
+				return "", path, fmt.Errorf("erreur lors de la création du dossier %s: %w", path, err)
+			}
+
+			if err := db.SaveHosterFolder(manager.Name(), path, folderID); err != nil {
+				return "", path, err
+			}
+
+			log.Printf("Dossier %s prêt sur %s (ID: %s)", path, manager.Name(), folderID)
+		}
+
+		parentID = folderID
+	}
+
+	return parentID, path, nil
+}
+
+// prepareUploadFolders prépare chez chaque hébergeur qui le permet le dossier du film ou de
+// la saison, pour que le fichier y soit uploadé directement. Un échec n'empêche pas l'upload:
+// le fichier est alors envoyé à la racine du compte.
+func prepareUploadFolders(uploadID int64, uploaders []upload.Uploader) {
+	if !cfg.Folders.Enabled {
+		return
+	}
+
+	var existing *storage.Upload
+	for _, uploader := range uploaders {
+		folderUploader, ok := uploader.(upload.FolderUploader)
+		if !ok {
+			continue
+		}
+
+		if existing == nil {
+			var err error
+			existing, err = db.GetUpload(uploadID)
+			if err != nil {
+				log.Printf("Erreur lors de la récupération de l'upload %d: %v", uploadID, err)
+				return
+			}
+		}
+
+		folderID, path, err := hosterFolder(folderUploader, folderNames(existing))
+		if err != nil {
+			log.Printf("Erreur lors de la préparation du dossier sur %s, upload à la racine: %v", uploader.Name(), err)
+			continue
+		}
+
+		folderUploader.SetUploadFolder(folderID)
+		log.Printf("Upload vers %s dans le dossier %s", uploader.Name(), path)
+	}
+}
+
+// forgetMissingFolder invalide le cache des dossiers de l'upload chez l'hébergeur quand
+// celui-ci signale un dossier inexistant: le dossier a été supprimé du compte et sera recréé
+// à la prochaine tentative. Les autres erreurs, passagères, conservent le cache.
+func forgetMissingFolder(uploadID int64, hoster string, err error) {
+	if !cfg.Folders.Enabled || !errors.Is(err, upload.ErrFolderNotFound) {
+		return
+	}
+
+	existing, getErr := db.GetUpload(uploadID)
+	if getErr != nil {
+		log.Printf("Erreur lors de la récupération de l'upload %d: %v", uploadID, getErr)
+		return
+	}
+
+	names := folderNames(existing)
+	if err := db.DeleteHosterFolders(hoster, names[0]); err != nil {
+		log.Printf("Erreur lors de l'invalidation du cache des dossiers: %v", err)
+		return
+	}
+
+	log.Printf("Dossier %s introuvable sur %s, il sera recréé au prochain upload", names[0], hoster)
+}
+
+// placeInFolder range un fichier uploadé dans le dossier de son film ou de sa saison, chez
+// les hébergeurs qui ne permettent pas d'uploader directement dans un dossier.
+// Un échec n'empêche pas l'upload: le fichier reste simplement à la racine du compte.
+func placeInFolder(uploadID int64, result *upload.UploadResult) {
+	if !cfg.Folders.Enabled {
+		return
+	}
+
+	uploader := uploaderByName(result.Hoster)
+	if _, ok := uploader.(upload.FolderUploader); ok {
+		return
+	}
+	manager, ok := uploader.(upload.FolderManager)
+	if !ok {
+		return
+	}
+	mover, ok := uploader.(upload.Mover)
+	if !ok {
+		return
+	}
+
+	existing, err := db.GetUpload(uploadID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération de l'upload %d: %v", uploadID, err)
+		return
+	}
+
+	names := folderNames(existing)
+	folderID, path, err := hosterFolder(manager, names)
+	if err != nil {
+		log.Printf("Erreur lors de la préparation du dossier sur %s: %v", result.Hoster, err)
+		return
+	}
+
+	if err := mover.MoveFile(result.FileCode, folderID); err != nil {
+		log.Printf("Erreur lors du rangement du fichier %s dans %s sur %s: %v", result.FileCode, path, result.Hoster, err)
+		forgetMissingFolder(uploadID, result.Hoster, err)
+		return
+	}
+
+	log.Printf("Fichier %s rangé dans %s sur %s", result.FileCode, path, result.Hoster)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"media-upload-system/storage"
+	"media-upload-system/upload"
+	"media-upload-system/upload/hostertest"
+)
+
+// setupFolders prépare un upload de film envoyé directement au faux serveur MixDrop, avec
+// le rangement par dossier activé
+func setupFolders(t *testing.T, server *hostertest.MixDropServer) (int64, string) {
+	t.Helper()
+
+	setupHosters(t, server, t.TempDir())
+	cfg.Folders.Enabled = true
+	cfg.Folders.MovieTemplate = "{title} ({tmdb_id})"
+
+	filePath := filepath.Join(t.TempDir(), "film.mkv")
+	if err := os.WriteFile(filePath, make([]byte, 4096), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	uploadID, err := db.AddUpload(&storage.Upload{TmdbID: 10, Title: "Film", Type: storage.TypeMovie, FilePath: filePath})
+	if err != nil {
+		t.Fatalf("AddUpload: %v", err)
+	}
+	return uploadID, filePath
+}
+
+// TestUploadIntoFolder vérifie que le fichier est uploadé dans le dossier du film, sans
+// être déplacé après l'upload
+func TestUploadIntoFolder(t *testing.T) {
+	server := hostertest.NewMixDrop()
+	defer server.Close()
+
+	uploadID, filePath := setupFolders(t, server)
+
+	results, _ := uploadToHosters(uploadID, filePath, "Film", "le film", nil, func(*upload.UploadResult) {})
+	if len(results) != 1 {
+		t.Fatalf("uploadToHosters: %+v", results)
+	}
+
+	folderID, err := db.GetHosterFolder("mixdrop", "Film (10)")
+	if err != nil || folderID == "" {
+		t.Fatalf("GetHosterFolder: %q, %v", folderID, err)
+	}
+
+	files := server.Files()
+	if len(files) != 1 || files[0].FolderID != folderID {
+		t.Fatalf("fichier hors du dossier %s: %+v", folderID, files)
+	}
+	if server.Requests("/filemove") != 0 {
+		t.Fatalf("fichier déplacé après l'upload: %d requêtes", server.Requests("/filemove"))
+	}
+}
+
+// TestFolderCacheMissingFolder vérifie qu'un dossier supprimé chez l'hébergeur est oublié,
+// puis recréé à la tentative suivante
+func TestFolderCacheMissingFolder(t *testing.T) {
+	server := hostertest.NewMixDrop()
+	defer server.Close()
+
+	uploadID, filePath := setupFolders(t, server)
+	if err := db.SaveHosterFolder("mixdrop", "Film (10)", "999"); err != nil {
+		t.Fatalf("SaveHosterFolder: %v", err)
+	}
+
+	results, _ := uploadToHosters(uploadID, filePath, "Film", "le film", nil, func(*upload.UploadResult) {})
+	if len(results) != 0 {
+		t.Fatalf("échec attendu dans un dossier inexistant: %+v", results)
+	}
+	if folderID, err := db.GetHosterFolder("mixdrop", "Film (10)"); err != nil || folderID != "" {
+		t.Fatalf("cache conservé pour un dossier inexistant: %q, %v", folderID, err)
+	}
+
+	results, _ = uploadToHosters(uploadID, filePath, "Film", "le film", nil, func(*upload.UploadResult) {})
+	if len(results) != 1 {
+		t.Fatalf("la tentative suivante a échoué: %+v", results)
+	}
+	if files := server.Files(); len(files) != 1 || files[0].FolderID == "" || files[0].FolderID == "999" {
+		t.Fatalf("fichier hors d'un dossier recréé: %+v", files)
+	}
+}
+
+// TestFolderCacheTransientError vérifie qu'une erreur passagère conserve le cache des dossiers
+func TestFolderCacheTransientError(t *testing.T) {
+	server := hostertest.NewMixDrop()
+	defer server.Close()
+
+	uploadID, filePath := setupFolders(t, server)
+
+	results, _ := uploadToHosters(uploadID, filePath, "Film", "le film", nil, func(*upload.UploadResult) {})
+	if len(results) != 1 {
+		t.Fatalf("uploadToHosters: %+v", results)
+	}
+	folderID, _ := db.GetHosterFolder("mixdrop", "Film (10)")
+
+	server.Fail("/upload", hostertest.FailServerError, 1)
+	results, _ = uploadToHosters(uploadID, filePath, "Film", "le film", nil, func(*upload.UploadResult) {})
+	if len(results) != 0 {
+		t.Fatalf("échec attendu: %+v", results)
+	}
+	if cached, err := db.GetHosterFolder("mixdrop", "Film (10)"); err != nil || cached != folderID {
+		t.Fatalf("cache modifié par une erreur passagère: %q au lieu de %q, %v", cached, folderID, err)
+	}
+}
@@ -2,7 +2,10 @@ module media-upload-system
 
 go 1.22
 
-require github.com/mattn/go-sqlite3 v1.14.22
+require (
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.22
+)
 
 require (
 	github.com/fsnotify/fsnotify v1.9.0 // indirect
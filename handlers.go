@@ -0,0 +1,427 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"media-upload-system/storage"
+)
+
+// writeJSON envoie une réponse JSON
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Erreur lors de l'encodage de la réponse JSON: %v", err)
+	}
+}
+
+// queryInt lit un paramètre entier de la requête, avec une valeur par défaut
+func queryInt(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// uploadDetails est un upload avec ses liens hébergés
+type uploadDetails struct {
+	*storage.Upload
+	Links []storage.HostedLink `json:"links"`
+}
+
+// uploadsHandler retourne les uploads et les informations de leurs fichiers.
+// GET /api/uploads?id=42 pour un upload et ses liens, ou ?tmdb_id=603&type=movie pour un média.
+func uploadsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if value := r.URL.Query().Get("id"); value != "" {
+		uploadID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "id invalide", http.StatusBadRequest)
+			return
+		}
+
+		upload, err := db.GetUpload(uploadID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Upload introuvable", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Erreur lors de la récupération de l'upload %d: %v", uploadID, err)
+			http.Error(w, "Erreur interne", http.StatusInternalServerError)
+			return
+		}
+
+		links, err := db.GetUploadLinks(uploadID)
+		if err != nil {
+			log.Printf("Erreur lors de la récupération des liens de l'upload %d: %v", uploadID, err)
+			http.Error(w, "Erreur interne", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, uploadDetails{Upload: upload, Links: links})
+		return
+	}
+
+	tmdbID, err := queryInt(r, "tmdb_id", 0)
+	if err != nil || tmdbID <= 0 {
+		http.Error(w, "id ou tmdb_id requis", http.StatusBadRequest)
+		return
+	}
+
+	mediaType := r.URL.Query().Get("type")
+	if mediaType == "" {
+		mediaType = storage.TypeMovie
+	}
+
+	uploads, err := db.GetUploadsByTmdbID(tmdbID, mediaType)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des uploads: %v", err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, uploads)
+}
+
+// seasonDetails est une saison avec ses épisodes connus
+type seasonDetails struct {
+	storage.Season
+	Complete    bool              `json:"complete"`
+	EpisodeList []storage.Episode `json:"episode_list"`
+}
+
+// mediaDetails est un film ou une série avec ses saisons
+type mediaDetails struct {
+	*storage.Media
+	Seasons []seasonDetails `json:"seasons,omitempty"`
+}
+
+// mediaHandler retourne un film ou une série, et pour une série ses saisons, leur complétude
+// et leurs épisodes. GET /api/media?id=12, ou ?tmdb_id=1399&type=series
+func mediaHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var media *storage.Media
+	var err error
+	if value := r.URL.Query().Get("id"); value != "" {
+		mediaID, parseErr := strconv.ParseInt(value, 10, 64)
+		if parseErr != nil {
+			http.Error(w, "id invalide", http.StatusBadRequest)
+			return
+		}
+		media, err = db.GetMedia(mediaID)
+		if err == sql.ErrNoRows {
+			media, err = nil, nil
+		}
+	} else {
+		tmdbID, parseErr := queryInt(r, "tmdb_id", 0)
+		if parseErr != nil || tmdbID <= 0 {
+			http.Error(w, "id ou tmdb_id requis", http.StatusBadRequest)
+			return
+		}
+
+		mediaType := r.URL.Query().Get("type")
+		if mediaType == "" {
+			mediaType = storage.TypeMovie
+		}
+		media, err = db.GetMediaByTmdbID(mediaType, tmdbID)
+	}
+	if err != nil {
+		log.Printf("Erreur lors de la récupération du média: %v", err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		return
+	}
+	if media == nil {
+		http.Error(w, "Média introuvable", http.StatusNotFound)
+		return
+	}
+
+	details := mediaDetails{Media: media}
+	if media.Type == storage.TypeSeries {
+		seasons, err := db.GetSeasons(media.ID)
+		if err != nil {
+			log.Printf("Erreur lors de la récupération des saisons de %s: %v", media.Title, err)
+			http.Error(w, "Erreur interne", http.StatusInternalServerError)
+			return
+		}
+
+		for _, season := range seasons {
+			episodes, err := db.GetEpisodes(media.ID, season.Number)
+			if err != nil {
+				log.Printf("Erreur lors de la récupération des épisodes de %s: %v", media.Title, err)
+				http.Error(w, "Erreur interne", http.StatusInternalServerError)
+				return
+			}
+			details.Seasons = append(details.Seasons, seasonDetails{
+				Season:      season,
+				Complete:    season.Complete(),
+				EpisodeList: episodes,
+			})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, details)
+}
+
+// eventsHandler retourne l'historique des changements d'un upload, d'un lien ou d'un média.
+// GET /api/events?upload_id=42, ?link_id=7 ou ?tmdb_id=603, avec &limit=100
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var filter storage.EventFilter
+	var err error
+	query := r.URL.Query()
+	if value := query.Get("upload_id"); value != "" {
+		if filter.UploadID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			http.Error(w, "upload_id invalide", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("link_id"); value != "" {
+		if filter.LinkID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			http.Error(w, "link_id invalide", http.StatusBadRequest)
+			return
+		}
+	}
+	if filter.TmdbID, err = queryInt(r, "tmdb_id", 0); err != nil {
+		http.Error(w, "tmdb_id invalide", http.StatusBadRequest)
+		return
+	}
+	if filter.UploadID == 0 && filter.LinkID == 0 && filter.TmdbID == 0 {
+		http.Error(w, "upload_id, link_id ou tmdb_id requis", http.StatusBadRequest)
+		return
+	}
+
+	filter.Limit, err = queryInt(r, "limit", 100)
+	if err != nil || filter.Limit <= 0 {
+		http.Error(w, "limit invalide", http.StatusBadRequest)
+		return
+	}
+
+	events, err := db.GetEvents(filter)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des événements: %v", err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, events)
+}
+
+// attemptsHandler retourne l'historique des tentatives d'upload.
+// GET /api/attempts?upload_id=42 pour un upload, ou ?hoster=mixdrop&limit=100 pour les plus récentes.
+func attemptsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if value := r.URL.Query().Get("upload_id"); value != "" {
+		uploadID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "upload_id invalide", http.StatusBadRequest)
+			return
+		}
+
+		attempts, err := db.GetUploadAttempts(uploadID)
+		if err != nil {
+			log.Printf("Erreur lors de la récupération des tentatives d'upload: %v", err)
+			http.Error(w, "Erreur interne", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, attempts)
+		return
+	}
+
+	limit, err := queryInt(r, "limit", 100)
+	if err != nil || limit <= 0 {
+		http.Error(w, "limit invalide", http.StatusBadRequest)
+		return
+	}
+
+	attempts, err := db.GetRecentUploadAttempts(r.URL.Query().Get("hoster"), limit)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des tentatives d'upload: %v", err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, attempts)
+}
+
+// attemptStatsHandler retourne la fiabilité et la vitesse de chaque hébergeur.
+// GET /api/attempts/stats?days=30
+func attemptStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	days, err := queryInt(r, "days", 30)
+	if err != nil || days <= 0 {
+		http.Error(w, "days invalide", http.StatusBadRequest)
+		return
+	}
+
+	stats, err := db.GetHosterAttemptStats(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Printf("Erreur lors du calcul des statistiques d'upload: %v", err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// hosterUsageHandler retourne le volume envoyé à chaque hébergeur, jour par jour.
+// GET /api/hosters/usage?days=7
+func hosterUsageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	days, err := queryInt(r, "days", 7)
+	if err != nil || days <= 0 {
+		http.Error(w, "days invalide", http.StatusBadRequest)
+		return
+	}
+
+	usages, err := db.GetHosterUsageSince(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Printf("Erreur lors de la récupération du volume envoyé: %v", err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, usages)
+}
+
+// breakersHandler retourne l'état des disjoncteurs des hébergeurs.
+// GET /api/hosters/breakers
+func breakersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, breakers.States())
+}
+
+// adminRequest représente le corps des actions d'administration sur un upload
+type adminRequest struct {
+	UploadID int64             `json:"upload_id"`
+	Title    string            `json:"title,omitempty"`
+	Folders  map[string]string `json:"folders,omitempty"`
+}
+
+// decodeAdminRequest vérifie le jeton d'administration et décode le corps d'une action POST
+func decodeAdminRequest(w http.ResponseWriter, r *http.Request) (*adminRequest, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	if cfg.Server.AdminToken == "" {
+		http.Error(w, "Actions d'administration désactivées", http.StatusForbidden)
+		return nil, false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Server.AdminToken)) != 1 {
+		http.Error(w, "Non autorisé", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	var request adminRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.UploadID == 0 {
+		http.Error(w, "JSON invalide", http.StatusBadRequest)
+		return nil, false
+	}
+
+	return &request, true
+}
+
+// adminStore retourne le stockage qui attribue les changements d'une action d'administration
+// à l'API d'administration
+func adminStore(r *http.Request) storage.Store {
+	return db.WithActor(storage.ActorAdmin, requestCorrelationID(r))
+}
+
+// writeActionResult répond avec le résultat d'une action d'administration
+func writeActionResult(w http.ResponseWriter, action string, err error) {
+	if err != nil {
+		log.Printf("Erreur lors de l'action %s: %v", action, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": "success",
+	})
+}
+
+// adminDeleteHandler supprime les fichiers d'un upload chez les hébergeurs.
+// POST /api/admin/uploads/delete {"upload_id": 42}
+func adminDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	request, ok := decodeAdminRequest(w, r)
+	if !ok {
+		return
+	}
+
+	writeActionResult(w, "delete", deleteUploadFiles(adminStore(r), request.UploadID))
+}
+
+// adminRenameHandler renomme les fichiers d'un upload chez les hébergeurs.
+// POST /api/admin/uploads/rename {"upload_id": 42, "title": "Nouveau titre"}
+func adminRenameHandler(w http.ResponseWriter, r *http.Request) {
+	request, ok := decodeAdminRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if request.Title == "" {
+		http.Error(w, "title manquant", http.StatusBadRequest)
+		return
+	}
+
+	writeActionResult(w, "rename", renameUploadFiles(adminStore(r), request.UploadID, request.Title))
+}
+
+// adminMoveHandler range les fichiers d'un upload dans un dossier de chaque hébergeur.
+// POST /api/admin/uploads/move {"upload_id": 42, "folders": {"netu": "123"}}
+func adminMoveHandler(w http.ResponseWriter, r *http.Request) {
+	request, ok := decodeAdminRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if len(request.Folders) == 0 {
+		http.Error(w, "folders manquant", http.StatusBadRequest)
+		return
+	}
+
+	writeActionResult(w, "move", moveUploadFiles(request.UploadID, request.Folders))
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"log"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"media-upload-system/storage"
+	"media-upload-system/upload"
+)
+
+// newUploaders crée les uploaders configurés, dans l'ordre de priorité.
+// Le CDN tus et le stockage S3 passent en premier: leur URL publique sert de source aux remote uploads.
+func newUploaders() []upload.Uploader {
+	return []upload.Uploader{
+		upload.NewTusUploader(
+			cfg.Uploaders.Tus.Endpoint,
+			cfg.Uploaders.Tus.Headers,
+			int64(cfg.Uploaders.Tus.ChunkSizeMB)*1024*1024,
+			cfg.Uploaders.Tus.Checksum,
+			cfg.Uploaders.Tus.PublicURL,
+			cfg.Uploaders.Tus.EmbedURL,
+			cfg.Uploaders.Tus.Enabled,
+		),
+		upload.NewS3Uploader(
+			cfg.Uploaders.S3.Endpoint,
+			cfg.Uploaders.S3.Region,
+			cfg.Uploaders.S3.Bucket,
+			cfg.Uploaders.S3.AccessKey,
+			cfg.Uploaders.S3.SecretKey,
+			cfg.Uploaders.S3.Prefix,
+			int64(cfg.Uploaders.S3.PartSizeMB)*1024*1024,
+			cfg.Uploaders.S3.PublicURL,
+			cfg.Uploaders.S3.Enabled,
+		),
+		upload.NewMixDropUploader(
+			cfg.Uploaders.MixDrop.Email,
+			cfg.Uploaders.MixDrop.ApiKey,
+			cfg.Uploaders.MixDrop.Enabled,
+		).SetBaseURLs(
+			cfg.Uploaders.MixDrop.UploadURL,
+			cfg.Uploaders.MixDrop.APIURL,
+			cfg.Uploaders.MixDrop.PublicURL,
+		),
+		upload.NewNetuUploader(
+			cfg.Uploaders.Netu.ApiKey,
+			cfg.Uploaders.Netu.Enabled,
+		).SetBaseURLs(
+			cfg.Uploaders.Netu.APIURL,
+			cfg.Uploaders.Netu.PlayerURL,
+		),
+	}
+}
+
+// uploaderByName retourne l'uploader d'un hébergeur, activé ou non, ou nil s'il n'existe pas
+func uploaderByName(name string) upload.Uploader {
+	for _, uploader := range newUploaders() {
+		if uploader.Name() == name {
+			return uploader
+		}
+	}
+	return nil
+}
+
+// newHosterLimiter crée le limiteur d'uploads simultanés par hébergeur
+func newHosterLimiter() *upload.HosterLimiter {
+	return upload.NewHosterLimiter(map[string]int{
+		"tus":     cfg.Uploaders.Tus.MaxConcurrent,
+		"s3":      cfg.Uploaders.S3.MaxConcurrent,
+		"mixdrop": cfg.Uploaders.MixDrop.MaxConcurrent,
+		"netu":    cfg.Uploaders.Netu.MaxConcurrent,
+	})
+}
+
+// uploadToHosters upload un fichier vers chaque hébergeur activé absent de skip et retourne
+// les résultats réussis ainsi que le nombre d'hébergeurs tentés, après quotas et disjoncteurs.
+// onResult est appelé pour chaque upload réussi, dès que l'hébergeur a terminé.
+func uploadToHosters(uploadID int64, filePath, title, label string, skip map[string]bool, onResult func(*upload.UploadResult)) ([]*upload.UploadResult, int) {
+	var uploaders []upload.Uploader
+	for _, uploader := range newUploaders() {
+		if !uploader.IsEnabled() {
+			log.Printf("Uploader %s désactivé, ignoré", uploader.Name())
+			continue
+		}
+		if skip[uploader.Name()] {
+			log.Printf("Miroir %s déjà présent, ignoré", uploader.Name())
+			continue
+		}
+		uploaders = append(uploaders, uploader)
+	}
+
+	// Écarter ou rediriger les hébergeurs dont un quota serait dépassé
+	uploaders = applyQuotas(uploaders, filePath, skip)
+
+	// Écarter les hébergeurs dont le disjoncteur est ouvert
+	uploaders = allowedByBreakers(uploaders)
+	attempted := len(uploaders)
+
+	// Les hébergeurs qui exposent une URL de téléchargement passent en premier, pour que
+	// les suivants puissent importer le fichier depuis cette URL
+	uploaders = sourceProvidersFirst(uploaders)
+
+	// Les fichiers sont uploadés directement dans le dossier du média quand c'est possible
+	prepareUploadFolders(uploadID, uploaders)
+
+	// URL publique du fichier configurée, ou fournie ensuite par un upload précédent
+	sourceURL := remoteSourceURL(filePath)
+	remote := remoteSourceExpected(uploaders, sourceURL)
+
+	var results []*upload.UploadResult
+
+	// En mode parallèle, les hébergeurs compatibles reçoivent le fichier en même temps,
+	// les autres sont traités ensuite un par un
+	if cfg.Uploaders.Parallel.Enabled {
+		results, uploaders = uploadInParallel(uploadID, filePath, title, label, uploaders, remote, onResult)
+	}
+
+	for _, result := range results {
+		if sourceURL == "" && result.DownloadURL != "" {
+			sourceURL = result.DownloadURL
+		}
+	}
+
+	for _, uploader := range uploaders {
+		log.Printf("Démarrage de l'upload vers %s pour %s %s (ID: %d)",
+			uploader.Name(), label, title, uploadID)
+
+		// Forcer le garbage collection avant chaque upload
+		runtime.GC()
+
+		release := hosterLimiter.Acquire(uploader.Name())
+		result, err := uploadToHoster(uploadID, uploader, filePath, title, sourceURL)
+		release()
+
+		if err != nil {
+			log.Printf("Erreur lors de l'upload vers %s: %v", uploader.Name(), err)
+			continue
+		}
+
+		log.Printf("Upload vers %s terminé avec succès pour %s %s (ID: %d)",
+			uploader.Name(), label, title, uploadID)
+
+		// Libérer la mémoire après un upload réussi
+		freeMemoryAfterUpload()
+
+		results = append(results, result)
+		onResult(result)
+
+		if sourceURL == "" && result.DownloadURL != "" {
+			sourceURL = result.DownloadURL
+		}
+	}
+
+	return results, attempted
+}
+
+// uploadInParallel envoie le fichier simultanément aux hébergeurs qui acceptent un flux
+// et dont la limite de concurrence le permet. Les hébergeurs restants, ceux qui savent
+// reprendre un envoi interrompu et, si remote est vrai, ceux qui savent importer le fichier
+// depuis une URL sont retournés pour être traités séquentiellement.
+func uploadInParallel(uploadID int64, filePath, title, label string, uploaders []upload.Uploader, remote bool, onResult func(*upload.UploadResult)) ([]*upload.UploadResult, []upload.Uploader) {
+	var streams []upload.StreamUploader
+	var remaining []upload.Uploader
+	releases := make(map[string]func())
+
+	for _, uploader := range uploaders {
+		_, resumable := uploader.(upload.ResumableUploader)
+		_, remoteCapable := uploader.(upload.RemoteUploader)
+		streamUploader, ok := uploader.(upload.StreamUploader)
+		if !ok || resumable || (remote && remoteCapable) {
+			remaining = append(remaining, uploader)
+			continue
+		}
+
+		release, ok := hosterLimiter.TryAcquire(uploader.Name())
+		if !ok {
+			log.Printf("Limite d'uploads simultanés atteinte pour %s, upload différé", uploader.Name())
+			remaining = append(remaining, uploader)
+			continue
+		}
+
+		releases[uploader.Name()] = release
+		streams = append(streams, streamUploader)
+	}
+
+	if len(streams) == 0 {
+		return nil, remaining
+	}
+
+	log.Printf("Démarrage de l'upload parallèle vers %d hébergeurs pour %s %s (ID: %d)",
+		len(streams), label, title, uploadID)
+
+	var results []*upload.UploadResult
+	var mutex sync.Mutex
+
+	startedAt := time.Now()
+	err := upload.FanOut(filePath, title, streams, func(hoster string, result *upload.UploadResult, sent int64, err error) {
+		releases[hoster]()
+		recordUploadAttempt(uploadID, hoster, startedAt, sent, err)
+
+		if err != nil {
+			log.Printf("Erreur lors de l'upload vers %s: %v", hoster, err)
+			breakers.Failure(hoster)
+			forgetMissingFolder(uploadID, hoster, err)
+			return
+		}
+
+		breakers.Success(hoster)
+		recordHosterUsage(hoster, filePath)
+
+		log.Printf("Upload vers %s terminé avec succès pour %s %s (ID: %d)",
+			hoster, label, title, uploadID)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		results = append(results, result)
+		onResult(result)
+	})
+	if err != nil {
+		log.Printf("Erreur lors de l'upload parallèle: %v", err)
+	}
+
+	// Libérer la mémoire après les uploads
+	freeMemoryAfterUpload()
+
+	return results, remaining
+}
+
+// uploadToHoster upload le fichier vers un hébergeur et enregistre la tentative dans l'historique
+func uploadToHoster(uploadID int64, uploader upload.Uploader, filePath, title, sourceURL string) (*upload.UploadResult, error) {
+	startedAt := time.Now()
+	counter := &upload.ByteCounter{}
+
+	result, err := transferToHoster(uploadID, uploader, filePath, title, sourceURL, counter)
+	recordUploadAttempt(uploadID, uploader.Name(), startedAt, counter.Sent(), err)
+	if err != nil {
+		breakers.Failure(uploader.Name())
+		forgetMissingFolder(uploadID, uploader.Name(), err)
+	} else {
+		breakers.Success(uploader.Name())
+		recordHosterUsage(uploader.Name(), filePath)
+	}
+
+	return result, err
+}
+
+// transferToHoster utilise le remote upload quand c'est possible, sinon envoie le fichier,
+// par blocs reprenables si l'hébergeur le permet. Les octets envoyés sont comptés dans counter.
+func transferToHoster(uploadID int64, uploader upload.Uploader, filePath, title, sourceURL string, counter *upload.ByteCounter) (*upload.UploadResult, error) {
+	remoteUploader, ok := uploader.(upload.RemoteUploader)
+	if ok && sourceURL != "" && cfg.Uploaders.RemoteUpload.Enabled {
+		result, err := upload.WaitRemoteUpload(
+			remoteUploader,
+			sourceURL,
+			title,
+			time.Duration(cfg.Uploaders.RemoteUpload.PollIntervalSeconds)*time.Second,
+			time.Duration(cfg.Uploaders.RemoteUpload.TimeoutMinutes)*time.Minute,
+		)
+		if err == nil {
+			return result, nil
+		}
+
+		log.Printf("Remote upload vers %s impossible, envoi du fichier: %v", uploader.Name(), err)
+	}
+
+	store := &dbSessionStore{uploadID: uploadID, hoster: uploader.Name()}
+	return upload.Transfer(uploader, filePath, title, store, counter)
+}
+
+// remoteSourceURL retourne l'URL publique du fichier s'il se trouve dans le répertoire
+// SourceRoot exposé à SourceBaseURL, ou une chaîne vide
+func remoteSourceURL(filePath string) string {
+	remote := cfg.Uploaders.RemoteUpload
+	if !remote.Enabled || remote.SourceBaseURL == "" || remote.SourceRoot == "" {
+		return ""
+	}
+
+	relative, err := filepath.Rel(remote.SourceRoot, filePath)
+	if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return ""
+	}
+
+	segments := strings.Split(filepath.ToSlash(relative), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return strings.TrimSuffix(remote.SourceBaseURL, "/") + "/" + strings.Join(segments, "/")
+}
+
+// remoteSourceExpected indique si une URL source sera disponible pour les remote uploads:
+// configurée pour le fichier, ou fournie par un des hébergeurs à uploader
+func remoteSourceExpected(uploaders []upload.Uploader, sourceURL string) bool {
+	if !cfg.Uploaders.RemoteUpload.Enabled {
+		return false
+	}
+	if sourceURL != "" {
+		return true
+	}
+
+	for _, uploader := range uploaders {
+		if provider, ok := uploader.(upload.SourceProvider); ok && provider.ProvidesDownloadURL() {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceProvidersFirst place en tête, sans changer leur ordre, les hébergeurs qui exposent
+// une URL de téléchargement direct
+func sourceProvidersFirst(uploaders []upload.Uploader) []upload.Uploader {
+	var providers, others []upload.Uploader
+	for _, uploader := range uploaders {
+		if provider, ok := uploader.(upload.SourceProvider); ok && provider.ProvidesDownloadURL() {
+			providers = append(providers, uploader)
+		} else {
+			others = append(others, uploader)
+		}
+	}
+	return append(providers, others...)
+}
+
+// allowedByBreakers retire les hébergeurs dont le disjoncteur est ouvert
+func allowedByBreakers(uploaders []upload.Uploader) []upload.Uploader {
+	var allowed []upload.Uploader
+	for _, uploader := range uploaders {
+		if !breakers.Allow(uploader.Name()) {
+			log.Printf("Disjoncteur de %s ouvert, hébergeur ignoré", uploader.Name())
+			continue
+		}
+		allowed = append(allowed, uploader)
+	}
+	return allowed
+}
+
+// recordUploadAttempt enregistre une tentative d'upload dans l'historique, avec le volume
+// compté sur le corps des requêtes de cette tentative
+func recordUploadAttempt(uploadID int64, hoster string, startedAt time.Time, bytesSent int64, uploadErr error) {
+	attempt := &storage.UploadAttempt{
+		UploadID:   uploadID,
+		Hoster:     hoster,
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+		BytesSent:  bytesSent,
+	}
+
+	if uploadErr != nil {
+		attempt.HTTPStatus = upload.StatusCode(uploadErr)
+		attempt.Error = uploadErr.Error()
+	}
+
+	if _, err := db.AddUploadAttempt(attempt); err != nil {
+		log.Printf("Erreur lors de l'enregistrement de la tentative d'upload: %v", err)
+	}
+}
+
+// dbSessionStore persiste les sessions d'upload reprenables d'un hébergeur dans la base
+type dbSessionStore struct {
+	uploadID int64
+	hoster   string
+}
+
+// Load récupère la session enregistrée
+func (s *dbSessionStore) Load() (*upload.UploadSession, error) {
+	session, err := db.GetUploadSession(s.uploadID, s.hoster)
+	if err != nil || session == nil {
+		return nil, err
+	}
+
+	return &upload.UploadSession{
+		SessionID: session.SessionID,
+		FilePath:  session.FilePath,
+		Size:      session.TotalSize,
+		ModTime:   session.FileModTime,
+		Offset:    session.Offset,
+		ChunkSize: session.ChunkSize,
+		Data:      session.Data,
+	}, nil
+}
+
+// Save enregistre la progression de la session
+func (s *dbSessionStore) Save(session *upload.UploadSession) error {
+	return db.SaveUploadSession(&storage.UploadSession{
+		UploadID:    s.uploadID,
+		Hoster:      s.hoster,
+		SessionID:   session.SessionID,
+		FilePath:    session.FilePath,
+		TotalSize:   session.Size,
+		FileModTime: session.ModTime,
+		Offset:      session.Offset,
+		ChunkSize:   session.ChunkSize,
+		Data:        session.Data,
+	})
+}
+
+// Clear supprime la session, une fois l'upload terminé ou devenu invalide
+func (s *dbSessionStore) Clear() error {
+	return db.DeleteUploadSession(s.uploadID, s.hoster)
+}
+
+// saveHostedLink enregistre un lien dès que son upload est terminé.
+// En mode parallèle, le lien est aussi publié immédiatement dans Strapi pour
+// qu'un hébergeur lent ne retarde pas la publication des autres.
+func saveHostedLink(uploadID int64, tmdbID int, ficheTitle string, result *upload.UploadResult, correlationID string) {
+	link := storage.HostedLink{
+		Hoster:   result.Hoster,
+		FileCode: result.FileCode,
+		URL:      result.URL,
+		Embed:    result.Embed,
+	}
+
+	if err := db.WithActor(storage.ActorWorker, correlationID).AddUploadLink(uploadID, link); err != nil {
+		log.Printf("Erreur lors de l'ajout du lien à la base de données: %v", err)
+	}
+
+	placeInFolder(uploadID, result)
+	uploadSubtitles(uploadID, result)
+
+	if !cfg.Uploaders.Parallel.Enabled || !cfg.Strapi.Enabled {
+		return
+	}
+
+	ficheID, err := strapiClient.CreateFiche(ficheTitle, tmdbID)
+	if err != nil {
+		log.Printf("ERREUR lors de la création de la fiche Strapi: %v", err)
+		return
+	}
+
+	embedLinkID, err := publishLink(ficheID, uploadID, result.Embed, result.FileCode)
+	if err != nil {
+		log.Printf("ERREUR lors de l'envoi du lien à Strapi: %v", err)
+		return
+	}
+
+	log.Printf("Lien d'embed %s publié dans Strapi avec l'ID: %s", result.Hoster, embedLinkID)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"media-upload-system/config"
+	"media-upload-system/storage"
+	"media-upload-system/upload"
+	"media-upload-system/upload/hostertest"
+)
+
+// setupHosters prépare la configuration, la base et les limiteurs pour un seul hébergeur
+// MixDrop servi par un faux serveur, avec le remote upload depuis sourceRoot
+func setupHosters(t *testing.T, server *hostertest.MixDropServer, sourceRoot string) {
+	t.Helper()
+
+	cfg = &config.Config{}
+	cfg.Uploaders.MixDrop.Enabled = true
+	cfg.Uploaders.MixDrop.Email = hostertest.MixDropEmail
+	cfg.Uploaders.MixDrop.ApiKey = hostertest.MixDropAPIKey
+	cfg.Uploaders.MixDrop.UploadURL = server.URL + "/upload"
+	cfg.Uploaders.MixDrop.APIURL = server.URL
+	cfg.Uploaders.MixDrop.PublicURL = server.URL
+	cfg.Uploaders.Parallel.Enabled = true
+	cfg.Uploaders.RemoteUpload.Enabled = true
+	cfg.Uploaders.RemoteUpload.PollIntervalSeconds = 1
+	cfg.Uploaders.RemoteUpload.TimeoutMinutes = 1
+	cfg.Uploaders.RemoteUpload.SourceBaseURL = "https://files.example.com/media/"
+	cfg.Uploaders.RemoteUpload.SourceRoot = sourceRoot
+
+	database, err := storage.NewDatabase(filepath.Join(t.TempDir(), "uploads.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := database.MigrateToLatest(); err != nil {
+		t.Fatalf("MigrateToLatest: %v", err)
+	}
+	db = database
+
+	hosterLimiter = newHosterLimiter()
+	breakers = upload.NewBreakers(5, time.Minute)
+}
+
+func TestRemoteSourceURL(t *testing.T) {
+	cfg = &config.Config{}
+	cfg.Uploaders.RemoteUpload.Enabled = true
+	cfg.Uploaders.RemoteUpload.SourceBaseURL = "https://files.example.com/media/"
+	cfg.Uploaders.RemoteUpload.SourceRoot = "/data/media"
+
+	tests := map[string]string{
+		"/data/media/Films/Le Film (2020)/film.mkv": "https://files.example.com/media/Films/Le%20Film%20%282020%29/film.mkv",
+		"/data/mediatheque/film.mkv":                "",
+		"/data/media/../secret.mkv":                 "",
+	}
+	for filePath, want := range tests {
+		if got := remoteSourceURL(filePath); got != want {
+			t.Fatalf("remoteSourceURL(%q) = %q, attendu %q", filePath, got, want)
+		}
+	}
+}
+
+// TestUploadToHostersRemote vérifie qu'un fichier exposé par SourceBaseURL est importé par
+// remote upload, même en mode parallèle, au lieu d'être envoyé
+func TestUploadToHostersRemote(t *testing.T) {
+	server := hostertest.NewMixDrop()
+	defer server.Close()
+
+	sourceRoot := t.TempDir()
+	setupHosters(t, server, sourceRoot)
+
+	filePath := filepath.Join(sourceRoot, "Le Film", "film.mkv")
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filePath, make([]byte, 4096), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	uploadID, err := db.AddUpload(&storage.Upload{TmdbID: 10, Title: "Film", Type: storage.TypeMovie, FilePath: filePath})
+	if err != nil {
+		t.Fatalf("AddUpload: %v", err)
+	}
+
+	results, attempted := uploadToHosters(uploadID, filePath, "Film", "le film", nil, func(*upload.UploadResult) {})
+	if attempted != 1 || len(results) != 1 || results[0].Hoster != "mixdrop" {
+		t.Fatalf("uploadToHosters: %d tentés, résultats %+v", attempted, results)
+	}
+
+	if server.Requests("/remoteupload") != 1 || server.Requests("/upload") != 0 {
+		t.Fatalf("remote upload attendu: %d remote uploads, %d envois directs",
+			server.Requests("/remoteupload"), server.Requests("/upload"))
+	}
+
+	files := server.Files()
+	if len(files) != 1 || files[0].Name != "https://files.example.com/media/Le%20Film/film.mkv" || files[0].Code != results[0].FileCode {
+		t.Fatalf("fichier importé inattendu: %+v", files)
+	}
+}
+
+// TestUploadToHostersDirect vérifie qu'un fichier hors de SourceRoot est envoyé directement
+func TestUploadToHostersDirect(t *testing.T) {
+	server := hostertest.NewMixDrop()
+	defer server.Close()
+
+	setupHosters(t, server, t.TempDir())
+
+	filePath := filepath.Join(t.TempDir(), "film.mkv")
+	if err := os.WriteFile(filePath, make([]byte, 4096), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	uploadID, err := db.AddUpload(&storage.Upload{TmdbID: 10, Title: "Film", Type: storage.TypeMovie, FilePath: filePath})
+	if err != nil {
+		t.Fatalf("AddUpload: %v", err)
+	}
+
+	results, _ := uploadToHosters(uploadID, filePath, "Film", "le film", nil, func(*upload.UploadResult) {})
+	if len(results) != 1 || server.Requests("/remoteupload") != 0 || server.Requests("/upload") != 1 {
+		t.Fatalf("envoi direct attendu: résultats %+v, %d remote uploads, %d envois directs",
+			results, server.Requests("/remoteupload"), server.Requests("/upload"))
+	}
+}
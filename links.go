@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"media-upload-system/api"
+	"media-upload-system/storage"
+	"media-upload-system/upload"
+	"media-upload-system/worker"
+)
+
+// newLinkChecker crée le vérificateur de liens avec l'API de vérification de chaque hébergeur
+func newLinkChecker() *worker.LinkChecker {
+	checkers := make(map[string]upload.FileChecker)
+	for _, uploader := range newUploaders() {
+		if checker, ok := uploader.(upload.FileChecker); ok {
+			checkers[uploader.Name()] = checker
+		}
+	}
+
+	linkChecker := worker.NewLinkChecker(
+		db.WithActor(storage.ActorScheduler, ""),
+		checkers,
+		time.Duration(cfg.LinkChecker.IntervalMinutes)*time.Minute,
+		time.Duration(cfg.LinkChecker.RecheckHours)*time.Hour,
+		cfg.LinkChecker.FailureThreshold,
+		cfg.LinkChecker.BatchSize,
+	)
+	linkChecker.OnDead(handleDeadLink)
+	linkChecker.OnStillDead(retryDeadLink)
+
+	return linkChecker
+}
+
+// handleDeadLink signale un lien devenu indisponible et planifie son réupload
+func handleDeadLink(link storage.HostedLink) {
+	title := fmt.Sprintf("upload %d", link.UploadID)
+	existing, err := db.GetUpload(link.UploadID)
+	if err == nil {
+		title = existing.Title
+		if existing.Season != nil && existing.Episode != nil {
+			title = fmt.Sprintf("%s S%02dE%02d", existing.Title, *existing.Season, *existing.Episode)
+		}
+	}
+
+	log.Printf("Le lien %s de %s est mort: %s", link.Hoster, title, link.URL)
+
+	fields := []api.DiscordEmbedField{
+		{Name: "Hébergeur", Value: link.Hoster, Inline: true},
+		{Name: "Code", Value: link.FileCode, Inline: true},
+		{Name: "Lien", Value: link.URL},
+	}
+	if err := discordClient.NotifyAlert(fmt.Sprintf("💀 Lien mort: %s", title), "", fields); err != nil {
+		log.Printf("Erreur lors de la notification à Discord: %v", err)
+	}
+
+	if err == nil && cfg.LinkChecker.Reupload {
+		scheduleLinkReupload(existing, link)
+	}
+}
+
+// retryDeadLink relance le réupload d'un lien mort qui n'a pas été remplacé, par exemple
+// parce que la limite quotidienne était atteinte quand il est mort
+func retryDeadLink(link storage.HostedLink) {
+	if !cfg.LinkChecker.Reupload {
+		return
+	}
+
+	pending, err := db.HasQueuedLinkTask("link_reupload", link.ID)
+	if err != nil {
+		log.Printf("Erreur lors de la recherche des réuploads en attente: %v", err)
+		return
+	}
+	if pending {
+		return
+	}
+
+	existing, err := db.GetUpload(link.UploadID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération de l'upload %d: %v", link.UploadID, err)
+		return
+	}
+
+	scheduleLinkReupload(existing, link)
+}
+
+// scheduleLinkReupload ajoute un réupload du lien à la queue si le fichier source existe
+// encore et que la limite quotidienne de réuploads n'est pas atteinte. Un lien ignoré reste
+// mort et est reconsidéré aux vérifications suivantes.
+func scheduleLinkReupload(existing *storage.Upload, link storage.HostedLink) {
+	if _, err := os.Stat(existing.FilePath); err != nil {
+		log.Printf("Fichier source introuvable pour le réupload du lien %d: %v", link.ID, err)
+		return
+	}
+
+	count, err := db.CountLinkReuploadsSince(existing.ID, link.Hoster, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		log.Printf("Erreur lors du comptage des réuploads: %v", err)
+		return
+	}
+
+	if count >= cfg.LinkChecker.MaxReuploadsPerDay {
+		log.Printf("Limite de %d réuploads par jour atteinte pour %s sur %s, réupload ignoré",
+			cfg.LinkChecker.MaxReuploadsPerDay, existing.Title, link.Hoster)
+		return
+	}
+
+	// Chaque réupload est suivi dans le journal sous son propre identifiant
+	payload := storage.TaskPayload{
+		UploadID:      existing.ID,
+		TmdbID:        existing.TmdbID,
+		Title:         existing.Title,
+		FilePath:      existing.FilePath,
+		Season:        existing.Season,
+		Episode:       existing.Episode,
+		Hoster:        link.Hoster,
+		LinkID:        link.ID,
+		CorrelationID: newCorrelationID(),
+	}
+
+	taskID, err := queueManager.AddTask("link_reupload", payload, 3)
+	if err != nil {
+		log.Printf("Erreur lors de l'ajout du réupload à la queue: %v", err)
+		return
+	}
+
+	if err := db.AddLinkReupload(existing.ID, link.Hoster, link.ID); err != nil {
+		log.Printf("Erreur lors de l'enregistrement du réupload: %v", err)
+	}
+
+	log.Printf("Réupload du lien %s de %s ajouté à la queue (tâche %d)", link.Hoster, existing.Title, taskID)
+}
+
+// handleLinkReuploadTask renvoie le fichier d'un lien mort à son hébergeur et remplace
+// l'ancien lien dans la base de données et dans Strapi
+func handleLinkReuploadTask(payloadBytes []byte) error {
+	var payload storage.TaskPayload
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		return fmt.Errorf("erreur lors du décodage du payload: %w", err)
+	}
+
+	oldLink, err := db.GetHostedLink(payload.LinkID)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la récupération du lien %d: %w", payload.LinkID, err)
+	}
+
+	if oldLink.Status != storage.LinkStatusDead {
+		log.Printf("Le lien %d n'est plus marqué mort, réupload annulé", oldLink.ID)
+		return nil
+	}
+
+	uploader := uploaderByName(payload.Hoster)
+	if uploader == nil || !uploader.IsEnabled() {
+		return fmt.Errorf("uploader %s introuvable ou désactivé", payload.Hoster)
+	}
+
+	if !breakers.Allow(uploader.Name()) {
+		return fmt.Errorf("disjoncteur de %s ouvert, réupload reporté", uploader.Name())
+	}
+
+	if info, err := os.Stat(payload.FilePath); err == nil {
+		if exceeded, reason := quotaExceeded(uploader, info.Size()); exceeded {
+			notifyQuotaExceeded(uploader.Name(), reason, nil)
+			return fmt.Errorf("quota de %s atteint: %s", uploader.Name(), reason)
+		}
+	}
+
+	// Même titre chez l'hébergeur que lors de l'upload initial
+	uploadTitle := payload.Title
+	if payload.Season != nil && payload.Episode != nil {
+		uploadTitle = fmt.Sprintf("%s S%02dE%02d", payload.Title, *payload.Season, *payload.Episode)
+	}
+
+	log.Printf("Réupload de %s vers %s (lien %d)", uploadTitle, uploader.Name(), oldLink.ID)
+	prepareUploadFolders(payload.UploadID, []upload.Uploader{uploader})
+
+	release := hosterLimiter.Acquire(uploader.Name())
+	result, err := uploadToHoster(payload.UploadID, uploader, payload.FilePath, uploadTitle, "")
+	release()
+
+	if err != nil {
+		return fmt.Errorf("erreur lors du réupload vers %s: %w", uploader.Name(), err)
+	}
+
+	freeMemoryAfterUpload()
+
+	newLink := storage.HostedLink{
+		Hoster:   result.Hoster,
+		FileCode: result.FileCode,
+		URL:      result.URL,
+		Embed:    result.Embed,
+	}
+	if err := db.WithActor(storage.ActorWorker, payload.CorrelationID).ReplaceUploadLink(oldLink.ID, newLink); err != nil {
+		return err
+	}
+
+	log.Printf("Lien %s de %s remplacé: %s", uploader.Name(), uploadTitle, result.URL)
+
+	placeInFolder(oldLink.UploadID, result)
+	uploadSubtitles(oldLink.UploadID, result)
+
+	if cfg.Strapi.Enabled {
+		ficheID, err := strapiClient.CreateFiche(payload.Title, payload.TmdbID)
+		if err != nil {
+			log.Printf("ERREUR lors de la récupération de la fiche Strapi: %v", err)
+		} else if linkID, err := strapiClient.ReplaceLink(ficheID, oldLink.Embed, result.Embed, linkMedia(oldLink.UploadID), linkEpisode(oldLink.UploadID)); err != nil {
+			log.Printf("ERREUR lors du remplacement du lien dans Strapi: %v", err)
+		} else {
+			log.Printf("Lien d'embed %s remplacé dans Strapi (ID: %s)", result.Hoster, linkID)
+
+			if subtitles := linkSubtitles(oldLink.UploadID, result.FileCode); len(subtitles) > 0 {
+				if err := strapiClient.UpdateLinkSubtitles(ficheID, result.Embed, subtitles); err != nil {
+					log.Printf("ERREUR lors de la mise à jour des sous-titres dans Strapi: %v", err)
+				}
+			}
+
+			// Le nouveau lien reprend la place de sa version parmi les liens de la fiche
+			publishVersionOrder(oldLink.UploadID)
+		}
+	}
+
+	fields := []api.DiscordEmbedField{
+		{Name: "Hébergeur", Value: result.Hoster, Inline: true},
+		{Name: "Nouveau lien", Value: result.URL},
+	}
+	if err := discordClient.NotifyAlert(fmt.Sprintf("♻️ Lien réuploadé: %s", uploadTitle), "", fields); err != nil {
+		log.Printf("Erreur lors de la notification à Discord: %v", err)
+	}
+
+	return nil
+}
@@ -27,13 +27,15 @@ import (
 // Variables globales
 var (
 	cfg           *config.Config
-	db            *storage.Database
+	db            storage.Store
 	workerPool    *worker.Pool
 	queueManager  *worker.QueueManager
 	apiClient     *api.Client
 	discordClient *api.DiscordWebhook
 	strapiClient  *strapi.StrapiClient
 	tmdbClient    *tmdb.TMDBClient
+	hosterLimiter *upload.HosterLimiter
+	breakers      *upload.Breakers
 )
 
 // Gestionnaire de webhook
@@ -68,6 +70,8 @@ func webhookHandler(w http.ResponseWriter, r *http.Request) {
 	switch webhook.EventType {
 	case "Download":
 		handleDownloadEvent(&webhook)
+	case "MovieDelete", "MovieFileDelete", "SeriesDelete", "EpisodeFileDelete":
+		handleDeleteEvent(&webhook)
 	default:
 		log.Printf("Type d'événement ignoré: %s", webhook.EventType)
 	}
@@ -108,15 +112,31 @@ func handleDownloadEvent(webhook *model.RadarrWebhook) {
 			log.Printf("Indexeur: %s", webhook.Release.Indexer)
 		}
 
+		media := uploadMedia(webhook, webhook.MovieFile)
+		correlationID := newCorrelationID()
+		store := db.WithActor(storage.ActorWebhook, correlationID)
+
+		// Enregistrer le film et ses identifiants TMDB et IMDb
+		saveWebhookMedia(webhook)
+
 		// Vérifier si le film a déjà été uploadé
 		existingUpload, err := db.CheckExistingUpload(webhook.Movie.TmdbId, storage.TypeMovie, nil, nil)
 		if err != nil {
 			log.Printf("Erreur lors de la vérification d'un upload existant: %v", err)
 		}
 
-		if existingUpload != nil && existingUpload.UploadStatus == storage.StatusCompleted {
-			log.Printf("Le film a déjà été uploadé: %s (ID: %d)", existingUpload.Title, existingUpload.ID)
-			return
+		// Un upload partiel a déjà des miroirs, les manquants sont relancés automatiquement.
+		// Une mise à niveau ou une meilleure version est uploadée comme nouvelle version.
+		var replacedUploadID int64
+		if existingUpload != nil && (existingUpload.UploadStatus == storage.StatusCompleted ||
+			existingUpload.UploadStatus == storage.StatusPartial) {
+			if !isUpgrade(webhook, media, existingUpload) {
+				log.Printf("Le film a déjà été uploadé: %s (ID: %d)", existingUpload.Title, existingUpload.ID)
+				return
+			}
+			log.Printf("Mise à niveau du film %s, la version %d (upload %d) sera remplacée",
+				existingUpload.Title, existingUpload.Version, existingUpload.ID)
+			replacedUploadID = existingUpload.ID
 		}
 
 		// Créer un nouvel upload
@@ -126,23 +146,30 @@ func handleDownloadEvent(webhook *model.RadarrWebhook) {
 			Type:         storage.TypeMovie,
 			FilePath:     webhook.MovieFile.Path,
 			UploadStatus: storage.StatusPending,
+			Media:        media,
+			Fingerprint:  ingestFingerprint(webhook.MovieFile.Path),
 		}
 
 		// Ajouter l'upload à la base de données
-		uploadID, err := db.AddUpload(newUpload)
+		uploadID, err := store.AddUpload(newUpload)
 		if err != nil {
 			log.Printf("Erreur lors de l'ajout de l'upload à la base de données: %v", err)
 			return
 		}
 
-		log.Printf("Upload ajouté à la base de données avec l'ID: %d", uploadID)
+		log.Printf("Upload ajouté à la base de données avec l'ID: %d (version %d)", uploadID, newUpload.Version)
+
+		// Enregistrer les langues des sous-titres inclus dans la vidéo
+		recordEmbeddedSubtitles(uploadID, webhook.MovieFile.MediaInfo)
 
 		// Créer le payload pour la queue
 		payload := storage.TaskPayload{
-			UploadID: uploadID,
-			TmdbID:   webhook.Movie.TmdbId,
-			Title:    webhook.Movie.Title,
-			FilePath: webhook.MovieFile.Path,
+			UploadID:         uploadID,
+			TmdbID:           webhook.Movie.TmdbId,
+			Title:            webhook.Movie.Title,
+			FilePath:         webhook.MovieFile.Path,
+			ReplacesUploadID: replacedUploadID,
+			CorrelationID:    correlationID,
 		}
 
 		// Ajouter la tâche à la queue
@@ -161,6 +188,22 @@ func handleDownloadEvent(webhook *model.RadarrWebhook) {
 		log.Printf("IMDB ID: %s", webhook.Series.ImdbId)
 		log.Printf("Chemin: %s", webhook.Series.Path)
 
+		media := uploadMedia(webhook, webhook.EpisodeFile)
+		correlationID := newCorrelationID()
+		store := db.WithActor(storage.ActorWebhook, correlationID)
+
+		// Enregistrer la série, ses identifiants TMDB, TVDB et IMDb et les épisodes reçus
+		saveWebhookMedia(webhook)
+
+		// Le fichier est celui indiqué par Sonarr. Il peut contenir plusieurs épisodes,
+		// qui partagent alors son chemin et son empreinte.
+		if webhook.EpisodeFile == nil || webhook.EpisodeFile.Path == "" {
+			log.Printf("Fichier de l'épisode absent du webhook, upload impossible")
+			return
+		}
+		filePath := webhook.EpisodeFile.Path
+		fingerprint := ingestFingerprint(filePath)
+
 		log.Printf("Épisodes:")
 		for _, episode := range webhook.Episodes {
 			log.Printf("  - S%02dE%02d: %s", episode.SeasonNumber, episode.EpisodeNumber, episode.Title)
@@ -175,9 +218,18 @@ func handleDownloadEvent(webhook *model.RadarrWebhook) {
 				continue
 			}
 
-			if existingUpload != nil && existingUpload.UploadStatus == storage.StatusCompleted {
-				log.Printf("L'épisode a déjà été uploadé: %s S%02dE%02d (ID: %d)", existingUpload.Title, *existingUpload.Season, *existingUpload.Episode, existingUpload.ID)
-				continue
+			// Un upload partiel a déjà des miroirs, les manquants sont relancés automatiquement.
+			// Une mise à niveau ou une meilleure version est uploadée comme nouvelle version.
+			var replacedUploadID int64
+			if existingUpload != nil && (existingUpload.UploadStatus == storage.StatusCompleted ||
+				existingUpload.UploadStatus == storage.StatusPartial) {
+				if !isUpgrade(webhook, media, existingUpload) {
+					log.Printf("L'épisode a déjà été uploadé: %s S%02dE%02d (ID: %d)", existingUpload.Title, *existingUpload.Season, *existingUpload.Episode, existingUpload.ID)
+					continue
+				}
+				log.Printf("Mise à niveau de l'épisode S%02dE%02d, la version %d (upload %d) sera remplacée",
+					season, episodeNum, existingUpload.Version, existingUpload.ID)
+				replacedUploadID = existingUpload.ID
 			}
 
 			// Créer un nouvel upload
@@ -187,27 +239,34 @@ func handleDownloadEvent(webhook *model.RadarrWebhook) {
 				Type:         storage.TypeSeries,
 				Season:       &season,
 				Episode:      &episodeNum,
-				FilePath:     fmt.Sprintf("%s/Season %d/%s", webhook.Series.Path, season, episode.Title),
+				FilePath:     filePath,
 				UploadStatus: storage.StatusPending,
+				Media:        media,
+				Fingerprint:  fingerprint,
 			}
 
 			// Ajouter l'upload à la base de données
-			uploadID, err := db.AddUpload(newUpload)
+			uploadID, err := store.AddUpload(newUpload)
 			if err != nil {
 				log.Printf("Erreur lors de l'ajout de l'upload à la base de données: %v", err)
 				continue
 			}
 
-			log.Printf("Upload ajouté à la base de données avec l'ID: %d", uploadID)
+			log.Printf("Upload ajouté à la base de données avec l'ID: %d (version %d)", uploadID, newUpload.Version)
+
+			// Enregistrer les langues des sous-titres inclus dans la vidéo
+			recordEmbeddedSubtitles(uploadID, webhook.EpisodeFile.MediaInfo)
 
 			// Créer le payload pour la queue
 			payload := storage.TaskPayload{
-				UploadID: uploadID,
-				TmdbID:   webhook.Series.TmdbId,
-				Title:    webhook.Series.Title,
-				FilePath: newUpload.FilePath,
-				Season:   &season,
-				Episode:  &episodeNum,
+				UploadID:         uploadID,
+				TmdbID:           webhook.Series.TmdbId,
+				Title:            webhook.Series.Title,
+				FilePath:         newUpload.FilePath,
+				Season:           &season,
+				Episode:          &episodeNum,
+				ReplacesUploadID: replacedUploadID,
+				CorrelationID:    correlationID,
 			}
 
 			// Ajouter la tâche à la queue
@@ -266,7 +325,12 @@ func handleMovieUploadTask(payloadBytes []byte) error {
 		return fmt.Errorf("erreur lors du décodage du payload: %w", err)
 	}
 
-	return processMovieUpload(payload.UploadID, payload.TmdbID, payload.Title, payload.FilePath)
+	if err := processMovieUpload(payload.UploadID, payload.TmdbID, payload.Title, payload.FilePath, payload.CorrelationID); err != nil {
+		return err
+	}
+
+	replaceUpload(payload.UploadID, payload.ReplacesUploadID, payload.CorrelationID)
+	return nil
 }
 
 func handleEpisodeUploadTask(payloadBytes []byte) error {
@@ -279,165 +343,35 @@ func handleEpisodeUploadTask(payloadBytes []byte) error {
 		return fmt.Errorf("saison ou épisode manquant dans le payload")
 	}
 
-	return processEpisodeUpload(payload.UploadID, payload.TmdbID, payload.Title, payload.FilePath, *payload.Season, *payload.Episode)
+	if err := processEpisodeUpload(payload.UploadID, payload.TmdbID, payload.Title, payload.FilePath, *payload.Season, *payload.Episode, payload.CorrelationID); err != nil {
+		return err
+	}
+
+	replaceUpload(payload.UploadID, payload.ReplacesUploadID, payload.CorrelationID)
+	return nil
 }
 
-// Traiter l'upload d'un film
-func processMovieUpload(uploadID int64, tmdbID int, title, filePath string) error {
+// Traiter l'upload d'un film. Les changements sont rattachés à correlationID dans le journal.
+func processMovieUpload(uploadID int64, tmdbID int, title, filePath, correlationID string) error {
 	log.Printf("Traitement de l'upload du film: %s (ID: %d)", title, uploadID)
 
 	// Mettre à jour le statut
-	if err := db.UpdateUploadStatus(uploadID, storage.StatusUploading); err != nil {
+	if err := db.WithActor(storage.ActorWorker, correlationID).UpdateUploadStatus(uploadID, storage.StatusUploading); err != nil {
 		return fmt.Errorf("erreur lors de la mise à jour du statut: %w", err)
 	}
 
-	// Vérifier si des liens existent déjà pour cet upload
-	existingLinks, err := db.GetUploadLinks(uploadID)
-	if err != nil {
-		log.Printf("Erreur lors de la récupération des liens existants: %v", err)
-	}
-
-	// Si des liens existent déjà, ne pas réuploader
-	if len(existingLinks) > 0 {
-		log.Printf("Des liens existent déjà pour cet upload (ID: %d), utilisation des liens existants", uploadID)
-
-		// Mettre à jour le statut
-		if err := db.UpdateUploadStatus(uploadID, storage.StatusCompleted); err != nil {
-			return fmt.Errorf("erreur lors de la mise à jour du statut: %w", err)
-		}
-
-		// Convertir les liens existants pour Discord
-		var discordLinks []api.HostedLink
-		for _, link := range existingLinks {
-			discordLinks = append(discordLinks, api.HostedLink{
-				Hoster:   link.Hoster,
-				URL:      link.URL,
-				Embed:    link.Embed,
-				FileCode: link.FileCode,
-			})
-		}
-
-		// Continuer avec les notifications et Strapi
-		return processUploadResults(uploadID, tmdbID, title, discordLinks, true)
-	}
-
-	// Créer une liste pour stocker les résultats d'upload
-	var results []*upload.UploadResult
-	var uploadSuccess bool = false
-
-	// Optimiser la mémoire avant les uploads
-	optimizeMemoryForUpload()
-	defer restoreNormalSettings()
-
-	// Définir l'ordre de priorité des uploaders
-	type UploaderConfig struct {
-		Name     string
-		Enabled  bool
-		Priority int
-		Upload   func() (*upload.UploadResult, error)
-	}
-
-	// Configurer les uploaders disponibles
-	uploaders := []UploaderConfig{
-		{
-			Name:     "mixdrop",
-			Enabled:  cfg.Uploaders.MixDrop.Enabled,
-			Priority: 1, // Priorité plus haute (sera essayé en premier)
-			Upload: func() (*upload.UploadResult, error) {
-				mixdropUploader := upload.NewMixDropUploader(
-					cfg.Uploaders.MixDrop.Email,
-					cfg.Uploaders.MixDrop.ApiKey,
-					cfg.Uploaders.MixDrop.Enabled,
-				)
-				return mixdropUploader.UploadFile(filePath, title)
-			},
-		},
-		{
-			Name:     "netu",
-			Enabled:  cfg.Uploaders.Netu.Enabled,
-			Priority: 2, // Priorité plus basse
-			Upload: func() (*upload.UploadResult, error) {
-				netuUploader := upload.NewNetuUploader(
-					cfg.Uploaders.Netu.ApiKey,
-					cfg.Uploaders.Netu.Enabled,
-				)
-				return netuUploader.UploadFile(filePath, title)
-			},
-		},
-	}
+	// Réutiliser les liens d'un fichier identique déjà uploadé
+	reuseFingerprintLinks(uploadID, correlationID)
 
-	// Essayer chaque uploader jusqu'à ce qu'un réussisse
-	for _, uploaderConfig := range uploaders {
-		if !uploaderConfig.Enabled {
-			log.Printf("Uploader %s désactivé, ignoré", uploaderConfig.Name)
-			continue
-		}
-
-		log.Printf("Démarrage de l'upload vers %s pour le film %s (ID: %d)",
-			uploaderConfig.Name, title, uploadID)
-
-		// Forcer le garbage collection avant chaque upload
-		runtime.GC()
-
-		result, err := uploaderConfig.Upload()
-		if err != nil {
-			log.Printf("Erreur lors de l'upload vers %s: %v", uploaderConfig.Name, err)
-			continue
-		}
-
-		log.Printf("Upload vers %s terminé avec succès pour le film %s (ID: %d)",
-			uploaderConfig.Name, title, uploadID)
-
-		// Libérer la mémoire après un upload réussi
-		freeMemoryAfterUpload()
-
-		results = append(results, result)
-		uploadSuccess = true
-
-		// Si on a réussi un upload et qu'on veut économiser du temps/ressources,
-		// on peut arrêter ici au lieu d'essayer tous les uploaders
-		// Décommentez la ligne suivante pour n'utiliser qu'un seul uploader
-		// break
-	}
-
-	// Vérifier si au moins un upload a réussi
-	if !uploadSuccess {
-		if err := db.UpdateUploadStatus(uploadID, storage.StatusFailed); err != nil {
-			return fmt.Errorf("erreur lors de la mise à jour du statut: %w", err)
-		}
-		return fmt.Errorf("aucun upload n'a réussi")
-	}
-
-	// Mettre à jour le statut
-	if err := db.UpdateUploadStatus(uploadID, storage.StatusCompleted); err != nil {
-		return fmt.Errorf("erreur lors de la mise à jour du statut: %w", err)
+	// Uploader le fichier vers les hébergeurs qui n'ont pas encore de miroir
+	discordLinks, publish, err := uploadMirrors(uploadID, tmdbID, filePath, title, "le film", title, correlationID)
+	if err != nil {
+		return err
 	}
 
-	// Traiter les résultats d'upload
-	var discordLinks []api.HostedLink
-
-	for _, result := range results {
-		if result.Success {
-			// Ajouter le lien à la base de données
-			link := storage.HostedLink{
-				Hoster:   result.Hoster,
-				FileCode: result.FileCode,
-				URL:      result.URL,
-				Embed:    result.Embed,
-			}
-
-			if err := db.AddUploadLink(uploadID, link); err != nil {
-				log.Printf("Erreur lors de l'ajout du lien à la base de données: %v", err)
-			}
-
-			// Ajouter le lien pour Discord
-			discordLinks = append(discordLinks, api.HostedLink{
-				Hoster:   result.Hoster,
-				URL:      result.URL,
-				Embed:    result.Embed,
-				FileCode: result.FileCode,
-			})
-		}
+	if !publish {
+		log.Printf("Aucun nouveau miroir pour le film %s (ID: %d)", title, uploadID)
+		return nil
 	}
 
 	// Traiter les résultats (notifications Discord et Strapi)
@@ -532,7 +466,7 @@ func processUploadResults(uploadID int64, tmdbID int, title string, discordLinks
 			// Envoyer tous les liens d'embed à Strapi
 			for _, link := range discordLinks {
 				log.Printf("Envoi du lien d'embed à Strapi pour %s: %s", link.Hoster, link.Embed)
-				embedLinkID, err := strapiClient.CreateLink(ficheID, link.Embed)
+				embedLinkID, err := publishLink(ficheID, uploadID, link.Embed, link.FileCode)
 				if err != nil {
 					log.Printf("ERREUR lors de l'envoi du lien à Strapi: %v", err)
 					// Continuer malgré l'erreur
@@ -553,202 +487,34 @@ func processUploadResults(uploadID int64, tmdbID int, title string, discordLinks
 	return nil
 }
 
-// Traiter l'upload d'un épisode
-func processEpisodeUpload(uploadID int64, tmdbID int, title, filePath string, season, episode int) error {
+// Traiter l'upload d'un épisode. Les changements sont rattachés à correlationID dans le journal.
+func processEpisodeUpload(uploadID int64, tmdbID int, title, filePath string, season, episode int, correlationID string) error {
 	log.Printf("Traitement de l'upload de l'épisode: %s S%02dE%02d (ID: %d)", title, season, episode, uploadID)
 
 	// Mettre à jour le statut
-	if err := db.UpdateUploadStatus(uploadID, storage.StatusUploading); err != nil {
+	if err := db.WithActor(storage.ActorWorker, correlationID).UpdateUploadStatus(uploadID, storage.StatusUploading); err != nil {
 		return fmt.Errorf("erreur lors de la mise à jour du statut: %w", err)
 	}
 
-	// Vérifier si des liens existent déjà pour cet upload
-	existingLinks, err := db.GetUploadLinks(uploadID)
-	if err != nil {
-		log.Printf("Erreur lors de la récupération des liens existants: %v", err)
-	}
-
-	// Si des liens existent déjà, ne pas réuploader
-	if len(existingLinks) > 0 {
-		log.Printf("Des liens existent déjà pour cet upload (ID: %d), utilisation des liens existants", uploadID)
+	// Titre formaté pour l'épisode chez les hébergeurs. Les liens sont publiés dans la fiche
+	// de la série, avec la saison et le numéro de l'épisode.
+	uploadTitle := fmt.Sprintf("%s S%02dE%02d", title, season, episode)
 
-		// Mettre à jour le statut
-		if err := db.UpdateUploadStatus(uploadID, storage.StatusCompleted); err != nil {
-			return fmt.Errorf("erreur lors de la mise à jour du statut: %w", err)
-		}
-
-		// Convertir les liens existants pour Discord
-		var discordLinks []api.HostedLink
-		for _, link := range existingLinks {
-			discordLinks = append(discordLinks, api.HostedLink{
-				Hoster:   link.Hoster,
-				URL:      link.URL,
-				Embed:    link.Embed,
-				FileCode: link.FileCode,
-			})
-		}
+	// Réutiliser les liens d'un fichier identique déjà uploadé
+	reuseFingerprintLinks(uploadID, correlationID)
 
-		// Notifier Discord
-		if len(discordLinks) > 0 {
-			if err := discordClient.NotifyEpisodeUpload(title, tmdbID, season, episode, discordLinks); err != nil {
-				log.Printf("Erreur lors de la notification à Discord: %v", err)
-				// Continuer même en cas d'erreur
-			}
-		}
-
-		// Traiter Strapi
-		if cfg.Strapi.Enabled && len(discordLinks) > 0 {
-			// Créer un titre formaté pour l'épisode
-			episodeTitle := fmt.Sprintf("%s - S%02dE%02d", title, season, episode)
-
-			// Créer la fiche de série une seule fois
-			log.Printf("Création de la fiche dans Strapi pour l'épisode %s (TMDB ID: %d)", episodeTitle, tmdbID)
-			ficheID, err := strapiClient.CreateFiche(episodeTitle, tmdbID)
-			if err != nil {
-				log.Printf("ERREUR lors de la création de la fiche Strapi: %v", err)
-				// Continuer malgré l'erreur
-			} else {
-				log.Printf("Fiche créée dans Strapi avec l'ID: %s", ficheID)
-
-				// Envoyer tous les liens d'embed à Strapi
-				for _, link := range discordLinks {
-					log.Printf("Envoi du lien d'embed à Strapi pour %s: %s", link.Hoster, link.Embed)
-					embedLinkID, err := strapiClient.CreateLink(ficheID, link.Embed)
-					if err != nil {
-						log.Printf("ERREUR lors de l'envoi du lien à Strapi: %v", err)
-						// Continuer malgré l'erreur
-					} else {
-						log.Printf("Lien d'embed créé dans Strapi avec l'ID: %s", embedLinkID)
-					}
-				}
-			}
-		}
-
-		log.Printf("Upload de l'épisode terminé avec succès (liens existants): %s S%02dE%02d (ID: %d)",
-			title, season, episode, uploadID)
-		return nil
-	}
-
-	// Créer une liste pour stocker les résultats d'upload
-	var results []*upload.UploadResult
-	var uploadSuccess bool = false
-
-	// Optimiser la mémoire avant les uploads
-	optimizeMemoryForUpload()
-	defer restoreNormalSettings()
-
-	// Titre formaté pour l'épisode
-	episodeTitle := fmt.Sprintf("%s S%02dE%02d", title, season, episode)
-
-	// Définir l'ordre de priorité des uploaders
-	type UploaderConfig struct {
-		Name     string
-		Enabled  bool
-		Priority int
-		Upload   func() (*upload.UploadResult, error)
-	}
-
-	// Configurer les uploaders disponibles
-	uploaders := []UploaderConfig{
-		{
-			Name:     "mixdrop",
-			Enabled:  cfg.Uploaders.MixDrop.Enabled,
-			Priority: 1, // Priorité plus haute (sera essayé en premier)
-			Upload: func() (*upload.UploadResult, error) {
-				mixdropUploader := upload.NewMixDropUploader(
-					cfg.Uploaders.MixDrop.Email,
-					cfg.Uploaders.MixDrop.ApiKey,
-					cfg.Uploaders.MixDrop.Enabled,
-				)
-				return mixdropUploader.UploadFile(filePath, episodeTitle)
-			},
-		},
-		{
-			Name:     "netu",
-			Enabled:  cfg.Uploaders.Netu.Enabled,
-			Priority: 2, // Priorité plus basse
-			Upload: func() (*upload.UploadResult, error) {
-				netuUploader := upload.NewNetuUploader(
-					cfg.Uploaders.Netu.ApiKey,
-					cfg.Uploaders.Netu.Enabled,
-				)
-				return netuUploader.UploadFile(filePath, episodeTitle)
-			},
-		},
-	}
-
-	// Essayer chaque uploader jusqu'à ce qu'un réussisse
-	for _, uploaderConfig := range uploaders {
-		if !uploaderConfig.Enabled {
-			log.Printf("Uploader %s désactivé, ignoré", uploaderConfig.Name)
-			continue
-		}
-
-		log.Printf("Démarrage de l'upload vers %s pour l'épisode %s (ID: %d)",
-			uploaderConfig.Name, episodeTitle, uploadID)
-
-		// Forcer le garbage collection avant chaque upload
-		runtime.GC()
-
-		result, err := uploaderConfig.Upload()
-		if err != nil {
-			log.Printf("Erreur lors de l'upload vers %s: %v", uploaderConfig.Name, err)
-			continue
-		}
-
-		log.Printf("Upload vers %s terminé avec succès pour l'épisode %s (ID: %d)",
-			uploaderConfig.Name, episodeTitle, uploadID)
-
-		// Libérer la mémoire après un upload réussi
-		freeMemoryAfterUpload()
-
-		results = append(results, result)
-		uploadSuccess = true
-
-		// Si on a réussi un upload et qu'on veut économiser du temps/ressources,
-		// on peut arrêter ici au lieu d'essayer tous les uploaders
-		// Décommentez la ligne suivante pour n'utiliser qu'un seul uploader
-		// break
-	}
-
-	// Vérifier si au moins un upload a réussi
-	if !uploadSuccess {
-		if err := db.UpdateUploadStatus(uploadID, storage.StatusFailed); err != nil {
-			return fmt.Errorf("erreur lors de la mise à jour du statut: %w", err)
-		}
-		return fmt.Errorf("aucun upload n'a réussi")
-	}
-
-	// Mettre à jour le statut
-	if err := db.UpdateUploadStatus(uploadID, storage.StatusCompleted); err != nil {
-		return fmt.Errorf("erreur lors de la mise à jour du statut: %w", err)
+	// Uploader le fichier vers les hébergeurs qui n'ont pas encore de miroir
+	discordLinks, publish, err := uploadMirrors(uploadID, tmdbID, filePath, uploadTitle, "l'épisode", title, correlationID)
+	if err != nil {
+		return err
 	}
 
-	// Traiter les résultats d'upload
-	var discordLinks []api.HostedLink
-
-	for _, result := range results {
-		if result.Success {
-			// Ajouter le lien à la base de données
-			link := storage.HostedLink{
-				Hoster:   result.Hoster,
-				FileCode: result.FileCode,
-				URL:      result.URL,
-				Embed:    result.Embed,
-			}
-
-			if err := db.AddUploadLink(uploadID, link); err != nil {
-				log.Printf("Erreur lors de l'ajout du lien à la base de données: %v", err)
-			}
+	// Signaler la saison quand son dernier épisode est disponible
+	checkSeasonCompletion(uploadID)
 
-			// Ajouter le lien pour Discord
-			discordLinks = append(discordLinks, api.HostedLink{
-				Hoster:   result.Hoster,
-				URL:      result.URL,
-				Embed:    result.Embed,
-				FileCode: result.FileCode,
-			})
-		}
+	if !publish {
+		log.Printf("Aucun nouveau miroir pour l'épisode %s S%02dE%02d (ID: %d)", title, season, episode, uploadID)
+		return nil
 	}
 
 	// Notifier Discord
@@ -761,12 +527,9 @@ func processEpisodeUpload(uploadID int64, tmdbID int, title, filePath string, se
 
 	// Si Strapi est activé, envoyer les données à Strapi
 	if cfg.Strapi.Enabled && len(discordLinks) > 0 {
-		// Créer un titre formaté pour l'épisode
-		episodeTitle := fmt.Sprintf("%s - S%02dE%02d", title, season, episode)
-
 		// Créer la fiche de série une seule fois
-		log.Printf("Création de la fiche dans Strapi pour l'épisode %s (TMDB ID: %d)", episodeTitle, tmdbID)
-		ficheID, err := strapiClient.CreateFiche(episodeTitle, tmdbID)
+		log.Printf("Création de la fiche dans Strapi pour la série %s (TMDB ID: %d)", title, tmdbID)
+		ficheID, err := strapiClient.CreateFiche(title, tmdbID)
 		if err != nil {
 			log.Printf("ERREUR lors de la création de la fiche Strapi: %v", err)
 			// Continuer malgré l'erreur
@@ -776,7 +539,7 @@ func processEpisodeUpload(uploadID int64, tmdbID int, title, filePath string, se
 			// Envoyer tous les liens d'embed à Strapi
 			for _, link := range discordLinks {
 				log.Printf("Envoi du lien d'embed à Strapi pour %s: %s", link.Hoster, link.Embed)
-				embedLinkID, err := strapiClient.CreateLink(ficheID, link.Embed)
+				embedLinkID, err := publishLink(ficheID, uploadID, link.Embed, link.FileCode)
 				if err != nil {
 					log.Printf("ERREUR lors de l'envoi du lien à Strapi: %v", err)
 					// Continuer malgré l'erreur
@@ -795,6 +558,7 @@ func main() {
 	// Analyser les arguments de la ligne de commande
 	configPath := flag.String("config", "config.json", "Chemin vers le fichier de configuration")
 	createConfig := flag.Bool("create-config", false, "Créer un fichier de configuration par défaut")
+	autoMigrate := flag.Bool("auto-migrate", true, "Appliquer les migrations du schéma au démarrage")
 	flag.Parse()
 
 	// Créer un fichier de configuration par défaut si demandé
@@ -837,13 +601,75 @@ func main() {
 	log.SetOutput(os.Stdout)
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 
+	// Commande de migration du schéma: webhook-server [-config ...] migrate [status|up|down [n]|to <version>]
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrateCommand(flag.Args()[1:]); err != nil {
+			log.Fatalf("Erreur lors de la migration: %v", err)
+		}
+		return
+	}
+
+	// Historique des changements: webhook-server [-config ...] events upload|link|tmdb <id> [limite]
+	if flag.Arg(0) == "events" {
+		if err := runEventsCommand(flag.Args()[1:]); err != nil {
+			log.Fatalf("Erreur lors de la lecture du journal: %v", err)
+		}
+		return
+	}
+
+	// Sauvegarde à chaud de la base: webhook-server [-config ...] backup [fichier]
+	if flag.Arg(0) == "backup" {
+		if err := runBackupCommand(flag.Args()[1:]); err != nil {
+			log.Fatalf("Erreur lors de la sauvegarde: %v", err)
+		}
+		return
+	}
+
+	// Export et import des uploads, liens et queue: webhook-server [-config ...] export|import <fichier|-> [json|ndjson]
+	if flag.Arg(0) == "export" {
+		if err := runExportCommand(flag.Args()[1:]); err != nil {
+			log.Fatalf("Erreur lors de l'export: %v", err)
+		}
+		return
+	}
+	if flag.Arg(0) == "import" {
+		if err := runImportCommand(flag.Args()[1:]); err != nil {
+			log.Fatalf("Erreur lors de l'import: %v", err)
+		}
+		return
+	}
+
 	// Initialiser la base de données
-	db, err = storage.NewDatabase(cfg.Database.Path)
+	db, err = openDatabase()
 	if err != nil {
 		log.Fatalf("Erreur lors de l'initialisation de la base de données: %v", err)
 	}
 	defer db.Close()
 
+	// Mettre le schéma à jour, ou refuser de démarrer sur un schéma périmé
+	if *autoMigrate {
+		if err := db.MigrateToLatest(); err != nil {
+			log.Fatalf("Erreur lors de la migration de la base de données: %v", err)
+		}
+	} else {
+		pending, err := db.PendingMigrations()
+		if err != nil {
+			log.Fatalf("Erreur lors de la vérification du schéma: %v", err)
+		}
+		if len(pending) > 0 {
+			log.Fatalf("%d migrations en attente, lancez la commande migrate up", len(pending))
+		}
+	}
+
+	// Initialiser le limiteur d'uploads simultanés par hébergeur
+	hosterLimiter = newHosterLimiter()
+
+	// Initialiser les disjoncteurs des hébergeurs
+	breakers = upload.NewBreakers(
+		cfg.CircuitBreaker.FailureThreshold,
+		time.Duration(cfg.CircuitBreaker.CooldownMinutes)*time.Minute,
+	)
+
 	// Initialiser le pool de workers - IMPORTANT: un seul worker pour éviter les problèmes de mémoire
 	workerPool = worker.NewPool(1) // Un seul worker à la fois
 	workerPool.Start()
@@ -855,6 +681,22 @@ func main() {
 	// Enregistrer les gestionnaires de tâches
 	queueManager.RegisterHandler("movie_upload", handleMovieUploadTask)
 	queueManager.RegisterHandler("episode_upload", handleEpisodeUploadTask)
+	queueManager.RegisterHandler("link_reupload", handleLinkReuploadTask)
+	queueManager.RegisterHandler("files_delete", handleFilesDeleteTask)
+
+	// Limiter le débit des uploads selon les profils horaires
+	bandwidth := upload.NewBandwidth()
+	upload.SetBandwidth(bandwidth)
+	if err := startBandwidthSchedule(bandwidth); err != nil {
+		log.Fatalf("Erreur dans la configuration de la bande passante: %v", err)
+	}
+
+	// Différer les nouveaux uploads en dehors des plages autorisées
+	windows, err := uploadWindows(cfg.Bandwidth.UploadWindows)
+	if err != nil {
+		log.Fatalf("Erreur dans la configuration de la bande passante: %v", err)
+	}
+	queueManager.SetUploadWindows(windows, "movie_upload", "episode_upload", "link_reupload")
 
 	// Démarrer le gestionnaire de queue
 	queueManager.Start()
@@ -886,8 +728,33 @@ func main() {
 		log.Printf("Strapi est désactivé dans la configuration")
 	}
 
+	// Relancer périodiquement les miroirs manquants des uploads partiels
+	startMirrorRetries()
+
+	// Sauvegarder périodiquement la base de données
+	if cfg.Backup.Enabled {
+		startBackupSchedule()
+	}
+
+	// Démarrer la vérification périodique des liens hébergés
+	if cfg.LinkChecker.Enabled {
+		linkChecker := newLinkChecker()
+		linkChecker.Start()
+		defer linkChecker.Stop()
+	}
+
 	// Définir les routes
 	http.HandleFunc("/webhook", webhookHandler)
+	http.HandleFunc("/api/uploads", uploadsHandler)
+	http.HandleFunc("/api/media", mediaHandler)
+	http.HandleFunc("/api/events", eventsHandler)
+	http.HandleFunc("/api/attempts", attemptsHandler)
+	http.HandleFunc("/api/attempts/stats", attemptStatsHandler)
+	http.HandleFunc("/api/hosters/usage", hosterUsageHandler)
+	http.HandleFunc("/api/hosters/breakers", breakersHandler)
+	http.HandleFunc("/api/admin/uploads/delete", adminDeleteHandler)
+	http.HandleFunc("/api/admin/uploads/rename", adminRenameHandler)
+	http.HandleFunc("/api/admin/uploads/move", adminMoveHandler)
 
 	// Démarrer le serveur
 	serverAddr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
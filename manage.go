@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"media-upload-system/model"
+	"media-upload-system/storage"
+	"media-upload-system/upload"
+)
+
+// deleteUploadFiles supprime les fichiers d'un upload chez les hébergeurs, puis ses liens dans
+// la base et dans Strapi. Les liens dont le fichier n'a pas pu être supprimé sont conservés.
+// Les changements sont enregistrés dans le journal au nom de l'auteur de store.
+func deleteUploadFiles(store storage.Store, uploadID int64) error {
+	existing, err := db.GetUpload(uploadID)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la récupération de l'upload %d: %w", uploadID, err)
+	}
+
+	links, err := db.GetUploadLinks(uploadID)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la récupération des liens: %w", err)
+	}
+
+	ficheID := strapiFicheID(existing.TmdbID)
+
+	var failures []string
+	for _, link := range links {
+		deleter, ok := uploaderByName(link.Hoster).(upload.Deleter)
+		if !ok {
+			failures = append(failures, fmt.Sprintf("%s: suppression non supportée", link.Hoster))
+			continue
+		}
+
+		// Un fichier réutilisé par un autre upload est conservé, seul le lien est supprimé
+		shared, err := db.CountFileLinks(link.Hoster, link.FileCode)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", link.Hoster, err))
+			continue
+		}
+
+		// Un fichier déjà mort n'existe plus chez l'hébergeur, seul le lien est supprimé
+		if shared <= 1 {
+			if err := deleter.DeleteFile(link.FileCode); err != nil && link.Status != storage.LinkStatusDead {
+				failures = append(failures, fmt.Sprintf("%s: %v", link.Hoster, err))
+				continue
+			}
+		}
+
+		if ficheID != "" {
+			if err := strapiClient.DeleteLink(ficheID, link.Embed); err != nil {
+				log.Printf("ERREUR lors de la suppression du lien dans Strapi: %v", err)
+			}
+		}
+
+		if err := store.DeleteUploadLink(link.ID); err != nil {
+			return err
+		}
+
+		log.Printf("Fichier %s supprimé de %s pour %s (ID: %d)", link.FileCode, link.Hoster, existing.Title, uploadID)
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("fichiers non supprimés: %s", strings.Join(failures, ", "))
+	}
+
+	return store.UpdateUploadStatus(uploadID, storage.StatusDeleted)
+}
+
+// renameUploadFiles renomme les fichiers d'un upload chez les hébergeurs et met à jour son titre.
+// Les URLs d'embed ne changent pas, les liens Strapi restent donc valides.
+func renameUploadFiles(store storage.Store, uploadID int64, title string) error {
+	existing, err := db.GetUpload(uploadID)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la récupération de l'upload %d: %w", uploadID, err)
+	}
+
+	links, err := db.GetUploadLinks(uploadID)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la récupération des liens: %w", err)
+	}
+
+	hosterTitle := title
+	if existing.Season != nil && existing.Episode != nil {
+		hosterTitle = fmt.Sprintf("%s S%02dE%02d", title, *existing.Season, *existing.Episode)
+	}
+
+	var failures []string
+	for _, link := range links {
+		renamer, ok := uploaderByName(link.Hoster).(upload.Renamer)
+		if !ok {
+			log.Printf("Renommage non supporté par %s, fichier %s ignoré", link.Hoster, link.FileCode)
+			continue
+		}
+
+		if err := renamer.RenameFile(link.FileCode, hosterTitle); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", link.Hoster, err))
+			continue
+		}
+
+		log.Printf("Fichier %s renommé sur %s: %s", link.FileCode, link.Hoster, hosterTitle)
+	}
+
+	if err := store.UpdateUploadTitle(uploadID, title); err != nil {
+		return err
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("fichiers non renommés: %s", strings.Join(failures, ", "))
+	}
+
+	return nil
+}
+
+// moveUploadFiles range les fichiers d'un upload dans un dossier de chaque hébergeur
+func moveUploadFiles(uploadID int64, folders map[string]string) error {
+	links, err := db.GetUploadLinks(uploadID)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la récupération des liens: %w", err)
+	}
+
+	var failures []string
+	for _, link := range links {
+		folderID, exists := folders[link.Hoster]
+		if !exists {
+			continue
+		}
+
+		mover, ok := uploaderByName(link.Hoster).(upload.Mover)
+		if !ok {
+			failures = append(failures, fmt.Sprintf("%s: déplacement non supporté", link.Hoster))
+			continue
+		}
+
+		if err := mover.MoveFile(link.FileCode, folderID); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", link.Hoster, err))
+			continue
+		}
+
+		log.Printf("Fichier %s déplacé sur %s dans le dossier %s", link.FileCode, link.Hoster, folderID)
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("fichiers non déplacés: %s", strings.Join(failures, ", "))
+	}
+
+	return nil
+}
+
+// strapiFicheID retourne l'ID de la fiche Strapi d'un média, ou une chaîne vide
+func strapiFicheID(tmdbID int) string {
+	if !cfg.Strapi.Enabled {
+		return ""
+	}
+
+	id, err := strapiClient.SearchFicheByTMDBID(tmdbID)
+	if err != nil {
+		log.Printf("Erreur lors de la recherche de la fiche Strapi: %v", err)
+		return ""
+	}
+	if id == 0 {
+		return ""
+	}
+
+	return strconv.Itoa(id)
+}
+
+// scheduleFilesDelete ajoute à la queue la suppression des fichiers d'un upload
+func scheduleFilesDelete(uploadID int64, correlationID string) {
+	payload := storage.TaskPayload{UploadID: uploadID, CorrelationID: correlationID}
+	taskID, err := queueManager.AddTask("files_delete", payload, 3)
+	if err != nil {
+		log.Printf("Erreur lors de l'ajout de la suppression à la queue: %v", err)
+		return
+	}
+
+	log.Printf("Suppression des fichiers de l'upload %d ajoutée à la queue (tâche %d)", uploadID, taskID)
+}
+
+// handleFilesDeleteTask supprime les fichiers hébergés d'un upload
+func handleFilesDeleteTask(payloadBytes []byte) error {
+	var payload storage.TaskPayload
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		return fmt.Errorf("erreur lors du décodage du payload: %w", err)
+	}
+
+	return deleteUploadFiles(db.WithActor(storage.ActorWorker, payload.CorrelationID), payload.UploadID)
+}
+
+// replaceUpload marque la version remplacée par une mise à niveau, une fois que le nouvel
+// upload a au moins un miroir. Ses fichiers sont supprimés, ou conservés et publiés après
+// ceux de la meilleure version si DeleteReplaced est désactivé. Le remplacement et la
+// suppression sont rattachés à correlationID dans le journal.
+func replaceUpload(uploadID, replacedUploadID int64, correlationID string) {
+	if replacedUploadID == 0 {
+		return
+	}
+
+	links, err := db.GetUploadLinks(uploadID)
+	if err != nil || len(links) == 0 {
+		log.Printf("Aucun miroir pour l'upload %d, l'upload remplacé %d est conservé", uploadID, replacedUploadID)
+		return
+	}
+
+	if err := db.WithActor(storage.ActorWorker, correlationID).SupersedeUpload(replacedUploadID, uploadID); err != nil {
+		log.Printf("Erreur lors du remplacement de l'upload %d: %v", replacedUploadID, err)
+		return
+	}
+
+	if cfg.FileManagement.DeleteReplaced {
+		scheduleFilesDelete(replacedUploadID, correlationID)
+		return
+	}
+
+	log.Printf("Miroirs de l'upload remplacé %d conservés", replacedUploadID)
+	publishVersionOrder(uploadID)
+}
+
+// handleDeleteEvent supprime les fichiers hébergés des médias supprimés de Radarr ou Sonarr
+func handleDeleteEvent(webhook *model.RadarrWebhook) {
+	if !cfg.FileManagement.DeleteOnRemove {
+		log.Printf("Suppression des fichiers hébergés désactivée, événement %s ignoré", webhook.EventType)
+		return
+	}
+
+	// Média retiré de Radarr ou Sonarr sans supprimer ses fichiers: les miroirs sont conservés.
+	// Les événements de suppression d'un fichier ne portent pas ce champ.
+	isMediaDelete := webhook.EventType == "MovieDelete" || webhook.EventType == "SeriesDelete"
+	if isMediaDelete && !webhook.DeletedFiles {
+		log.Printf("Média retiré sans suppression des fichiers, miroirs conservés (événement %s)", webhook.EventType)
+		return
+	}
+
+	// Les mises à niveau sont traitées à la réception du nouveau fichier
+	if strings.EqualFold(webhook.DeleteReason, "upgrade") {
+		log.Printf("Fichier remplacé par une mise à niveau, suppression différée")
+		return
+	}
+
+	correlationID := newCorrelationID()
+
+	var uploads []*storage.Upload
+	switch {
+	case webhook.Movie != nil:
+		movieUploads, err := db.GetUploadsByTmdbID(webhook.Movie.TmdbId, storage.TypeMovie)
+		if err != nil {
+			log.Printf("Erreur lors de la récupération des uploads du film: %v", err)
+			return
+		}
+		uploads = movieUploads
+	case webhook.Series != nil:
+		seriesUploads, err := db.GetUploadsByTmdbID(webhook.Series.TmdbId, storage.TypeSeries)
+		if err != nil {
+			log.Printf("Erreur lors de la récupération des uploads de la série: %v", err)
+			return
+		}
+
+		// Sans liste d'épisodes, toute la série est supprimée
+		if len(webhook.Episodes) == 0 {
+			uploads = seriesUploads
+			break
+		}
+
+		for _, existing := range seriesUploads {
+			for _, episode := range webhook.Episodes {
+				if existing.Season != nil && existing.Episode != nil &&
+					*existing.Season == episode.SeasonNumber && *existing.Episode == episode.EpisodeNumber {
+					uploads = append(uploads, existing)
+				}
+			}
+		}
+	default:
+		log.Printf("Événement de suppression sans média identifiable")
+		return
+	}
+
+	for _, existing := range uploads {
+		if existing.UploadStatus == storage.StatusDeleted {
+			continue
+		}
+		scheduleFilesDelete(existing.ID, correlationID)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"media-upload-system/config"
+	"media-upload-system/model"
+	"media-upload-system/storage"
+	"media-upload-system/worker"
+)
+
+// setupDeleteEvent prépare la configuration, la base et la queue avec un upload de film terminé
+func setupDeleteEvent(t *testing.T) {
+	t.Helper()
+
+	cfg = &config.Config{}
+	cfg.FileManagement.DeleteOnRemove = true
+
+	database, err := storage.NewDatabase(filepath.Join(t.TempDir(), "uploads.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := database.MigrateToLatest(); err != nil {
+		t.Fatalf("MigrateToLatest: %v", err)
+	}
+	db = database
+	queueManager = worker.NewQueueManager(database, worker.NewPool(1))
+
+	upload := &storage.Upload{TmdbID: 42, Title: "Film", Type: storage.TypeMovie, FilePath: "/media/film.mkv", UploadStatus: storage.StatusCompleted}
+	if _, err := db.AddUpload(upload); err != nil {
+		t.Fatalf("AddUpload: %v", err)
+	}
+}
+
+// queuedDeletes retourne le nombre de suppressions de fichiers en attente
+func queuedDeletes(t *testing.T) int {
+	t.Helper()
+
+	items, err := db.GetPendingQueueItems()
+	if err != nil {
+		t.Fatalf("GetPendingQueueItems: %v", err)
+	}
+
+	count := 0
+	for _, item := range items {
+		if item.Type == "files_delete" {
+			count++
+		}
+	}
+	return count
+}
+
+func TestHandleDeleteEventKeepsFiles(t *testing.T) {
+	setupDeleteEvent(t)
+
+	handleDeleteEvent(&model.RadarrWebhook{EventType: "MovieDelete", Movie: &model.Movie{TmdbId: 42}, DeletedFiles: false})
+	if count := queuedDeletes(t); count != 0 {
+		t.Fatalf("suppression planifiée alors que les fichiers sont conservés: %d", count)
+	}
+}
+
+func TestHandleDeleteEventDeletedFiles(t *testing.T) {
+	setupDeleteEvent(t)
+
+	handleDeleteEvent(&model.RadarrWebhook{EventType: "MovieDelete", Movie: &model.Movie{TmdbId: 42}, DeletedFiles: true})
+	if count := queuedDeletes(t); count != 1 {
+		t.Fatalf("une suppression attendue, obtenu %d", count)
+	}
+}
+
+func TestHandleDeleteEventFileDelete(t *testing.T) {
+	setupDeleteEvent(t)
+
+	// La suppression d'un fichier ne porte pas deletedFiles
+	handleDeleteEvent(&model.RadarrWebhook{EventType: "MovieFileDelete", Movie: &model.Movie{TmdbId: 42}})
+	if count := queuedDeletes(t); count != 1 {
+		t.Fatalf("une suppression attendue, obtenu %d", count)
+	}
+}
+
+// TestReplaceUploadCorrelation vérifie que le remplacement d'une version et la suppression de
+// ses fichiers restent rattachés au webhook qui a déclenché la mise à niveau
+func TestReplaceUploadCorrelation(t *testing.T) {
+	setupDeleteEvent(t)
+	cfg.FileManagement.DeleteReplaced = true
+
+	replacement := &storage.Upload{TmdbID: 42, Title: "Film", Type: storage.TypeMovie, FilePath: "/media/film-2160p.mkv"}
+	uploadID, err := db.AddUpload(replacement)
+	if err != nil {
+		t.Fatalf("AddUpload: %v", err)
+	}
+	if err := db.AddUploadLink(uploadID, storage.HostedLink{Hoster: "mixdrop", FileCode: "abc"}); err != nil {
+		t.Fatalf("AddUploadLink: %v", err)
+	}
+
+	replaceUpload(uploadID, 1, "webhook-1")
+
+	events, err := db.GetEvents(storage.EventFilter{UploadID: 1})
+	if err != nil || len(events) == 0 || events[0].Action != storage.ActionSuperseded || events[0].CorrelationID != "webhook-1" {
+		t.Fatalf("remplacement non rattaché au webhook: %+v, %v", events, err)
+	}
+
+	items, err := db.GetPendingQueueItems()
+	if err != nil || len(items) != 1 {
+		t.Fatalf("GetPendingQueueItems: %+v, %v", items, err)
+	}
+	var payload storage.TaskPayload
+	if err := json.Unmarshal([]byte(items[0].Payload), &payload); err != nil || payload.CorrelationID != "webhook-1" {
+		t.Fatalf("suppression non rattachée au webhook: %s, %v", items[0].Payload, err)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"log"
+	"sort"
+
+	"media-upload-system/model"
+	"media-upload-system/storage"
+	"media-upload-system/strapi"
+)
+
+// uploadMedia extrait les informations d'un fichier reçu par webhook: qualité, codecs,
+// langues et release dont il provient
+func uploadMedia(webhook *model.RadarrWebhook, file *model.MovieFile) storage.UploadMedia {
+	media := storage.UploadMedia{
+		DownloadClient: webhook.DownloadClient,
+		DownloadID:     webhook.DownloadId,
+	}
+	if webhook.CustomFormatInfo != nil {
+		media.CustomFormatScore = webhook.CustomFormatInfo.CustomFormatScore
+	}
+	if webhook.Release != nil {
+		media.ReleaseTitle = webhook.Release.ReleaseTitle
+		media.Indexer = webhook.Release.Indexer
+		media.Size = webhook.Release.Size
+	}
+	if file == nil {
+		return media
+	}
+
+	media.Quality = file.Quality
+	media.QualityVersion = file.QualityVersion
+	media.ReleaseGroup = file.ReleaseGroup
+	if file.Size > 0 {
+		media.Size = file.Size
+	}
+	if file.SceneName != "" {
+		media.ReleaseTitle = file.SceneName
+	}
+	for _, language := range file.Languages {
+		media.Languages = append(media.Languages, language.Name)
+	}
+
+	if info := file.MediaInfo; info != nil {
+		media.Width = info.Width
+		media.Height = info.Height
+		media.VideoCodec = info.VideoCodec
+		media.VideoDynamicRange = info.VideoDynamicRangeType
+		if media.VideoDynamicRange == "" {
+			media.VideoDynamicRange = info.VideoDynamicRange
+		}
+		media.AudioCodec = info.AudioCodec
+		media.AudioChannels = info.AudioChannels
+		media.AudioLanguages = info.AudioLanguages
+		media.SubtitleLanguages = info.Subtitles
+	}
+
+	return media
+}
+
+// linkMedia retourne les informations du fichier d'un upload à publier avec ses liens,
+// ou nil si elles ne sont pas connues
+func linkMedia(uploadID int64) *strapi.LinkMedia {
+	upload, err := db.GetUpload(uploadID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération de l'upload %d: %v", uploadID, err)
+		return nil
+	}
+	if upload == nil || !upload.Media.Known() {
+		return nil
+	}
+
+	return &strapi.LinkMedia{
+		Quality:        upload.Media.Quality,
+		Resolution:     upload.Media.Resolution(),
+		Size:           upload.Media.Size,
+		VideoCodec:     upload.Media.VideoCodec,
+		DynamicRange:   upload.Media.VideoDynamicRange,
+		AudioCodec:     upload.Media.AudioCodec,
+		AudioChannels:  upload.Media.AudioChannels,
+		AudioLanguages: upload.Media.AudioLanguages,
+		ReleaseGroup:   upload.Media.ReleaseGroup,
+		Version:        upload.Version,
+	}
+}
+
+// isUpgrade indique si un fichier doit être uploadé comme nouvelle version de l'upload
+// existant: Radarr ou Sonarr le signale comme une mise à niveau, ou il est meilleur que
+// le fichier déjà uploadé
+func isUpgrade(webhook *model.RadarrWebhook, media storage.UploadMedia, existing *storage.Upload) bool {
+	if !cfg.FileManagement.UploadUpgrades {
+		return false
+	}
+	return webhook.IsUpgrade || (existing.Media.Known() && media.Better(existing.Media))
+}
+
+// bestVersionsFirst trie les versions d'un média de la meilleure à la moins bonne,
+// la plus récente d'abord à qualité égale
+func bestVersionsFirst(versions []*storage.Upload) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, b := versions[i], versions[j]
+		if a.Media.Better(b.Media) {
+			return true
+		}
+		if b.Media.Better(a.Media) {
+			return false
+		}
+		return a.Version > b.Version
+	})
+}
+
+// publishVersionOrder classe dans Strapi les liens de toutes les versions d'un média,
+// ceux de la meilleure version en premier
+func publishVersionOrder(uploadID int64) {
+	if !cfg.Strapi.Enabled {
+		return
+	}
+
+	current, err := db.GetUpload(uploadID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération de l'upload %d: %v", uploadID, err)
+		return
+	}
+
+	versions, err := db.GetUploadVersions(current.TmdbID, current.Type, current.Season, current.Episode)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des versions de %s: %v", current.Title, err)
+		return
+	}
+	bestVersionsFirst(versions)
+
+	var embeds []string
+	for _, version := range versions {
+		if version.UploadStatus == storage.StatusDeleted {
+			continue
+		}
+
+		links, err := db.GetUploadLinks(version.ID)
+		if err != nil {
+			log.Printf("Erreur lors de la récupération des liens de l'upload %d: %v", version.ID, err)
+			return
+		}
+		for _, link := range links {
+			if link.Status != storage.LinkStatusDead {
+				embeds = append(embeds, link.Embed)
+			}
+		}
+	}
+
+	ficheID := strapiFicheID(current.TmdbID)
+	if ficheID == "" || len(embeds) == 0 {
+		return
+	}
+
+	if err := strapiClient.ReorderLinks(ficheID, embeds); err != nil {
+		log.Printf("ERREUR lors du classement des liens dans Strapi: %v", err)
+		return
+	}
+
+	log.Printf("Liens de %d versions de %s classés dans Strapi", len(versions), current.Title)
+}
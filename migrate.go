@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"media-upload-system/storage"
+)
+
+// openDatabase ouvre la base de données du moteur configuré
+func openDatabase() (*storage.Database, error) {
+	if cfg.Database.Driver == storage.DriverPostgres {
+		return storage.Open(storage.DriverPostgres, cfg.Database.DSN)
+	}
+	return storage.Open(cfg.Database.Driver, cfg.Database.Path)
+}
+
+// runMigrateCommand exécute la commande migrate:
+//
+//	migrate status       affiche les migrations appliquées et en attente
+//	migrate up           applique toutes les migrations en attente
+//	migrate down [n]     annule les n dernières migrations (1 par défaut)
+//	migrate to <version> amène le schéma à une version précise
+func runMigrateCommand(args []string) error {
+	database, err := openDatabase()
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'ouverture de la base de données: %w", err)
+	}
+	defer database.Close()
+
+	current, err := database.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	command := "status"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "status":
+		statuses, err := database.MigrationStatuses()
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Version du schéma: %d (dernière version: %d)", current, storage.LatestVersion())
+		for _, status := range statuses {
+			state := "en attente"
+			if status.AppliedAt != nil {
+				state = "appliquée le " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			log.Printf("  %3d %-20s %s", status.Version, status.Name, state)
+		}
+		return nil
+	case "up":
+		return database.MigrateToLatest()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("nombre de migrations invalide: %s", args[1])
+			}
+		}
+		if steps > current {
+			steps = current
+		}
+		return database.Migrate(current - steps)
+	case "to":
+		if len(args) < 2 {
+			return fmt.Errorf("version manquante: migrate to <version>")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("version invalide: %s", args[1])
+		}
+		return database.Migrate(version)
+	default:
+		return fmt.Errorf("commande de migration inconnue: %s (status, up, down [n], to <version>)", command)
+	}
+}
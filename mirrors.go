@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"media-upload-system/api"
+	"media-upload-system/storage"
+	"media-upload-system/upload"
+)
+
+// mirrorStatus retourne le statut d'un upload selon la politique de miroirs.
+// Les hébergeurs désactivés ne sont pas exigés, puisqu'aucun miroir ne peut y être créé.
+func mirrorStatus(mirrors map[string]bool) string {
+	if len(mirrors) == 0 {
+		return storage.StatusFailed
+	}
+
+	enabled := make(map[string]bool)
+	for _, uploader := range newUploaders() {
+		if uploader.IsEnabled() {
+			enabled[uploader.Name()] = true
+		}
+	}
+
+	for _, hoster := range cfg.Mirrors.Required {
+		if enabled[hoster] && !mirrors[hoster] {
+			return storage.StatusPartial
+		}
+	}
+
+	// Le minimum ne peut pas dépasser le nombre d'hébergeurs activés
+	minMirrors := cfg.Mirrors.MinMirrors
+	if minMirrors > len(enabled) {
+		minMirrors = len(enabled)
+	}
+	if len(mirrors) < minMirrors {
+		return storage.StatusPartial
+	}
+
+	return storage.StatusCompleted
+}
+
+// uploadMirrors upload le fichier vers les hébergeurs qui n'ont pas encore de miroir, puis
+// met à jour le statut de l'upload selon la politique de miroirs. Retourne tous les liens de
+// l'upload et indique s'ils doivent être publiés: au premier upload, quand de nouveaux miroirs
+// ont été ajoutés, ou quand il n'y avait plus rien à uploader. Les changements sont
+// rattachés à correlationID dans le journal.
+func uploadMirrors(uploadID int64, tmdbID int, filePath, title, label, ficheTitle, correlationID string) ([]api.HostedLink, bool, error) {
+	existingLinks, err := db.GetUploadLinks(uploadID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des liens existants: %v", err)
+	}
+
+	mirrors := make(map[string]bool)
+	var links []api.HostedLink
+	for _, link := range existingLinks {
+		mirrors[link.Hoster] = true
+		links = append(links, api.HostedLink{
+			Hoster:   link.Hoster,
+			URL:      link.URL,
+			Embed:    link.Embed,
+			FileCode: link.FileCode,
+		})
+	}
+
+	missing := 0
+	for _, uploader := range newUploaders() {
+		if uploader.IsEnabled() && !mirrors[uploader.Name()] {
+			missing++
+		}
+	}
+
+	if len(existingLinks) > 0 {
+		log.Printf("%d miroirs existent déjà pour cet upload (ID: %d), %d hébergeurs restants",
+			len(existingLinks), uploadID, missing)
+	}
+
+	var results []*upload.UploadResult
+	attempted := 0
+	if missing > 0 {
+		// Optimiser la mémoire avant les uploads
+		optimizeMemoryForUpload()
+		defer restoreNormalSettings()
+
+		results, attempted = uploadToHosters(uploadID, filePath, title, label, mirrors, func(result *upload.UploadResult) {
+			saveHostedLink(uploadID, tmdbID, ficheTitle, result, correlationID)
+		})
+	}
+
+	for _, result := range results {
+		mirrors[result.Hoster] = true
+		// Lien déjà enregistré en base par saveHostedLink
+		links = append(links, api.HostedLink{
+			Hoster:   result.Hoster,
+			URL:      result.URL,
+			Embed:    result.Embed,
+			FileCode: result.FileCode,
+		})
+	}
+
+	status := mirrorStatus(mirrors)
+
+	// Aucun hébergeur n'a pu être tenté (quotas ou disjoncteurs): l'upload reste partiel
+	// pour être relancé plus tard, sans consommer les tentatives de la tâche
+	if status == storage.StatusFailed && missing > 0 && attempted == 0 {
+		status = storage.StatusPartial
+	}
+
+	if err := db.WithActor(storage.ActorWorker, correlationID).UpdateUploadStatus(uploadID, status); err != nil {
+		return nil, false, fmt.Errorf("erreur lors de la mise à jour du statut: %w", err)
+	}
+
+	switch status {
+	case storage.StatusFailed:
+		return nil, false, fmt.Errorf("aucun upload n'a réussi")
+	case storage.StatusPartial:
+		log.Printf("Upload partiel pour %s %s (ID: %d): %d miroirs, les miroirs manquants seront relancés",
+			label, title, uploadID, len(mirrors))
+	}
+
+	return links, len(results) > 0 || missing == 0, nil
+}
+
+// startMirrorRetries relance périodiquement les miroirs manquants des uploads partiels
+func startMirrorRetries() {
+	interval := time.Duration(cfg.Mirrors.RetryIntervalMinutes) * time.Minute
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			retryMissingMirrors(interval)
+		}
+	}()
+
+	log.Printf("Relance des miroirs manquants démarrée (intervalle: %v)", interval)
+}
+
+// retryMissingMirrors remet en queue, avec leur type de tâche d'origine, les uploads
+// partiels qui n'ont pas été mis à jour depuis delay
+func retryMissingMirrors(delay time.Duration) {
+	uploads, err := db.GetUploadsToRetry(time.Now().Add(-delay), cfg.Mirrors.MaxRetries)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des uploads partiels: %v", err)
+		return
+	}
+
+	for _, existing := range uploads {
+		taskType := "movie_upload"
+		if existing.Type == storage.TypeSeries {
+			taskType = "episode_upload"
+		}
+
+		// Chaque relance est suivie dans le journal sous son propre identifiant
+		correlationID := newCorrelationID()
+		payload := storage.TaskPayload{
+			UploadID:      existing.ID,
+			TmdbID:        existing.TmdbID,
+			Title:         existing.Title,
+			FilePath:      existing.FilePath,
+			Season:        existing.Season,
+			Episode:       existing.Episode,
+			CorrelationID: correlationID,
+		}
+
+		taskID, err := queueManager.AddTask(taskType, payload, 3)
+		if err != nil {
+			log.Printf("Erreur lors de l'ajout de la relance à la queue: %v", err)
+			continue
+		}
+
+		if err := db.WithActor(storage.ActorScheduler, correlationID).MarkUploadForRetry(existing.ID); err != nil {
+			log.Printf("Erreur lors de la relance de l'upload %d: %v", existing.ID, err)
+		}
+
+		log.Printf("Miroirs manquants de %s (ID: %d) relancés (tâche %d)", existing.Title, existing.ID, taskID)
+	}
+}
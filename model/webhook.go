@@ -7,7 +7,10 @@ type RadarrWebhook struct {
 	MovieFile          *MovieFile          `json:"movieFile,omitempty"`
 	Series             *Series             `json:"series,omitempty"`
 	Episodes           []*Episode          `json:"episodes,omitempty"`
+	EpisodeFile        *MovieFile          `json:"episodeFile,omitempty"` // Sonarr, même format que MovieFile
 	IsUpgrade          bool                `json:"isUpgrade"`
+	DeletedFiles       bool                `json:"deletedFiles"`
+	DeleteReason       string              `json:"deleteReason,omitempty"`
 	DownloadClient     string              `json:"downloadClient"`
 	DownloadClientType string              `json:"downloadClientType"`
 	DownloadId         string              `json:"downloadId"`
@@ -50,6 +53,7 @@ type MovieFile struct {
 	Quality        string     `json:"quality"`
 	QualityVersion int        `json:"qualityVersion"`
 	SceneName      string     `json:"sceneName,omitempty"`
+	ReleaseGroup   string     `json:"releaseGroup,omitempty"`
 	IndexerFlags   string     `json:"indexerFlags,omitempty"`
 	Size           int64      `json:"size"`
 	DateAdded      string     `json:"dateAdded"`
@@ -129,6 +133,7 @@ type Season struct {
 // Episode représente un épisode d'une série
 type Episode struct {
 	ID            int    `json:"id"`
+	TvdbId        int    `json:"tvdbId,omitempty"`
 	EpisodeNumber int    `json:"episodeNumber"`
 	SeasonNumber  int    `json:"seasonNumber"`
 	Title         string `json:"title"`
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"media-upload-system/api"
+	"media-upload-system/upload"
+)
+
+// quotaAlerts mémorise le dernier jour où chaque hébergeur a été signalé, pour ne
+// notifier Discord qu'une fois par jour
+var (
+	quotaAlertsMutex sync.Mutex
+	quotaAlerts      = make(map[string]string)
+)
+
+// applyQuotas retire les hébergeurs dont un quota serait dépassé par le fichier et les
+// remplace, si possible, par leur hébergeur de repli
+func applyQuotas(uploaders []upload.Uploader, filePath string, skip map[string]bool) []upload.Uploader {
+	if len(cfg.Quotas) == 0 {
+		return uploaders
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		log.Printf("Impossible de vérifier les quotas, fichier inaccessible: %v", err)
+		return uploaders
+	}
+
+	selected := make(map[string]bool)
+	for _, uploader := range uploaders {
+		selected[uploader.Name()] = true
+	}
+
+	var allowed []upload.Uploader
+	for _, uploader := range uploaders {
+		exceeded, reason := quotaExceeded(uploader, info.Size())
+		if !exceeded {
+			allowed = append(allowed, uploader)
+			continue
+		}
+
+		fallback := quotaFallback(uploader.Name(), info.Size(), selected, skip)
+		if fallback != nil {
+			log.Printf("Quota de %s atteint (%s), upload redirigé vers %s", uploader.Name(), reason, fallback.Name())
+			selected[fallback.Name()] = true
+			allowed = append(allowed, fallback)
+		} else {
+			log.Printf("Quota de %s atteint (%s), hébergeur ignoré", uploader.Name(), reason)
+		}
+
+		notifyQuotaExceeded(uploader.Name(), reason, fallback)
+	}
+
+	return allowed
+}
+
+// quotaExceeded indique si l'envoi de size octets dépasserait un quota de l'hébergeur.
+// Une erreur de l'API de l'hébergeur ne bloque pas l'upload.
+func quotaExceeded(uploader upload.Uploader, size int64) (bool, string) {
+	quota := cfg.Quotas[uploader.Name()]
+
+	if quota.DailyUploadGB > 0 {
+		usage, err := db.GetHosterUsage(uploader.Name(), time.Now().UTC())
+		if err != nil {
+			log.Printf("Erreur lors de la récupération du volume envoyé à %s: %v", uploader.Name(), err)
+		} else if usage.BytesUploaded+size > gigabytes(quota.DailyUploadGB) {
+			return true, fmt.Sprintf("quota journalier de %.0f GB, %.1f GB déjà envoyés aujourd'hui",
+				quota.DailyUploadGB, float64(usage.BytesUploaded)/(1024*1024*1024))
+		}
+	}
+
+	reporter, ok := uploader.(upload.AccountReporter)
+	if !ok {
+		return false, ""
+	}
+
+	usage, err := reporter.AccountUsage()
+	if err != nil {
+		log.Printf("Erreur lors de la récupération de l'utilisation du compte %s: %v", uploader.Name(), err)
+		return false, ""
+	}
+
+	limit := gigabytes(quota.StorageGB)
+	if limit == 0 {
+		limit = usage.StorageLimit
+	}
+
+	if limit > 0 && usage.StorageUsed+size > limit {
+		return true, fmt.Sprintf("stockage de %.1f GB, %.1f GB utilisés",
+			float64(limit)/(1024*1024*1024), float64(usage.StorageUsed)/(1024*1024*1024))
+	}
+
+	return false, ""
+}
+
+// quotaFallback retourne l'hébergeur de repli configuré s'il est activé, pas encore
+// sélectionné et lui-même sous ses quotas
+func quotaFallback(hoster string, size int64, selected, skip map[string]bool) upload.Uploader {
+	name := cfg.Quotas[hoster].Fallback
+	if name == "" || selected[name] || skip[name] {
+		return nil
+	}
+
+	for _, uploader := range newUploaders() {
+		if uploader.Name() != name || !uploader.IsEnabled() {
+			continue
+		}
+
+		if exceeded, _ := quotaExceeded(uploader, size); exceeded {
+			return nil
+		}
+		return uploader
+	}
+
+	return nil
+}
+
+// notifyQuotaExceeded signale à Discord qu'un quota est atteint, une fois par jour et par hébergeur.
+// Le jour est calculé en UTC, comme pour les quotas journaliers.
+func notifyQuotaExceeded(hoster, reason string, fallback upload.Uploader) {
+	today := time.Now().UTC().Format("2006-01-02")
+
+	quotaAlertsMutex.Lock()
+	if quotaAlerts[hoster] == today {
+		quotaAlertsMutex.Unlock()
+		return
+	}
+	quotaAlerts[hoster] = today
+	quotaAlertsMutex.Unlock()
+
+	action := "Uploads ignorés"
+	if fallback != nil {
+		action = fmt.Sprintf("Uploads redirigés vers %s", fallback.Name())
+	}
+
+	fields := []api.DiscordEmbedField{
+		{Name: "Hébergeur", Value: hoster, Inline: true},
+		{Name: "Action", Value: action, Inline: true},
+	}
+	if err := discordClient.NotifyAlert(fmt.Sprintf("📦 Quota atteint: %s", hoster), reason, fields); err != nil {
+		log.Printf("Erreur lors de la notification à Discord: %v", err)
+	}
+}
+
+// recordHosterUsage ajoute la taille du fichier au volume envoyé aujourd'hui à l'hébergeur
+func recordHosterUsage(hoster, filePath string) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		log.Printf("Impossible de comptabiliser le volume envoyé à %s: %v", hoster, err)
+		return
+	}
+
+	if err := db.AddHosterUsage(hoster, time.Now().UTC(), info.Size()); err != nil {
+		log.Printf("Erreur lors de la comptabilisation du volume envoyé: %v", err)
+	}
+}
+
+// gigabytes convertit une taille en GB en octets
+func gigabytes(value float64) int64 {
+	return int64(value * 1024 * 1024 * 1024)
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// UploadAttempt représente une tentative d'upload d'un fichier vers un hébergeur
+type UploadAttempt struct {
+	ID         int64     `json:"id"`
+	UploadID   int64     `json:"upload_id"`
+	Hoster     string    `json:"hoster"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	BytesSent  int64     `json:"bytes_sent"`
+	AvgSpeed   float64   `json:"avg_speed"` // octets par seconde
+	HTTPStatus int       `json:"http_status,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// HosterAttemptStats résume les tentatives d'upload d'un hébergeur sur une période
+type HosterAttemptStats struct {
+	Hoster      string  `json:"hoster"`
+	Attempts    int     `json:"attempts"`
+	Successes   int     `json:"successes"`
+	SuccessRate float64 `json:"success_rate"`
+	BytesSent   int64   `json:"bytes_sent"`
+	AvgSpeed    float64 `json:"avg_speed"` // moyenne des uploads réussis, en octets par seconde
+}
+
+// AddUploadAttempt enregistre une tentative d'upload et calcule sa vitesse moyenne
+func (db *Database) AddUploadAttempt(attempt *UploadAttempt) (int64, error) {
+	if duration := attempt.FinishedAt.Sub(attempt.StartedAt).Seconds(); duration > 0 {
+		attempt.AvgSpeed = float64(attempt.BytesSent) / duration
+	}
+
+	query := `
+	INSERT INTO upload_attempts (upload_id, hoster, started_at, finished_at, bytes_sent, avg_speed, http_status, error)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	id, err := db.insert(
+		query,
+		attempt.UploadID,
+		attempt.Hoster,
+		formatTimestamp(attempt.StartedAt),
+		formatTimestamp(attempt.FinishedAt),
+		attempt.BytesSent,
+		attempt.AvgSpeed,
+		attempt.HTTPStatus,
+		attempt.Error,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors de l'enregistrement de la tentative d'upload: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetUploadAttempts récupère les tentatives d'upload d'un upload, de la plus ancienne à la plus récente
+func (db *Database) GetUploadAttempts(uploadID int64) ([]UploadAttempt, error) {
+	query := `
+	SELECT id, upload_id, hoster, started_at, finished_at, bytes_sent, avg_speed, http_status, error
+	FROM upload_attempts
+	WHERE upload_id = ?
+	ORDER BY started_at ASC, id ASC
+	`
+
+	rows, err := db.query(query, uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la récupération des tentatives d'upload: %w", err)
+	}
+	defer rows.Close()
+
+	return scanUploadAttempts(rows)
+}
+
+// GetRecentUploadAttempts récupère les dernières tentatives d'upload, éventuellement filtrées par hébergeur
+func (db *Database) GetRecentUploadAttempts(hoster string, limit int) ([]UploadAttempt, error) {
+	query := `
+	SELECT id, upload_id, hoster, started_at, finished_at, bytes_sent, avg_speed, http_status, error
+	FROM upload_attempts
+	WHERE ? = '' OR hoster = ?
+	ORDER BY started_at DESC, id DESC
+	LIMIT ?
+	`
+
+	rows, err := db.query(query, hoster, hoster, limit)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la récupération des tentatives d'upload: %w", err)
+	}
+	defer rows.Close()
+
+	return scanUploadAttempts(rows)
+}
+
+// GetHosterAttemptStats calcule, pour chaque hébergeur, la fiabilité et la vitesse des uploads depuis une date
+func (db *Database) GetHosterAttemptStats(since time.Time) ([]HosterAttemptStats, error) {
+	query := `
+	SELECT
+		hoster,
+		COUNT(*),
+		SUM(CASE WHEN error = '' THEN 1 ELSE 0 END),
+		SUM(bytes_sent),
+		COALESCE(AVG(CASE WHEN error = '' AND bytes_sent > 0 THEN avg_speed END), 0)
+	FROM upload_attempts
+	WHERE started_at >= ?
+	GROUP BY hoster
+	ORDER BY hoster
+	`
+
+	rows, err := db.query(query, formatTimestamp(since))
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors du calcul des statistiques d'upload: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []HosterAttemptStats
+	for rows.Next() {
+		var stat HosterAttemptStats
+		if err := rows.Scan(&stat.Hoster, &stat.Attempts, &stat.Successes, &stat.BytesSent, &stat.AvgSpeed); err != nil {
+			return nil, err
+		}
+
+		if stat.Attempts > 0 {
+			stat.SuccessRate = float64(stat.Successes) / float64(stat.Attempts)
+		}
+
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
+
+// scanUploadAttempts lit les tentatives d'upload retournées par une requête
+func scanUploadAttempts(rows *sql.Rows) ([]UploadAttempt, error) {
+	var attempts []UploadAttempt
+	for rows.Next() {
+		var attempt UploadAttempt
+		var startedAt, finishedAt timestamp
+
+		err := rows.Scan(
+			&attempt.ID,
+			&attempt.UploadID,
+			&attempt.Hoster,
+			&startedAt,
+			&finishedAt,
+			&attempt.BytesSent,
+			&attempt.AvgSpeed,
+			&attempt.HTTPStatus,
+			&attempt.Error,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		attempt.StartedAt = startedAt.Time
+		attempt.FinishedAt = finishedAt.Time
+		attempts = append(attempts, attempt)
+	}
+
+	return attempts, rows.Err()
+}
@@ -0,0 +1,384 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Media est un film ou une série, identifié par TMDB et, quand ils sont connus, par TVDB et IMDb
+type Media struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	TmdbID    int       `json:"tmdb_id"`
+	TvdbID    int       `json:"tvdb_id,omitempty"`
+	ImdbID    string    `json:"imdb_id,omitempty"`
+	Title     string    `json:"title"`
+	Year      int       `json:"year,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Season est une saison d'une série. EpisodeCount est le nombre d'épisodes annoncé par TMDB,
+// 0 s'il n'est pas connu. CompletedAt est la date à laquelle la saison a été complète.
+type Season struct {
+	ID           int64      `json:"id"`
+	MediaID      int64      `json:"media_id"`
+	Number       int        `json:"number"`
+	EpisodeCount int        `json:"episode_count"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	// Episodes compte les épisodes connus, Uploaded ceux qui ont un upload disponible
+	Episodes int `json:"episodes"`
+	Uploaded int `json:"uploaded"`
+}
+
+// Complete indique si tous les épisodes annoncés de la saison ont un upload disponible
+func (s Season) Complete() bool {
+	return s.EpisodeCount > 0 && s.Uploaded >= s.EpisodeCount
+}
+
+// Episode est un épisode d'une saison. Uploaded indique qu'il a un upload disponible.
+type Episode struct {
+	ID           int64     `json:"id"`
+	SeasonID     int64     `json:"season_id"`
+	MediaID      int64     `json:"media_id"`
+	SeasonNumber int       `json:"season_number"`
+	Number       int       `json:"number"`
+	Title        string    `json:"title,omitempty"`
+	AirDate      string    `json:"air_date,omitempty"`
+	TvdbID       int       `json:"tvdb_id,omitempty"`
+	Uploaded     bool      `json:"uploaded"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// availableUpload est la condition des uploads dont les liens sont publiés: terminés ou
+// partiels, et non remplacés par une nouvelle version
+const availableUpload = "uploads.upload_status IN ('" + StatusCompleted + "', '" + StatusPartial + "') AND uploads.superseded_by IS NULL"
+
+// ensureMedia retourne l'ID du film ou de la série, créé s'il n'existe pas encore
+func ensureMedia(tx *Tx, mediaType string, tmdbID int, title string) (int64, error) {
+	_, err := tx.Exec(`
+		INSERT INTO media (type, tmdb_id, title) VALUES (?, ?, ?)
+		ON CONFLICT (type, tmdb_id) DO NOTHING
+	`, mediaType, tmdbID, title)
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors de la création du média: %w", err)
+	}
+
+	var id int64
+	err = tx.QueryRow("SELECT id FROM media WHERE type = ? AND tmdb_id = ?", mediaType, tmdbID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors de la récupération du média: %w", err)
+	}
+
+	return id, nil
+}
+
+// ensureSeason retourne l'ID de la saison d'une série, créée si elle n'existe pas encore
+func ensureSeason(tx *Tx, mediaID int64, number int) (int64, error) {
+	_, err := tx.Exec(`
+		INSERT INTO seasons (media_id, number) VALUES (?, ?)
+		ON CONFLICT (media_id, number) DO NOTHING
+	`, mediaID, number)
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors de la création de la saison: %w", err)
+	}
+
+	var id int64
+	err = tx.QueryRow("SELECT id FROM seasons WHERE media_id = ? AND number = ?", mediaID, number).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors de la récupération de la saison: %w", err)
+	}
+
+	return id, nil
+}
+
+// ensureEpisode retourne l'ID de l'épisode d'une série, créé avec sa saison s'il n'existe pas encore
+func ensureEpisode(tx *Tx, mediaID int64, season, number int) (int64, error) {
+	seasonID, err := ensureSeason(tx, mediaID, season)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO episodes (season_id, number) VALUES (?, ?)
+		ON CONFLICT (season_id, number) DO NOTHING
+	`, seasonID, number)
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors de la création de l'épisode: %w", err)
+	}
+
+	var id int64
+	err = tx.QueryRow("SELECT id FROM episodes WHERE season_id = ? AND number = ?", seasonID, number).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors de la récupération de l'épisode: %w", err)
+	}
+
+	return id, nil
+}
+
+// SaveMedia crée le film ou la série s'il n'existe pas encore et complète ses identifiants,
+// son titre et son année. Les valeurs vides ne remplacent pas celles déjà connues.
+func (db *Database) SaveMedia(media *Media) (int64, error) {
+	var id int64
+	err := db.inTx(func(tx *Tx) error {
+		var err error
+		id, err = ensureMedia(tx, media.Type, media.TmdbID, media.Title)
+		if err != nil {
+			return err
+		}
+
+		var sets []string
+		var args []interface{}
+		if media.TvdbID != 0 {
+			sets = append(sets, "tvdb_id = ?")
+			args = append(args, media.TvdbID)
+		}
+		if media.ImdbID != "" {
+			sets = append(sets, "imdb_id = ?")
+			args = append(args, media.ImdbID)
+		}
+		if media.Title != "" {
+			sets = append(sets, "title = ?")
+			args = append(args, media.Title)
+		}
+		if media.Year != 0 {
+			sets = append(sets, "year = ?")
+			args = append(args, media.Year)
+		}
+		if len(sets) == 0 {
+			return nil
+		}
+
+		query := "UPDATE media SET " + strings.Join(sets, ", ") + ", updated_at = CURRENT_TIMESTAMP WHERE id = ?"
+		_, err = tx.Exec(query, append(args, id)...)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors de l'enregistrement du média: %w", err)
+	}
+
+	media.ID = id
+	return id, nil
+}
+
+// SaveEpisode crée l'épisode de la série MediaID, avec sa saison, s'il n'existe pas encore
+// et complète son titre, sa date de diffusion et son ID TVDB
+func (db *Database) SaveEpisode(episode *Episode) (int64, error) {
+	var id int64
+	err := db.inTx(func(tx *Tx) error {
+		var err error
+		id, err = ensureEpisode(tx, episode.MediaID, episode.SeasonNumber, episode.Number)
+		if err != nil {
+			return err
+		}
+
+		var sets []string
+		var args []interface{}
+		if episode.Title != "" {
+			sets = append(sets, "title = ?")
+			args = append(args, episode.Title)
+		}
+		if episode.AirDate != "" {
+			sets = append(sets, "air_date = ?")
+			args = append(args, episode.AirDate)
+		}
+		if episode.TvdbID != 0 {
+			sets = append(sets, "tvdb_id = ?")
+			args = append(args, episode.TvdbID)
+		}
+		if len(sets) == 0 {
+			return nil
+		}
+
+		_, err = tx.Exec("UPDATE episodes SET "+strings.Join(sets, ", ")+" WHERE id = ?", append(args, id)...)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors de l'enregistrement de l'épisode: %w", err)
+	}
+
+	episode.ID = id
+	return id, nil
+}
+
+// mediaColumns sont les colonnes lues par scanMedia
+const mediaColumns = "id, type, tmdb_id, tvdb_id, imdb_id, title, year, created_at, updated_at"
+
+// GetMedia récupère un film ou une série par son ID
+func (db *Database) GetMedia(id int64) (*Media, error) {
+	return scanMedia(db.queryRow("SELECT "+mediaColumns+" FROM media WHERE id = ?", id))
+}
+
+// GetMediaByTmdbID récupère un film ou une série par son ID TMDB, ou nil s'il n'existe pas
+func (db *Database) GetMediaByTmdbID(mediaType string, tmdbID int) (*Media, error) {
+	media, err := scanMedia(db.queryRow("SELECT "+mediaColumns+" FROM media WHERE type = ? AND tmdb_id = ?", mediaType, tmdbID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return media, nil
+}
+
+// GetSeasons récupère les saisons d'une série avec le nombre d'épisodes connus et uploadés
+func (db *Database) GetSeasons(mediaID int64) ([]Season, error) {
+	query := `
+		SELECT seasons.id, seasons.media_id, seasons.number, seasons.episode_count,
+			seasons.completed_at, seasons.created_at,
+			(SELECT COUNT(*) FROM episodes WHERE episodes.season_id = seasons.id),
+			(
+				SELECT COUNT(DISTINCT uploads.episode_id) FROM uploads
+				JOIN episodes ON episodes.id = uploads.episode_id
+				WHERE episodes.season_id = seasons.id AND ` + availableUpload + `
+			)
+		FROM seasons
+		WHERE seasons.media_id = ?
+		ORDER BY seasons.number
+	`
+
+	rows, err := db.query(query, mediaID)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la récupération des saisons: %w", err)
+	}
+	defer rows.Close()
+
+	var seasons []Season
+	for rows.Next() {
+		var season Season
+		var completedAt timestamp
+		var createdAt timestamp
+
+		err := rows.Scan(
+			&season.ID,
+			&season.MediaID,
+			&season.Number,
+			&season.EpisodeCount,
+			&completedAt,
+			&createdAt,
+			&season.Episodes,
+			&season.Uploaded,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		season.CompletedAt = completedAt.ptr()
+		season.CreatedAt = createdAt.Time
+		seasons = append(seasons, season)
+	}
+
+	return seasons, rows.Err()
+}
+
+// GetEpisodes récupère les épisodes connus d'une saison d'une série
+func (db *Database) GetEpisodes(mediaID int64, season int) ([]Episode, error) {
+	query := `
+		SELECT episodes.id, episodes.season_id, seasons.media_id, seasons.number, episodes.number,
+			episodes.title, episodes.air_date, episodes.tvdb_id, episodes.created_at,
+			EXISTS (SELECT 1 FROM uploads WHERE uploads.episode_id = episodes.id AND ` + availableUpload + `)
+		FROM episodes
+		JOIN seasons ON seasons.id = episodes.season_id
+		WHERE seasons.media_id = ? AND seasons.number = ?
+		ORDER BY episodes.number
+	`
+
+	rows, err := db.query(query, mediaID, season)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la récupération des épisodes: %w", err)
+	}
+	defer rows.Close()
+
+	var episodes []Episode
+	for rows.Next() {
+		var episode Episode
+		var createdAt timestamp
+
+		err := rows.Scan(
+			&episode.ID,
+			&episode.SeasonID,
+			&episode.MediaID,
+			&episode.SeasonNumber,
+			&episode.Number,
+			&episode.Title,
+			&episode.AirDate,
+			&episode.TvdbID,
+			&createdAt,
+			&episode.Uploaded,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		episode.CreatedAt = createdAt.Time
+		episodes = append(episodes, episode)
+	}
+
+	return episodes, rows.Err()
+}
+
+// SetSeasonEpisodeCount enregistre le nombre d'épisodes annoncé d'une saison
+func (db *Database) SetSeasonEpisodeCount(mediaID int64, season, count int) error {
+	query := `
+		UPDATE seasons
+		SET episode_count = ?
+		WHERE media_id = ? AND number = ?
+	`
+
+	if _, err := db.exec(query, count, mediaID, season); err != nil {
+		return fmt.Errorf("erreur lors de la mise à jour de la saison: %w", err)
+	}
+
+	return nil
+}
+
+// MarkSeasonCompleted enregistre la date à laquelle une saison est devenue complète.
+// Retourne false si elle l'était déjà, pour ne la signaler qu'une fois.
+func (db *Database) MarkSeasonCompleted(seasonID int64) (bool, error) {
+	query := `
+		UPDATE seasons
+		SET completed_at = ?
+		WHERE id = ? AND completed_at IS NULL
+	`
+
+	result, err := db.exec(query, formatTimestamp(time.Now()), seasonID)
+	if err != nil {
+		return false, fmt.Errorf("erreur lors de la mise à jour de la saison: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// scanMedia lit un film ou une série
+func scanMedia(row rowScanner) (*Media, error) {
+	var media Media
+	var createdAt, updatedAt timestamp
+
+	err := row.Scan(
+		&media.ID,
+		&media.Type,
+		&media.TmdbID,
+		&media.TvdbID,
+		&media.ImdbID,
+		&media.Title,
+		&media.Year,
+		&createdAt,
+		&updatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	media.CreatedAt = createdAt.Time
+	media.UpdatedAt = updatedAt.Time
+	return &media, nil
+}
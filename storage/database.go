@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -20,7 +22,15 @@ const (
 	StatusPending   = "pending"
 	StatusUploading = "uploading"
 	StatusCompleted = "completed"
+	StatusPartial   = "partial" // certains miroirs manquent encore
 	StatusFailed    = "failed"
+	StatusDeleted   = "deleted" // fichiers supprimés des hébergeurs
+)
+
+// Statuts des liens hébergés
+const (
+	LinkStatusActive = "active"
+	LinkStatusDead   = "dead"
 )
 
 // QueueStatus représente l'état d'une tâche dans la queue
@@ -54,212 +64,320 @@ type TaskPayload struct {
 	FilePath string `json:"file_path"`
 	Season   *int   `json:"season,omitempty"`
 	Episode  *int   `json:"episode,omitempty"`
+	Hoster   string `json:"hoster,omitempty"`
+	LinkID   int64  `json:"link_id,omitempty"`
+	// ReplacesUploadID est la version remplacée par une mise à niveau, une fois les nouveaux miroirs en ligne
+	ReplacesUploadID int64 `json:"replaces_upload_id,omitempty"`
+	// CorrelationID relie les changements de la tâche à l'événement qui l'a créée
+	CorrelationID string `json:"correlation_id,omitempty"`
 }
 
-// Database représente une connexion à la base de données
+// Database représente une connexion à la base de données SQLite ou PostgreSQL
 type Database struct {
-	db *sql.DB
+	db      *sql.DB
+	path    string
+	driver  string
+	dialect dialect
+	// actor et correlationID sont enregistrés dans le journal avec les changements, voir WithActor
+	actor         string
+	correlationID string
 }
 
 // Upload représente un upload en cours ou terminé
 type Upload struct {
-	ID           int64
-	TmdbID       int
-	Title        string
-	Type         string
-	Season       *int
-	Episode      *int
-	FilePath     string
-	UploadStatus string
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	ID      int64  `json:"id"`
+	TmdbID  int    `json:"tmdb_id"`
+	Title   string `json:"title"`
+	Type    string `json:"type"`
+	Season  *int   `json:"season,omitempty"`
+	Episode *int   `json:"episode,omitempty"`
+	// MediaID et EpisodeID relient l'upload au film ou à la série et à l'épisode, créés
+	// au besoin par AddUpload
+	MediaID      int64  `json:"media_id,omitempty"`
+	EpisodeID    *int64 `json:"episode_id,omitempty"`
+	FilePath     string `json:"file_path"`
+	UploadStatus string `json:"upload_status"`
+	// MirrorRetries compte les relances des miroirs manquants
+	MirrorRetries int         `json:"mirror_retries"`
+	Media         UploadMedia `json:"media"`
+	// Fingerprint est l'empreinte rapide du contenu du fichier, ContentSHA256 son empreinte
+	// complète quand elle a été calculée
+	Fingerprint   string `json:"fingerprint,omitempty"`
+	ContentSHA256 string `json:"content_sha256,omitempty"`
+	// Version numérote les uploads successifs d'un même film ou épisode, à partir de 1
+	Version int `json:"version"`
+	// SupersededBy est l'upload de la version qui a remplacé celle-ci
+	SupersededBy *int64    `json:"superseded_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
 // HostedLink représente un lien vers un fichier hébergé
 type HostedLink struct {
-	ID        int64
-	UploadID  int64
-	Hoster    string
-	FileCode  string
-	URL       string
-	Embed     string
-	CreatedAt time.Time
+	ID            int64      `json:"id"`
+	UploadID      int64      `json:"upload_id"`
+	Hoster        string     `json:"hoster"`
+	FileCode      string     `json:"file_code"`
+	URL           string     `json:"url"`
+	Embed         string     `json:"embed"`
+	Status        string     `json:"status"`
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+	FailureCount  int        `json:"failure_count"`
+	CreatedAt     time.Time  `json:"created_at"`
 }
 
-// NewDatabase crée une nouvelle instance de la base de données
-func NewDatabase(path string) (*Database, error) {
-	db, err := sql.Open("sqlite3", path)
-	if err != nil {
-		return nil, err
+// uploadColumns sont les colonnes lues par scanUpload
+const uploadColumns = `id, tmdb_id, title, type, season, episode, media_id, episode_id, file_path, upload_status, mirror_retries,
+	quality, quality_version, size, width, height, video_codec, video_dynamic_range,
+	audio_codec, audio_channels, audio_languages, subtitle_languages, languages,
+	release_group, release_title, indexer, download_client, download_id, custom_format_score,
+	fingerprint, content_sha256, version, superseded_by, created_at, updated_at`
+
+// mediaFilter retourne la condition qui sélectionne les uploads d'un film ou d'un épisode,
+// et ses paramètres. Un film n'a ni saison ni épisode.
+func mediaFilter(tmdbID int, mediaType string, season, episode *int) (string, []interface{}) {
+	if season == nil || episode == nil {
+		return "tmdb_id = ? AND type = ? AND season IS NULL AND episode IS NULL",
+			[]interface{}{tmdbID, mediaType}
+	}
+	return "tmdb_id = ? AND type = ? AND season = ? AND episode = ?",
+		[]interface{}{tmdbID, mediaType, *season, *episode}
+}
+
+// Open ouvre la base de données du moteur indiqué: un chemin de fichier pour SQLite,
+// une chaîne de connexion pour PostgreSQL
+func Open(driver, source string) (*Database, error) {
+	switch driver {
+	case "", DriverSQLite:
+		return NewDatabase(source)
+	case DriverPostgres:
+		return NewPostgresDatabase(source)
+	default:
+		return nil, fmt.Errorf("moteur de base de données inconnu: %s", driver)
+	}
+}
+
+// Réglages des connexions SQLite
+const (
+	// sqliteBusyTimeout est le temps d'attente, en millisecondes, d'une écriture bloquée
+	// par une autre avant l'erreur "database is locked"
+	sqliteBusyTimeout = 5000
+	// sqliteMaxOpenConns limite les connexions: en WAL les lectures sont parallèles,
+	// mais les écritures restent sérialisées
+	sqliteMaxOpenConns = 4
+)
+
+// sqliteDSN ajoute au chemin de la base les réglages appliqués à chaque connexion:
+// journal WAL, attente des verrous, foreign keys et transactions qui réservent
+// l'écriture dès leur début pour ne pas échouer sur un verrou en cours de route
+func sqliteDSN(path string) string {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
 	}
 
-	// Activer les foreign keys
-	_, err = db.Exec("PRAGMA foreign_keys = ON")
+	params := fmt.Sprintf("_busy_timeout=%d&_foreign_keys=on&_txlock=immediate", sqliteBusyTimeout)
+	if !isMemoryPath(path) {
+		params += "&_journal_mode=WAL&_synchronous=NORMAL"
+	}
+
+	return path + separator + params
+}
+
+// isMemoryPath indique si le chemin désigne une base en mémoire
+func isMemoryPath(path string) bool {
+	return path == "" || strings.Contains(path, ":memory:") || strings.Contains(path, "mode=memory")
+}
+
+// NewDatabase ouvre la base de données SQLite, sans modifier son schéma.
+// Migrate doit être appelé avant d'utiliser une base qui n'est pas à jour.
+func NewDatabase(path string) (*Database, error) {
+	db, err := sql.Open("sqlite3", sqliteDSN(path))
 	if err != nil {
 		return nil, err
 	}
 
-	database := &Database{
-		db: db,
+	// Chaque connexion à une base en mémoire ouvre une base différente
+	if isMemoryPath(path) {
+		db.SetMaxOpenConns(1)
+	} else {
+		db.SetMaxOpenConns(sqliteMaxOpenConns)
+		db.SetMaxIdleConns(sqliteMaxOpenConns)
 	}
 
-	// Initialiser les tables
-	if err := database.InitTables(); err != nil {
+	// Vérifier que la base est accessible et que les réglages sont valides
+	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	// Initialiser la table de queue
-	if err := database.InitQueueTable(); err != nil {
+	database := &Database{
+		db:      db,
+		path:    path,
+		driver:  DriverSQLite,
+		dialect: sqliteDialect{},
+	}
+
+	// Initialiser la table des versions du schéma
+	if err := database.initMigrationsTable(); err != nil {
 		return nil, err
 	}
 
 	return database, nil
 }
 
+// Driver retourne le moteur de la base de données
+func (db *Database) Driver() string {
+	return db.driver
+}
+
 // Close ferme la connexion à la base de données
 func (db *Database) Close() error {
 	return db.db.Close()
 }
 
-// InitTables initialise les tables de la base de données
-func (db *Database) InitTables() error {
-	// Table des uploads
-	_, err := db.db.Exec(`
-		CREATE TABLE IF NOT EXISTS uploads (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			tmdb_id INTEGER NOT NULL,
-			title TEXT NOT NULL,
-			type TEXT NOT NULL,
-			season INTEGER,
-			episode INTEGER,
-			file_path TEXT NOT NULL,
-			upload_status TEXT NOT NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Table des liens hébergés
-	_, err = db.db.Exec(`
-		CREATE TABLE IF NOT EXISTS hosted_links (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			upload_id INTEGER NOT NULL,
-			hoster TEXT NOT NULL,
-			file_code TEXT NOT NULL,
-			url TEXT NOT NULL,
-			embed TEXT NOT NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (upload_id) REFERENCES uploads (id) ON DELETE CASCADE
-		)
-	`)
-	if err != nil {
-		return err
-	}
+// AddUpload ajoute un nouvel upload à la base de données, comme nouvelle version du film
+// ou de l'épisode. Le film, ou la série, la saison et l'épisode sont créés s'ils n'existent
+// pas encore. Version, MediaID et EpisodeID sont renseignés.
+func (db *Database) AddUpload(upload *Upload) (int64, error) {
+	var id, mediaID int64
+	var episodeID *int64
+	var version int
+	err := db.inTx(func(tx *Tx) error {
+		filter, args := mediaFilter(upload.TmdbID, upload.Type, upload.Season, upload.Episode)
+		err := tx.QueryRow("SELECT COALESCE(MAX(version), 0) + 1 FROM uploads WHERE "+filter, args...).Scan(&version)
+		if err != nil {
+			return fmt.Errorf("erreur lors du calcul de la version de l'upload: %w", err)
+		}
 
-	return nil
-}
+		mediaID, err = ensureMedia(tx, upload.Type, upload.TmdbID, upload.Title)
+		if err != nil {
+			return err
+		}
+		if upload.Season != nil && upload.Episode != nil {
+			episode, err := ensureEpisode(tx, mediaID, *upload.Season, *upload.Episode)
+			if err != nil {
+				return err
+			}
+			episodeID = &episode
+		}
 
-// InitQueueTable initialise la table de queue dans la base de données
-func (db *Database) InitQueueTable() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS queue (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		type TEXT NOT NULL,
-		payload TEXT NOT NULL,
-		status TEXT NOT NULL,
-		attempts INTEGER NOT NULL DEFAULT 0,
-		max_attempts INTEGER NOT NULL DEFAULT 3,
-		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		processed_at TIMESTAMP
-	)`
-
-	_, err := db.db.Exec(query)
-	return err
-}
-
-// AddUpload ajoute un nouvel upload à la base de données
-func (db *Database) AddUpload(upload *Upload) (int64, error) {
-	query := `
-		INSERT INTO uploads (
-			tmdb_id, title, type, season, episode, file_path, upload_status, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
-	`
+		query := `
+			INSERT INTO uploads (
+				tmdb_id, title, type, season, episode, media_id, episode_id, file_path, upload_status,
+				quality, quality_version, size, width, height, video_codec, video_dynamic_range,
+				audio_codec, audio_channels, audio_languages, subtitle_languages, languages,
+				release_group, release_title, indexer, download_client, download_id, custom_format_score,
+				fingerprint, content_sha256, version, updated_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		`
 
-	result, err := db.db.Exec(
-		query,
-		upload.TmdbID,
-		upload.Title,
-		upload.Type,
-		upload.Season,
-		upload.Episode,
-		upload.FilePath,
-		upload.UploadStatus,
-	)
+		media := upload.Media
+		id, err = tx.insert(
+			query,
+			upload.TmdbID,
+			upload.Title,
+			upload.Type,
+			upload.Season,
+			upload.Episode,
+			mediaID,
+			episodeID,
+			upload.FilePath,
+			upload.UploadStatus,
+			media.Quality,
+			media.QualityVersion,
+			media.Size,
+			media.Width,
+			media.Height,
+			media.VideoCodec,
+			media.VideoDynamicRange,
+			media.AudioCodec,
+			media.AudioChannels,
+			stringList(media.AudioLanguages),
+			stringList(media.SubtitleLanguages),
+			stringList(media.Languages),
+			media.ReleaseGroup,
+			media.ReleaseTitle,
+			media.Indexer,
+			media.DownloadClient,
+			media.DownloadID,
+			media.CustomFormatScore,
+			upload.Fingerprint,
+			upload.ContentSHA256,
+			version,
+		)
+		if err != nil {
+			return err
+		}
+
+		return db.recordEvent(tx, Event{
+			Entity:   EntityUpload,
+			EntityID: id,
+			UploadID: id,
+			TmdbID:   upload.TmdbID,
+			Action:   ActionCreated,
+			NewValue: upload.UploadStatus,
+		})
+	})
 	if err != nil {
 		return 0, err
 	}
 
-	return result.LastInsertId()
+	upload.Version = version
+	upload.MediaID = mediaID
+	upload.EpisodeID = episodeID
+	return id, nil
 }
 
 // GetUpload récupère un upload par son ID
 func (db *Database) GetUpload(id int64) (*Upload, error) {
 	query := `
-		SELECT id, tmdb_id, title, type, season, episode, file_path, upload_status, created_at, updated_at
+		SELECT ` + uploadColumns + `
 		FROM uploads
 		WHERE id = ?
 	`
 
-	var upload Upload
-	var createdAt, updatedAt string
-	var season, episode sql.NullInt64
-
-	err := db.db.QueryRow(query, id).Scan(
-		&upload.ID,
-		&upload.TmdbID,
-		&upload.Title,
-		&upload.Type,
-		&season,
-		&episode,
-		&upload.FilePath,
-		&upload.UploadStatus,
-		&createdAt,
-		&updatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	if season.Valid {
-		s := int(season.Int64)
-		upload.Season = &s
-	}
-
-	if episode.Valid {
-		e := int(episode.Int64)
-		upload.Episode = &e
-	}
-
-	upload.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAt)
-	upload.UpdatedAt, _ = time.Parse("2006-01-02 15:04:05", updatedAt)
-
-	return &upload, nil
+	return scanUpload(db.queryRow(query, id))
 }
 
 // UpdateUploadStatus met à jour le statut d'un upload
 func (db *Database) UpdateUploadStatus(id int64, status string) error {
-	query := `
-		UPDATE uploads
-		SET upload_status = ?, updated_at = CURRENT_TIMESTAMP
-		WHERE id = ?
-	`
+	return db.updateUpload(id, "upload_status", status, ActionStatus)
+}
+
+// updateUpload modifie une colonne texte d'un upload et enregistre le changement dans le journal
+func (db *Database) updateUpload(id int64, column, value, action string) error {
+	return db.inTx(func(tx *Tx) error {
+		var old string
+		err := tx.QueryRow(fmt.Sprintf("SELECT %s FROM uploads WHERE id = ?", column), id).Scan(&old)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
 
-	_, err := db.db.Exec(query, status, id)
-	return err
+		query := fmt.Sprintf(`
+			UPDATE uploads
+			SET %s = ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?
+		`, column)
+		if _, err := tx.Exec(query, value, id); err != nil {
+			return err
+		}
+
+		if old == value {
+			return nil
+		}
+		return db.recordEvent(tx, Event{
+			Entity:   EntityUpload,
+			EntityID: id,
+			UploadID: id,
+			Action:   action,
+			OldValue: old,
+			NewValue: value,
+		})
+	})
 }
 
 // Fonction pour vérifier si un lien existe déjà
@@ -271,7 +389,7 @@ func (db *Database) CheckLinkExists(uploadID int64, hoster, fileCode string) (bo
 	`
 
 	var count int
-	err := db.db.QueryRow(query, uploadID, hoster, fileCode).Scan(&count)
+	err := db.queryRow(query, uploadID, hoster, fileCode).Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -299,36 +417,54 @@ func (db *Database) AddUploadLink(uploadID int64, link HostedLink) error {
 		) VALUES (?, ?, ?, ?, ?)
 	`
 
-	_, err = db.db.Exec(
-		query,
-		uploadID,
-		link.Hoster,
-		link.FileCode,
-		link.URL,
-		link.Embed,
-	)
-	return err
+	return db.inTx(func(tx *Tx) error {
+		id, err := tx.insert(
+			query,
+			uploadID,
+			link.Hoster,
+			link.FileCode,
+			link.URL,
+			link.Embed,
+		)
+		if err != nil {
+			return err
+		}
+
+		return db.recordEvent(tx, Event{
+			Entity:   EntityLink,
+			EntityID: id,
+			UploadID: uploadID,
+			Action:   ActionCreated,
+			NewValue: link.URL,
+		})
+	})
 }
 
 // GetUploadLinks récupère tous les liens hébergés pour un upload
 func (db *Database) GetUploadLinks(uploadID int64) ([]HostedLink, error) {
 	query := `
-		SELECT id, upload_id, hoster, file_code, url, embed, created_at
+		SELECT id, upload_id, hoster, file_code, url, embed, status, last_checked_at, failure_count, created_at
 		FROM hosted_links
 		WHERE upload_id = ?
 		ORDER BY created_at DESC
 	`
 
-	rows, err := db.db.Query(query, uploadID)
+	rows, err := db.query(query, uploadID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return scanHostedLinks(rows)
+}
+
+// scanHostedLinks lit les liens hébergés retournés par une requête
+func scanHostedLinks(rows *sql.Rows) ([]HostedLink, error) {
 	var links []HostedLink
 	for rows.Next() {
 		var link HostedLink
-		var createdAt string
+		var createdAt timestamp
+		var lastCheckedAt timestamp
 
 		err := rows.Scan(
 			&link.ID,
@@ -337,62 +473,221 @@ func (db *Database) GetUploadLinks(uploadID int64) ([]HostedLink, error) {
 			&link.FileCode,
 			&link.URL,
 			&link.Embed,
+			&link.Status,
+			&lastCheckedAt,
+			&link.FailureCount,
 			&createdAt,
 		)
 		if err != nil {
 			return nil, err
 		}
 
-		link.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAt)
+		link.CreatedAt = createdAt.Time
+		link.LastCheckedAt = lastCheckedAt.ptr()
+
 		links = append(links, link)
 	}
 
-	return links, nil
+	return links, rows.Err()
 }
 
-// CheckExistingUpload vérifie si un média a déjà été uploadé
-func (db *Database) CheckExistingUpload(tmdbID int, mediaType string, season, episode *int) (*Upload, error) {
-	var query string
-	var args []interface{}
+// GetHostedLink récupère un lien hébergé par son ID
+func (db *Database) GetHostedLink(id int64) (*HostedLink, error) {
+	query := `
+		SELECT id, upload_id, hoster, file_code, url, embed, status, last_checked_at, failure_count, created_at
+		FROM hosted_links
+		WHERE id = ?
+	`
+
+	rows, err := db.query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links, err := scanHostedLinks(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(links) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return &links[0], nil
+}
+
+// ReplaceUploadLink remplace le fichier d'un lien hébergé (après un nouvel upload) et le réactive
+func (db *Database) ReplaceUploadLink(id int64, link HostedLink) error {
+	query := `
+		UPDATE hosted_links
+		SET file_code = ?, url = ?, embed = ?, status = ?, failure_count = 0, last_checked_at = NULL
+		WHERE id = ?
+	`
+
+	err := db.inTx(func(tx *Tx) error {
+		var uploadID int64
+		var oldURL string
+		err := tx.QueryRow("SELECT upload_id, url FROM hosted_links WHERE id = ?", id).Scan(&uploadID, &oldURL)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(query, link.FileCode, link.URL, link.Embed, LinkStatusActive, id); err != nil {
+			return err
+		}
+
+		return db.recordEvent(tx, Event{
+			Entity:   EntityLink,
+			EntityID: id,
+			UploadID: uploadID,
+			Action:   ActionReplaced,
+			OldValue: oldURL,
+			NewValue: link.URL,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("erreur lors du remplacement du lien: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteUploadLink supprime un lien hébergé
+func (db *Database) DeleteUploadLink(id int64) error {
+	err := db.inTx(func(tx *Tx) error {
+		var uploadID int64
+		var oldURL string
+		err := tx.QueryRow("SELECT upload_id, url FROM hosted_links WHERE id = ?", id).Scan(&uploadID, &oldURL)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM hosted_links WHERE id = ?", id); err != nil {
+			return err
+		}
+
+		return db.recordEvent(tx, Event{
+			Entity:   EntityLink,
+			EntityID: id,
+			UploadID: uploadID,
+			Action:   ActionDeleted,
+			OldValue: oldURL,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("erreur lors de la suppression du lien: %w", err)
+	}
+
+	return nil
+}
+
+// GetLinksToCheck récupère les liens actifs qui n'ont pas été vérifiés depuis la date donnée
+func (db *Database) GetLinksToCheck(checkedBefore time.Time, limit int) ([]HostedLink, error) {
+	query := `
+		SELECT id, upload_id, hoster, file_code, url, embed, status, last_checked_at, failure_count, created_at
+		FROM hosted_links
+		WHERE status = ? AND (last_checked_at IS NULL OR last_checked_at < ?)
+		ORDER BY last_checked_at IS NOT NULL, last_checked_at ASC
+		LIMIT ?
+	`
+
+	cutoff := formatTimestamp(checkedBefore)
+
+	rows, err := db.query(query, LinkStatusActive, cutoff, limit)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la récupération des liens à vérifier: %w", err)
+	}
+	defer rows.Close()
+
+	return scanHostedLinks(rows)
+}
+
+// GetDeadLinks récupère les liens morts qui n'ont pas encore été remplacés
+func (db *Database) GetDeadLinks(limit int) ([]HostedLink, error) {
+	query := `
+		SELECT id, upload_id, hoster, file_code, url, embed, status, last_checked_at, failure_count, created_at
+		FROM hosted_links
+		WHERE status = ?
+		ORDER BY id
+		LIMIT ?
+	`
+
+	rows, err := db.query(query, LinkStatusDead, limit)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la récupération des liens morts: %w", err)
+	}
+	defer rows.Close()
+
+	return scanHostedLinks(rows)
+}
+
+// UpdateLinkCheck enregistre le résultat de la vérification d'un lien
+func (db *Database) UpdateLinkCheck(id int64, status string, failureCount int) error {
+	query := `
+		UPDATE hosted_links
+		SET status = ?, failure_count = ?, last_checked_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
 
+	err := db.inTx(func(tx *Tx) error {
+		var uploadID int64
+		var oldStatus string
+		err := tx.QueryRow("SELECT upload_id, status FROM hosted_links WHERE id = ?", id).Scan(&uploadID, &oldStatus)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(query, status, failureCount, id); err != nil {
+			return err
+		}
+
+		if oldStatus == status {
+			return nil
+		}
+		return db.recordEvent(tx, Event{
+			Entity:   EntityLink,
+			EntityID: id,
+			UploadID: uploadID,
+			Action:   ActionStatus,
+			OldValue: oldStatus,
+			NewValue: status,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("erreur lors de la mise à jour de la vérification du lien: %w", err)
+	}
+
+	return nil
+}
+
+// CheckExistingUpload vérifie si un média a déjà été uploadé et retourne sa dernière version
+// non remplacée
+func (db *Database) CheckExistingUpload(tmdbID int, mediaType string, season, episode *int) (*Upload, error) {
 	if mediaType == TypeMovie {
-		query = `
-			SELECT id, tmdb_id, title, type, season, episode, file_path, upload_status, created_at, updated_at
-			FROM uploads
-			WHERE tmdb_id = ? AND type = ?
-			ORDER BY created_at DESC
-			LIMIT 1
-		`
-		args = []interface{}{tmdbID, mediaType}
-	} else if mediaType == TypeSeries && season != nil && episode != nil {
-		query = `
-			SELECT id, tmdb_id, title, type, season, episode, file_path, upload_status, created_at, updated_at
-			FROM uploads
-			WHERE tmdb_id = ? AND type = ? AND season = ? AND episode = ?
-			ORDER BY created_at DESC
-			LIMIT 1
-		`
-		args = []interface{}{tmdbID, mediaType, *season, *episode}
-	} else {
+		season, episode = nil, nil
+	} else if mediaType != TypeSeries || season == nil || episode == nil {
 		return nil, fmt.Errorf("type de média ou paramètres invalides")
 	}
 
-	var upload Upload
-	var createdAt, updatedAt string
-	var dbSeason, dbEpisode sql.NullInt64
+	filter, args := mediaFilter(tmdbID, mediaType, season, episode)
+	query := `
+		SELECT ` + uploadColumns + `
+		FROM uploads
+		WHERE ` + filter + ` AND superseded_by IS NULL
+		ORDER BY version DESC, id DESC
+		LIMIT 1
+	`
 
-	err := db.db.QueryRow(query, args...).Scan(
-		&upload.ID,
-		&upload.TmdbID,
-		&upload.Title,
-		&upload.Type,
-		&dbSeason,
-		&dbEpisode,
-		&upload.FilePath,
-		&upload.UploadStatus,
-		&createdAt,
-		&updatedAt,
-	)
+	upload, err := scanUpload(db.queryRow(query, args...))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -400,74 +695,252 @@ func (db *Database) CheckExistingUpload(tmdbID int, mediaType string, season, ep
 		return nil, err
 	}
 
-	if dbSeason.Valid {
-		s := int(dbSeason.Int64)
-		upload.Season = &s
+	return upload, nil
+}
+
+// GetUploadVersions récupère toutes les versions d'un film ou d'un épisode, de la plus récente
+// à la plus ancienne
+func (db *Database) GetUploadVersions(tmdbID int, mediaType string, season, episode *int) ([]*Upload, error) {
+	filter, args := mediaFilter(tmdbID, mediaType, season, episode)
+	query := `
+		SELECT ` + uploadColumns + `
+		FROM uploads
+		WHERE ` + filter + `
+		ORDER BY version DESC, id DESC
+	`
+
+	rows, err := db.query(query, args...)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	if dbEpisode.Valid {
-		e := int(dbEpisode.Int64)
-		upload.Episode = &e
+	return scanUploads(rows)
+}
+
+// SupersedeUpload indique qu'un upload a été remplacé par une nouvelle version
+func (db *Database) SupersedeUpload(id, supersededBy int64) error {
+	query := `
+		UPDATE uploads
+		SET superseded_by = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+
+	err := db.inTx(func(tx *Tx) error {
+		if _, err := tx.Exec(query, supersededBy, id); err != nil {
+			return err
+		}
+
+		return db.recordEvent(tx, Event{
+			Entity:   EntityUpload,
+			EntityID: id,
+			UploadID: id,
+			Action:   ActionSuperseded,
+			NewValue: strconv.FormatInt(supersededBy, 10),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("erreur lors du remplacement de l'upload: %w", err)
 	}
 
-	upload.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAt)
-	upload.UpdatedAt, _ = time.Parse("2006-01-02 15:04:05", updatedAt)
+	return nil
+}
 
-	return &upload, nil
+// UpdateUploadTitle met à jour le titre d'un upload
+func (db *Database) UpdateUploadTitle(id int64, title string) error {
+	if err := db.updateUpload(id, "title", title, ActionTitle); err != nil {
+		return fmt.Errorf("erreur lors de la mise à jour du titre: %w", err)
+	}
+
+	return nil
+}
+
+// GetUploadsByTmdbID récupère tous les uploads d'un média, par exemple tous les épisodes d'une série
+func (db *Database) GetUploadsByTmdbID(tmdbID int, mediaType string) ([]*Upload, error) {
+	query := `
+		SELECT ` + uploadColumns + `
+		FROM uploads
+		WHERE tmdb_id = ? AND type = ?
+		ORDER BY created_at ASC
+	`
+
+	rows, err := db.query(query, tmdbID, mediaType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanUploads(rows)
 }
 
 // GetPendingUploads récupère tous les uploads en attente
 func (db *Database) GetPendingUploads() ([]*Upload, error) {
 	query := `
-		SELECT id, tmdb_id, title, type, season, episode, file_path, upload_status, created_at, updated_at
+		SELECT ` + uploadColumns + `
 		FROM uploads
 		WHERE upload_status = ?
 		ORDER BY created_at ASC
 	`
 
-	rows, err := db.db.Query(query, StatusPending)
+	rows, err := db.query(query, StatusPending)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var uploads []*Upload
-	for rows.Next() {
-		var upload Upload
-		var createdAt, updatedAt string
-		var season, episode sql.NullInt64
+	return scanUploads(rows)
+}
 
-		err := rows.Scan(
-			&upload.ID,
-			&upload.TmdbID,
-			&upload.Title,
-			&upload.Type,
-			&season,
-			&episode,
-			&upload.FilePath,
-			&upload.UploadStatus,
-			&createdAt,
-			&updatedAt,
-		)
+// GetUploadsToRetry récupère les uploads partiels dont les miroirs manquants peuvent être
+// relancés: pas de mise à jour depuis la date donnée et moins de maxRetries relances
+func (db *Database) GetUploadsToRetry(updatedBefore time.Time, maxRetries int) ([]*Upload, error) {
+	query := `
+		SELECT ` + uploadColumns + `
+		FROM uploads
+		WHERE upload_status = ? AND mirror_retries < ? AND updated_at <= ? AND superseded_by IS NULL
+		ORDER BY updated_at ASC
+	`
+
+	rows, err := db.query(query, StatusPartial, maxRetries, formatTimestamp(updatedBefore))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanUploads(rows)
+}
+
+// MarkUploadForRetry remet un upload partiel en attente et compte la relance
+func (db *Database) MarkUploadForRetry(id int64) error {
+	query := `
+		UPDATE uploads
+		SET upload_status = ?, mirror_retries = mirror_retries + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+
+	err := db.inTx(func(tx *Tx) error {
+		var oldStatus string
+		err := tx.QueryRow("SELECT upload_status FROM uploads WHERE id = ?", id).Scan(&oldStatus)
+		if err == sql.ErrNoRows {
+			return nil
+		}
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		if season.Valid {
-			s := int(season.Int64)
-			upload.Season = &s
+		if _, err := tx.Exec(query, StatusPending, id); err != nil {
+			return err
 		}
 
-		if episode.Valid {
-			e := int(episode.Int64)
-			upload.Episode = &e
-		}
+		return db.recordEvent(tx, Event{
+			Entity:   EntityUpload,
+			EntityID: id,
+			UploadID: id,
+			Action:   ActionRetry,
+			OldValue: oldStatus,
+			NewValue: StatusPending,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("erreur lors de la relance de l'upload: %w", err)
+	}
+
+	return nil
+}
+
+// rowScanner est une ligne ou un ensemble de lignes retournés par une requête
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUpload lit un upload sélectionné avec uploadColumns
+func scanUpload(row rowScanner) (*Upload, error) {
+	var upload Upload
+	var createdAt, updatedAt timestamp
+	var season, episode, mediaID, episodeID, supersededBy sql.NullInt64
+	var audioLanguages, subtitleLanguages, languages stringList
+	media := &upload.Media
+
+	err := row.Scan(
+		&upload.ID,
+		&upload.TmdbID,
+		&upload.Title,
+		&upload.Type,
+		&season,
+		&episode,
+		&mediaID,
+		&episodeID,
+		&upload.FilePath,
+		&upload.UploadStatus,
+		&upload.MirrorRetries,
+		&media.Quality,
+		&media.QualityVersion,
+		&media.Size,
+		&media.Width,
+		&media.Height,
+		&media.VideoCodec,
+		&media.VideoDynamicRange,
+		&media.AudioCodec,
+		&media.AudioChannels,
+		&audioLanguages,
+		&subtitleLanguages,
+		&languages,
+		&media.ReleaseGroup,
+		&media.ReleaseTitle,
+		&media.Indexer,
+		&media.DownloadClient,
+		&media.DownloadID,
+		&media.CustomFormatScore,
+		&upload.Fingerprint,
+		&upload.ContentSHA256,
+		&upload.Version,
+		&supersededBy,
+		&createdAt,
+		&updatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
 
-		upload.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAt)
-		upload.UpdatedAt, _ = time.Parse("2006-01-02 15:04:05", updatedAt)
+	if season.Valid {
+		s := int(season.Int64)
+		upload.Season = &s
+	}
+
+	if episode.Valid {
+		e := int(episode.Int64)
+		upload.Episode = &e
+	}
+
+	upload.MediaID = mediaID.Int64
+	if episodeID.Valid {
+		upload.EpisodeID = &episodeID.Int64
+	}
+
+	if supersededBy.Valid {
+		upload.SupersededBy = &supersededBy.Int64
+	}
+
+	media.AudioLanguages = audioLanguages
+	media.SubtitleLanguages = subtitleLanguages
+	media.Languages = languages
+	upload.CreatedAt = createdAt.Time
+	upload.UpdatedAt = updatedAt.Time
+
+	return &upload, nil
+}
+
+// scanUploads lit les uploads retournés par une requête
+func scanUploads(rows *sql.Rows) ([]*Upload, error) {
+	var uploads []*Upload
+	for rows.Next() {
+		upload, err := scanUpload(rows)
+		if err != nil {
+			return nil, err
+		}
 
-		uploads = append(uploads, &upload)
+		uploads = append(uploads, upload)
 	}
 
-	return uploads, nil
+	return uploads, rows.Err()
 }
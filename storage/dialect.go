@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Moteurs de base de données supportés
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// dialect isole les différences de SQL entre les moteurs de base de données.
+// Les requêtes sont écrites pour SQLite, avec des paramètres "?", et adaptées au moteur.
+type dialect interface {
+	// rebind adapte les paramètres d'une requête
+	rebind(query string) string
+	// schema adapte une requête de création ou de modification de table
+	schema(statement string) string
+	// columnExists indique si une table possède une colonne
+	columnExists(tx *Tx, table, column string) (bool, error)
+	// tableExists indique si une table existe
+	tableExists(db *sql.DB, table string) (bool, error)
+	// returningID indique si l'ID d'une insertion doit être lu avec RETURNING id
+	returningID() bool
+	// skipLocked retourne la clause qui réserve une ligne sans attendre les autres transactions
+	skipLocked() string
+	// resetSequence fait repartir les IDs d'une table après le plus grand ID inséré
+	resetSequence(tx *Tx, table string) error
+}
+
+// sqliteDialect est le dialecte de SQLite, dans lequel les requêtes sont écrites
+type sqliteDialect struct{}
+
+func (sqliteDialect) rebind(query string) string {
+	return query
+}
+
+func (sqliteDialect) schema(statement string) string {
+	return statement
+}
+
+func (sqliteDialect) columnExists(tx *Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
+func (sqliteDialect) tableExists(db *sql.DB, table string) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count)
+	return count > 0, err
+}
+
+func (sqliteDialect) returningID() bool {
+	return false
+}
+
+func (sqliteDialect) skipLocked() string {
+	// SQLite n'a qu'un écrivain à la fois, la ligne ne peut pas être réservée deux fois
+	return ""
+}
+
+func (sqliteDialect) resetSequence(tx *Tx, table string) error {
+	// AUTOINCREMENT repart déjà du plus grand ID inséré
+	return nil
+}
+
+// postgresDialect est le dialecte de PostgreSQL
+type postgresDialect struct{}
+
+// rebind remplace les paramètres "?" par $1, $2... en ignorant les chaînes littérales
+func (postgresDialect) rebind(query string) string {
+	var builder strings.Builder
+	builder.Grow(len(query) + 10)
+
+	index := 0
+	inString := false
+	for _, char := range query {
+		switch {
+		case char == '\'':
+			inString = !inString
+		case char == '?' && !inString:
+			index++
+			builder.WriteString("$" + strconv.Itoa(index))
+			continue
+		}
+		builder.WriteRune(char)
+	}
+
+	return builder.String()
+}
+
+// postgresTypes traduit les types de colonnes SQLite. Les entiers SQLite sont sur 64 bits.
+var postgresTypes = strings.NewReplacer(
+	"INTEGER PRIMARY KEY AUTOINCREMENT", "BIGSERIAL PRIMARY KEY",
+	"INTEGER", "BIGINT",
+	"REAL", "DOUBLE PRECISION",
+)
+
+func (postgresDialect) schema(statement string) string {
+	return postgresTypes.Replace(statement)
+}
+
+func (postgresDialect) columnExists(tx *Tx, table, column string) (bool, error) {
+	var count int
+	err := tx.QueryRow(`
+	SELECT COUNT(*) FROM information_schema.columns
+	WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?
+	`, table, column).Scan(&count)
+	return count > 0, err
+}
+
+func (postgresDialect) tableExists(db *sql.DB, table string) (bool, error) {
+	var count int
+	err := db.QueryRow(`
+	SELECT COUNT(*) FROM information_schema.tables
+	WHERE table_schema = current_schema() AND table_name = $1
+	`, table).Scan(&count)
+	return count > 0, err
+}
+
+func (postgresDialect) returningID() bool {
+	return true
+}
+
+func (postgresDialect) skipLocked() string {
+	return "FOR UPDATE SKIP LOCKED"
+}
+
+func (postgresDialect) resetSequence(tx *Tx, table string) error {
+	_, err := tx.Exec(fmt.Sprintf(
+		"SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM %[1]s",
+		table,
+	))
+	return err
+}
+
+// Tx est une transaction dont les requêtes sont adaptées au moteur de la base
+type Tx struct {
+	*sql.Tx
+	dialect dialect
+}
+
+// Exec exécute une requête dans la transaction
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.Exec(tx.dialect.rebind(query), args...)
+}
+
+// Query exécute une requête qui retourne des lignes dans la transaction
+func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.Query(tx.dialect.rebind(query), args...)
+}
+
+// QueryRow exécute une requête qui retourne au plus une ligne dans la transaction
+func (tx *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRow(tx.dialect.rebind(query), args...)
+}
+
+// insert exécute une insertion dans la transaction et retourne l'ID de la ligne créée
+func (tx *Tx) insert(query string, args ...interface{}) (int64, error) {
+	if tx.dialect.returningID() {
+		var id int64
+		err := tx.QueryRow(strings.TrimSpace(query)+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// exec exécute une requête adaptée au moteur de la base
+func (db *Database) exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.db.Exec(db.dialect.rebind(query), args...)
+}
+
+// query exécute une requête qui retourne des lignes
+func (db *Database) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.db.Query(db.dialect.rebind(query), args...)
+}
+
+// queryRow exécute une requête qui retourne au plus une ligne
+func (db *Database) queryRow(query string, args ...interface{}) *sql.Row {
+	return db.db.QueryRow(db.dialect.rebind(query), args...)
+}
+
+// insert exécute une insertion et retourne l'ID de la ligne créée
+func (db *Database) insert(query string, args ...interface{}) (int64, error) {
+	if db.dialect.returningID() {
+		var id int64
+		err := db.queryRow(strings.TrimSpace(query)+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := db.exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// begin démarre une transaction adaptée au moteur de la base
+func (db *Database) begin() (*Tx, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, dialect: db.dialect}, nil
+}
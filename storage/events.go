@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Auteurs des changements enregistrés dans le journal
+const (
+	ActorSystem    = "system"
+	ActorWebhook   = "webhook"
+	ActorWorker    = "worker"
+	ActorAdmin     = "admin"
+	ActorScheduler = "scheduler"
+)
+
+// Entités du journal
+const (
+	EntityUpload = "upload"
+	EntityLink   = "link"
+)
+
+// Actions du journal
+const (
+	ActionCreated    = "created"
+	ActionStatus     = "status"
+	ActionTitle      = "title"
+	ActionRetry      = "retry"
+	ActionSuperseded = "superseded"
+	ActionReplaced   = "replaced"
+	ActionDeleted    = "deleted"
+)
+
+// Event est un changement d'état enregistré dans le journal. Le journal n'est jamais modifié:
+// les événements d'un upload ou d'un lien supprimé restent consultables.
+type Event struct {
+	ID       int64  `json:"id"`
+	Entity   string `json:"entity"`
+	EntityID int64  `json:"entity_id"`
+	// UploadID et TmdbID permettent de retrouver l'historique d'un upload et de ses liens
+	UploadID int64  `json:"upload_id"`
+	TmdbID   int    `json:"tmdb_id"`
+	Action   string `json:"action"`
+	OldValue string `json:"old_value,omitempty"`
+	NewValue string `json:"new_value,omitempty"`
+	Actor    string `json:"actor"`
+	// CorrelationID relie les événements d'un même webhook ou d'une même requête
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// EventFilter sélectionne les événements d'un upload, d'un lien ou d'un média
+type EventFilter struct {
+	UploadID int64
+	LinkID   int64
+	TmdbID   int
+	Limit    int
+}
+
+// WithActor retourne une copie de la base qui attribue les changements enregistrés dans le
+// journal à actor et à correlationID, l'identifiant du webhook, de la requête ou de la tâche
+// qui les a provoqués. La copie partage la connexion de la base et ne doit pas être fermée.
+func (db *Database) WithActor(actor, correlationID string) Store {
+	scoped := *db
+	scoped.actor = actor
+	scoped.correlationID = correlationID
+	return &scoped
+}
+
+// inTx exécute fn dans une transaction, validée si fn ne retourne pas d'erreur
+func (db *Database) inTx(fn func(tx *Tx) error) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// recordEvent ajoute un événement au journal dans la transaction du changement
+func (db *Database) recordEvent(tx *Tx, event Event) error {
+	event.Actor = db.actor
+	if event.Actor == "" {
+		event.Actor = ActorSystem
+	}
+	event.CorrelationID = db.correlationID
+
+	if event.UploadID != 0 {
+		if event.TmdbID == 0 {
+			err := tx.QueryRow("SELECT tmdb_id FROM uploads WHERE id = ?", event.UploadID).Scan(&event.TmdbID)
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+		}
+	}
+
+	query := `
+		INSERT INTO events (
+			entity, entity_id, upload_id, tmdb_id, action, old_value, new_value, actor, correlation_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := tx.Exec(
+		query,
+		event.Entity,
+		event.EntityID,
+		event.UploadID,
+		event.TmdbID,
+		event.Action,
+		event.OldValue,
+		event.NewValue,
+		event.Actor,
+		event.CorrelationID,
+	)
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'enregistrement de l'événement: %w", err)
+	}
+
+	return nil
+}
+
+// GetEvents récupère l'historique d'un upload, d'un lien ou d'un média, du plus récent au plus ancien
+func (db *Database) GetEvents(filter EventFilter) ([]Event, error) {
+	query := `
+		SELECT id, entity, entity_id, upload_id, tmdb_id, action, old_value, new_value,
+			actor, correlation_id, created_at
+		FROM events
+		WHERE 1 = 1
+	`
+	var args []interface{}
+
+	if filter.UploadID != 0 {
+		query += " AND upload_id = ?"
+		args = append(args, filter.UploadID)
+	}
+	if filter.LinkID != 0 {
+		query += " AND entity = ? AND entity_id = ?"
+		args = append(args, EntityLink, filter.LinkID)
+	}
+	if filter.TmdbID != 0 {
+		query += " AND tmdb_id = ?"
+		args = append(args, filter.TmdbID)
+	}
+
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := db.query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la récupération des événements: %w", err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var event Event
+		var createdAt timestamp
+
+		err := rows.Scan(
+			&event.ID,
+			&event.Entity,
+			&event.EntityID,
+			&event.UploadID,
+			&event.TmdbID,
+			&event.Action,
+			&event.OldValue,
+			&event.NewValue,
+			&event.Actor,
+			&event.CorrelationID,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		event.CreatedAt = createdAt.Time
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,661 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Formats d'export de la base
+const (
+	// FormatJSON est un document JSON unique
+	FormatJSON = "json"
+	// FormatNDJSON est un objet JSON par ligne, lu et écrit au fil de l'eau
+	FormatNDJSON = "ndjson"
+)
+
+// Types des lignes d'un export NDJSON
+const (
+	recordHeader  = "header"
+	recordUpload  = "upload"
+	recordLink    = "link"
+	recordQueue   = "queue"
+	recordMedia   = "media"
+	recordSeason  = "season"
+	recordEpisode = "episode"
+)
+
+// ExportStats compte les lignes exportées ou importées
+type ExportStats struct {
+	Uploads  int `json:"uploads"`
+	Links    int `json:"links"`
+	Queue    int `json:"queue"`
+	Media    int `json:"media"`
+	Seasons  int `json:"seasons"`
+	Episodes int `json:"episodes"`
+}
+
+// exportHeader décrit la base exportée
+type exportHeader struct {
+	SchemaVersion int       `json:"schema_version"`
+	ExportedAt    time.Time `json:"exported_at"`
+}
+
+// exportDocument est un export au format JSON
+type exportDocument struct {
+	exportHeader
+	Uploads  []*Upload    `json:"uploads"`
+	Links    []HostedLink `json:"links"`
+	Queue    []*QueueItem `json:"queue"`
+	Media    []*Media     `json:"media"`
+	Seasons  []Season     `json:"seasons"`
+	Episodes []Episode    `json:"episodes"`
+}
+
+// exportRecord est une ligne d'un export NDJSON
+type exportRecord struct {
+	Type    string        `json:"type"`
+	Header  *exportHeader `json:"header,omitempty"`
+	Upload  *Upload       `json:"upload,omitempty"`
+	Link    *HostedLink   `json:"link,omitempty"`
+	Queue   *QueueItem    `json:"queue,omitempty"`
+	Media   *Media        `json:"media,omitempty"`
+	Season  *Season       `json:"season,omitempty"`
+	Episode *Episode      `json:"episode,omitempty"`
+}
+
+// Export écrit les uploads, leurs liens et la queue dans w, au format JSON ou NDJSON.
+// La base reste utilisable pendant l'export.
+func (db *Database) Export(w io.Writer, format string) (ExportStats, error) {
+	switch format {
+	case FormatJSON:
+		var document exportDocument
+		stats, err := db.exportRecords(func(record exportRecord) error {
+			switch record.Type {
+			case recordHeader:
+				document.exportHeader = *record.Header
+			case recordUpload:
+				document.Uploads = append(document.Uploads, record.Upload)
+			case recordLink:
+				document.Links = append(document.Links, *record.Link)
+			case recordQueue:
+				document.Queue = append(document.Queue, record.Queue)
+			case recordMedia:
+				document.Media = append(document.Media, record.Media)
+			case recordSeason:
+				document.Seasons = append(document.Seasons, *record.Season)
+			case recordEpisode:
+				document.Episodes = append(document.Episodes, *record.Episode)
+			}
+			return nil
+		})
+		if err != nil {
+			return stats, err
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(document); err != nil {
+			return stats, fmt.Errorf("erreur lors de l'écriture de l'export: %w", err)
+		}
+		return stats, nil
+	case FormatNDJSON:
+		encoder := json.NewEncoder(w)
+		return db.exportRecords(func(record exportRecord) error {
+			if err := encoder.Encode(record); err != nil {
+				return fmt.Errorf("erreur lors de l'écriture de l'export: %w", err)
+			}
+			return nil
+		})
+	default:
+		return ExportStats{}, fmt.Errorf("format d'export inconnu: %s (json, ndjson)", format)
+	}
+}
+
+// exportRecords lit les lignes à exporter et les passe à write, l'en-tête en premier puis
+// les uploads avant leurs liens. Les films et séries sont lus après les uploads, pour
+// inclure ceux des uploads créés pendant l'export.
+func (db *Database) exportRecords(write func(record exportRecord) error) (ExportStats, error) {
+	var stats ExportStats
+
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return stats, err
+	}
+	header := exportHeader{SchemaVersion: version, ExportedAt: time.Now().UTC()}
+	if err := write(exportRecord{Type: recordHeader, Header: &header}); err != nil {
+		return stats, err
+	}
+
+	rows, err := db.query("SELECT " + uploadColumns + " FROM uploads ORDER BY id")
+	if err != nil {
+		return stats, fmt.Errorf("erreur lors de la lecture des uploads: %w", err)
+	}
+	uploads, err := scanUploads(rows)
+	rows.Close()
+	if err != nil {
+		return stats, fmt.Errorf("erreur lors de la lecture des uploads: %w", err)
+	}
+
+	var maxUploadID int64
+	for _, upload := range uploads {
+		if err := write(exportRecord{Type: recordUpload, Upload: upload}); err != nil {
+			return stats, err
+		}
+		maxUploadID = upload.ID
+		stats.Uploads++
+	}
+
+	// Les liens d'un upload créé après la lecture des uploads ne sont pas exportés,
+	// ils ne pourraient pas être importés
+	rows, err = db.query(`
+		SELECT id, upload_id, hoster, file_code, url, embed, status, last_checked_at, failure_count, created_at
+		FROM hosted_links
+		WHERE upload_id <= ?
+		ORDER BY id
+	`, maxUploadID)
+	if err != nil {
+		return stats, fmt.Errorf("erreur lors de la lecture des liens: %w", err)
+	}
+	links, err := scanHostedLinks(rows)
+	rows.Close()
+	if err != nil {
+		return stats, fmt.Errorf("erreur lors de la lecture des liens: %w", err)
+	}
+
+	for i := range links {
+		if err := write(exportRecord{Type: recordLink, Link: &links[i]}); err != nil {
+			return stats, err
+		}
+		stats.Links++
+	}
+
+	rows, err = db.query(`
+		SELECT id, type, payload, status, attempts, max_attempts, created_at, updated_at, processed_at
+		FROM queue
+		ORDER BY id
+	`)
+	if err != nil {
+		return stats, fmt.Errorf("erreur lors de la lecture de la queue: %w", err)
+	}
+	items, err := scanQueueItems(rows)
+	rows.Close()
+	if err != nil {
+		return stats, err
+	}
+
+	for _, item := range items {
+		if err := write(exportRecord{Type: recordQueue, Queue: item}); err != nil {
+			return stats, err
+		}
+		stats.Queue++
+	}
+
+	if err := db.exportCatalog(write, &stats); err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// exportCatalog lit les films, séries, saisons et épisodes à exporter et les passe à write
+func (db *Database) exportCatalog(write func(record exportRecord) error, stats *ExportStats) error {
+	rows, err := db.query("SELECT " + mediaColumns + " FROM media ORDER BY id")
+	if err != nil {
+		return fmt.Errorf("erreur lors de la lecture des médias: %w", err)
+	}
+	var media []*Media
+	for rows.Next() {
+		item, err := scanMedia(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("erreur lors de la lecture des médias: %w", err)
+		}
+		media = append(media, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("erreur lors de la lecture des médias: %w", err)
+	}
+
+	for _, item := range media {
+		if err := write(exportRecord{Type: recordMedia, Media: item}); err != nil {
+			return err
+		}
+		stats.Media++
+	}
+
+	rows, err = db.query(`
+		SELECT id, media_id, number, episode_count, completed_at, created_at
+		FROM seasons
+		ORDER BY id
+	`)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la lecture des saisons: %w", err)
+	}
+	var seasons []Season
+	for rows.Next() {
+		var season Season
+		var completedAt, createdAt timestamp
+		err := rows.Scan(&season.ID, &season.MediaID, &season.Number, &season.EpisodeCount, &completedAt, &createdAt)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("erreur lors de la lecture des saisons: %w", err)
+		}
+		season.CompletedAt = completedAt.ptr()
+		season.CreatedAt = createdAt.Time
+		seasons = append(seasons, season)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("erreur lors de la lecture des saisons: %w", err)
+	}
+
+	for i := range seasons {
+		if err := write(exportRecord{Type: recordSeason, Season: &seasons[i]}); err != nil {
+			return err
+		}
+		stats.Seasons++
+	}
+
+	rows, err = db.query(`
+		SELECT episodes.id, episodes.season_id, seasons.media_id, seasons.number, episodes.number,
+			episodes.title, episodes.air_date, episodes.tvdb_id, episodes.created_at
+		FROM episodes
+		JOIN seasons ON seasons.id = episodes.season_id
+		ORDER BY episodes.id
+	`)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la lecture des épisodes: %w", err)
+	}
+	var episodes []Episode
+	for rows.Next() {
+		var episode Episode
+		var createdAt timestamp
+		err := rows.Scan(
+			&episode.ID,
+			&episode.SeasonID,
+			&episode.MediaID,
+			&episode.SeasonNumber,
+			&episode.Number,
+			&episode.Title,
+			&episode.AirDate,
+			&episode.TvdbID,
+			&createdAt,
+		)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("erreur lors de la lecture des épisodes: %w", err)
+		}
+		episode.CreatedAt = createdAt.Time
+		episodes = append(episodes, episode)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("erreur lors de la lecture des épisodes: %w", err)
+	}
+
+	for i := range episodes {
+		if err := write(exportRecord{Type: recordEpisode, Episode: &episodes[i]}); err != nil {
+			return err
+		}
+		stats.Episodes++
+	}
+
+	return nil
+}
+
+// importTables sont les tables remplies par l'import, qui doivent être vides
+var importTables = []string{"uploads", "hosted_links", "queue", "media", "seasons", "episodes"}
+
+// Import charge un export JSON ou NDJSON dans une base vide, en conservant les IDs.
+// L'import est annulé entièrement à la première erreur.
+func (db *Database) Import(r io.Reader, format string) (ExportStats, error) {
+	var stats ExportStats
+
+	for _, table := range importTables {
+		var count int
+		if err := db.queryRow("SELECT COUNT(*) FROM " + table).Scan(&count); err != nil {
+			return stats, fmt.Errorf("erreur lors de l'inspection de la table %s: %w", table, err)
+		}
+		if count > 0 {
+			return stats, fmt.Errorf("la table %s n'est pas vide, l'import nécessite une base vide", table)
+		}
+	}
+
+	err := db.inTx(func(tx *Tx) error {
+		read := func(record exportRecord) error {
+			return importRecord(tx, record, &stats)
+		}
+
+		switch format {
+		case FormatJSON:
+			var document exportDocument
+			if err := json.NewDecoder(r).Decode(&document); err != nil {
+				return fmt.Errorf("export JSON invalide: %w", err)
+			}
+			if err := read(exportRecord{Type: recordHeader, Header: &document.exportHeader}); err != nil {
+				return err
+			}
+			for _, upload := range document.Uploads {
+				if err := read(exportRecord{Type: recordUpload, Upload: upload}); err != nil {
+					return err
+				}
+			}
+			for i := range document.Links {
+				if err := read(exportRecord{Type: recordLink, Link: &document.Links[i]}); err != nil {
+					return err
+				}
+			}
+			for _, item := range document.Queue {
+				if err := read(exportRecord{Type: recordQueue, Queue: item}); err != nil {
+					return err
+				}
+			}
+			for _, item := range document.Media {
+				if err := read(exportRecord{Type: recordMedia, Media: item}); err != nil {
+					return err
+				}
+			}
+			for i := range document.Seasons {
+				if err := read(exportRecord{Type: recordSeason, Season: &document.Seasons[i]}); err != nil {
+					return err
+				}
+			}
+			for i := range document.Episodes {
+				if err := read(exportRecord{Type: recordEpisode, Episode: &document.Episodes[i]}); err != nil {
+					return err
+				}
+			}
+		case FormatNDJSON:
+			decoder := json.NewDecoder(r)
+			for line := 1; ; line++ {
+				var record exportRecord
+				err := decoder.Decode(&record)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return fmt.Errorf("export NDJSON invalide à la ligne %d: %w", line, err)
+				}
+				if err := read(record); err != nil {
+					return fmt.Errorf("ligne %d: %w", line, err)
+				}
+			}
+		default:
+			return fmt.Errorf("format d'export inconnu: %s (json, ndjson)", format)
+		}
+
+		for _, table := range importTables {
+			if err := tx.dialect.resetSequence(tx, table); err != nil {
+				return fmt.Errorf("erreur lors de la mise à jour des IDs de %s: %w", table, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ExportStats{}, err
+	}
+
+	return stats, nil
+}
+
+// importRecord insère une ligne d'un export dans la transaction d'import
+func importRecord(tx *Tx, record exportRecord, stats *ExportStats) error {
+	switch {
+	case record.Type == recordHeader && record.Header != nil:
+		if record.Header.SchemaVersion > LatestVersion() {
+			return fmt.Errorf("export d'un schéma plus récent (version %d, version connue: %d)",
+				record.Header.SchemaVersion, LatestVersion())
+		}
+		return nil
+	case record.Type == recordUpload && record.Upload != nil:
+		stats.Uploads++
+		return importUpload(tx, record.Upload)
+	case record.Type == recordLink && record.Link != nil:
+		stats.Links++
+		return importLink(tx, record.Link)
+	case record.Type == recordQueue && record.Queue != nil:
+		stats.Queue++
+		return importQueueItem(tx, record.Queue)
+	case record.Type == recordMedia && record.Media != nil:
+		stats.Media++
+		return importMedia(tx, record.Media)
+	case record.Type == recordSeason && record.Season != nil:
+		stats.Seasons++
+		return importSeason(tx, record.Season)
+	case record.Type == recordEpisode && record.Episode != nil:
+		stats.Episodes++
+		return importEpisode(tx, record.Episode)
+	default:
+		return fmt.Errorf("ligne d'export invalide: type %q", record.Type)
+	}
+}
+
+// importUpload insère un upload exporté avec son ID
+func importUpload(tx *Tx, upload *Upload) error {
+	query := `
+		INSERT INTO uploads (
+			id, tmdb_id, title, type, season, episode, media_id, episode_id, file_path, upload_status, mirror_retries,
+			quality, quality_version, size, width, height, video_codec, video_dynamic_range,
+			audio_codec, audio_channels, audio_languages, subtitle_languages, languages,
+			release_group, release_title, indexer, download_client, download_id, custom_format_score,
+			fingerprint, content_sha256, version, superseded_by, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	media := upload.Media
+	version := upload.Version
+	if version == 0 {
+		version = 1
+	}
+
+	// Un export antérieur aux films et séries ne les contient pas: ils sont recréés
+	mediaID, episodeID := upload.MediaID, upload.EpisodeID
+	if mediaID == 0 {
+		var err error
+		if mediaID, err = ensureMedia(tx, upload.Type, upload.TmdbID, upload.Title); err != nil {
+			return err
+		}
+		if upload.Season != nil && upload.Episode != nil {
+			episode, err := ensureEpisode(tx, mediaID, *upload.Season, *upload.Episode)
+			if err != nil {
+				return err
+			}
+			episodeID = &episode
+		}
+	}
+
+	_, err := tx.Exec(
+		query,
+		upload.ID,
+		upload.TmdbID,
+		upload.Title,
+		upload.Type,
+		upload.Season,
+		upload.Episode,
+		mediaID,
+		episodeID,
+		upload.FilePath,
+		upload.UploadStatus,
+		upload.MirrorRetries,
+		media.Quality,
+		media.QualityVersion,
+		media.Size,
+		media.Width,
+		media.Height,
+		media.VideoCodec,
+		media.VideoDynamicRange,
+		media.AudioCodec,
+		media.AudioChannels,
+		stringList(media.AudioLanguages),
+		stringList(media.SubtitleLanguages),
+		stringList(media.Languages),
+		media.ReleaseGroup,
+		media.ReleaseTitle,
+		media.Indexer,
+		media.DownloadClient,
+		media.DownloadID,
+		media.CustomFormatScore,
+		upload.Fingerprint,
+		upload.ContentSHA256,
+		version,
+		upload.SupersededBy,
+		importTimestamp(upload.CreatedAt),
+		importTimestamp(upload.UpdatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'import de l'upload %d: %w", upload.ID, err)
+	}
+
+	return nil
+}
+
+// importLink insère un lien exporté avec son ID
+func importLink(tx *Tx, link *HostedLink) error {
+	query := `
+		INSERT INTO hosted_links (
+			id, upload_id, hoster, file_code, url, embed, status, last_checked_at, failure_count, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	status := link.Status
+	if status == "" {
+		status = LinkStatusActive
+	}
+
+	_, err := tx.Exec(
+		query,
+		link.ID,
+		link.UploadID,
+		link.Hoster,
+		link.FileCode,
+		link.URL,
+		link.Embed,
+		status,
+		formatNullTimestamp(link.LastCheckedAt),
+		link.FailureCount,
+		importTimestamp(link.CreatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'import du lien %d: %w", link.ID, err)
+	}
+
+	return nil
+}
+
+// importQueueItem insère une tâche exportée avec son ID. Une tâche en cours sur la base
+// d'origine est remise en attente.
+func importQueueItem(tx *Tx, item *QueueItem) error {
+	query := `
+		INSERT INTO queue (
+			id, type, payload, link_id, status, attempts, max_attempts, created_at, updated_at, processed_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	status := item.Status
+	if status == QueueStatusProcessing {
+		status = QueueStatusPending
+	}
+
+	_, err := tx.Exec(
+		query,
+		item.ID,
+		item.Type,
+		item.Payload,
+		payloadLinkID([]byte(item.Payload)),
+		status,
+		item.Attempts,
+		item.MaxAttempts,
+		importTimestamp(item.CreatedAt),
+		importTimestamp(item.UpdatedAt),
+		formatNullTimestamp(item.ProcessedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'import de la tâche %d: %w", item.ID, err)
+	}
+
+	return nil
+}
+
+// importMedia insère un film ou une série exporté avec son ID
+func importMedia(tx *Tx, media *Media) error {
+	query := `
+		INSERT INTO media (id, type, tmdb_id, tvdb_id, imdb_id, title, year, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := tx.Exec(
+		query,
+		media.ID,
+		media.Type,
+		media.TmdbID,
+		media.TvdbID,
+		media.ImdbID,
+		media.Title,
+		media.Year,
+		importTimestamp(media.CreatedAt),
+		importTimestamp(media.UpdatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'import du média %d: %w", media.ID, err)
+	}
+
+	return nil
+}
+
+// importSeason insère une saison exportée avec son ID
+func importSeason(tx *Tx, season *Season) error {
+	query := `
+		INSERT INTO seasons (id, media_id, number, episode_count, completed_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := tx.Exec(
+		query,
+		season.ID,
+		season.MediaID,
+		season.Number,
+		season.EpisodeCount,
+		formatNullTimestamp(season.CompletedAt),
+		importTimestamp(season.CreatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'import de la saison %d: %w", season.ID, err)
+	}
+
+	return nil
+}
+
+// importEpisode insère un épisode exporté avec son ID
+func importEpisode(tx *Tx, episode *Episode) error {
+	query := `
+		INSERT INTO episodes (id, season_id, number, title, air_date, tvdb_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := tx.Exec(
+		query,
+		episode.ID,
+		episode.SeasonID,
+		episode.Number,
+		episode.Title,
+		episode.AirDate,
+		episode.TvdbID,
+		importTimestamp(episode.CreatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'import de l'épisode %d: %w", episode.ID, err)
+	}
+
+	return nil
+}
+
+// importTimestamp convertit une date exportée, l'heure de l'import si elle est absente
+func importTimestamp(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return formatTimestamp(t)
+}
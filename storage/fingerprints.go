@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// FindUploadByFingerprint retourne le dernier upload terminé et non remplacé dont le fichier
+// a la même empreinte, quel que soit son média, ou nil. L'upload excludeID est ignoré.
+func (db *Database) FindUploadByFingerprint(fingerprint string, excludeID int64) (*Upload, error) {
+	if fingerprint == "" {
+		return nil, nil
+	}
+
+	query := `
+		SELECT ` + uploadColumns + `
+		FROM uploads
+		WHERE fingerprint = ? AND id <> ? AND upload_status = ? AND superseded_by IS NULL
+		ORDER BY id DESC
+		LIMIT 1
+	`
+
+	upload, err := scanUpload(db.queryRow(query, fingerprint, excludeID, StatusCompleted))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la recherche de l'empreinte: %w", err)
+	}
+
+	return upload, nil
+}
+
+// UpdateUploadHash enregistre l'empreinte SHA-256 complète du fichier d'un upload
+func (db *Database) UpdateUploadHash(id int64, contentSHA256 string) error {
+	query := `
+		UPDATE uploads
+		SET content_sha256 = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+
+	if _, err := db.exec(query, contentSHA256, id); err != nil {
+		return fmt.Errorf("erreur lors de l'enregistrement de l'empreinte: %w", err)
+	}
+
+	return nil
+}
+
+// CountFileLinks compte les liens, tous uploads confondus, vers un fichier d'un hébergeur.
+// Un fichier réutilisé par plusieurs uploads ne doit être supprimé qu'avec son dernier lien.
+func (db *Database) CountFileLinks(hoster, fileCode string) (int, error) {
+	var count int
+	err := db.queryRow("SELECT COUNT(*) FROM hosted_links WHERE hoster = ? AND file_code = ?", hoster, fileCode).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors du comptage des liens du fichier: %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// GetHosterFolder récupère l'ID du dossier d'un hébergeur pour un chemin, ou une chaîne vide
+func (db *Database) GetHosterFolder(hoster, path string) (string, error) {
+	query := `SELECT folder_id FROM hoster_folders WHERE hoster = ? AND path = ?`
+
+	var folderID string
+	err := db.queryRow(query, hoster, path).Scan(&folderID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("erreur lors de la récupération du dossier: %w", err)
+	}
+
+	return folderID, nil
+}
+
+// SaveHosterFolder enregistre l'ID du dossier d'un hébergeur pour un chemin
+func (db *Database) SaveHosterFolder(hoster, path, folderID string) error {
+	query := `
+	INSERT INTO hoster_folders (hoster, path, folder_id)
+	VALUES (?, ?, ?)
+	ON CONFLICT (hoster, path) DO UPDATE SET folder_id = excluded.folder_id
+	`
+
+	if _, err := db.exec(query, hoster, path, folderID); err != nil {
+		return fmt.Errorf("erreur lors de l'enregistrement du dossier: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteHosterFolders oublie un dossier d'un hébergeur et ses sous-dossiers, par exemple
+// quand il a été supprimé du compte
+func (db *Database) DeleteHosterFolders(hoster, path string) error {
+	query := `DELETE FROM hoster_folders WHERE hoster = ? AND (path = ? OR path LIKE ?)`
+
+	if _, err := db.exec(query, hoster, path, path+"/%"); err != nil {
+		return fmt.Errorf("erreur lors de la suppression du dossier: %w", err)
+	}
+
+	return nil
+}
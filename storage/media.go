@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// UploadMedia décrit le fichier d'un upload, tel que rapporté par Radarr ou Sonarr
+type UploadMedia struct {
+	Quality           string   `json:"quality,omitempty"`
+	QualityVersion    int      `json:"quality_version,omitempty"` // > 1 pour un PROPER ou un REPACK
+	Size              int64    `json:"size,omitempty"`
+	Width             int      `json:"width,omitempty"`
+	Height            int      `json:"height,omitempty"`
+	VideoCodec        string   `json:"video_codec,omitempty"`
+	VideoDynamicRange string   `json:"video_dynamic_range,omitempty"`
+	AudioCodec        string   `json:"audio_codec,omitempty"`
+	AudioChannels     float64  `json:"audio_channels,omitempty"`
+	AudioLanguages    []string `json:"audio_languages,omitempty"`
+	SubtitleLanguages []string `json:"subtitle_languages,omitempty"`
+	Languages         []string `json:"languages,omitempty"`
+	ReleaseGroup      string   `json:"release_group,omitempty"`
+	ReleaseTitle      string   `json:"release_title,omitempty"`
+	Indexer           string   `json:"indexer,omitempty"`
+	DownloadClient    string   `json:"download_client,omitempty"`
+	// DownloadID est l'identifiant du téléchargement dans le client, le hash du torrent
+	DownloadID        string `json:"download_id,omitempty"`
+	CustomFormatScore int    `json:"custom_format_score,omitempty"`
+}
+
+// Known indique si les informations du fichier sont connues. Les uploads enregistrés
+// avant leur ajout n'en ont pas.
+func (m UploadMedia) Known() bool {
+	return m.Quality != "" || m.Height > 0 || m.Size > 0
+}
+
+// Resolution retourne la résolution du fichier, par exemple "1920x1080"
+func (m UploadMedia) Resolution() string {
+	if m.Width == 0 || m.Height == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", m.Width, m.Height)
+}
+
+// Better indique si le fichier est une meilleure version que other: résolution plus élevée,
+// puis meilleur score de formats personnalisés, puis PROPER ou REPACK, puis fichier plus lourd.
+// Un fichier dont les informations ne sont pas connues n'est jamais meilleur.
+func (m UploadMedia) Better(other UploadMedia) bool {
+	if !m.Known() {
+		return false
+	}
+	if !other.Known() {
+		return true
+	}
+
+	switch {
+	case m.Height != other.Height:
+		return m.Height > other.Height
+	case m.CustomFormatScore != other.CustomFormatScore:
+		return m.CustomFormatScore > other.CustomFormatScore
+	case m.QualityVersion != other.QualityVersion:
+		return m.QualityVersion > other.QualityVersion
+	default:
+		return m.Size > other.Size
+	}
+}
+
+// stringList est une liste de chaînes stockée en JSON dans une colonne texte
+type stringList []string
+
+// Value implémente driver.Valuer
+func (l stringList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "[]", nil
+	}
+	data, err := json.Marshal([]string(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implémente sql.Scanner
+func (l *stringList) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("type de liste non supporté: %T", value)
+	}
+
+	var list []string
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &list); err != nil {
+			return fmt.Errorf("liste invalide: %w", err)
+		}
+	}
+	*l = list
+	return nil
+}
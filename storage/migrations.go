@@ -0,0 +1,714 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Migration représente une évolution numérotée du schéma de la base, avec son retour arrière.
+// Up et Down sont exécutés dans une transaction avec l'enregistrement de la version.
+// Les requêtes sont écrites pour SQLite et adaptées au moteur de la base par la transaction.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *Tx) error
+	Down    func(tx *Tx) error
+}
+
+// MigrationStatus représente l'état d'une migration dans la base
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// migrations liste les migrations du schéma, par version croissante. Une migration publiée
+// ne doit plus être modifiée: toute évolution passe par une nouvelle version.
+// Les premières versions sont idempotentes pour adopter les bases créées avant les migrations.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial_schema",
+		Up: execStatements(`
+	CREATE TABLE IF NOT EXISTS uploads (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tmdb_id INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		type TEXT NOT NULL,
+		season INTEGER,
+		episode INTEGER,
+		file_path TEXT NOT NULL,
+		upload_status TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`, `
+	CREATE TABLE IF NOT EXISTS hosted_links (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		upload_id INTEGER NOT NULL,
+		hoster TEXT NOT NULL,
+		file_code TEXT NOT NULL,
+		url TEXT NOT NULL,
+		embed TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (upload_id) REFERENCES uploads (id) ON DELETE CASCADE
+	)`, `
+	CREATE TABLE IF NOT EXISTS queue (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		type TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		max_attempts INTEGER NOT NULL DEFAULT 3,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		processed_at TIMESTAMP
+	)`),
+		Down: execStatements(
+			"DROP TABLE IF EXISTS queue",
+			"DROP TABLE IF EXISTS hosted_links",
+			"DROP TABLE IF EXISTS uploads",
+		),
+	},
+	{
+		Version: 2,
+		Name:    "upload_sessions",
+		Up: execStatements(`
+	CREATE TABLE IF NOT EXISTS upload_sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		upload_id INTEGER NOT NULL,
+		hoster TEXT NOT NULL,
+		session_id TEXT NOT NULL,
+		file_path TEXT NOT NULL,
+		total_size INTEGER NOT NULL,
+		confirmed_offset INTEGER NOT NULL DEFAULT 0,
+		chunk_size INTEGER NOT NULL,
+		data TEXT NOT NULL DEFAULT '',
+		file_mtime TIMESTAMP,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (upload_id, hoster),
+		FOREIGN KEY (upload_id) REFERENCES uploads (id) ON DELETE CASCADE
+	)`),
+		Down: execStatements("DROP TABLE IF EXISTS upload_sessions"),
+	},
+	{
+		Version: 3,
+		Name:    "link_checks",
+		Up: migrationSteps(
+			addColumn("hosted_links", "status", "TEXT NOT NULL DEFAULT 'active'"),
+			addColumn("hosted_links", "last_checked_at", "TIMESTAMP"),
+			addColumn("hosted_links", "failure_count", "INTEGER NOT NULL DEFAULT 0"),
+		),
+		Down: execStatements(
+			"ALTER TABLE hosted_links DROP COLUMN failure_count",
+			"ALTER TABLE hosted_links DROP COLUMN last_checked_at",
+			"ALTER TABLE hosted_links DROP COLUMN status",
+		),
+	},
+	{
+		Version: 4,
+		Name:    "link_reuploads",
+		Up: migrationSteps(
+			execStatements(`
+	CREATE TABLE IF NOT EXISTS link_reuploads (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		upload_id INTEGER NOT NULL,
+		hoster TEXT NOT NULL,
+		link_id INTEGER NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (upload_id) REFERENCES uploads (id) ON DELETE CASCADE
+	)`),
+			// Le lien d'une tâche permet de retrouver un réupload en attente sans lire les payloads
+			addColumn("queue", "link_id", "INTEGER"),
+			execStatements("CREATE INDEX IF NOT EXISTS idx_queue_link ON queue (link_id)"),
+		),
+		Down: execStatements(
+			"DROP INDEX IF EXISTS idx_queue_link",
+			"ALTER TABLE queue DROP COLUMN link_id",
+			"DROP TABLE IF EXISTS link_reuploads",
+		),
+	},
+	{
+		Version: 5,
+		Name:    "upload_attempts",
+		Up: execStatements(`
+	CREATE TABLE IF NOT EXISTS upload_attempts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		upload_id INTEGER NOT NULL,
+		hoster TEXT NOT NULL,
+		started_at TIMESTAMP NOT NULL,
+		finished_at TIMESTAMP NOT NULL,
+		bytes_sent INTEGER NOT NULL DEFAULT 0,
+		avg_speed REAL NOT NULL DEFAULT 0,
+		http_status INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (upload_id) REFERENCES uploads (id) ON DELETE CASCADE
+	)`),
+		Down: execStatements("DROP TABLE IF EXISTS upload_attempts"),
+	},
+	{
+		Version: 6,
+		Name:    "mirror_retries",
+		Up:      addColumn("uploads", "mirror_retries", "INTEGER NOT NULL DEFAULT 0"),
+		Down:    execStatements("ALTER TABLE uploads DROP COLUMN mirror_retries"),
+	},
+	{
+		Version: 7,
+		Name:    "hoster_usage",
+		Up: execStatements(`
+	CREATE TABLE IF NOT EXISTS hoster_usage (
+		hoster TEXT NOT NULL,
+		day TEXT NOT NULL,
+		bytes_uploaded INTEGER NOT NULL DEFAULT 0,
+		uploads INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (hoster, day)
+	)`),
+		Down: execStatements("DROP TABLE IF EXISTS hoster_usage"),
+	},
+	{
+		Version: 8,
+		Name:    "hoster_folders",
+		Up: execStatements(`
+	CREATE TABLE IF NOT EXISTS hoster_folders (
+		hoster TEXT NOT NULL,
+		path TEXT NOT NULL,
+		folder_id TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (hoster, path)
+	)`),
+		Down: execStatements("DROP TABLE IF EXISTS hoster_folders"),
+	},
+	{
+		Version: 9,
+		Name:    "subtitles",
+		Up: execStatements(`
+	CREATE TABLE IF NOT EXISTS subtitles (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		upload_id INTEGER NOT NULL,
+		hoster TEXT NOT NULL DEFAULT '',
+		file_code TEXT NOT NULL DEFAULT '',
+		language TEXT NOT NULL,
+		format TEXT NOT NULL DEFAULT '',
+		source TEXT NOT NULL,
+		file_path TEXT NOT NULL DEFAULT '',
+		url TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (upload_id) REFERENCES uploads (id) ON DELETE CASCADE
+	)`),
+		Down: execStatements("DROP TABLE IF EXISTS subtitles"),
+	},
+	{
+		Version: 10,
+		Name:    "lookup_indexes",
+		Up: execStatements(
+			"CREATE INDEX IF NOT EXISTS idx_uploads_media ON uploads (tmdb_id, type, season, episode)",
+			"CREATE INDEX IF NOT EXISTS idx_hosted_links_upload ON hosted_links (upload_id)",
+			"CREATE INDEX IF NOT EXISTS idx_queue_status_created ON queue (status, created_at)",
+		),
+		Down: execStatements(
+			"DROP INDEX IF EXISTS idx_queue_status_created",
+			"DROP INDEX IF EXISTS idx_hosted_links_upload",
+			"DROP INDEX IF EXISTS idx_uploads_media",
+		),
+	},
+	{
+		Version: 11,
+		Name:    "utc_timestamps",
+		Up: migrationSteps(
+			normalizeTimestamps("uploads", "created_at", "updated_at"),
+			normalizeTimestamps("hosted_links", "created_at", "last_checked_at"),
+			normalizeTimestamps("queue", "created_at", "updated_at", "processed_at"),
+			normalizeTimestamps("upload_sessions", "created_at", "updated_at"),
+			normalizeTimestamps("link_reuploads", "created_at"),
+			normalizeTimestamps("upload_attempts", "started_at", "finished_at"),
+			normalizeTimestamps("hoster_folders", "created_at"),
+			normalizeTimestamps("subtitles", "created_at"),
+		),
+		// Les dates normalisées restent lisibles par les versions précédentes
+		Down: migrationSteps(),
+	},
+	{
+		Version: 12,
+		Name:    "upload_media",
+		Up: migrationSteps(
+			addColumn("uploads", "quality", "TEXT NOT NULL DEFAULT ''"),
+			addColumn("uploads", "quality_version", "INTEGER NOT NULL DEFAULT 0"),
+			addColumn("uploads", "size", "INTEGER NOT NULL DEFAULT 0"),
+			addColumn("uploads", "width", "INTEGER NOT NULL DEFAULT 0"),
+			addColumn("uploads", "height", "INTEGER NOT NULL DEFAULT 0"),
+			addColumn("uploads", "video_codec", "TEXT NOT NULL DEFAULT ''"),
+			addColumn("uploads", "video_dynamic_range", "TEXT NOT NULL DEFAULT ''"),
+			addColumn("uploads", "audio_codec", "TEXT NOT NULL DEFAULT ''"),
+			addColumn("uploads", "audio_channels", "REAL NOT NULL DEFAULT 0"),
+			addColumn("uploads", "audio_languages", "TEXT NOT NULL DEFAULT '[]'"),
+			addColumn("uploads", "subtitle_languages", "TEXT NOT NULL DEFAULT '[]'"),
+			addColumn("uploads", "languages", "TEXT NOT NULL DEFAULT '[]'"),
+			addColumn("uploads", "release_group", "TEXT NOT NULL DEFAULT ''"),
+			addColumn("uploads", "release_title", "TEXT NOT NULL DEFAULT ''"),
+			addColumn("uploads", "indexer", "TEXT NOT NULL DEFAULT ''"),
+			addColumn("uploads", "download_client", "TEXT NOT NULL DEFAULT ''"),
+			addColumn("uploads", "download_id", "TEXT NOT NULL DEFAULT ''"),
+			addColumn("uploads", "custom_format_score", "INTEGER NOT NULL DEFAULT 0"),
+		),
+		Down: execStatements(
+			"ALTER TABLE uploads DROP COLUMN custom_format_score",
+			"ALTER TABLE uploads DROP COLUMN download_id",
+			"ALTER TABLE uploads DROP COLUMN download_client",
+			"ALTER TABLE uploads DROP COLUMN indexer",
+			"ALTER TABLE uploads DROP COLUMN release_title",
+			"ALTER TABLE uploads DROP COLUMN release_group",
+			"ALTER TABLE uploads DROP COLUMN languages",
+			"ALTER TABLE uploads DROP COLUMN subtitle_languages",
+			"ALTER TABLE uploads DROP COLUMN audio_languages",
+			"ALTER TABLE uploads DROP COLUMN audio_channels",
+			"ALTER TABLE uploads DROP COLUMN audio_codec",
+			"ALTER TABLE uploads DROP COLUMN video_dynamic_range",
+			"ALTER TABLE uploads DROP COLUMN video_codec",
+			"ALTER TABLE uploads DROP COLUMN height",
+			"ALTER TABLE uploads DROP COLUMN width",
+			"ALTER TABLE uploads DROP COLUMN size",
+			"ALTER TABLE uploads DROP COLUMN quality_version",
+			"ALTER TABLE uploads DROP COLUMN quality",
+		),
+	},
+	{
+		Version: 13,
+		Name:    "upload_versions",
+		Up: migrationSteps(
+			addColumn("uploads", "version", "INTEGER NOT NULL DEFAULT 1"),
+			addColumn("uploads", "superseded_by", "INTEGER"),
+			// Les uploads existants d'un même média sont numérotés dans l'ordre de création,
+			// chacun étant remplacé par le prochain upload terminé, même partiellement
+			execStatements(`
+	UPDATE uploads SET version = (
+		SELECT COUNT(*) FROM uploads AS older
+		WHERE `+sameMedia("older", "uploads")+` AND older.id <= uploads.id
+	)`, `
+	UPDATE uploads SET superseded_by = (
+		SELECT MIN(newer.id) FROM uploads AS newer
+		WHERE `+sameMedia("newer", "uploads")+` AND newer.id > uploads.id
+			AND `+availableStatus("newer")+`
+	)`),
+		),
+		Down: execStatements(
+			"ALTER TABLE uploads DROP COLUMN superseded_by",
+			"ALTER TABLE uploads DROP COLUMN version",
+		),
+	},
+	{
+		Version: 14,
+		Name:    "events",
+		Up: execStatements(`
+	CREATE TABLE IF NOT EXISTS events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		entity TEXT NOT NULL,
+		entity_id INTEGER NOT NULL,
+		upload_id INTEGER NOT NULL DEFAULT 0,
+		tmdb_id INTEGER NOT NULL DEFAULT 0,
+		action TEXT NOT NULL,
+		old_value TEXT NOT NULL DEFAULT '',
+		new_value TEXT NOT NULL DEFAULT '',
+		actor TEXT NOT NULL,
+		correlation_id TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+			"CREATE INDEX IF NOT EXISTS idx_events_upload ON events (upload_id)",
+			"CREATE INDEX IF NOT EXISTS idx_events_tmdb ON events (tmdb_id)",
+			"CREATE INDEX IF NOT EXISTS idx_events_entity ON events (entity, entity_id)",
+		),
+		Down: execStatements("DROP TABLE IF EXISTS events"),
+	},
+	{
+		Version: 15,
+		Name:    "upload_fingerprints",
+		Up: migrationSteps(
+			addColumn("uploads", "fingerprint", "TEXT NOT NULL DEFAULT ''"),
+			addColumn("uploads", "content_sha256", "TEXT NOT NULL DEFAULT ''"),
+			execStatements(
+				"CREATE INDEX IF NOT EXISTS idx_uploads_fingerprint ON uploads (fingerprint)",
+				"CREATE INDEX IF NOT EXISTS idx_hosted_links_file ON hosted_links (hoster, file_code)",
+			),
+		),
+		Down: execStatements(
+			"DROP INDEX IF EXISTS idx_hosted_links_file",
+			"DROP INDEX IF EXISTS idx_uploads_fingerprint",
+			"ALTER TABLE uploads DROP COLUMN content_sha256",
+			"ALTER TABLE uploads DROP COLUMN fingerprint",
+		),
+	},
+	{
+		Version: 16,
+		Name:    "media_entities",
+		Up: migrationSteps(
+			execStatements(`
+	CREATE TABLE IF NOT EXISTS media (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		type TEXT NOT NULL,
+		tmdb_id INTEGER NOT NULL,
+		tvdb_id INTEGER NOT NULL DEFAULT 0,
+		imdb_id TEXT NOT NULL DEFAULT '',
+		title TEXT NOT NULL DEFAULT '',
+		year INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (type, tmdb_id)
+	)`, `
+	CREATE TABLE IF NOT EXISTS seasons (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		media_id INTEGER NOT NULL,
+		number INTEGER NOT NULL,
+		episode_count INTEGER NOT NULL DEFAULT 0,
+		completed_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (media_id, number),
+		FOREIGN KEY (media_id) REFERENCES media (id) ON DELETE CASCADE
+	)`, `
+	CREATE TABLE IF NOT EXISTS episodes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		season_id INTEGER NOT NULL,
+		number INTEGER NOT NULL,
+		title TEXT NOT NULL DEFAULT '',
+		air_date TEXT NOT NULL DEFAULT '',
+		tvdb_id INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (season_id, number),
+		FOREIGN KEY (season_id) REFERENCES seasons (id) ON DELETE CASCADE
+	)`),
+			addColumn("uploads", "media_id", "INTEGER"),
+			addColumn("uploads", "episode_id", "INTEGER"),
+			// Les films, séries, saisons et épisodes des uploads existants sont créés à partir
+			// de leurs colonnes, avec le titre de leur dernier upload
+			execStatements(`
+	INSERT INTO media (type, tmdb_id, title)
+	SELECT type, tmdb_id, (
+		SELECT latest.title FROM uploads AS latest
+		WHERE latest.type = uploads.type AND latest.tmdb_id = uploads.tmdb_id
+		ORDER BY latest.id DESC
+		LIMIT 1
+	)
+	FROM uploads
+	GROUP BY type, tmdb_id`, `
+	UPDATE uploads SET media_id = (
+		SELECT media.id FROM media
+		WHERE media.type = uploads.type AND media.tmdb_id = uploads.tmdb_id
+	)`, `
+	INSERT INTO seasons (media_id, number)
+	SELECT DISTINCT media_id, season FROM uploads
+	WHERE season IS NOT NULL AND episode IS NOT NULL`, `
+	INSERT INTO episodes (season_id, number)
+	SELECT DISTINCT seasons.id, uploads.episode FROM uploads
+	JOIN seasons ON seasons.media_id = uploads.media_id AND seasons.number = uploads.season
+	WHERE uploads.episode IS NOT NULL`, `
+	UPDATE uploads SET episode_id = (
+		SELECT episodes.id FROM episodes
+		JOIN seasons ON seasons.id = episodes.season_id
+		WHERE seasons.media_id = uploads.media_id AND seasons.number = uploads.season
+			AND episodes.number = uploads.episode
+	)`,
+				"CREATE INDEX IF NOT EXISTS idx_uploads_media_id ON uploads (media_id)",
+				"CREATE INDEX IF NOT EXISTS idx_uploads_episode_id ON uploads (episode_id)",
+			),
+		),
+		Down: execStatements(
+			"DROP INDEX IF EXISTS idx_uploads_episode_id",
+			"DROP INDEX IF EXISTS idx_uploads_media_id",
+			"ALTER TABLE uploads DROP COLUMN episode_id",
+			"ALTER TABLE uploads DROP COLUMN media_id",
+			"DROP TABLE IF EXISTS episodes",
+			"DROP TABLE IF EXISTS seasons",
+			"DROP TABLE IF EXISTS media",
+		),
+	},
+}
+
+// sameMedia retourne la condition qui associe les uploads de deux tables du même film ou
+// du même épisode
+func sameMedia(a, b string) string {
+	return fmt.Sprintf(
+		"%[1]s.tmdb_id = %[2]s.tmdb_id AND %[1]s.type = %[2]s.type AND "+
+			"COALESCE(%[1]s.season, -1) = COALESCE(%[2]s.season, -1) AND "+
+			"COALESCE(%[1]s.episode, -1) = COALESCE(%[2]s.episode, -1)",
+		a, b,
+	)
+}
+
+// availableStatus retourne la condition SQL d'un upload terminé, même partiellement
+func availableStatus(table string) string {
+	return fmt.Sprintf("%[1]s.upload_status IN ('%[2]s', '%[3]s')", table, StatusCompleted, StatusPartial)
+}
+
+// execStatements retourne une étape de migration qui exécute des requêtes dans l'ordre
+func execStatements(statements ...string) func(tx *Tx) error {
+	return func(tx *Tx) error {
+		for _, statement := range statements {
+			if _, err := tx.Exec(tx.dialect.schema(statement)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// migrationSteps enchaîne plusieurs étapes de migration
+func migrationSteps(steps ...func(tx *Tx) error) func(tx *Tx) error {
+	return func(tx *Tx) error {
+		for _, step := range steps {
+			if err := step(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addColumn retourne une étape de migration qui ajoute une colonne si elle n'existe pas encore
+func addColumn(table, column, definition string) func(tx *Tx) error {
+	return func(tx *Tx) error {
+		exists, err := tx.dialect.columnExists(tx, table, column)
+		if err != nil || exists {
+			return err
+		}
+
+		_, err = tx.Exec(tx.dialect.schema(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)))
+		return err
+	}
+}
+
+// normalizeTimestamps retourne une étape de migration qui réécrit les dates d'une table SQLite
+// en UTC au format de la base. Les dates illisibles sont laissées telles quelles.
+// PostgreSQL stocke des dates typées, qui n'ont pas besoin d'être réécrites.
+func normalizeTimestamps(table string, columns ...string) func(tx *Tx) error {
+	return func(tx *Tx) error {
+		if _, ok := tx.dialect.(sqliteDialect); !ok {
+			return nil
+		}
+
+		for _, column := range columns {
+			normalized := fmt.Sprintf("strftime('%%Y-%%m-%%d %%H:%%M:%%S', %s)", column)
+			query := fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s IS NOT NULL AND %s <> %s",
+				table, column, normalized, normalized, column, normalized)
+			if _, err := tx.Exec(query); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// initMigrationsTable initialise la table des versions du schéma
+func (db *Database) initMigrationsTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+
+	_, err := db.exec(query)
+	return err
+}
+
+// LatestVersion retourne la version la plus récente du schéma
+func LatestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion retourne la version actuelle du schéma de la base (0 si aucune migration)
+func (db *Database) SchemaVersion() (int, error) {
+	var version int
+	if err := db.queryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("erreur lors de la lecture de la version du schéma: %w", err)
+	}
+	return version, nil
+}
+
+// MigrationStatuses retourne toutes les migrations connues, avec leur date d'application
+func (db *Database) MigrationStatuses() ([]MigrationStatus, error) {
+	rows, err := db.query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la lecture des migrations appliquées: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt timestamp
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("erreur lors de la lecture des migrations appliquées: %w", err)
+		}
+		applied[version] = appliedAt.Time
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if appliedAt, exists := applied[migration.Version]; exists {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// PendingMigrations retourne les migrations qui restent à appliquer
+func (db *Database) PendingMigrations() ([]Migration, error) {
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if migration.Version > current {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// Migrate amène le schéma à la version target, en appliquant les migrations montantes ou
+// en annulant les migrations plus récentes. Une copie de la base est faite avant tout changement.
+func (db *Database) Migrate(target int) error {
+	if target < 0 || target > LatestVersion() {
+		return fmt.Errorf("version de schéma inconnue: %d (dernière version: %d)", target, LatestVersion())
+	}
+
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if current == target {
+		return nil
+	}
+	if current > LatestVersion() {
+		return fmt.Errorf("la base est en version %d, plus récente que ce programme (%d)", current, LatestVersion())
+	}
+
+	backupPath, err := db.backupBeforeMigration(current)
+	if err != nil {
+		return err
+	}
+	if backupPath != "" {
+		log.Printf("Copie de la base avant migration: %s", backupPath)
+	}
+
+	if target > current {
+		for _, migration := range migrations {
+			if migration.Version > current && migration.Version <= target {
+				if err := db.applyMigration(migration, true); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if migration.Version <= current && migration.Version > target {
+			if err := db.applyMigration(migration, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// MigrateToLatest applique toutes les migrations en attente
+func (db *Database) MigrateToLatest() error {
+	return db.Migrate(LatestVersion())
+}
+
+// applyMigration applique (up) ou annule une migration dans une transaction
+func (db *Database) applyMigration(migration Migration, up bool) error {
+	direction := "down"
+	if up {
+		direction = "up"
+	}
+
+	tx, err := db.begin()
+	if err != nil {
+		return fmt.Errorf("erreur lors du démarrage de la migration %d: %w", migration.Version, err)
+	}
+	defer tx.Rollback()
+
+	if up {
+		err = migration.Up(tx)
+		if err == nil {
+			_, err = tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", migration.Version, migration.Name)
+		}
+	} else {
+		err = migration.Down(tx)
+		if err == nil {
+			_, err = tx.Exec("DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("erreur lors de la migration %d (%s, %s): %w", migration.Version, migration.Name, direction, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("erreur lors de la validation de la migration %d: %w", migration.Version, err)
+	}
+
+	log.Printf("Migration %d (%s) appliquée: %s", migration.Version, migration.Name, direction)
+	return nil
+}
+
+// Backup écrit une copie cohérente de la base SQLite dans dest, même pendant son utilisation.
+// Les bases PostgreSQL se sauvegardent avec pg_dump.
+func (db *Database) Backup(dest string) error {
+	if db.driver != DriverSQLite {
+		return fmt.Errorf("sauvegarde non supportée pour %s, utilisez les outils du serveur", db.driver)
+	}
+
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("le fichier de sauvegarde existe déjà: %s", dest)
+	}
+
+	if _, err := db.exec("VACUUM INTO ?", dest); err != nil {
+		return fmt.Errorf("erreur lors de la sauvegarde de la base: %w", err)
+	}
+
+	return nil
+}
+
+// backupBeforeMigration copie la base à côté de son fichier avant une migration.
+// Une base vide, en mémoire ou PostgreSQL n'est pas copiée.
+func (db *Database) backupBeforeMigration(version int) (string, error) {
+	if db.driver != DriverSQLite || isMemoryPath(db.path) {
+		return "", nil
+	}
+
+	exists, err := db.dialect.tableExists(db.db, "uploads")
+	if err != nil {
+		return "", fmt.Errorf("erreur lors de l'inspection de la base: %w", err)
+	}
+	if !exists {
+		return "", nil
+	}
+
+	// Un suffixe évite d'écraser une copie faite dans la même seconde
+	dest := fmt.Sprintf("%s.v%d-%s.bak", db.path, version, time.Now().Format("20060102-150405"))
+	for i := 1; ; i++ {
+		if _, err := os.Stat(dest); os.IsNotExist(err) {
+			break
+		}
+		dest = fmt.Sprintf("%s.v%d-%s-%d.bak", db.path, version, time.Now().Format("20060102-150405"), i)
+	}
+
+	if err := db.Backup(dest); err != nil {
+		return "", err
+	}
+
+	return dest, nil
+}
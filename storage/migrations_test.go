@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// insertVersion ajoute un upload du même film avec le statut donné
+func insertVersion(t *testing.T, db *Database, status string) int64 {
+	t.Helper()
+
+	result, err := db.exec(`
+		INSERT INTO uploads (tmdb_id, title, type, file_path, upload_status)
+		VALUES (10, 'Film', 'movie', '/media/film.mkv', ?)
+	`, status)
+	if err != nil {
+		t.Fatalf("insertion de l'upload: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatalf("LastInsertId: %v", err)
+	}
+	return id
+}
+
+// supersededBy retourne l'upload qui remplace l'upload donné, ou 0
+func supersededBy(t *testing.T, db *Database, id int64) int64 {
+	t.Helper()
+
+	var replacement sql.NullInt64
+	if err := db.queryRow("SELECT superseded_by FROM uploads WHERE id = ?", id).Scan(&replacement); err != nil {
+		t.Fatalf("lecture de superseded_by: %v", err)
+	}
+	return replacement.Int64
+}
+
+// TestUploadVersionsBackfill vérifie qu'un upload n'est remplacé que par un upload suivant
+// terminé, même partiellement
+func TestUploadVersionsBackfill(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "uploads.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Migrate(12); err != nil {
+		t.Fatalf("Migrate(12): %v", err)
+	}
+
+	first := insertVersion(t, db, StatusCompleted)
+	failed := insertVersion(t, db, StatusFailed)
+	partial := insertVersion(t, db, StatusPartial)
+	pending := insertVersion(t, db, StatusPending)
+
+	if err := db.Migrate(13); err != nil {
+		t.Fatalf("Migrate(13): %v", err)
+	}
+
+	if got := supersededBy(t, db, first); got != partial {
+		t.Fatalf("upload %d remplacé par %d, attendu %d", first, got, partial)
+	}
+	if got := supersededBy(t, db, failed); got != partial {
+		t.Fatalf("upload %d remplacé par %d, attendu %d", failed, got, partial)
+	}
+	if got := supersededBy(t, db, partial); got != 0 {
+		t.Fatalf("dernière version disponible remplacée par l'upload %d en attente", got)
+	}
+	if got := supersededBy(t, db, pending); got != 0 {
+		t.Fatalf("upload %d remplacé par %d", pending, got)
+	}
+}
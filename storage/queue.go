@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -15,38 +16,82 @@ func (db *Database) AddToQueue(taskType string, payload interface{}, maxAttempts
 	}
 
 	query := `
-	INSERT INTO queue (type, payload, status, max_attempts, updated_at)
-	VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+	INSERT INTO queue (type, payload, link_id, status, max_attempts, updated_at)
+	VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
 	`
 
-	result, err := db.db.Exec(query, taskType, string(payloadJSON), QueueStatusPending, maxAttempts)
+	id, err := db.insert(query, taskType, string(payloadJSON), payloadLinkID(payloadJSON), QueueStatusPending, maxAttempts)
 	if err != nil {
 		return 0, fmt.Errorf("erreur lors de l'ajout à la queue: %w", err)
 	}
 
-	id, err := result.LastInsertId()
+	return id, nil
+}
+
+// payloadLinkID retourne le lien concerné par une tâche, ou nil si son payload n'en a pas
+func payloadLinkID(payload []byte) interface{} {
+	var task struct {
+		LinkID int64 `json:"link_id"`
+	}
+	if err := json.Unmarshal(payload, &task); err != nil || task.LinkID == 0 {
+		return nil
+	}
+	return task.LinkID
+}
+
+// HasQueuedLinkTask indique si une tâche de ce type attend ou est en cours pour le lien.
+// Une tâche échouée compte tant qu'il lui reste des tentatives.
+func (db *Database) HasQueuedLinkTask(taskType string, linkID int64) (bool, error) {
+	query := `
+	SELECT COUNT(*) FROM queue
+	WHERE link_id = ? AND type = ?
+		AND (status IN (?, ?) OR (status = ? AND attempts < max_attempts))
+	`
+
+	var count int
+	err := db.queryRow(query, linkID, taskType, QueueStatusPending, QueueStatusProcessing, QueueStatusFailed).Scan(&count)
 	if err != nil {
-		return 0, fmt.Errorf("erreur lors de la récupération de l'ID: %w", err)
+		return false, fmt.Errorf("erreur lors de la recherche des tâches du lien %d: %w", linkID, err)
 	}
 
-	return id, nil
+	return count > 0, nil
 }
 
-// GetNextQueueItem récupère la prochaine tâche à traiter
-func (db *Database) GetNextQueueItem() (*QueueItem, error) {
+// ClaimNextQueueItem réserve la prochaine tâche à traiter, en ignorant les types exclus:
+// la tâche passe en traitement et son nombre de tentatives est incrémenté dans la même requête,
+// pour qu'elle ne soit jamais confiée à deux workers ou deux instances
+func (db *Database) ClaimNextQueueItem(excludedTypes ...string) (*QueueItem, error) {
 	query := `
-	SELECT id, type, payload, status, attempts, max_attempts, created_at, updated_at, processed_at
-	FROM queue
-	WHERE status = ? OR (status = ? AND attempts < max_attempts)
-	ORDER BY created_at ASC
-	LIMIT 1
+	UPDATE queue
+	SET status = ?, attempts = attempts + 1, updated_at = CURRENT_TIMESTAMP
+	WHERE id = (
+		SELECT id
+		FROM queue
+		WHERE (status = ? OR (status = ? AND attempts < max_attempts))
+	`
+	args := []interface{}{QueueStatusProcessing, QueueStatusPending, QueueStatusFailed}
+
+	if len(excludedTypes) > 0 {
+		placeholders := strings.Repeat("?, ", len(excludedTypes)-1) + "?"
+		query += " AND type NOT IN (" + placeholders + ")"
+		for _, taskType := range excludedTypes {
+			args = append(args, taskType)
+		}
+	}
+
+	query += `
+		ORDER BY created_at ASC, id ASC
+		LIMIT 1
+		` + db.dialect.skipLocked() + `
+	)
+	RETURNING id, type, payload, status, attempts, max_attempts, created_at, updated_at, processed_at
 	`
 
 	var item QueueItem
-	var createdAt, updatedAt string
-	var processedAt sql.NullString
+	var createdAt, updatedAt timestamp
+	var processedAt timestamp
 
-	err := db.db.QueryRow(query, QueueStatusPending, QueueStatusFailed).Scan(
+	err := db.queryRow(query, args...).Scan(
 		&item.ID,
 		&item.Type,
 		&item.Payload,
@@ -63,16 +108,13 @@ func (db *Database) GetNextQueueItem() (*QueueItem, error) {
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("erreur lors de la récupération de la tâche: %w", err)
+		return nil, fmt.Errorf("erreur lors de la réservation de la tâche: %w", err)
 	}
 
-	item.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAt)
-	item.UpdatedAt, _ = time.Parse("2006-01-02 15:04:05", updatedAt)
+	item.CreatedAt = createdAt.Time
+	item.UpdatedAt = updatedAt.Time
 
-	if processedAt.Valid {
-		t, _ := time.Parse("2006-01-02 15:04:05", processedAt.String)
-		item.ProcessedAt = &t
-	}
+	item.ProcessedAt = processedAt.ptr()
 
 	return &item, nil
 }
@@ -85,7 +127,7 @@ func (db *Database) UpdateQueueItemStatus(id int64, status QueueStatus) error {
 	WHERE id = ?
 	`
 
-	_, err := db.db.Exec(query, status, id)
+	_, err := db.exec(query, status, id)
 	if err != nil {
 		return fmt.Errorf("erreur lors de la mise à jour du statut: %w", err)
 	}
@@ -93,22 +135,6 @@ func (db *Database) UpdateQueueItemStatus(id int64, status QueueStatus) error {
 	return nil
 }
 
-// MarkQueueItemProcessing marque une tâche comme étant en cours de traitement
-func (db *Database) MarkQueueItemProcessing(id int64) error {
-	query := `
-	UPDATE queue
-	SET status = ?, attempts = attempts + 1, updated_at = CURRENT_TIMESTAMP
-	WHERE id = ?
-	`
-
-	_, err := db.db.Exec(query, QueueStatusProcessing, id)
-	if err != nil {
-		return fmt.Errorf("erreur lors du marquage de la tâche: %w", err)
-	}
-
-	return nil
-}
-
 // MarkQueueItemCompleted marque une tâche comme terminée
 func (db *Database) MarkQueueItemCompleted(id int64) error {
 	query := `
@@ -117,7 +143,7 @@ func (db *Database) MarkQueueItemCompleted(id int64) error {
 	WHERE id = ?
 	`
 
-	_, err := db.db.Exec(query, QueueStatusCompleted, id)
+	_, err := db.exec(query, QueueStatusCompleted, id)
 	if err != nil {
 		return fmt.Errorf("erreur lors du marquage de la tâche: %w", err)
 	}
@@ -133,7 +159,7 @@ func (db *Database) MarkQueueItemFailed(id int64) error {
 	WHERE id = ?
 	`
 
-	_, err := db.db.Exec(query, QueueStatusFailed, id)
+	_, err := db.exec(query, QueueStatusFailed, id)
 	if err != nil {
 		return fmt.Errorf("erreur lors du marquage de la tâche: %w", err)
 	}
@@ -150,18 +176,23 @@ func (db *Database) GetPendingQueueItems() ([]*QueueItem, error) {
 	ORDER BY created_at ASC
 	`
 
-	rows, err := db.db.Query(query, QueueStatusPending, QueueStatusProcessing, QueueStatusFailed)
+	rows, err := db.query(query, QueueStatusPending, QueueStatusProcessing, QueueStatusFailed)
 	if err != nil {
 		return nil, fmt.Errorf("erreur lors de la récupération des tâches: %w", err)
 	}
 	defer rows.Close()
 
+	return scanQueueItems(rows)
+}
+
+// scanQueueItems lit les tâches retournées par une requête
+func scanQueueItems(rows *sql.Rows) ([]*QueueItem, error) {
 	var items []*QueueItem
 
 	for rows.Next() {
 		var item QueueItem
-		var createdAt, updatedAt string
-		var processedAt sql.NullString
+		var createdAt, updatedAt timestamp
+		var processedAt timestamp
 
 		err := rows.Scan(
 			&item.ID,
@@ -179,32 +210,27 @@ func (db *Database) GetPendingQueueItems() ([]*QueueItem, error) {
 			return nil, fmt.Errorf("erreur lors de la lecture de la tâche: %w", err)
 		}
 
-		item.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdAt)
-		item.UpdatedAt, _ = time.Parse("2006-01-02 15:04:05", updatedAt)
+		item.CreatedAt = createdAt.Time
+		item.UpdatedAt = updatedAt.Time
 
-		if processedAt.Valid {
-			t, _ := time.Parse("2006-01-02 15:04:05", processedAt.String)
-			item.ProcessedAt = &t
-		}
+		item.ProcessedAt = processedAt.ptr()
 
 		items = append(items, &item)
 	}
 
-	return items, nil
+	return items, rows.Err()
 }
 
-// ResetStuckQueueItems réinitialise les tâches bloquées en traitement
-func (db *Database) ResetStuckQueueItems() (int, error) {
-	// Considère comme "bloquées" les tâches en traitement depuis plus de 30 minutes
-	thirtyMinutesAgo := time.Now().Add(-30 * time.Minute).Format("2006-01-02 15:04:05")
-
+// ResetStuckQueueItems remet en attente les tâches en traitement qui n'ont pas été mises
+// à jour depuis updatedBefore, considérées comme bloquées
+func (db *Database) ResetStuckQueueItems(updatedBefore time.Time) (int, error) {
 	query := `
 	UPDATE queue
 	SET status = ?
 	WHERE status = ? AND updated_at < ?
 	`
 
-	result, err := db.db.Exec(query, QueueStatusPending, QueueStatusProcessing, thirtyMinutesAgo)
+	result, err := db.exec(query, QueueStatusPending, QueueStatusProcessing, formatTimestamp(updatedBefore))
 	if err != nil {
 		return 0, fmt.Errorf("erreur lors de la réinitialisation des tâches bloquées: %w", err)
 	}
@@ -217,16 +243,14 @@ func (db *Database) ResetStuckQueueItems() (int, error) {
 	return int(count), nil
 }
 
-// CleanupOldCompletedItems supprime les tâches terminées anciennes
-func (db *Database) CleanupOldCompletedItems(days int) (int, error) {
-	cutoffDate := time.Now().AddDate(0, 0, -days).Format("2006-01-02 15:04:05")
-
+// CleanupOldCompletedItems supprime les tâches terminées avant processedBefore
+func (db *Database) CleanupOldCompletedItems(processedBefore time.Time) (int, error) {
 	query := `
 	DELETE FROM queue
 	WHERE status = ? AND processed_at < ?
 	`
 
-	result, err := db.db.Exec(query, QueueStatusCompleted, cutoffDate)
+	result, err := db.exec(query, QueueStatusCompleted, formatTimestamp(processedBefore))
 	if err != nil {
 		return 0, fmt.Errorf("erreur lors du nettoyage des tâches anciennes: %w", err)
 	}
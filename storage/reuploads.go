@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// AddLinkReupload enregistre le lancement d'un réupload pour un lien mort
+func (db *Database) AddLinkReupload(uploadID int64, hoster string, linkID int64) error {
+	query := `
+	INSERT INTO link_reuploads (upload_id, hoster, link_id)
+	VALUES (?, ?, ?)
+	`
+
+	_, err := db.exec(query, uploadID, hoster, linkID)
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'enregistrement du réupload: %w", err)
+	}
+
+	return nil
+}
+
+// CountLinkReuploadsSince compte les réuploads lancés pour un upload et un hébergeur depuis une date
+func (db *Database) CountLinkReuploadsSince(uploadID int64, hoster string, since time.Time) (int, error) {
+	query := `
+	SELECT COUNT(*)
+	FROM link_reuploads
+	WHERE upload_id = ? AND hoster = ? AND created_at >= ?
+	`
+
+	var count int
+	err := db.queryRow(query, uploadID, hoster, formatTimestamp(since)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors du comptage des réuploads: %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// UploadSession représente l'état persistant d'un upload reprenable vers un hébergeur
+type UploadSession struct {
+	ID        int64
+	UploadID  int64
+	Hoster    string
+	SessionID string
+	FilePath  string
+	TotalSize int64
+	// FileModTime est la date de modification du fichier au début de l'envoi
+	FileModTime time.Time
+	Offset      int64
+	ChunkSize   int64
+	Data        string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// GetUploadSession récupère la session reprenable d'un upload pour un hébergeur
+func (db *Database) GetUploadSession(uploadID int64, hoster string) (*UploadSession, error) {
+	query := `
+	SELECT id, upload_id, hoster, session_id, file_path, total_size, file_mtime, confirmed_offset, chunk_size, data, created_at, updated_at
+	FROM upload_sessions
+	WHERE upload_id = ? AND hoster = ?
+	`
+
+	var session UploadSession
+	var fileModTime, createdAt, updatedAt timestamp
+
+	err := db.queryRow(query, uploadID, hoster).Scan(
+		&session.ID,
+		&session.UploadID,
+		&session.Hoster,
+		&session.SessionID,
+		&session.FilePath,
+		&session.TotalSize,
+		&fileModTime,
+		&session.Offset,
+		&session.ChunkSize,
+		&session.Data,
+		&createdAt,
+		&updatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la récupération de la session d'upload: %w", err)
+	}
+
+	session.FileModTime = fileModTime.Time
+	session.CreatedAt = createdAt.Time
+	session.UpdatedAt = updatedAt.Time
+
+	return &session, nil
+}
+
+// SaveUploadSession crée ou met à jour la session reprenable d'un upload pour un hébergeur
+func (db *Database) SaveUploadSession(session *UploadSession) error {
+	query := `
+	INSERT INTO upload_sessions (
+		upload_id, hoster, session_id, file_path, total_size, file_mtime, confirmed_offset, chunk_size, data, updated_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	ON CONFLICT (upload_id, hoster) DO UPDATE SET
+		session_id = excluded.session_id,
+		file_path = excluded.file_path,
+		total_size = excluded.total_size,
+		file_mtime = excluded.file_mtime,
+		confirmed_offset = excluded.confirmed_offset,
+		chunk_size = excluded.chunk_size,
+		data = excluded.data,
+		updated_at = CURRENT_TIMESTAMP
+	`
+
+	_, err := db.exec(
+		query,
+		session.UploadID,
+		session.Hoster,
+		session.SessionID,
+		session.FilePath,
+		session.TotalSize,
+		formatTimestamp(session.FileModTime),
+		session.Offset,
+		session.ChunkSize,
+		session.Data,
+	)
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'enregistrement de la session d'upload: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteUploadSession supprime la session reprenable d'un upload pour un hébergeur
+func (db *Database) DeleteUploadSession(uploadID int64, hoster string) error {
+	query := `
+	DELETE FROM upload_sessions
+	WHERE upload_id = ? AND hoster = ?
+	`
+
+	_, err := db.exec(query, uploadID, hoster)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la suppression de la session d'upload: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package storagetest
+
+import (
+	"fmt"
+	"testing"
+
+	"media-upload-system/storage"
+)
+
+// BenchmarkRows est le nombre d'uploads, de liens et de tâches de la base des benchmarks
+const BenchmarkRows = 100000
+
+// Seed remplit le stockage avec rows films, un lien par film et rows tâches terminées
+func Seed(tb testing.TB, store storage.Store, rows int) {
+	tb.Helper()
+
+	for i := 1; i <= rows; i++ {
+		id, err := store.AddUpload(&storage.Upload{
+			TmdbID:       i,
+			Title:        fmt.Sprintf("Film %d", i),
+			Type:         storage.TypeMovie,
+			FilePath:     fmt.Sprintf("/media/film-%d.mkv", i),
+			UploadStatus: storage.StatusCompleted,
+		})
+		if err != nil {
+			tb.Fatalf("AddUpload: %v", err)
+		}
+
+		link := storage.HostedLink{Hoster: "mixdrop", FileCode: fmt.Sprintf("code%d", i)}
+		if err := store.AddUploadLink(id, link); err != nil {
+			tb.Fatalf("AddUploadLink: %v", err)
+		}
+
+		taskID, err := store.AddToQueue("movie_upload", storage.TaskPayload{UploadID: id}, 3)
+		if err != nil {
+			tb.Fatalf("AddToQueue: %v", err)
+		}
+		if err := store.UpdateQueueItemStatus(taskID, storage.QueueStatusCompleted); err != nil {
+			tb.Fatalf("UpdateQueueItemStatus: %v", err)
+		}
+	}
+}
+
+// RunBenchmarks mesure la latence des recherches et de la queue sur une base de BenchmarkRows lignes
+func RunBenchmarks(b *testing.B, open Opener) {
+	store := open(b)
+	Seed(b, store, BenchmarkRows)
+
+	b.Run("CheckExistingUpload", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tmdbID := i%BenchmarkRows + 1
+			upload, err := store.CheckExistingUpload(tmdbID, storage.TypeMovie, nil, nil)
+			if err != nil || upload == nil {
+				b.Fatalf("CheckExistingUpload(%d): %v, %v", tmdbID, upload, err)
+			}
+		}
+	})
+
+	b.Run("GetUploadLinks", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := store.GetUploadLinks(int64(i%BenchmarkRows + 1)); err != nil {
+				b.Fatalf("GetUploadLinks: %v", err)
+			}
+		}
+	})
+
+	b.Run("AddToQueue", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := store.AddToQueue("link_check", storage.TaskPayload{LinkID: int64(i)}, 3); err != nil {
+				b.Fatalf("AddToQueue: %v", err)
+			}
+		}
+	})
+
+	// Réservation puis fin d'une tâche, comme le fait la boucle du gestionnaire de queue
+	b.Run("ClaimAndComplete", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			item, err := store.ClaimNextQueueItem()
+			if err != nil {
+				b.Fatalf("ClaimNextQueueItem: %v", err)
+			}
+			if item == nil {
+				b.StopTimer()
+				if _, err := store.AddToQueue("link_check", storage.TaskPayload{}, 3); err != nil {
+					b.Fatalf("AddToQueue: %v", err)
+				}
+				b.StartTimer()
+				continue
+			}
+			if err := store.MarkQueueItemCompleted(item.ID); err != nil {
+				b.Fatalf("MarkQueueItemCompleted: %v", err)
+			}
+		}
+	})
+
+	// Interrogation d'une queue vide, le cas le plus fréquent
+	b.Run("ClaimEmptyQueue", func(b *testing.B) {
+		for {
+			item, err := store.ClaimNextQueueItem()
+			if err != nil {
+				b.Fatalf("ClaimNextQueueItem: %v", err)
+			}
+			if item == nil {
+				break
+			}
+			store.MarkQueueItemCompleted(item.ID)
+		}
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := store.ClaimNextQueueItem(); err != nil {
+				b.Fatalf("ClaimNextQueueItem: %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,15 @@
+package storagetest_test
+
+import (
+	"os"
+	"testing"
+
+	"media-upload-system/storage/storagetest"
+)
+
+func TestPostgres(t *testing.T) {
+	if os.Getenv(storagetest.PostgresDSNEnv) == "" {
+		t.Skipf("%s non défini, tests PostgreSQL ignorés", storagetest.PostgresDSNEnv)
+	}
+	storagetest.Run(t, storagetest.OpenPostgres)
+}
@@ -0,0 +1,17 @@
+package storagetest_test
+
+import (
+	"testing"
+
+	"media-upload-system/storage/storagetest"
+)
+
+func TestSQLite(t *testing.T) {
+	storagetest.Run(t, storagetest.OpenSQLite)
+}
+
+// BenchmarkSQLite remplit une base de BenchmarkRows uploads, liens et tâches avant de mesurer
+// les requêtes: go test -bench . -run '^$' ./storage/storagetest
+func BenchmarkSQLite(b *testing.B) {
+	storagetest.RunBenchmarks(b, storagetest.OpenSQLite)
+}
@@ -0,0 +1,1041 @@
+// Package storagetest contient la suite de conformité des moteurs de stockage: chaque
+// implémentation de storage.Store doit s'y comporter de la même façon.
+//
+// Depuis un fichier de test:
+//
+//	func TestSQLite(t *testing.T)   { storagetest.Run(t, storagetest.OpenSQLite) }
+//	func TestPostgres(t *testing.T) { storagetest.Run(t, storagetest.OpenPostgres) }
+//
+// Les benchmarks mesurent la latence de la queue et des recherches sur une base remplie:
+//
+//	func BenchmarkSQLite(b *testing.B) { storagetest.RunBenchmarks(b, storagetest.OpenSQLite) }
+//
+// OpenPostgres utilise la base indiquée par la variable STORAGE_TEST_POSTGRES_DSN et ignore
+// les tests si elle n'est pas définie. Le schéma de cette base est recréé à chaque test.
+package storagetest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"media-upload-system/storage"
+)
+
+// PostgresDSNEnv est la variable d'environnement qui désigne la base PostgreSQL de test
+const PostgresDSNEnv = "STORAGE_TEST_POSTGRES_DSN"
+
+// Opener ouvre un stockage vide, au schéma à jour, fermé à la fin du test ou du benchmark
+type Opener func(tb testing.TB) storage.Store
+
+// OpenSQLite ouvre une base SQLite dans un répertoire temporaire
+func OpenSQLite(tb testing.TB) storage.Store {
+	tb.Helper()
+
+	store, err := storage.NewDatabase(filepath.Join(tb.TempDir(), "uploads.db"))
+	if err != nil {
+		tb.Fatalf("ouverture de la base SQLite: %v", err)
+	}
+	return prepare(tb, store)
+}
+
+// OpenPostgres ouvre la base PostgreSQL de test et recrée son schéma
+func OpenPostgres(tb testing.TB) storage.Store {
+	tb.Helper()
+
+	dsn := os.Getenv(PostgresDSNEnv)
+	if dsn == "" {
+		tb.Skipf("%s non défini, tests PostgreSQL ignorés", PostgresDSNEnv)
+	}
+
+	store, err := storage.NewPostgresDatabase(dsn)
+	if err != nil {
+		tb.Fatalf("ouverture de la base PostgreSQL: %v", err)
+	}
+
+	// Repartir d'une base vide en annulant toutes les migrations
+	if err := store.Migrate(0); err != nil {
+		store.Close()
+		tb.Fatalf("remise à zéro du schéma: %v", err)
+	}
+	return prepare(tb, store)
+}
+
+// prepare applique les migrations et ferme le stockage à la fin du test
+func prepare(tb testing.TB, store storage.Store) storage.Store {
+	tb.Helper()
+
+	tb.Cleanup(func() { store.Close() })
+	if err := store.MigrateToLatest(); err != nil {
+		tb.Fatalf("migration du schéma: %v", err)
+	}
+	return store
+}
+
+// Run exécute la suite de conformité sur les stockages ouverts par open
+func Run(t *testing.T, open Opener) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, store storage.Store)
+	}{
+		{"Uploads", testUploads},
+		{"UploadMedia", testUploadMedia},
+		{"UploadVersions", testUploadVersions},
+		{"Fingerprints", testFingerprints},
+		{"Catalog", testCatalog},
+		{"Links", testLinks},
+		{"Events", testEvents},
+		{"Queue", testQueue},
+		{"QueueConcurrentClaims", testQueueConcurrentClaims},
+		{"Timestamps", testTimestamps},
+		{"Sessions", testSessions},
+		{"Usage", testUsage},
+		{"Folders", testFolders},
+		{"Subtitles", testSubtitles},
+		{"Migrations", testMigrations},
+		{"ExportImport", testExportImport},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, open(t))
+		})
+	}
+}
+
+// testUploadMedia vérifie que les informations du fichier d'un upload sont conservées
+func testUploadMedia(t *testing.T, store storage.Store) {
+	media := storage.UploadMedia{
+		Quality:           "Bluray-1080p",
+		QualityVersion:    2,
+		Size:              8 << 30,
+		Width:             1920,
+		Height:            1080,
+		VideoCodec:        "x265",
+		VideoDynamicRange: "HDR10",
+		AudioCodec:        "EAC3",
+		AudioChannels:     5.1,
+		AudioLanguages:    []string{"fre", "eng"},
+		SubtitleLanguages: []string{"fre"},
+		Languages:         []string{"French", "English"},
+		ReleaseGroup:      "GROUPE",
+		ReleaseTitle:      "Film.2020.MULTi.1080p.BluRay.x265-GROUPE",
+		Indexer:           "Indexeur",
+		DownloadClient:    "qBittorrent",
+		DownloadID:        "0123456789ABCDEF0123456789ABCDEF01234567",
+		CustomFormatScore: 150,
+	}
+	id := addUpload(t, store, storage.Upload{TmdbID: 30, Title: "Film", Media: media})
+	emptyID := addUpload(t, store, storage.Upload{TmdbID: 31, Title: "Ancien film"})
+
+	upload, err := store.GetUpload(id)
+	if err != nil {
+		t.Fatalf("GetUpload: %v", err)
+	}
+	if !reflect.DeepEqual(upload.Media, media) {
+		t.Fatalf("GetUpload: informations du fichier perdues:\n%+v\nattendu:\n%+v", upload.Media, media)
+	}
+
+	existing, err := store.CheckExistingUpload(30, storage.TypeMovie, nil, nil)
+	if err != nil || existing == nil || !reflect.DeepEqual(existing.Media, media) {
+		t.Fatalf("CheckExistingUpload: %+v, %v", existing, err)
+	}
+
+	upload, err = store.GetUpload(emptyID)
+	if err != nil {
+		t.Fatalf("GetUpload: %v", err)
+	}
+	if upload.Media.Known() {
+		t.Fatalf("GetUpload: informations inattendues pour un upload sans fichier: %+v", upload.Media)
+	}
+}
+
+// testUploadVersions vérifie la numérotation et le remplacement des versions d'un média
+func testUploadVersions(t *testing.T, store storage.Store) {
+	season, episode, other := 1, 1, 2
+	firstID := addUpload(t, store, storage.Upload{TmdbID: 40, Title: "Film"})
+	secondID := addUpload(t, store, storage.Upload{TmdbID: 40, Title: "Film"})
+	episodeID := addUpload(t, store, storage.Upload{TmdbID: 40, Title: "Série", Type: storage.TypeSeries, Season: &season, Episode: &episode})
+	otherID := addUpload(t, store, storage.Upload{TmdbID: 40, Title: "Série", Type: storage.TypeSeries, Season: &season, Episode: &other})
+
+	for id, version := range map[int64]int{firstID: 1, secondID: 2, episodeID: 1, otherID: 1} {
+		upload, err := store.GetUpload(id)
+		if err != nil {
+			t.Fatalf("GetUpload: %v", err)
+		}
+		if upload.Version != version || upload.SupersededBy != nil {
+			t.Fatalf("upload %d: version %d remplacée par %v, attendu version %d", id, upload.Version, upload.SupersededBy, version)
+		}
+	}
+
+	// Une nouvelle version qui n'a pas encore de miroirs ne remplace pas la précédente
+	if err := store.UpdateUploadStatus(firstID, storage.StatusPartial); err != nil {
+		t.Fatalf("UpdateUploadStatus: %v", err)
+	}
+	existing, err := store.CheckExistingUpload(40, storage.TypeMovie, nil, nil)
+	if err != nil || existing == nil || existing.ID != secondID {
+		t.Fatalf("CheckExistingUpload: dernière version attendue, obtenu %+v, %v", existing, err)
+	}
+
+	retries, err := store.GetUploadsToRetry(time.Now().Add(time.Hour), 3)
+	if err != nil || len(retries) != 1 {
+		t.Fatalf("GetUploadsToRetry: %d uploads, %v", len(retries), err)
+	}
+
+	if err := store.SupersedeUpload(firstID, secondID); err != nil {
+		t.Fatalf("SupersedeUpload: %v", err)
+	}
+	if err := store.UpdateUploadStatus(secondID, storage.StatusCompleted); err != nil {
+		t.Fatalf("UpdateUploadStatus: %v", err)
+	}
+	existing, err = store.CheckExistingUpload(40, storage.TypeMovie, nil, nil)
+	if err != nil || existing == nil || existing.ID != secondID || existing.Version != 2 {
+		t.Fatalf("CheckExistingUpload: version courante attendue, obtenu %+v, %v", existing, err)
+	}
+
+	versions, err := store.GetUploadVersions(40, storage.TypeMovie, nil, nil)
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("GetUploadVersions: %d versions, %v", len(versions), err)
+	}
+	if versions[0].ID != secondID || versions[1].ID != firstID {
+		t.Fatalf("GetUploadVersions: versions dans le désordre: %d, %d", versions[0].ID, versions[1].ID)
+	}
+	if versions[1].SupersededBy == nil || *versions[1].SupersededBy != secondID {
+		t.Fatalf("GetUploadVersions: remplacement perdu: %+v", versions[1])
+	}
+
+	// Une version remplacée n'est plus relancée
+	retries, err = store.GetUploadsToRetry(time.Now().Add(time.Hour), 3)
+	if err != nil || len(retries) != 0 {
+		t.Fatalf("GetUploadsToRetry: %d uploads, %v", len(retries), err)
+	}
+
+	versions, err = store.GetUploadVersions(40, storage.TypeSeries, &season, &other)
+	if err != nil || len(versions) != 1 || versions[0].ID != otherID {
+		t.Fatalf("GetUploadVersions: versions de l'épisode inattendues: %d, %v", len(versions), err)
+	}
+}
+
+// testCatalog vérifie la création des films, séries, saisons et épisodes avec les uploads et
+// le suivi des saisons complètes
+func testCatalog(t *testing.T, store storage.Store) {
+	season, first, second := 1, 1, 2
+	movieID := addUpload(t, store, storage.Upload{TmdbID: 60, Title: "Film"})
+	firstID := addUpload(t, store, storage.Upload{TmdbID: 61, Title: "Série", Type: storage.TypeSeries, Season: &season, Episode: &first})
+
+	movie, err := store.GetUpload(movieID)
+	if err != nil || movie.MediaID == 0 || movie.EpisodeID != nil {
+		t.Fatalf("GetUpload: film mal rattaché: %+v, %v", movie, err)
+	}
+	episode, err := store.GetUpload(firstID)
+	if err != nil || episode.MediaID == 0 || episode.MediaID == movie.MediaID || episode.EpisodeID == nil {
+		t.Fatalf("GetUpload: épisode mal rattaché: %+v, %v", episode, err)
+	}
+
+	// Les valeurs vides ne remplacent pas les identifiants déjà connus
+	mediaID, err := store.SaveMedia(&storage.Media{Type: storage.TypeSeries, TmdbID: 61, TvdbID: 610, ImdbID: "tt0610", Year: 2020})
+	if err != nil || mediaID != episode.MediaID {
+		t.Fatalf("SaveMedia: ID %d, %v, attendu %d", mediaID, err, episode.MediaID)
+	}
+	if _, err := store.SaveMedia(&storage.Media{Type: storage.TypeSeries, TmdbID: 61, Title: "Série renommée"}); err != nil {
+		t.Fatalf("SaveMedia: %v", err)
+	}
+	media, err := store.GetMediaByTmdbID(storage.TypeSeries, 61)
+	if err != nil || media == nil {
+		t.Fatalf("GetMediaByTmdbID: %+v, %v", media, err)
+	}
+	if media.TvdbID != 610 || media.ImdbID != "tt0610" || media.Year != 2020 || media.Title != "Série renommée" {
+		t.Fatalf("GetMediaByTmdbID: identifiants perdus: %+v", media)
+	}
+	if media, err := store.GetMediaByTmdbID(storage.TypeMovie, 61); err != nil || media != nil {
+		t.Fatalf("GetMediaByTmdbID: média inconnu attendu, obtenu %+v, %v", media, err)
+	}
+
+	if _, err := store.SaveEpisode(&storage.Episode{MediaID: mediaID, SeasonNumber: season, Number: second, Title: "Deuxième"}); err != nil {
+		t.Fatalf("SaveEpisode: %v", err)
+	}
+
+	// L'épisode 1 n'est pas encore disponible tant que son upload n'est pas terminé
+	seasons, err := store.GetSeasons(mediaID)
+	if err != nil || len(seasons) != 1 {
+		t.Fatalf("GetSeasons: %d saisons, %v", len(seasons), err)
+	}
+	if seasons[0].Episodes != 2 || seasons[0].Uploaded != 0 || seasons[0].Complete() {
+		t.Fatalf("GetSeasons: saison inattendue: %+v", seasons[0])
+	}
+
+	if err := store.UpdateUploadStatus(firstID, storage.StatusCompleted); err != nil {
+		t.Fatalf("UpdateUploadStatus: %v", err)
+	}
+	if err := store.SetSeasonEpisodeCount(mediaID, season, 2); err != nil {
+		t.Fatalf("SetSeasonEpisodeCount: %v", err)
+	}
+	seasons, err = store.GetSeasons(mediaID)
+	if err != nil || seasons[0].Uploaded != 1 || seasons[0].EpisodeCount != 2 || seasons[0].Complete() {
+		t.Fatalf("GetSeasons: saison inattendue: %+v, %v", seasons, err)
+	}
+
+	secondID := addUpload(t, store, storage.Upload{TmdbID: 61, Title: "Série", Type: storage.TypeSeries, Season: &season, Episode: &second})
+	if err := store.UpdateUploadStatus(secondID, storage.StatusPartial); err != nil {
+		t.Fatalf("UpdateUploadStatus: %v", err)
+	}
+
+	episodes, err := store.GetEpisodes(mediaID, season)
+	if err != nil || len(episodes) != 2 {
+		t.Fatalf("GetEpisodes: %d épisodes, %v", len(episodes), err)
+	}
+	if episodes[1].Number != second || episodes[1].Title != "Deuxième" || !episodes[0].Uploaded || !episodes[1].Uploaded {
+		t.Fatalf("GetEpisodes: épisodes inattendus: %+v", episodes)
+	}
+
+	seasons, err = store.GetSeasons(mediaID)
+	if err != nil || !seasons[0].Complete() {
+		t.Fatalf("GetSeasons: saison complète attendue: %+v, %v", seasons, err)
+	}
+
+	// La saison complète n'est signalée qu'une fois
+	for i, want := range []bool{true, false} {
+		marked, err := store.MarkSeasonCompleted(seasons[0].ID)
+		if err != nil || marked != want {
+			t.Fatalf("MarkSeasonCompleted %d: %v, %v, attendu %v", i, marked, err, want)
+		}
+	}
+	seasons, err = store.GetSeasons(mediaID)
+	if err != nil || seasons[0].CompletedAt == nil {
+		t.Fatalf("GetSeasons: date de complétion perdue: %+v, %v", seasons, err)
+	}
+}
+
+// testFingerprints vérifie la recherche d'un fichier identique sous un autre média et le
+// comptage des liens vers un même fichier
+func testFingerprints(t *testing.T, store storage.Store) {
+	firstID := addUpload(t, store, storage.Upload{TmdbID: 10, Title: "Film", Fingerprint: "abc"})
+	secondID := addUpload(t, store, storage.Upload{TmdbID: 11, Title: "Mauvais film", Fingerprint: "abc"})
+
+	// Seul un upload terminé est réutilisable
+	match, err := store.FindUploadByFingerprint("abc", secondID)
+	if err != nil || match != nil {
+		t.Fatalf("FindUploadByFingerprint avant la fin de l'upload: %+v, %v", match, err)
+	}
+
+	if err := store.UpdateUploadStatus(firstID, storage.StatusCompleted); err != nil {
+		t.Fatalf("UpdateUploadStatus: %v", err)
+	}
+	if err := store.UpdateUploadHash(firstID, "sha"); err != nil {
+		t.Fatalf("UpdateUploadHash: %v", err)
+	}
+	match, err = store.FindUploadByFingerprint("abc", secondID)
+	if err != nil || match == nil || match.ID != firstID || match.Fingerprint != "abc" || match.ContentSHA256 != "sha" {
+		t.Fatalf("FindUploadByFingerprint: %+v, %v", match, err)
+	}
+
+	// L'upload lui-même et les empreintes vides ne correspondent jamais
+	match, err = store.FindUploadByFingerprint("abc", firstID)
+	if err != nil || match != nil {
+		t.Fatalf("FindUploadByFingerprint sur lui-même: %+v, %v", match, err)
+	}
+	match, err = store.FindUploadByFingerprint("", secondID)
+	if err != nil || match != nil {
+		t.Fatalf("FindUploadByFingerprint sans empreinte: %+v, %v", match, err)
+	}
+
+	link := storage.HostedLink{Hoster: "mixdrop", FileCode: "shared", Embed: "https://mixdrop/e/shared"}
+	for _, id := range []int64{firstID, secondID} {
+		if err := store.AddUploadLink(id, link); err != nil {
+			t.Fatalf("AddUploadLink: %v", err)
+		}
+	}
+	count, err := store.CountFileLinks("mixdrop", "shared")
+	if err != nil || count != 2 {
+		t.Fatalf("CountFileLinks: %d, %v", count, err)
+	}
+}
+
+// testEvents vérifie que les changements des uploads et des liens sont enregistrés dans le
+// journal avec leur auteur et leur identifiant de corrélation
+func testEvents(t *testing.T, store storage.Store) {
+	webhook := store.WithActor(storage.ActorWebhook, "webhook-1")
+	uploadID := addUpload(t, webhook, storage.Upload{TmdbID: 50, Title: "Film"})
+	otherID := addUpload(t, store, storage.Upload{TmdbID: 51, Title: "Autre film"})
+
+	// La tâche reprend l'identifiant du webhook qui l'a créée
+	worker := store.WithActor(storage.ActorWorker, "webhook-1")
+	if err := worker.UpdateUploadStatus(uploadID, storage.StatusUploading); err != nil {
+		t.Fatalf("UpdateUploadStatus: %v", err)
+	}
+	// Un statut inchangé n'est pas enregistré
+	if err := worker.UpdateUploadStatus(uploadID, storage.StatusUploading); err != nil {
+		t.Fatalf("UpdateUploadStatus: %v", err)
+	}
+	if err := worker.AddUploadLink(uploadID, storage.HostedLink{Hoster: "mixdrop", FileCode: "abc", URL: "https://mixdrop/f/abc"}); err != nil {
+		t.Fatalf("AddUploadLink: %v", err)
+	}
+	links, err := store.GetUploadLinks(uploadID)
+	if err != nil || len(links) != 1 {
+		t.Fatalf("GetUploadLinks: %d liens, %v", len(links), err)
+	}
+	linkID := links[0].ID
+
+	if err := store.WithActor(storage.ActorScheduler, "").UpdateLinkCheck(linkID, storage.LinkStatusDead, 2); err != nil {
+		t.Fatalf("UpdateLinkCheck: %v", err)
+	}
+	admin := store.WithActor(storage.ActorAdmin, "requete-1")
+	if err := admin.DeleteUploadLink(linkID); err != nil {
+		t.Fatalf("DeleteUploadLink: %v", err)
+	}
+	if err := admin.UpdateUploadTitle(uploadID, "Film renommé"); err != nil {
+		t.Fatalf("UpdateUploadTitle: %v", err)
+	}
+
+	events, err := store.GetEvents(storage.EventFilter{UploadID: uploadID})
+	if err != nil {
+		t.Fatalf("GetEvents: %v", err)
+	}
+
+	expected := []storage.Event{
+		{Entity: storage.EntityUpload, Action: storage.ActionTitle, OldValue: "Film", NewValue: "Film renommé", Actor: storage.ActorAdmin, CorrelationID: "requete-1"},
+		{Entity: storage.EntityLink, Action: storage.ActionDeleted, OldValue: "https://mixdrop/f/abc", Actor: storage.ActorAdmin, CorrelationID: "requete-1"},
+		{Entity: storage.EntityLink, Action: storage.ActionStatus, OldValue: storage.LinkStatusActive, NewValue: storage.LinkStatusDead, Actor: storage.ActorScheduler},
+		{Entity: storage.EntityLink, Action: storage.ActionCreated, NewValue: "https://mixdrop/f/abc", Actor: storage.ActorWorker, CorrelationID: "webhook-1"},
+		{Entity: storage.EntityUpload, Action: storage.ActionStatus, OldValue: storage.StatusPending, NewValue: storage.StatusUploading, Actor: storage.ActorWorker, CorrelationID: "webhook-1"},
+		{Entity: storage.EntityUpload, Action: storage.ActionCreated, NewValue: storage.StatusPending, Actor: storage.ActorWebhook, CorrelationID: "webhook-1"},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("GetEvents: %d événements, %d attendus: %+v", len(events), len(expected), events)
+	}
+	for i, event := range events {
+		want := expected[i]
+		if event.Entity != want.Entity || event.Action != want.Action || event.OldValue != want.OldValue ||
+			event.NewValue != want.NewValue || event.Actor != want.Actor || event.CorrelationID != want.CorrelationID {
+			t.Fatalf("GetEvents: événement %d inattendu:\n%+v\nattendu:\n%+v", i, event, want)
+		}
+		if event.UploadID != uploadID || event.TmdbID != 50 || event.CreatedAt.IsZero() {
+			t.Fatalf("GetEvents: événement %d mal rattaché: %+v", i, event)
+		}
+	}
+
+	// L'historique d'un lien supprimé reste consultable
+	linkEvents, err := store.GetEvents(storage.EventFilter{LinkID: linkID, Limit: 2})
+	if err != nil || len(linkEvents) != 2 || linkEvents[0].Action != storage.ActionDeleted {
+		t.Fatalf("GetEvents: historique du lien inattendu: %+v, %v", linkEvents, err)
+	}
+
+	otherEvents, err := store.GetEvents(storage.EventFilter{TmdbID: 51})
+	if err != nil || len(otherEvents) != 1 || otherEvents[0].EntityID != otherID {
+		t.Fatalf("GetEvents: historique du média inattendu: %+v, %v", otherEvents, err)
+	}
+	if otherEvents[0].Actor != storage.ActorSystem || otherEvents[0].CorrelationID != "" {
+		t.Fatalf("GetEvents: auteur par défaut inattendu: %+v", otherEvents[0])
+	}
+}
+
+// addUpload crée un upload de test
+func addUpload(t *testing.T, store storage.Store, upload storage.Upload) int64 {
+	t.Helper()
+
+	if upload.Type == "" {
+		upload.Type = storage.TypeMovie
+	}
+	if upload.FilePath == "" {
+		upload.FilePath = "/media/film.mkv"
+	}
+	if upload.UploadStatus == "" {
+		upload.UploadStatus = storage.StatusPending
+	}
+
+	id, err := store.AddUpload(&upload)
+	if err != nil {
+		t.Fatalf("AddUpload: %v", err)
+	}
+	if id == 0 {
+		t.Fatalf("AddUpload: ID nul")
+	}
+	return id
+}
+
+func testUploads(t *testing.T, store storage.Store) {
+	season, episode := 1, 2
+	movieID := addUpload(t, store, storage.Upload{TmdbID: 10, Title: "Film"})
+	episodeID := addUpload(t, store, storage.Upload{TmdbID: 20, Title: "Série", Type: storage.TypeSeries, Season: &season, Episode: &episode})
+
+	upload, err := store.GetUpload(movieID)
+	if err != nil {
+		t.Fatalf("GetUpload: %v", err)
+	}
+	if upload.TmdbID != 10 || upload.Title != "Film" || upload.Season != nil {
+		t.Fatalf("GetUpload: upload inattendu %+v", upload)
+	}
+
+	if err := store.UpdateUploadStatus(movieID, storage.StatusCompleted); err != nil {
+		t.Fatalf("UpdateUploadStatus: %v", err)
+	}
+	if err := store.UpdateUploadTitle(movieID, "Film renommé"); err != nil {
+		t.Fatalf("UpdateUploadTitle: %v", err)
+	}
+	upload, _ = store.GetUpload(movieID)
+	if upload.UploadStatus != storage.StatusCompleted || upload.Title != "Film renommé" {
+		t.Fatalf("mise à jour non enregistrée: %+v", upload)
+	}
+
+	existing, err := store.CheckExistingUpload(20, storage.TypeSeries, &season, &episode)
+	if err != nil || existing == nil || existing.ID != episodeID {
+		t.Fatalf("CheckExistingUpload: %+v, %v", existing, err)
+	}
+	if existing.Season == nil || *existing.Season != season || *existing.Episode != episode {
+		t.Fatalf("CheckExistingUpload: saison et épisode perdus: %+v", existing)
+	}
+
+	other := 3
+	existing, err = store.CheckExistingUpload(20, storage.TypeSeries, &season, &other)
+	if err != nil || existing != nil {
+		t.Fatalf("CheckExistingUpload: aucun upload attendu, obtenu %+v, %v", existing, err)
+	}
+
+	uploads, err := store.GetUploadsByTmdbID(20, storage.TypeSeries)
+	if err != nil || len(uploads) != 1 {
+		t.Fatalf("GetUploadsByTmdbID: %d uploads, %v", len(uploads), err)
+	}
+
+	pending, err := store.GetPendingUploads()
+	if err != nil || len(pending) != 1 || pending[0].ID != episodeID {
+		t.Fatalf("GetPendingUploads: %d uploads, %v", len(pending), err)
+	}
+
+	if err := store.UpdateUploadStatus(episodeID, storage.StatusPartial); err != nil {
+		t.Fatalf("UpdateUploadStatus: %v", err)
+	}
+	retries, err := store.GetUploadsToRetry(time.Now().Add(time.Hour), 3)
+	if err != nil || len(retries) != 1 {
+		t.Fatalf("GetUploadsToRetry: %d uploads, %v", len(retries), err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := store.MarkUploadForRetry(episodeID); err != nil {
+			t.Fatalf("MarkUploadForRetry: %v", err)
+		}
+	}
+	retries, err = store.GetUploadsToRetry(time.Now().Add(time.Hour), 3)
+	if err != nil || len(retries) != 0 {
+		t.Fatalf("GetUploadsToRetry après 3 relances: %d uploads, %v", len(retries), err)
+	}
+}
+
+func testLinks(t *testing.T, store storage.Store) {
+	uploadID := addUpload(t, store, storage.Upload{TmdbID: 10, Title: "Film"})
+
+	link := storage.HostedLink{Hoster: "mixdrop", FileCode: "abc", URL: "https://mixdrop/f/abc", Embed: "https://mixdrop/e/abc"}
+	if err := store.AddUploadLink(uploadID, link); err != nil {
+		t.Fatalf("AddUploadLink: %v", err)
+	}
+	if err := store.AddUploadLink(uploadID, storage.HostedLink{Hoster: "netu", FileCode: "def"}); err != nil {
+		t.Fatalf("AddUploadLink: %v", err)
+	}
+
+	exists, err := store.CheckLinkExists(uploadID, "mixdrop", "abc")
+	if err != nil || !exists {
+		t.Fatalf("CheckLinkExists: %v, %v", exists, err)
+	}
+
+	links, err := store.GetUploadLinks(uploadID)
+	if err != nil || len(links) != 2 {
+		t.Fatalf("GetUploadLinks: %d liens, %v", len(links), err)
+	}
+	mixdrop := links[0]
+	if mixdrop.Hoster != "mixdrop" || mixdrop.Status != storage.LinkStatusActive || mixdrop.Embed != link.Embed {
+		t.Fatalf("GetUploadLinks: lien inattendu %+v", mixdrop)
+	}
+
+	toCheck, err := store.GetLinksToCheck(time.Now().Add(time.Hour), 10)
+	if err != nil || len(toCheck) != 2 {
+		t.Fatalf("GetLinksToCheck: %d liens, %v", len(toCheck), err)
+	}
+
+	if err := store.UpdateLinkCheck(mixdrop.ID, storage.LinkStatusDead, 3); err != nil {
+		t.Fatalf("UpdateLinkCheck: %v", err)
+	}
+	checked, err := store.GetHostedLink(mixdrop.ID)
+	if err != nil || checked.Status != storage.LinkStatusDead || checked.FailureCount != 3 || checked.LastCheckedAt == nil {
+		t.Fatalf("GetHostedLink après vérification: %+v, %v", checked, err)
+	}
+	dead, err := store.GetDeadLinks(10)
+	if err != nil || len(dead) != 1 || dead[0].ID != mixdrop.ID {
+		t.Fatalf("GetDeadLinks: %+v, %v", dead, err)
+	}
+
+	replacement := storage.HostedLink{Hoster: "mixdrop", FileCode: "xyz", URL: "https://mixdrop/f/xyz", Embed: "https://mixdrop/e/xyz"}
+	if err := store.ReplaceUploadLink(mixdrop.ID, replacement); err != nil {
+		t.Fatalf("ReplaceUploadLink: %v", err)
+	}
+	replaced, err := store.GetHostedLink(mixdrop.ID)
+	if err != nil || replaced.FileCode != "xyz" || replaced.Status != storage.LinkStatusActive || replaced.FailureCount != 0 {
+		t.Fatalf("GetHostedLink après remplacement: %+v, %v", replaced, err)
+	}
+	if dead, err := store.GetDeadLinks(10); err != nil || len(dead) != 0 {
+		t.Fatalf("GetDeadLinks après remplacement: %+v, %v", dead, err)
+	}
+
+	if err := store.DeleteUploadLink(mixdrop.ID); err != nil {
+		t.Fatalf("DeleteUploadLink: %v", err)
+	}
+	links, _ = store.GetUploadLinks(uploadID)
+	if len(links) != 1 || links[0].Hoster != "netu" {
+		t.Fatalf("GetUploadLinks après suppression: %+v", links)
+	}
+
+	if err := store.AddLinkReupload(uploadID, "netu", links[0].ID); err != nil {
+		t.Fatalf("AddLinkReupload: %v", err)
+	}
+	count, err := store.CountLinkReuploadsSince(uploadID, "netu", time.Now().Add(-time.Hour))
+	if err != nil || count != 1 {
+		t.Fatalf("CountLinkReuploadsSince: %d, %v", count, err)
+	}
+}
+
+func testQueue(t *testing.T, store storage.Store) {
+	first, err := store.AddToQueue("movie_upload", storage.TaskPayload{UploadID: 1}, 2)
+	if err != nil {
+		t.Fatalf("AddToQueue: %v", err)
+	}
+	second, err := store.AddToQueue("link_check", storage.TaskPayload{UploadID: 2}, 1)
+	if err != nil {
+		t.Fatalf("AddToQueue: %v", err)
+	}
+
+	// Les types exclus ne sont pas réservés
+	item, err := store.ClaimNextQueueItem("movie_upload")
+	if err != nil || item == nil || item.ID != second {
+		t.Fatalf("ClaimNextQueueItem avec exclusion: %+v, %v", item, err)
+	}
+	if item.Status != storage.QueueStatusProcessing || item.Attempts != 1 {
+		t.Fatalf("ClaimNextQueueItem: tâche non marquée en cours: %+v", item)
+	}
+
+	item, err = store.ClaimNextQueueItem()
+	if err != nil || item == nil || item.ID != first || item.Type != "movie_upload" {
+		t.Fatalf("ClaimNextQueueItem: %+v, %v", item, err)
+	}
+	if item.Payload == "" {
+		t.Fatalf("ClaimNextQueueItem: payload vide")
+	}
+
+	// Une tâche en cours n'est pas réservée deux fois
+	item, err = store.ClaimNextQueueItem()
+	if err != nil || item != nil {
+		t.Fatalf("ClaimNextQueueItem: aucune tâche attendue, obtenu %+v, %v", item, err)
+	}
+
+	// Une tâche échouée est relancée tant qu'il reste des tentatives
+	if err := store.MarkQueueItemFailed(first); err != nil {
+		t.Fatalf("MarkQueueItemFailed: %v", err)
+	}
+	item, err = store.ClaimNextQueueItem()
+	if err != nil || item == nil || item.ID != first || item.Attempts != 2 {
+		t.Fatalf("ClaimNextQueueItem après échec: %+v, %v", item, err)
+	}
+	if err := store.MarkQueueItemFailed(first); err != nil {
+		t.Fatalf("MarkQueueItemFailed: %v", err)
+	}
+	item, err = store.ClaimNextQueueItem()
+	if err != nil || item != nil {
+		t.Fatalf("ClaimNextQueueItem après épuisement des tentatives: %+v, %v", item, err)
+	}
+
+	if err := store.MarkQueueItemCompleted(second); err != nil {
+		t.Fatalf("MarkQueueItemCompleted: %v", err)
+	}
+	pending, err := store.GetPendingQueueItems()
+	if err != nil || len(pending) != 1 || pending[0].ID != first {
+		t.Fatalf("GetPendingQueueItems: %d tâches, %v", len(pending), err)
+	}
+
+	if err := store.UpdateQueueItemStatus(first, storage.QueueStatusPending); err != nil {
+		t.Fatalf("UpdateQueueItemStatus: %v", err)
+	}
+
+	// Les tâches d'un lien sont retrouvées tant qu'elles peuvent encore être traitées
+	reupload, err := store.AddToQueue("link_reupload", storage.TaskPayload{UploadID: 1, LinkID: 7}, 1)
+	if err != nil {
+		t.Fatalf("AddToQueue: %v", err)
+	}
+	for _, check := range []struct {
+		taskType string
+		linkID   int64
+		want     bool
+	}{
+		{"link_reupload", 7, true},
+		{"link_reupload", 8, false},
+		{"movie_upload", 7, false},
+	} {
+		queued, err := store.HasQueuedLinkTask(check.taskType, check.linkID)
+		if err != nil || queued != check.want {
+			t.Fatalf("HasQueuedLinkTask(%s, %d): %v, %v", check.taskType, check.linkID, queued, err)
+		}
+	}
+	if item, err := store.ClaimNextQueueItem("movie_upload"); err != nil || item == nil || item.ID != reupload {
+		t.Fatalf("ClaimNextQueueItem: %+v, %v", item, err)
+	}
+	if err := store.MarkQueueItemFailed(reupload); err != nil {
+		t.Fatalf("MarkQueueItemFailed: %v", err)
+	}
+	if queued, err := store.HasQueuedLinkTask("link_reupload", 7); err != nil || queued {
+		t.Fatalf("HasQueuedLinkTask après épuisement des tentatives: %v, %v", queued, err)
+	}
+}
+
+// testTimestamps vérifie que les dates sont lues en UTC et que les seuils de la queue
+// ne dépendent pas du fuseau horaire local
+func testTimestamps(t *testing.T, store storage.Store) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+5", 5*60*60)
+	defer func() { time.Local = local }()
+
+	before := time.Now().Add(-time.Minute)
+	uploadID := addUpload(t, store, storage.Upload{TmdbID: 10, Title: "Film"})
+	upload, err := store.GetUpload(uploadID)
+	if err != nil {
+		t.Fatalf("GetUpload: %v", err)
+	}
+	if upload.CreatedAt.Location() != time.UTC || upload.CreatedAt.Before(before) || upload.CreatedAt.After(time.Now().Add(time.Minute)) {
+		t.Fatalf("date de création invalide: %v", upload.CreatedAt)
+	}
+
+	taskID, err := store.AddToQueue("movie_upload", storage.TaskPayload{UploadID: uploadID}, 3)
+	if err != nil {
+		t.Fatalf("AddToQueue: %v", err)
+	}
+	item, err := store.ClaimNextQueueItem()
+	if err != nil || item == nil {
+		t.Fatalf("ClaimNextQueueItem: %+v, %v", item, err)
+	}
+	if item.CreatedAt.Before(before) || item.UpdatedAt.Before(before) {
+		t.Fatalf("dates de la tâche invalides: %v, %v", item.CreatedAt, item.UpdatedAt)
+	}
+
+	// Une tâche qui vient d'être réservée n'est pas bloquée
+	count, err := store.ResetStuckQueueItems(time.Now().Add(-30 * time.Minute))
+	if err != nil || count != 0 {
+		t.Fatalf("ResetStuckQueueItems avant le seuil: %d, %v", count, err)
+	}
+	count, err = store.ResetStuckQueueItems(time.Now().Add(time.Minute))
+	if err != nil || count != 1 {
+		t.Fatalf("ResetStuckQueueItems après le seuil: %d, %v", count, err)
+	}
+
+	item, err = store.ClaimNextQueueItem()
+	if err != nil || item == nil || item.ID != taskID {
+		t.Fatalf("ClaimNextQueueItem après réinitialisation: %+v, %v", item, err)
+	}
+	if err := store.MarkQueueItemCompleted(taskID); err != nil {
+		t.Fatalf("MarkQueueItemCompleted: %v", err)
+	}
+
+	// Une tâche qui vient de se terminer est conservée
+	count, err = store.CleanupOldCompletedItems(time.Now().Add(-time.Hour))
+	if err != nil || count != 0 {
+		t.Fatalf("CleanupOldCompletedItems avant le seuil: %d, %v", count, err)
+	}
+	count, err = store.CleanupOldCompletedItems(time.Now().Add(time.Minute))
+	if err != nil || count != 1 {
+		t.Fatalf("CleanupOldCompletedItems après le seuil: %d, %v", count, err)
+	}
+
+	if err := store.AddUploadLink(uploadID, storage.HostedLink{Hoster: "netu", FileCode: "abc"}); err != nil {
+		t.Fatalf("AddUploadLink: %v", err)
+	}
+	links, err := store.GetLinksToCheck(time.Now().Add(-time.Hour), 10)
+	if err != nil || len(links) != 1 {
+		t.Fatalf("GetLinksToCheck: %d liens, %v", len(links), err)
+	}
+	linkID := links[0].ID
+	if err := store.UpdateLinkCheck(linkID, storage.LinkStatusActive, 0); err != nil {
+		t.Fatalf("UpdateLinkCheck: %v", err)
+	}
+
+	// Un lien vérifié à l'instant n'est pas revérifié avant le délai
+	links, err = store.GetLinksToCheck(time.Now().Add(-time.Hour), 10)
+	if err != nil || len(links) != 0 {
+		t.Fatalf("GetLinksToCheck après vérification: %d liens, %v", len(links), err)
+	}
+	link, err := store.GetHostedLink(linkID)
+	if err != nil || link.LastCheckedAt == nil || link.LastCheckedAt.Before(before) {
+		t.Fatalf("date de vérification invalide: %+v, %v", link, err)
+	}
+}
+
+func testQueueConcurrentClaims(t *testing.T, store storage.Store) {
+	const tasks = 20
+	for i := 0; i < tasks; i++ {
+		if _, err := store.AddToQueue("movie_upload", storage.TaskPayload{UploadID: int64(i)}, 3); err != nil {
+			t.Fatalf("AddToQueue: %v", err)
+		}
+	}
+
+	var mutex sync.Mutex
+	claimed := make(map[int64]int)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 5; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				item, err := store.ClaimNextQueueItem()
+				if err != nil {
+					t.Errorf("ClaimNextQueueItem: %v", err)
+					return
+				}
+				if item == nil {
+					return
+				}
+				mutex.Lock()
+				claimed[item.ID]++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claimed) != tasks {
+		t.Fatalf("%d tâches réservées sur %d", len(claimed), tasks)
+	}
+	for id, count := range claimed {
+		if count != 1 {
+			t.Fatalf("tâche %d réservée %d fois", id, count)
+		}
+	}
+}
+
+func testSessions(t *testing.T, store storage.Store) {
+	uploadID := addUpload(t, store, storage.Upload{TmdbID: 10, Title: "Film"})
+
+	modTime := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	session := &storage.UploadSession{UploadID: uploadID, Hoster: "tus", SessionID: "s1", FilePath: "/media/film.mkv", TotalSize: 100, FileModTime: modTime, ChunkSize: 10}
+	if err := store.SaveUploadSession(session); err != nil {
+		t.Fatalf("SaveUploadSession: %v", err)
+	}
+	session.Offset = 50
+	if err := store.SaveUploadSession(session); err != nil {
+		t.Fatalf("SaveUploadSession: %v", err)
+	}
+
+	saved, err := store.GetUploadSession(uploadID, "tus")
+	if err != nil || saved == nil || saved.Offset != 50 || saved.SessionID != "s1" || !saved.FileModTime.Equal(modTime) {
+		t.Fatalf("GetUploadSession: %+v, %v", saved, err)
+	}
+
+	if err := store.DeleteUploadSession(uploadID, "tus"); err != nil {
+		t.Fatalf("DeleteUploadSession: %v", err)
+	}
+	saved, err = store.GetUploadSession(uploadID, "tus")
+	if err != nil || saved != nil {
+		t.Fatalf("GetUploadSession après suppression: %+v, %v", saved, err)
+	}
+
+	started := time.Now().Add(-10 * time.Second)
+	attempt := &storage.UploadAttempt{UploadID: uploadID, Hoster: "tus", StartedAt: started, FinishedAt: started.Add(5 * time.Second), BytesSent: 500}
+	if _, err := store.AddUploadAttempt(attempt); err != nil {
+		t.Fatalf("AddUploadAttempt: %v", err)
+	}
+	attempts, err := store.GetUploadAttempts(uploadID)
+	if err != nil || len(attempts) != 1 || attempts[0].BytesSent != 500 {
+		t.Fatalf("GetUploadAttempts: %+v, %v", attempts, err)
+	}
+	stats, err := store.GetHosterAttemptStats(time.Now().Add(-time.Hour))
+	if err != nil || len(stats) != 1 || stats[0].Successes != 1 {
+		t.Fatalf("GetHosterAttemptStats: %+v, %v", stats, err)
+	}
+}
+
+func testUsage(t *testing.T, store storage.Store) {
+	now := time.Now()
+	for _, size := range []int64{100, 250} {
+		if err := store.AddHosterUsage("netu", now, size); err != nil {
+			t.Fatalf("AddHosterUsage: %v", err)
+		}
+	}
+
+	usage, err := store.GetHosterUsage("netu", now)
+	if err != nil || usage.BytesUploaded != 350 || usage.Uploads != 2 {
+		t.Fatalf("GetHosterUsage: %+v, %v", usage, err)
+	}
+
+	usages, err := store.GetHosterUsageSince(now.AddDate(0, 0, -1))
+	if err != nil || len(usages) != 1 {
+		t.Fatalf("GetHosterUsageSince: %+v, %v", usages, err)
+	}
+}
+
+func testFolders(t *testing.T, store storage.Store) {
+	for path, id := range map[string]string{"Série": "1", "Série/Saison 1": "2", "Série 2": "3"} {
+		if err := store.SaveHosterFolder("netu", path, id); err != nil {
+			t.Fatalf("SaveHosterFolder: %v", err)
+		}
+	}
+	if err := store.SaveHosterFolder("netu", "Série", "4"); err != nil {
+		t.Fatalf("SaveHosterFolder: %v", err)
+	}
+
+	id, err := store.GetHosterFolder("netu", "Série")
+	if err != nil || id != "4" {
+		t.Fatalf("GetHosterFolder: %q, %v", id, err)
+	}
+
+	// La suppression d'un dossier emporte ses sous-dossiers, mais pas les dossiers voisins
+	if err := store.DeleteHosterFolders("netu", "Série"); err != nil {
+		t.Fatalf("DeleteHosterFolders: %v", err)
+	}
+	for path, expected := range map[string]string{"Série": "", "Série/Saison 1": "", "Série 2": "3"} {
+		id, err := store.GetHosterFolder("netu", path)
+		if err != nil || id != expected {
+			t.Fatalf("GetHosterFolder(%q) après suppression: %q, %v", path, id, err)
+		}
+	}
+}
+
+func testSubtitles(t *testing.T, store storage.Store) {
+	uploadID := addUpload(t, store, storage.Upload{TmdbID: 10, Title: "Film"})
+
+	subtitle := &storage.Subtitle{UploadID: uploadID, Hoster: "mixdrop", FileCode: "abc", Language: "fr", Format: "srt", Source: storage.SubtitleSourceExternal, FilePath: "/media/film.fr.srt"}
+	id, err := store.AddSubtitle(subtitle)
+	if err != nil || id == 0 {
+		t.Fatalf("AddSubtitle: %d, %v", id, err)
+	}
+
+	exists, err := store.HasSubtitle(uploadID, "abc", "/media/film.fr.srt", "fr")
+	if err != nil || !exists {
+		t.Fatalf("HasSubtitle: %v, %v", exists, err)
+	}
+
+	subtitles, err := store.GetSubtitles(uploadID)
+	if err != nil || len(subtitles) != 1 || subtitles[0].Language != "fr" {
+		t.Fatalf("GetSubtitles: %+v, %v", subtitles, err)
+	}
+}
+
+func testMigrations(t *testing.T, store storage.Store) {
+	latest := storage.LatestVersion()
+
+	version, err := store.SchemaVersion()
+	if err != nil || version != latest {
+		t.Fatalf("SchemaVersion: %d, %v", version, err)
+	}
+
+	pending, err := store.PendingMigrations()
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("PendingMigrations: %d, %v", len(pending), err)
+	}
+
+	// Toutes les migrations doivent pouvoir être annulées puis réappliquées
+	if err := store.Migrate(0); err != nil {
+		t.Fatalf("Migrate(0): %v", err)
+	}
+	statuses, err := store.MigrationStatuses()
+	if err != nil || len(statuses) != latest || statuses[0].AppliedAt != nil {
+		t.Fatalf("MigrationStatuses après annulation: %+v, %v", statuses, err)
+	}
+	if err := store.MigrateToLatest(); err != nil {
+		t.Fatalf("MigrateToLatest: %v", err)
+	}
+	addUpload(t, store, storage.Upload{TmdbID: 10, Title: "Film"})
+}
+
+// testExportImport vérifie qu'un export rechargé dans une base vide restitue les uploads,
+// les liens, la queue et les saisons avec leurs IDs
+func testExportImport(t *testing.T, store storage.Store) {
+	season, episode := 1, 2
+	firstID := addUpload(t, store, storage.Upload{TmdbID: 10, Title: "Film", Media: storage.UploadMedia{Quality: "WEBDL-720p", Languages: []string{"French"}}})
+	secondID := addUpload(t, store, storage.Upload{TmdbID: 10, Title: "Film", Media: storage.UploadMedia{Quality: "Bluray-1080p"}})
+	episodeID := addUpload(t, store, storage.Upload{TmdbID: 20, Title: "Série", Type: storage.TypeSeries, Season: &season, Episode: &episode})
+	if err := store.SupersedeUpload(firstID, secondID); err != nil {
+		t.Fatalf("SupersedeUpload: %v", err)
+	}
+	if err := store.AddUploadLink(secondID, storage.HostedLink{Hoster: "mixdrop", FileCode: "abc", Embed: "https://mixdrop/e/abc"}); err != nil {
+		t.Fatalf("AddUploadLink: %v", err)
+	}
+	if err := store.AddUploadLink(episodeID, storage.HostedLink{Hoster: "netu", FileCode: "def"}); err != nil {
+		t.Fatalf("AddUploadLink: %v", err)
+	}
+	links, _ := store.GetUploadLinks(episodeID)
+	if err := store.UpdateLinkCheck(links[0].ID, storage.LinkStatusDead, 2); err != nil {
+		t.Fatalf("UpdateLinkCheck: %v", err)
+	}
+	if _, err := store.AddToQueue("movie_upload", storage.TaskPayload{UploadID: secondID}, 3); err != nil {
+		t.Fatalf("AddToQueue: %v", err)
+	}
+	if _, err := store.ClaimNextQueueItem(); err != nil {
+		t.Fatalf("ClaimNextQueueItem: %v", err)
+	}
+
+	want := make(map[int64]*storage.Upload)
+	wantLinks := make(map[int64][]storage.HostedLink)
+	for _, id := range []int64{firstID, secondID, episodeID} {
+		want[id], _ = store.GetUpload(id)
+		wantLinks[id], _ = store.GetUploadLinks(id)
+	}
+	wantSeasons, _ := store.GetSeasons(want[episodeID].MediaID)
+
+	for _, format := range []string{storage.FormatJSON, storage.FormatNDJSON} {
+		var buf bytes.Buffer
+		stats, err := store.Export(&buf, format)
+		if err != nil || stats.Uploads != len(want) || stats.Links != 2 || stats.Queue != 1 {
+			t.Fatalf("Export %s: %+v, %v", format, stats, err)
+		}
+		exported := buf.Bytes()
+
+		// L'import refuse une base qui contient déjà des données
+		if _, err := store.Import(bytes.NewReader(exported), format); err == nil {
+			t.Fatalf("Import %s: erreur attendue sur une base non vide", format)
+		}
+
+		if err := store.Migrate(0); err != nil {
+			t.Fatalf("Migrate(0): %v", err)
+		}
+		if err := store.MigrateToLatest(); err != nil {
+			t.Fatalf("MigrateToLatest: %v", err)
+		}
+
+		stats, err = store.Import(bytes.NewReader(exported), format)
+		if err != nil || stats.Uploads != len(want) || stats.Links != 2 || stats.Queue != 1 || stats.Seasons != 1 || stats.Episodes != 1 {
+			t.Fatalf("Import %s: %+v, %v", format, stats, err)
+		}
+		seasons, err := store.GetSeasons(want[episodeID].MediaID)
+		if err != nil || !reflect.DeepEqual(seasons, wantSeasons) {
+			t.Fatalf("saisons modifiées par l'import %s:\n%+v\n%+v, %v", format, seasons, wantSeasons, err)
+		}
+
+		for id, upload := range want {
+			imported, err := store.GetUpload(id)
+			if err != nil || imported == nil {
+				t.Fatalf("GetUpload %d après import %s: %v", id, format, err)
+			}
+			if !reflect.DeepEqual(imported, upload) {
+				t.Fatalf("upload %d modifié par l'import %s:\n%+v\n%+v", id, format, imported, upload)
+			}
+			links, err := store.GetUploadLinks(id)
+			if err != nil || !reflect.DeepEqual(links, wantLinks[id]) {
+				t.Fatalf("liens de l'upload %d modifiés par l'import %s:\n%+v\n%+v, %v", id, format, links, wantLinks[id], err)
+			}
+		}
+
+		// La tâche en cours lors de l'export est de nouveau disponible
+		item, err := store.ClaimNextQueueItem()
+		if err != nil || item == nil || item.Type != "movie_upload" {
+			t.Fatalf("ClaimNextQueueItem après import %s: %+v, %v", format, item, err)
+		}
+		if err := store.UpdateQueueItemStatus(item.ID, storage.QueueStatusProcessing); err != nil {
+			t.Fatalf("UpdateQueueItemStatus: %v", err)
+		}
+
+		// Les IDs continuent après ceux de l'export
+		id := addUpload(t, store, storage.Upload{TmdbID: 30, Title: "Autre"})
+		if id <= episodeID {
+			t.Fatalf("AddUpload après import %s: ID %d réutilisé", format, id)
+		}
+		want[id], _ = store.GetUpload(id)
+		wantLinks[id] = nil
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"io"
+	"time"
+)
+
+// UploadStore stocke les uploads
+type UploadStore interface {
+	AddUpload(upload *Upload) (int64, error)
+	GetUpload(id int64) (*Upload, error)
+	UpdateUploadStatus(id int64, status string) error
+	UpdateUploadTitle(id int64, title string) error
+	CheckExistingUpload(tmdbID int, mediaType string, season, episode *int) (*Upload, error)
+	GetUploadVersions(tmdbID int, mediaType string, season, episode *int) ([]*Upload, error)
+	SupersedeUpload(id, supersededBy int64) error
+	GetUploadsByTmdbID(tmdbID int, mediaType string) ([]*Upload, error)
+	GetPendingUploads() ([]*Upload, error)
+	GetUploadsToRetry(updatedBefore time.Time, maxRetries int) ([]*Upload, error)
+	MarkUploadForRetry(id int64) error
+	FindUploadByFingerprint(fingerprint string, excludeID int64) (*Upload, error)
+	UpdateUploadHash(id int64, contentSHA256 string) error
+}
+
+// LinkStore stocke les liens hébergés des uploads et leurs vérifications
+type LinkStore interface {
+	CheckLinkExists(uploadID int64, hoster, fileCode string) (bool, error)
+	AddUploadLink(uploadID int64, link HostedLink) error
+	GetUploadLinks(uploadID int64) ([]HostedLink, error)
+	GetHostedLink(id int64) (*HostedLink, error)
+	ReplaceUploadLink(id int64, link HostedLink) error
+	DeleteUploadLink(id int64) error
+	GetLinksToCheck(checkedBefore time.Time, limit int) ([]HostedLink, error)
+	GetDeadLinks(limit int) ([]HostedLink, error)
+	UpdateLinkCheck(id int64, status string, failureCount int) error
+	CountFileLinks(hoster, fileCode string) (int, error)
+}
+
+// QueueStore stocke la queue des tâches. ClaimNextQueueItem doit pouvoir être appelé
+// en parallèle sans confier deux fois la même tâche.
+type QueueStore interface {
+	AddToQueue(taskType string, payload interface{}, maxAttempts int) (int64, error)
+	ClaimNextQueueItem(excludedTypes ...string) (*QueueItem, error)
+	UpdateQueueItemStatus(id int64, status QueueStatus) error
+	MarkQueueItemCompleted(id int64) error
+	MarkQueueItemFailed(id int64) error
+	GetPendingQueueItems() ([]*QueueItem, error)
+	HasQueuedLinkTask(taskType string, linkID int64) (bool, error)
+	ResetStuckQueueItems(updatedBefore time.Time) (int, error)
+	CleanupOldCompletedItems(processedBefore time.Time) (int, error)
+}
+
+// Store regroupe tout le stockage utilisé par le serveur
+type Store interface {
+	UploadStore
+	LinkStore
+	QueueStore
+
+	// Sessions d'upload reprenables
+	GetUploadSession(uploadID int64, hoster string) (*UploadSession, error)
+	SaveUploadSession(session *UploadSession) error
+	DeleteUploadSession(uploadID int64, hoster string) error
+
+	// Tentatives d'upload
+	AddUploadAttempt(attempt *UploadAttempt) (int64, error)
+	GetUploadAttempts(uploadID int64) ([]UploadAttempt, error)
+	GetRecentUploadAttempts(hoster string, limit int) ([]UploadAttempt, error)
+	GetHosterAttemptStats(since time.Time) ([]HosterAttemptStats, error)
+
+	// Réuploads des liens morts
+	AddLinkReupload(uploadID int64, hoster string, linkID int64) error
+	CountLinkReuploadsSince(uploadID int64, hoster string, since time.Time) (int, error)
+
+	// Volume envoyé aux hébergeurs
+	AddHosterUsage(hoster string, day time.Time, bytes int64) error
+	GetHosterUsage(hoster string, day time.Time) (*HosterUsage, error)
+	GetHosterUsageSince(since time.Time) ([]HosterUsage, error)
+
+	// Dossiers des hébergeurs
+	GetHosterFolder(hoster, path string) (string, error)
+	SaveHosterFolder(hoster, path, folderID string) error
+	DeleteHosterFolders(hoster, path string) error
+
+	// Sous-titres
+	AddSubtitle(subtitle *Subtitle) (int64, error)
+	GetSubtitles(uploadID int64) ([]*Subtitle, error)
+	HasSubtitle(uploadID int64, fileCode, filePath, language string) (bool, error)
+
+	// Films, séries, saisons et épisodes
+	SaveMedia(media *Media) (int64, error)
+	SaveEpisode(episode *Episode) (int64, error)
+	GetMedia(id int64) (*Media, error)
+	GetMediaByTmdbID(mediaType string, tmdbID int) (*Media, error)
+	GetSeasons(mediaID int64) ([]Season, error)
+	GetEpisodes(mediaID int64, season int) ([]Episode, error)
+	SetSeasonEpisodeCount(mediaID int64, season, count int) error
+	MarkSeasonCompleted(seasonID int64) (bool, error)
+
+	// Journal des changements
+	WithActor(actor, correlationID string) Store
+	GetEvents(filter EventFilter) ([]Event, error)
+
+	// Sauvegarde, export et import
+	Backup(dest string) error
+	Export(w io.Writer, format string) (ExportStats, error)
+	Import(r io.Reader, format string) (ExportStats, error)
+
+	// Schéma
+	SchemaVersion() (int, error)
+	MigrationStatuses() ([]MigrationStatus, error)
+	PendingMigrations() ([]Migration, error)
+	Migrate(target int) error
+	MigrateToLatest() error
+
+	Driver() string
+	Close() error
+}
+
+// Vérifie que Database implémente Store
+var _ Store = (*Database)(nil)
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Sources des sous-titres
+const (
+	SubtitleSourceExternal = "external" // fichier à côté de la vidéo
+	SubtitleSourceEmbedded = "embedded" // piste incluse dans la vidéo
+)
+
+// Subtitle représente une piste de sous-titres d'un upload. Les fichiers externes ont une
+// ligne par fichier hébergé; les pistes incluses dans la vidéo n'ont pas d'hébergeur.
+type Subtitle struct {
+	ID        int64     `json:"id"`
+	UploadID  int64     `json:"upload_id"`
+	Hoster    string    `json:"hoster,omitempty"`
+	FileCode  string    `json:"file_code,omitempty"` // fichier vidéo hébergé auquel les sous-titres sont associés
+	Language  string    `json:"language"`
+	Format    string    `json:"format,omitempty"`
+	Source    string    `json:"source"`
+	FilePath  string    `json:"file_path,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddSubtitle enregistre une piste de sous-titres
+func (db *Database) AddSubtitle(subtitle *Subtitle) (int64, error) {
+	query := `
+	INSERT INTO subtitles (upload_id, hoster, file_code, language, format, source, file_path, url)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	id, err := db.insert(
+		query,
+		subtitle.UploadID,
+		subtitle.Hoster,
+		subtitle.FileCode,
+		subtitle.Language,
+		subtitle.Format,
+		subtitle.Source,
+		subtitle.FilePath,
+		subtitle.URL,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors de l'enregistrement des sous-titres: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetSubtitles récupère les sous-titres d'un upload
+func (db *Database) GetSubtitles(uploadID int64) ([]*Subtitle, error) {
+	query := `
+	SELECT id, upload_id, hoster, file_code, language, format, source, file_path, url, created_at
+	FROM subtitles
+	WHERE upload_id = ?
+	ORDER BY id
+	`
+
+	rows, err := db.query(query, uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la récupération des sous-titres: %w", err)
+	}
+	defer rows.Close()
+
+	var subtitles []*Subtitle
+	for rows.Next() {
+		subtitle := &Subtitle{}
+		var createdAt timestamp
+		if err := rows.Scan(
+			&subtitle.ID,
+			&subtitle.UploadID,
+			&subtitle.Hoster,
+			&subtitle.FileCode,
+			&subtitle.Language,
+			&subtitle.Format,
+			&subtitle.Source,
+			&subtitle.FilePath,
+			&subtitle.URL,
+			&createdAt,
+		); err != nil {
+			return nil, fmt.Errorf("erreur lors de la lecture des sous-titres: %w", err)
+		}
+		subtitle.CreatedAt = createdAt.Time
+		subtitles = append(subtitles, subtitle)
+	}
+
+	return subtitles, rows.Err()
+}
+
+// HasSubtitle indique si des sous-titres ont déjà été enregistrés pour un fichier hébergé
+// et un fichier de sous-titres (ou une langue pour les pistes incluses)
+func (db *Database) HasSubtitle(uploadID int64, fileCode, filePath, language string) (bool, error) {
+	query := `
+	SELECT COUNT(*) FROM subtitles
+	WHERE upload_id = ? AND file_code = ? AND file_path = ? AND language = ?
+	`
+
+	var count int
+	if err := db.queryRow(query, uploadID, fileCode, filePath, language).Scan(&count); err != nil {
+		return false, fmt.Errorf("erreur lors de la vérification des sous-titres: %w", err)
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// timestampLayout est le format des dates stockées dans la base, toujours en UTC. C'est celui
+// de CURRENT_TIMESTAMP dans SQLite: les dates se comparent donc comme des chaînes.
+const timestampLayout = "2006-01-02 15:04:05"
+
+// timestampLayouts sont les formats acceptés à la lecture, pour les lignes écrites par
+// d'anciennes versions ou par les pilotes
+var timestampLayouts = []string{
+	timestampLayout,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+// formatTimestamp convertit une date en paramètre de requête, en UTC au format de la base
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+// formatNullTimestamp convertit une date facultative en paramètre de requête, NULL si elle est absente
+func formatNullTimestamp(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return formatTimestamp(*t)
+}
+
+// parseTimestamp lit une date de la base. Une date sans fuseau horaire est en UTC.
+func parseTimestamp(value string) (time.Time, error) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("date invalide: %q", value)
+}
+
+// timestamp lit une colonne de date, quel que soit le type renvoyé par le pilote,
+// et la convertit en UTC. Valid est faux pour une date NULL.
+type timestamp struct {
+	Time  time.Time
+	Valid bool
+}
+
+// Scan implémente sql.Scanner
+func (ts *timestamp) Scan(value interface{}) error {
+	var err error
+	switch v := value.(type) {
+	case nil:
+		ts.Time, ts.Valid = time.Time{}, false
+		return nil
+	case time.Time:
+		ts.Time = v.UTC()
+	case string:
+		ts.Time, err = parseTimestamp(v)
+	case []byte:
+		ts.Time, err = parseTimestamp(string(v))
+	default:
+		err = fmt.Errorf("type de date non supporté: %T", value)
+	}
+
+	ts.Valid = err == nil
+	return err
+}
+
+// ptr retourne la date, ou nil si elle est NULL
+func (ts timestamp) ptr() *time.Time {
+	if !ts.Valid {
+		return nil
+	}
+	t := ts.Time
+	return &t
+}
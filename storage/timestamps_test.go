@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	want := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	for _, value := range []string{
+		"2024-03-01 10:00:00",
+		"2024-03-01 10:00:00.000",
+		"2024-03-01 12:00:00+02:00",
+		"2024-03-01T10:00:00",
+		"2024-03-01T10:00:00Z",
+		"2024-03-01T05:00:00-05:00",
+	} {
+		got, err := parseTimestamp(value)
+		if err != nil {
+			t.Fatalf("parseTimestamp(%q): %v", value, err)
+		}
+		if !got.Equal(want) || got.Location() != time.UTC {
+			t.Fatalf("parseTimestamp(%q) = %v, attendu %v", value, got, want)
+		}
+	}
+
+	if _, err := parseTimestamp("hier"); err == nil {
+		t.Fatalf("parseTimestamp: erreur attendue sur une date invalide")
+	}
+}
+
+func TestTimestampScan(t *testing.T) {
+	zone := time.FixedZone("UTC+5", 5*60*60)
+	want := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	for _, value := range []interface{}{
+		time.Date(2024, 3, 1, 15, 0, 0, 0, zone),
+		"2024-03-01 10:00:00",
+		[]byte("2024-03-01 10:00:00"),
+	} {
+		var ts timestamp
+		if err := ts.Scan(value); err != nil {
+			t.Fatalf("Scan(%v): %v", value, err)
+		}
+		if !ts.Valid || !ts.Time.Equal(want) || ts.Time.Location() != time.UTC {
+			t.Fatalf("Scan(%v) = %+v, attendu %v", value, ts, want)
+		}
+	}
+
+	var ts timestamp
+	if err := ts.Scan(nil); err != nil || ts.Valid || ts.ptr() != nil {
+		t.Fatalf("Scan(nil) = %+v, %v", ts, err)
+	}
+	if err := ts.Scan(42); err == nil {
+		t.Fatalf("Scan(42): erreur attendue")
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	zone := time.FixedZone("UTC+5", 5*60*60)
+	if got := formatTimestamp(time.Date(2024, 3, 1, 1, 30, 0, 0, zone)); got != "2024-02-29 20:30:00" {
+		t.Fatalf("formatTimestamp = %q", got)
+	}
+	if got := formatNullTimestamp(nil); got != nil {
+		t.Fatalf("formatNullTimestamp(nil) = %v", got)
+	}
+}
+
+// TestNormalizeTimestamps vérifie que la migration utc_timestamps réécrit en UTC les dates
+// enregistrées avec un fuseau horaire par les versions précédentes
+func TestNormalizeTimestamps(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "uploads.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Migrate(10); err != nil {
+		t.Fatalf("Migrate(10): %v", err)
+	}
+
+	_, err = db.exec(`
+		INSERT INTO uploads (tmdb_id, title, type, file_path, upload_status, created_at, updated_at)
+		VALUES (10, 'Film', 'movie', '/media/film.mkv', 'completed', '2024-03-01T12:00:00+02:00', '2024-03-01 10:00:00.123')
+	`)
+	if err != nil {
+		t.Fatalf("insertion de l'upload: %v", err)
+	}
+
+	if err := db.Migrate(11); err != nil {
+		t.Fatalf("Migrate(11): %v", err)
+	}
+
+	var createdAt, updatedAt string
+	if err := db.queryRow("SELECT CAST(created_at AS TEXT), CAST(updated_at AS TEXT) FROM uploads").Scan(&createdAt, &updatedAt); err != nil {
+		t.Fatalf("lecture des dates: %v", err)
+	}
+	if createdAt != "2024-03-01 10:00:00" || updatedAt != "2024-03-01 10:00:00" {
+		t.Fatalf("dates non normalisées: %q, %q", createdAt, updatedAt)
+	}
+}
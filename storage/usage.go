@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// HosterUsage représente le volume envoyé à un hébergeur pendant une journée
+type HosterUsage struct {
+	Hoster        string `json:"hoster"`
+	Day           string `json:"day"` // AAAA-MM-JJ (UTC)
+	BytesUploaded int64  `json:"bytes_uploaded"`
+	Uploads       int    `json:"uploads"`
+}
+
+// AddHosterUsage ajoute un fichier uploadé au volume du jour de l'hébergeur
+func (db *Database) AddHosterUsage(hoster string, day time.Time, bytes int64) error {
+	query := `
+	INSERT INTO hoster_usage (hoster, day, bytes_uploaded, uploads)
+	VALUES (?, ?, ?, 1)
+	ON CONFLICT (hoster, day) DO UPDATE SET
+		bytes_uploaded = hoster_usage.bytes_uploaded + excluded.bytes_uploaded,
+		uploads = hoster_usage.uploads + 1
+	`
+
+	_, err := db.exec(query, hoster, day.UTC().Format("2006-01-02"), bytes)
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'enregistrement du volume envoyé: %w", err)
+	}
+
+	return nil
+}
+
+// GetHosterUsage récupère le volume envoyé à un hébergeur pendant une journée
+func (db *Database) GetHosterUsage(hoster string, day time.Time) (*HosterUsage, error) {
+	usage := &HosterUsage{
+		Hoster: hoster,
+		Day:    day.UTC().Format("2006-01-02"),
+	}
+
+	query := `
+	SELECT COALESCE(SUM(bytes_uploaded), 0), COALESCE(SUM(uploads), 0)
+	FROM hoster_usage
+	WHERE hoster = ? AND day = ?
+	`
+
+	err := db.queryRow(query, hoster, usage.Day).Scan(&usage.BytesUploaded, &usage.Uploads)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la récupération du volume envoyé: %w", err)
+	}
+
+	return usage, nil
+}
+
+// GetHosterUsageSince récupère le volume envoyé à chaque hébergeur, jour par jour, depuis une date
+func (db *Database) GetHosterUsageSince(since time.Time) ([]HosterUsage, error) {
+	query := `
+	SELECT hoster, day, bytes_uploaded, uploads
+	FROM hoster_usage
+	WHERE day >= ?
+	ORDER BY day DESC, hoster
+	`
+
+	rows, err := db.query(query, since.UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la récupération du volume envoyé: %w", err)
+	}
+	defer rows.Close()
+
+	var usages []HosterUsage
+	for rows.Next() {
+		var usage HosterUsage
+		if err := rows.Scan(&usage.Hoster, &usage.Day, &usage.BytesUploaded, &usage.Uploads); err != nil {
+			return nil, err
+		}
+		usages = append(usages, usage)
+	}
+
+	return usages, rows.Err()
+}
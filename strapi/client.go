@@ -3,6 +3,7 @@ package strapi
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -23,7 +24,7 @@ type StrapiClient struct {
 	Token      string
 	HTTPClient *http.Client
 	TMDBClient *tmdb.TMDBClient
-	DB         *storage.Database
+	DB         storage.UploadStore
 }
 
 // LoginResponse représente la réponse de l'API Strapi pour la connexion
@@ -87,7 +88,7 @@ type ErrorResponse struct {
 }
 
 // NewStrapiClient crée un nouveau client Strapi
-func NewStrapiClient(baseURL, username, password string, tmdbClient *tmdb.TMDBClient, db *storage.Database) *StrapiClient {
+func NewStrapiClient(baseURL, username, password string, tmdbClient *tmdb.TMDBClient, db storage.UploadStore) *StrapiClient {
 	return &StrapiClient{
 		BaseURL:  baseURL,
 		Username: username,
@@ -487,8 +488,58 @@ func (c *StrapiClient) CheckLinkExists(ficheID, embedURL string) (bool, error) {
 	return len(response.Data) > 0, nil
 }
 
+// LinkSubtitle représente une piste de sous-titres publiée avec un lien
+type LinkSubtitle struct {
+	Language string `json:"language"`
+	URL      string `json:"url,omitempty"`
+}
+
+// LinkMedia décrit le fichier publié avec un lien, pour afficher la qualité de chaque version
+type LinkMedia struct {
+	Quality        string   `json:"quality,omitempty"`
+	Resolution     string   `json:"resolution,omitempty"`
+	Size           int64    `json:"size,omitempty"`
+	VideoCodec     string   `json:"videoCodec,omitempty"`
+	DynamicRange   string   `json:"dynamicRange,omitempty"`
+	AudioCodec     string   `json:"audioCodec,omitempty"`
+	AudioChannels  float64  `json:"audioChannels,omitempty"`
+	AudioLanguages []string `json:"audioLanguages,omitempty"`
+	ReleaseGroup   string   `json:"releaseGroup,omitempty"`
+	Version        int      `json:"version,omitempty"`
+}
+
+// LinkEpisode place le lien d'un épisode dans la fiche de sa série
+type LinkEpisode struct {
+	Season  int    `json:"season"`
+	Episode int    `json:"episode"`
+	Title   string `json:"title,omitempty"`
+}
+
 // CreateLink crée un nouveau lien dans Strapi
 func (c *StrapiClient) CreateLink(ficheID, embedURL string) (string, error) {
+	return c.CreateLinkWithDetails(ficheID, embedURL, nil, nil)
+}
+
+// CreateLinkWithSubtitles crée un nouveau lien dans Strapi avec ses sous-titres.
+// Si le lien existe déjà, ses sous-titres sont mis à jour.
+func (c *StrapiClient) CreateLinkWithSubtitles(ficheID, embedURL string, subtitles []LinkSubtitle) (string, error) {
+	return c.CreateLinkWithDetails(ficheID, embedURL, subtitles, nil)
+}
+
+// CreateLinkWithDetails crée un nouveau lien dans Strapi avec ses sous-titres et les
+// informations de son fichier. Si le lien existe déjà, ces informations sont mises à jour.
+func (c *StrapiClient) CreateLinkWithDetails(ficheID, embedURL string, subtitles []LinkSubtitle, media *LinkMedia) (string, error) {
+	return c.createLink(ficheID, embedURL, subtitles, media, nil)
+}
+
+// CreateEpisodeLink crée le lien d'un épisode dans la fiche de sa série, avec sa saison et
+// son numéro. Si le lien existe déjà, ces informations sont mises à jour.
+func (c *StrapiClient) CreateEpisodeLink(ficheID, embedURL string, episode *LinkEpisode, subtitles []LinkSubtitle, media *LinkMedia) (string, error) {
+	return c.createLink(ficheID, embedURL, subtitles, media, episode)
+}
+
+// createLink crée un lien dans Strapi, ou met à jour les informations d'un lien existant
+func (c *StrapiClient) createLink(ficheID, embedURL string, subtitles []LinkSubtitle, media *LinkMedia, episode *LinkEpisode) (string, error) {
 	// Vérifier si le token est disponible
 	if c.Token == "" {
 		if err := c.Login(); err != nil {
@@ -505,15 +556,41 @@ func (c *StrapiClient) CreateLink(ficheID, embedURL string) (string, error) {
 
 	if exists {
 		log.Printf("Le lien existe déjà pour la fiche %s: %s", ficheID, embedURL)
+		fields := make(map[string]interface{})
+		if len(subtitles) > 0 {
+			fields["subtitles"] = subtitles
+		}
+		if media != nil {
+			fields["media"] = media
+		}
+		if episode != nil {
+			fields["episode"] = episode
+		}
+		if len(fields) > 0 {
+			if err := c.updateLink(ficheID, embedURL, fields); err != nil {
+				return "", err
+			}
+		}
 		return "exists", nil
 	}
 
 	// Préparer les données du lien
+	linkData := map[string]interface{}{
+		"link":  embedURL,
+		"fiche": ficheID,
+	}
+	if len(subtitles) > 0 {
+		linkData["subtitles"] = subtitles
+	}
+	if media != nil {
+		linkData["media"] = media
+	}
+	if episode != nil {
+		linkData["episode"] = episode
+	}
+
 	data := map[string]interface{}{
-		"data": map[string]interface{}{
-			"link":  embedURL,
-			"fiche": ficheID,
-		},
+		"data": linkData,
 	}
 
 	jsonData, err := json.Marshal(data)
@@ -557,3 +634,237 @@ func (c *StrapiClient) CreateLink(ficheID, embedURL string) (string, error) {
 	// Retourner l'ID du lien créé
 	return fmt.Sprintf("%d", linkResp.Data.ID), nil
 }
+
+// FindLink recherche le lien d'une fiche par son URL d'embed et retourne son documentId
+func (c *StrapiClient) FindLink(ficheID, embedURL string) (string, error) {
+	// Vérifier si le token est disponible
+	if c.Token == "" {
+		if err := c.Login(); err != nil {
+			return "", fmt.Errorf("erreur lors de la connexion: %w", err)
+		}
+	}
+
+	// Créer l'URL avec les filtres
+	apiURL := fmt.Sprintf("%s/api/links?filters[fiche][id][$eq]=%s&filters[link][$eq]=%s",
+		c.BaseURL, ficheID, url.QueryEscape(embedURL))
+
+	// Créer la requête
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("erreur lors de la création de la requête: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	// Envoyer la requête
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("erreur lors de l'envoi de la requête: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Lire la réponse
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("erreur lors de la lecture de la réponse: %w", err)
+	}
+
+	// Vérifier si la requête a réussi
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("erreur lors de la recherche du lien: code %d, réponse: %s", resp.StatusCode, string(body))
+	}
+
+	// Décoder la réponse JSON
+	var response struct {
+		Data []struct {
+			DocumentID string `json:"documentId"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("erreur lors du décodage de la réponse JSON: %w", err)
+	}
+
+	if len(response.Data) == 0 {
+		return "", nil // Aucun lien trouvé
+	}
+
+	return response.Data[0].DocumentID, nil
+}
+
+// ReplaceLink remplace l'URL d'un lien existant d'une fiche et les informations de son fichier,
+// comme createLink, ou crée le lien s'il n'existe pas
+func (c *StrapiClient) ReplaceLink(ficheID, oldEmbedURL, newEmbedURL string, media *LinkMedia, episode *LinkEpisode) (string, error) {
+	linkID, err := c.FindLink(ficheID, oldEmbedURL)
+	if err != nil {
+		log.Printf("Erreur lors de la recherche de l'ancien lien: %v", err)
+		// Continuer malgré l'erreur
+	}
+
+	if linkID == "" {
+		log.Printf("Ancien lien introuvable pour la fiche %s, création du nouveau lien", ficheID)
+		return c.createLink(ficheID, newEmbedURL, nil, media, episode)
+	}
+
+	// Préparer les données du lien: le nouveau fichier peut différer de l'ancien
+	linkData := map[string]interface{}{
+		"link": newEmbedURL,
+	}
+	if media != nil {
+		linkData["media"] = media
+	}
+	if episode != nil {
+		linkData["episode"] = episode
+	}
+
+	data := map[string]interface{}{
+		"data": linkData,
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("erreur lors de la sérialisation des données du lien: %w", err)
+	}
+
+	// Créer la requête
+	req, err := http.NewRequest("PUT", c.BaseURL+"/api/links/"+linkID, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("erreur lors de la création de la requête: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	// Envoyer la requête
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("erreur lors de l'envoi de la requête: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Lire la réponse
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("erreur lors de la lecture de la réponse: %w", err)
+	}
+
+	// Vérifier si la requête a réussi
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("erreur lors de la mise à jour du lien: code %d, réponse: %s", resp.StatusCode, string(body))
+	}
+
+	return linkID, nil
+}
+
+// UpdateLinkSubtitles remplace les sous-titres du lien d'une fiche correspondant à une URL d'embed
+func (c *StrapiClient) UpdateLinkSubtitles(ficheID, embedURL string, subtitles []LinkSubtitle) error {
+	return c.updateLink(ficheID, embedURL, map[string]interface{}{"subtitles": subtitles})
+}
+
+// UpdateLinkMedia remplace les informations du fichier du lien d'une fiche
+func (c *StrapiClient) UpdateLinkMedia(ficheID, embedURL string, media *LinkMedia) error {
+	return c.updateLink(ficheID, embedURL, map[string]interface{}{"media": media})
+}
+
+// errLinkNotFound est retournée quand aucun lien de la fiche ne correspond à l'URL d'embed
+var errLinkNotFound = errors.New("lien introuvable")
+
+// ReorderLinks classe les liens d'une fiche dans l'ordre des URLs d'embed: le champ position
+// du premier vaut 0. Les liens introuvables sont ignorés.
+func (c *StrapiClient) ReorderLinks(ficheID string, embedURLs []string) error {
+	var failures []string
+	for position, embedURL := range embedURLs {
+		err := c.updateLink(ficheID, embedURL, map[string]interface{}{"position": position})
+		if err != nil && !errors.Is(err, errLinkNotFound) {
+			failures = append(failures, fmt.Sprintf("%s: %v", embedURL, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("liens non classés: %s", strings.Join(failures, ", "))
+	}
+
+	return nil
+}
+
+// updateLink met à jour des champs du lien d'une fiche correspondant à une URL d'embed
+func (c *StrapiClient) updateLink(ficheID, embedURL string, fields map[string]interface{}) error {
+	linkID, err := c.FindLink(ficheID, embedURL)
+	if err != nil {
+		return err
+	}
+
+	if linkID == "" {
+		return fmt.Errorf("%w pour la fiche %s: %s", errLinkNotFound, ficheID, embedURL)
+	}
+
+	// Préparer les données du lien
+	data := map[string]interface{}{
+		"data": fields,
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la sérialisation des données du lien: %w", err)
+	}
+
+	// Créer la requête
+	req, err := http.NewRequest("PUT", c.BaseURL+"/api/links/"+linkID, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("erreur lors de la création de la requête: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	// Envoyer la requête
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'envoi de la requête: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Vérifier si la requête a réussi
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("erreur lors de la mise à jour du lien: code %d, réponse: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// DeleteLink supprime le lien d'une fiche correspondant à une URL d'embed
+func (c *StrapiClient) DeleteLink(ficheID, embedURL string) error {
+	linkID, err := c.FindLink(ficheID, embedURL)
+	if err != nil {
+		return err
+	}
+
+	if linkID == "" {
+		log.Printf("Lien introuvable pour la fiche %s, rien à supprimer: %s", ficheID, embedURL)
+		return nil
+	}
+
+	// Créer la requête
+	req, err := http.NewRequest("DELETE", c.BaseURL+"/api/links/"+linkID, nil)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la création de la requête: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	// Envoyer la requête
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'envoi de la requête: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Vérifier si la requête a réussi
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("erreur lors de la suppression du lien: code %d, réponse: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package strapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestReplaceLinkSendsMedia(t *testing.T) {
+	var update struct {
+		Data struct {
+			Link    string       `json:"link"`
+			Media   *LinkMedia   `json:"media"`
+			Episode *LinkEpisode `json:"episode"`
+		} `json:"data"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/links":
+			if r.URL.Query().Get("filters[link][$eq]") != "https://old/e/abc" {
+				w.Write([]byte(`{"data": []}`))
+				return
+			}
+			w.Write([]byte(`{"data": [{"documentId": "lien-1"}]}`))
+		case r.Method == http.MethodPut && r.URL.Path == "/api/links/lien-1":
+			if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+				t.Errorf("décodage de la mise à jour: %v", err)
+			}
+			w.Write([]byte(`{"data": {"documentId": "lien-1"}}`))
+		default:
+			t.Errorf("requête inattendue: %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewStrapiClient(server.URL, "", "", nil, nil)
+	client.Token = "jeton"
+
+	media := &LinkMedia{Quality: "Bluray-1080p", VideoCodec: "x265", Version: 2}
+	episode := &LinkEpisode{Season: 1, Episode: 3}
+	linkID, err := client.ReplaceLink("7", "https://old/e/abc", "https://new/e/def", media, episode)
+	if err != nil || linkID != "lien-1" {
+		t.Fatalf("ReplaceLink: %q, %v", linkID, err)
+	}
+
+	if update.Data.Link != "https://new/e/def" {
+		t.Fatalf("lien non remplacé: %+v", update.Data)
+	}
+	if !reflect.DeepEqual(update.Data.Media, media) {
+		t.Fatalf("informations du fichier non envoyées: %+v", update.Data.Media)
+	}
+	if update.Data.Episode == nil || *update.Data.Episode != *episode {
+		t.Fatalf("épisode non envoyé: %+v", update.Data.Episode)
+	}
+}
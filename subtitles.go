@@ -0,0 +1,176 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"media-upload-system/model"
+	"media-upload-system/storage"
+	"media-upload-system/strapi"
+	"media-upload-system/upload"
+)
+
+// subtitleFile représente un fichier de sous-titres trouvé à côté d'une vidéo
+type subtitleFile struct {
+	Path     string
+	Language string
+	Format   string
+}
+
+// subtitleFlags sont les marqueurs de Radarr et Sonarr qui ne désignent pas une langue
+var subtitleFlags = map[string]bool{
+	"forced": true, "sdh": true, "cc": true, "hi": true, "default": true,
+}
+
+// findSubtitles retourne les fichiers de sous-titres du dossier de la vidéo dont le nom
+// commence par celui de la vidéo, par exemple film.fr.srt ou film.en.forced.ass
+func findSubtitles(videoPath string) []subtitleFile {
+	entries, err := os.ReadDir(filepath.Dir(videoPath))
+	if err != nil {
+		log.Printf("Impossible de lister les sous-titres de %s: %v", videoPath, err)
+		return nil
+	}
+
+	base := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+
+	var subtitles []subtitleFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, base+".") {
+			continue
+		}
+
+		ext := strings.ToLower(filepath.Ext(name))
+		if !subtitleExtension(ext) {
+			continue
+		}
+
+		// Le premier segment entre le nom de la vidéo et l'extension qui n'est pas un marqueur est la langue
+		language := "und"
+		for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSuffix(name, filepath.Ext(name)), base), ".") {
+			part = strings.ToLower(part)
+			if part != "" && !subtitleFlags[part] {
+				language = part
+				break
+			}
+		}
+
+		subtitles = append(subtitles, subtitleFile{
+			Path:     filepath.Join(filepath.Dir(videoPath), name),
+			Language: language,
+			Format:   strings.TrimPrefix(ext, "."),
+		})
+	}
+
+	return subtitles
+}
+
+// subtitleExtension indique si l'extension fait partie des formats de sous-titres configurés
+func subtitleExtension(ext string) bool {
+	for _, allowed := range cfg.Subtitles.Extensions {
+		if strings.EqualFold(ext, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordEmbeddedSubtitles enregistre les langues des pistes de sous-titres incluses dans la vidéo
+func recordEmbeddedSubtitles(uploadID int64, mediaInfo *model.MediaInfo) {
+	if mediaInfo == nil {
+		return
+	}
+
+	for _, language := range mediaInfo.Subtitles {
+		if language == "" {
+			continue
+		}
+
+		subtitle := &storage.Subtitle{
+			UploadID: uploadID,
+			Language: language,
+			Source:   storage.SubtitleSourceEmbedded,
+		}
+		if _, err := db.AddSubtitle(subtitle); err != nil {
+			log.Printf("Erreur lors de l'enregistrement des sous-titres inclus: %v", err)
+		}
+	}
+}
+
+// uploadSubtitles envoie les sous-titres trouvés à côté de la vidéo à l'hébergeur d'un
+// fichier uploadé, s'il les gère. Un échec n'empêche pas l'upload de la vidéo.
+func uploadSubtitles(uploadID int64, result *upload.UploadResult) {
+	if !cfg.Subtitles.Enabled {
+		return
+	}
+
+	subtitleUploader, ok := uploaderByName(result.Hoster).(upload.SubtitleUploader)
+	if !ok {
+		return
+	}
+
+	existing, err := db.GetUpload(uploadID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération de l'upload %d: %v", uploadID, err)
+		return
+	}
+
+	for _, subtitle := range findSubtitles(existing.FilePath) {
+		exists, err := db.HasSubtitle(uploadID, result.FileCode, subtitle.Path, subtitle.Language)
+		if err != nil {
+			log.Printf("%v", err)
+			continue
+		}
+		if exists {
+			continue
+		}
+
+		subtitleURL, err := subtitleUploader.UploadSubtitle(result.FileCode, subtitle.Path, subtitle.Language)
+		if err != nil {
+			log.Printf("Erreur lors de l'envoi des sous-titres %s vers %s: %v", subtitle.Path, result.Hoster, err)
+			continue
+		}
+
+		record := &storage.Subtitle{
+			UploadID: uploadID,
+			Hoster:   result.Hoster,
+			FileCode: result.FileCode,
+			Language: subtitle.Language,
+			Format:   subtitle.Format,
+			Source:   storage.SubtitleSourceExternal,
+			FilePath: subtitle.Path,
+			URL:      subtitleURL,
+		}
+		if _, err := db.AddSubtitle(record); err != nil {
+			log.Printf("Erreur lors de l'enregistrement des sous-titres: %v", err)
+			continue
+		}
+
+		log.Printf("Sous-titres %s envoyés vers %s pour le fichier %s", subtitle.Language, result.Hoster, result.FileCode)
+	}
+}
+
+// linkSubtitles retourne les sous-titres à publier avec le lien d'un fichier hébergé:
+// les pistes incluses dans la vidéo et les fichiers envoyés à cet hébergeur
+func linkSubtitles(uploadID int64, fileCode string) []strapi.LinkSubtitle {
+	subtitles, err := db.GetSubtitles(uploadID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des sous-titres: %v", err)
+		return nil
+	}
+
+	var linked []strapi.LinkSubtitle
+	for _, subtitle := range subtitles {
+		if subtitle.Source == storage.SubtitleSourceExternal && subtitle.FileCode != fileCode {
+			continue
+		}
+		linked = append(linked, strapi.LinkSubtitle{
+			Language: subtitle.Language,
+			URL:      subtitle.URL,
+		})
+	}
+
+	return linked
+}
@@ -26,6 +26,18 @@ type MovieDetails struct {
 	ReleaseDate string `json:"release_date"`
 }
 
+// SeasonDetails représente une saison d'une série et ses épisodes
+type SeasonDetails struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	SeasonNumber int    `json:"season_number"`
+	Episodes     []struct {
+		EpisodeNumber int    `json:"episode_number"`
+		Name          string `json:"name"`
+		AirDate       string `json:"air_date"`
+	} `json:"episodes"`
+}
+
 // NewTMDBClient crée un nouveau client TMDB
 func NewTMDBClient(apiKey string) *TMDBClient {
 	return &TMDBClient{
@@ -64,6 +76,35 @@ func (t *TMDBClient) GetMovieDetails(tmdbID int) (*MovieDetails, error) {
 	return &movie, nil
 }
 
+// GetSeasonDetails récupère une saison d'une série par l'ID TMDB de la série
+func (t *TMDBClient) GetSeasonDetails(tmdbID, season int) (*SeasonDetails, error) {
+	log.Printf("Récupération de la saison %d de la série TMDB ID: %d", season, tmdbID)
+
+	url := fmt.Sprintf("https://api.themoviedb.org/3/tv/%d/season/%d?api_key=%s&language=fr-FR", tmdbID, season, t.ApiKey)
+
+	// Créer un client HTTP avec timeout
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la requête HTTP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("l'API a retourné un code non-200: %d", resp.StatusCode)
+	}
+
+	var details SeasonDetails
+	if err := json.NewDecoder(resp.Body).Decode(&details); err != nil {
+		return nil, fmt.Errorf("erreur lors du décodage de la réponse JSON: %w", err)
+	}
+
+	return &details, nil
+}
+
 // GetMovieDetailsJSON récupère les détails d'un film au format JSON brut
 func (t *TMDBClient) GetMovieDetailsJSON(tmdbID int) (string, error) {
 	log.Printf("Récupération des détails complets du film TMDB ID: %d", tmdbID)
@@ -0,0 +1,13 @@
+package upload
+
+// AccountUsage représente l'utilisation d'un compte hébergeur (en octets, 0 = inconnu)
+type AccountUsage struct {
+	StorageUsed  int64
+	StorageLimit int64
+}
+
+// AccountReporter est implémenté par les uploaders dont l'API expose l'utilisation du compte
+type AccountReporter interface {
+	Uploader
+	AccountUsage() (*AccountUsage, error)
+}
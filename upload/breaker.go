@@ -0,0 +1,144 @@
+package upload
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// États d'un disjoncteur
+const (
+	BreakerClosed   = "closed"    // l'hébergeur est utilisé normalement
+	BreakerOpen     = "open"      // l'hébergeur est ignoré jusqu'à la fin du délai de refroidissement
+	BreakerHalfOpen = "half-open" // un upload de test est en cours
+)
+
+// BreakerState représente l'état du disjoncteur d'un hébergeur
+type BreakerState struct {
+	Hoster              string     `json:"hoster"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+}
+
+// breaker contient l'état interne du disjoncteur d'un hébergeur
+type breaker struct {
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+// Breakers regroupe un disjoncteur par hébergeur. Après threshold échecs consécutifs,
+// l'hébergeur est ignoré pendant cooldown, puis un seul upload de test est autorisé:
+// s'il réussit le disjoncteur se referme, sinon il se rouvre pour un nouveau délai.
+type Breakers struct {
+	mutex     sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	breakers  map[string]*breaker
+}
+
+// NewBreakers crée les disjoncteurs des hébergeurs
+func NewBreakers(threshold int, cooldown time.Duration) *Breakers {
+	return &Breakers{
+		threshold: threshold,
+		cooldown:  cooldown,
+		breakers:  make(map[string]*breaker),
+	}
+}
+
+// Allow indique si un upload peut être tenté vers l'hébergeur. Quand le délai de
+// refroidissement est écoulé, le premier appel obtient l'upload de test.
+func (b *Breakers) Allow(hoster string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	state := b.breaker(hoster)
+	switch state.state {
+	case BreakerOpen, BreakerHalfOpen:
+		// En semi-ouverture, un nouveau test n'est autorisé que si le précédent
+		// n'a rien signalé pendant tout un délai de refroidissement
+		if time.Since(state.openedAt) < b.cooldown {
+			return false
+		}
+		state.state = BreakerHalfOpen
+		state.openedAt = time.Now()
+		log.Printf("Disjoncteur de %s semi-ouvert, upload de test autorisé", hoster)
+		return true
+	default:
+		return true
+	}
+}
+
+// Success enregistre un upload réussi et referme le disjoncteur
+func (b *Breakers) Success(hoster string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	state := b.breaker(hoster)
+	if state.state != BreakerClosed {
+		log.Printf("Disjoncteur de %s refermé", hoster)
+	}
+
+	state.state = BreakerClosed
+	state.failures = 0
+}
+
+// Failure enregistre un upload échoué et ouvre le disjoncteur si le seuil est atteint
+// ou si l'upload de test a échoué
+func (b *Breakers) Failure(hoster string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	state := b.breaker(hoster)
+	state.failures++
+
+	if state.state == BreakerHalfOpen || (state.state == BreakerClosed && state.failures >= b.threshold) {
+		state.state = BreakerOpen
+		state.openedAt = time.Now()
+		log.Printf("Disjoncteur de %s ouvert après %d échecs consécutifs, hébergeur ignoré pendant %v",
+			hoster, state.failures, b.cooldown)
+	}
+}
+
+// States retourne l'état des disjoncteurs, triés par hébergeur
+func (b *Breakers) States() []BreakerState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	states := make([]BreakerState, 0, len(b.breakers))
+	for hoster, state := range b.breakers {
+		breakerState := BreakerState{
+			Hoster:              hoster,
+			State:               state.state,
+			ConsecutiveFailures: state.failures,
+		}
+
+		if state.state != BreakerClosed {
+			openedAt := state.openedAt
+			retryAt := state.openedAt.Add(b.cooldown)
+			breakerState.OpenedAt = &openedAt
+			breakerState.RetryAt = &retryAt
+		}
+
+		states = append(states, breakerState)
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Hoster < states[j].Hoster
+	})
+
+	return states
+}
+
+// breaker retourne le disjoncteur d'un hébergeur (le mutex doit être verrouillé)
+func (b *Breakers) breaker(hoster string) *breaker {
+	state, exists := b.breakers[hoster]
+	if !exists {
+		state = &breaker{state: BreakerClosed}
+		b.breakers[hoster] = state
+	}
+	return state
+}
@@ -0,0 +1,37 @@
+package upload
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// FileChecker est implémenté par les uploaders dont l'API permet de vérifier
+// qu'un fichier est toujours en ligne
+type FileChecker interface {
+	Uploader
+	CheckFile(fileCode string) (bool, error)
+}
+
+// CheckURL vérifie qu'une URL répond par une requête HEAD.
+// Un code 404 ou 410 indique un fichier supprimé, les autres erreurs restent indéterminées.
+func CheckURL(pageURL string) (bool, error) {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	resp, err := client.Head(pageURL)
+	if err != nil {
+		return false, fmt.Errorf("erreur lors de la requête HTTP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return false, nil
+	case resp.StatusCode >= 200 && resp.StatusCode < 400:
+		return true, nil
+	default:
+		return false, fmt.Errorf("le serveur a retourné un code inattendu: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,182 @@
+package upload
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// fanOutChunkSize est la taille des blocs lus sur le disque et copiés vers chaque hébergeur
+const fanOutChunkSize = 1024 * 1024
+
+// fanOutBufferedChunks est le nombre de blocs en attente pour un hébergeur. Un hébergeur qui
+// prend plus de retard quitte la lecture partagée et lit la suite du fichier de son côté.
+const fanOutBufferedChunks = 16
+
+// FanOut lit une seule fois le fichier et l'envoie en parallèle à plusieurs hébergeurs.
+// onResult est appelé dès qu'un hébergeur a terminé, sans attendre les autres, avec le nombre
+// d'octets du fichier envoyés à cet hébergeur. Un hébergeur lent ne ralentit pas les autres.
+func FanOut(filePath, title string, uploaders []StreamUploader, onResult func(hoster string, result *UploadResult, sent int64, err error)) error {
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("le fichier n'existe pas: %s", filePath)
+	}
+	if err != nil {
+		return fmt.Errorf("erreur lors de la lecture des informations du fichier: %w", err)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'ouverture du fichier: %w", err)
+	}
+	defer file.Close()
+
+	fileName := filepath.Base(filePath)
+	streams := make([]*fanOutStream, len(uploaders))
+
+	var wg sync.WaitGroup
+	for i, uploader := range uploaders {
+		reader, writer := io.Pipe()
+		stream := &fanOutStream{
+			name:   uploader.Name(),
+			writer: writer,
+			chunks: make(chan []byte, fanOutBufferedChunks),
+			open:   true,
+		}
+		streams[i] = stream
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			stream.forward(filePath)
+		}()
+		go func(uploader StreamUploader, reader *io.PipeReader) {
+			defer wg.Done()
+
+			counter := &ByteCounter{}
+			result, err := uploader.UploadStream(counter.Reader(reader), fileName, info.Size(), title)
+
+			// Fermer le flux pour que l'écriture vers cet hébergeur s'arrête
+			reader.CloseWithError(io.ErrClosedPipe)
+
+			onResult(uploader.Name(), result, counter.Sent(), err)
+		}(uploader, reader)
+	}
+
+	readErr := copyToStreams(file, streams)
+
+	for _, stream := range streams {
+		if stream.open {
+			stream.end(readErr)
+		}
+	}
+
+	wg.Wait()
+	return readErr
+}
+
+// fanOutStream transmet à un hébergeur les blocs de la lecture partagée
+type fanOutStream struct {
+	name   string
+	writer *io.PipeWriter
+	chunks chan []byte
+
+	// Champs modifiés par la lecture partagée, lus par forward après la fermeture de chunks
+	open     bool
+	detached bool  // l'hébergeur lit la suite du fichier de son côté
+	offset   int64 // octets transmis par la lecture partagée
+	readErr  error
+
+	failed int32 // l'envoi vers l'hébergeur a échoué
+}
+
+// end ferme la file des blocs, avec l'erreur de lecture à transmettre à l'hébergeur
+func (s *fanOutStream) end(readErr error) {
+	s.readErr = readErr
+	s.open = false
+	close(s.chunks)
+}
+
+// forward écrit les blocs reçus dans le flux de l'hébergeur, puis la suite du fichier s'il
+// a quitté la lecture partagée
+func (s *fanOutStream) forward(filePath string) {
+	for chunk := range s.chunks {
+		if atomic.LoadInt32(&s.failed) != 0 {
+			continue
+		}
+		if _, err := s.writer.Write(chunk); err != nil {
+			log.Printf("Flux vers %s interrompu: %v", s.name, err)
+			atomic.StoreInt32(&s.failed, 1)
+		}
+	}
+
+	if atomic.LoadInt32(&s.failed) != 0 || !s.detached {
+		s.writer.CloseWithError(s.readErr)
+		return
+	}
+
+	s.writer.CloseWithError(s.copyRemaining(filePath))
+}
+
+// copyRemaining envoie la fin du fichier, à partir de la position atteinte par la lecture partagée
+func (s *fanOutStream) copyRemaining(filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'ouverture du fichier: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(s.writer, io.NewSectionReader(file, s.offset, math.MaxInt64-s.offset)); err != nil {
+		return fmt.Errorf("erreur lors de la lecture du fichier: %w", err)
+	}
+	return nil
+}
+
+// copyToStreams lit le fichier et transmet chaque bloc aux hébergeurs encore actifs.
+// Un hébergeur en échec est retiré, un hébergeur en retard quitte la lecture partagée,
+// sans interrompre les autres.
+func copyToStreams(file io.Reader, streams []*fanOutStream) error {
+	for {
+		// Chaque bloc est partagé par les hébergeurs: un nouveau tampon est alloué à chaque lecture
+		chunk := make([]byte, fanOutChunkSize)
+		n, err := file.Read(chunk)
+		if n > 0 {
+			active := 0
+			for _, stream := range streams {
+				if !stream.open {
+					continue
+				}
+				if atomic.LoadInt32(&stream.failed) != 0 {
+					stream.end(nil)
+					continue
+				}
+
+				select {
+				case stream.chunks <- chunk[:n]:
+					stream.offset += int64(n)
+					active++
+				default:
+					log.Printf("Flux vers %s en retard, lecture du fichier séparée", stream.name)
+					stream.detached = true
+					stream.end(nil)
+				}
+			}
+
+			if active == 0 {
+				return nil
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("erreur lors de la lecture du fichier: %w", err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package upload_test
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"media-upload-system/upload"
+)
+
+// gatedUploader reçoit le flux en entier, après l'ouverture de gate s'il est défini
+type gatedUploader struct {
+	name     string
+	gate     chan struct{}
+	received []byte
+}
+
+func (u *gatedUploader) Name() string    { return u.name }
+func (u *gatedUploader) IsEnabled() bool { return true }
+
+func (u *gatedUploader) UploadFile(filePath, title string) (*upload.UploadResult, error) {
+	panic("non utilisé")
+}
+
+func (u *gatedUploader) UploadStream(content io.Reader, fileName string, size int64, title string) (*upload.UploadResult, error) {
+	if u.gate != nil {
+		<-u.gate
+	}
+
+	received, err := io.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+	u.received = received
+	return &upload.UploadResult{Success: true, Hoster: u.name}, nil
+}
+
+func TestFanOutSlowHoster(t *testing.T) {
+	// Plus de blocs que le tampon d'un hébergeur: le lent doit quitter la lecture partagée
+	path, content := writeRandomFile(t, "film.mkv", 40*1024*1024+123)
+
+	fast := &gatedUploader{name: "rapide"}
+	slow := &gatedUploader{name: "lent", gate: make(chan struct{})}
+
+	var mutex sync.Mutex
+	var finished []string
+	fastDone := make(chan struct{})
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- upload.FanOut(path, "Film", []upload.StreamUploader{slow, fast}, func(hoster string, result *upload.UploadResult, sent int64, err error) {
+			if err != nil {
+				t.Errorf("%s: %v", hoster, err)
+			}
+			mutex.Lock()
+			finished = append(finished, hoster)
+			mutex.Unlock()
+			if hoster == fast.name {
+				close(fastDone)
+			}
+		})
+	}()
+
+	// L'hébergeur rapide termine alors que le lent n'a encore rien lu
+	select {
+	case <-fastDone:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("l'hébergeur rapide est bloqué par le lent")
+	}
+	close(slow.gate)
+
+	if err := <-errChan; err != nil {
+		t.Fatalf("FanOut: %v", err)
+	}
+
+	if len(finished) != 2 || finished[0] != fast.name {
+		t.Fatalf("ordre de fin inattendu: %v", finished)
+	}
+	if !bytes.Equal(fast.received, content) || !bytes.Equal(slow.received, content) {
+		t.Fatalf("contenu reçu incomplet: %d et %d octets sur %d", len(fast.received), len(slow.received), len(content))
+	}
+}
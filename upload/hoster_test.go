@@ -0,0 +1,163 @@
+package upload_test
+
+import (
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"media-upload-system/upload"
+	"media-upload-system/upload/hostertest"
+)
+
+// failureModes sont les pannes qui font échouer une requête sans bloquer le client
+var failureModes = []hostertest.FailureMode{
+	hostertest.FailServerError,
+	hostertest.FailMalformedJSON,
+	hostertest.FailPartialWrite,
+	hostertest.FailRejected,
+}
+
+// writeTestFile crée un fichier vidéo factice de size octets
+func writeTestFile(t *testing.T, size int) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "film.mkv")
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+// writeRandomFile crée un fichier de size octets aléatoires, pour vérifier l'assemblage des blocs
+func writeRandomFile(t *testing.T, name string, size int) (string, []byte) {
+	t.Helper()
+
+	content := make([]byte, size)
+	if _, err := rand.Read(content); err != nil {
+		t.Fatalf("rand.Read: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path, content
+}
+
+// sessionStore simule la persistance en base d'une session d'upload reprenable
+type sessionStore struct {
+	session *upload.UploadSession
+	saves   int
+}
+
+// Load retourne une copie de la session enregistrée
+func (s *sessionStore) Load() (*upload.UploadSession, error) {
+	if s.session == nil {
+		return nil, nil
+	}
+	session := *s.session
+	return &session, nil
+}
+
+// Save enregistre une copie de la session
+func (s *sessionStore) Save(session *upload.UploadSession) error {
+	saved := *session
+	s.session = &saved
+	s.saves++
+	return nil
+}
+
+// Clear supprime la session
+func (s *sessionStore) Clear() error {
+	s.session = nil
+	return nil
+}
+
+// testFailureModes programme chaque panne une fois sur chaque chemin et vérifie que l'upload
+// échoue, puis qu'il réussit à la tentative suivante
+func testFailureModes(t *testing.T, server *hostertest.Server, uploader upload.Uploader, paths []string) {
+	path := writeTestFile(t, 4096)
+
+	for _, failedPath := range paths {
+		for _, mode := range failureModes {
+			server.Fail(failedPath, mode, 1)
+
+			result, err := uploader.UploadFile(path, "Film")
+			if err == nil {
+				t.Fatalf("%s sur %s: erreur attendue, obtenu %+v", mode, failedPath, result)
+			}
+			if mode == hostertest.FailServerError && upload.StatusCode(err) != 503 {
+				t.Fatalf("%s sur %s: code 503 attendu, obtenu %d (%v)", mode, failedPath, upload.StatusCode(err), err)
+			}
+
+			result, err = uploader.UploadFile(path, "Film")
+			if err != nil || !result.Success || result.FileCode == "" {
+				t.Fatalf("%s sur %s: la tentative suivante a échoué: %+v, %v", mode, failedPath, result, err)
+			}
+		}
+	}
+}
+
+// testTimeout vérifie qu'un upload bloqué par un serveur muet échoue quand la connexion est coupée
+func testTimeout(t *testing.T, server *hostertest.Server, uploader upload.Uploader, path string) {
+	server.Fail(path, hostertest.FailTimeout, 0)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := uploader.UploadFile(writeTestFile(t, 4096), "Film")
+		done <- err
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for server.Requests(path) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("aucune requête reçue sur %s", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case err := <-done:
+		t.Fatalf("l'upload s'est terminé sans réponse du serveur: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	server.Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatalf("erreur attendue après la coupure du serveur")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("l'upload est resté bloqué après la coupure du serveur")
+	}
+}
+
+// recordFixtures enregistre les échanges de fn à travers un proxy vers target et les relit
+// depuis un fichier, comme des fixtures enregistrées auprès d'un vrai hébergeur
+func recordFixtures(t *testing.T, target string, fn func(proxyURL string)) []hostertest.Fixture {
+	t.Helper()
+
+	recorder, err := hostertest.NewRecorder(target)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	fn(recorder.URL)
+	recorder.Close()
+
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	if err := recorder.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	fixtures, err := hostertest.LoadFixtures(path)
+	if err != nil {
+		t.Fatalf("LoadFixtures: %v", err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatalf("aucun échange enregistré")
+	}
+
+	return fixtures
+}
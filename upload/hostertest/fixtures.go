@@ -0,0 +1,192 @@
+package hostertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// serverPlaceholder remplace l'URL du serveur enregistré dans les corps des fixtures
+const serverPlaceholder = "{{server}}"
+
+// secretParams sont retirés des requêtes enregistrées
+var secretParams = []string{"key", "email", "api_key", "key_hash", "hash"}
+
+// Fixture représente un échange enregistré avec un hébergeur
+type Fixture struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Query       string `json:"query,omitempty"` // sans les identifiants
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body"`
+}
+
+// LoadFixtures lit des fixtures enregistrées dans un fichier JSON
+func LoadFixtures(path string) ([]Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la lecture des fixtures: %w", err)
+	}
+
+	var fixtures []Fixture
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("erreur lors du décodage des fixtures: %w", err)
+	}
+
+	return fixtures, nil
+}
+
+// SaveFixtures écrit des fixtures dans un fichier JSON
+func SaveFixtures(path string, fixtures []Fixture) error {
+	data, err := json.MarshalIndent(fixtures, "", "  ")
+	if err != nil {
+		return fmt.Errorf("erreur lors de la sérialisation des fixtures: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("erreur lors de l'écriture des fixtures: %w", err)
+	}
+
+	return nil
+}
+
+// Recorder est un proxy local vers un vrai hébergeur qui enregistre ses réponses.
+// Les corps des requêtes (les fichiers uploadés) ne sont pas conservés.
+type Recorder struct {
+	*httptest.Server
+
+	target   *url.URL
+	mutex    sync.Mutex
+	fixtures []Fixture
+}
+
+// NewRecorder démarre un proxy d'enregistrement vers target, par exemple https://api.mixdrop.ag
+func NewRecorder(target string) (*Recorder, error) {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("URL cible invalide: %w", err)
+	}
+
+	recorder := &Recorder{target: targetURL}
+
+	proxy := httputil.NewSingleHostReverseProxy(targetURL)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Host = targetURL.Host
+	}
+	proxy.ModifyResponse = recorder.record
+
+	recorder.Server = httptest.NewServer(proxy)
+	return recorder, nil
+}
+
+// record enregistre une réponse de l'hébergeur en remplaçant son URL par un marqueur.
+// Dans la réponse transmise au client, elle est remplacée par celle du proxy pour que les
+// requêtes suivantes, comme l'envoi au serveur d'upload de Netu, soient aussi enregistrées.
+func (rec *Recorder) record(resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	targetURL := rec.target.Scheme + "://" + rec.target.Host
+	proxied := []byte(strings.ReplaceAll(string(body), targetURL, rec.URL))
+	resp.Body = io.NopCloser(bytes.NewReader(proxied))
+	resp.ContentLength = int64(len(proxied))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(proxied)))
+
+	query := resp.Request.URL.Query()
+	for _, param := range secretParams {
+		query.Del(param)
+	}
+
+	fixture := Fixture{
+		Method:      resp.Request.Method,
+		Path:        resp.Request.URL.Path,
+		Query:       query.Encode(),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        strings.ReplaceAll(string(body), targetURL, serverPlaceholder),
+	}
+
+	rec.mutex.Lock()
+	rec.fixtures = append(rec.fixtures, fixture)
+	rec.mutex.Unlock()
+
+	return nil
+}
+
+// Fixtures retourne les échanges enregistrés
+func (rec *Recorder) Fixtures() []Fixture {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	return append([]Fixture(nil), rec.fixtures...)
+}
+
+// Save écrit les échanges enregistrés dans un fichier JSON
+func (rec *Recorder) Save(path string) error {
+	return SaveFixtures(path, rec.Fixtures())
+}
+
+// NewReplay démarre un serveur qui rejoue des fixtures. Les fixtures d'un même chemin et
+// d'une même méthode sont servies dans l'ordre, la dernière étant répétée ensuite.
+// Les pannes peuvent être programmées comme sur les autres faux serveurs.
+func NewReplay(fixtures []Fixture) *Server {
+	server := newServer(`{"success":false,"status":403,"msg":"request rejected"}`)
+
+	sequences := make(map[string][]Fixture)
+	for _, fixture := range fixtures {
+		sequences[fixture.Path] = append(sequences[fixture.Path], fixture)
+	}
+
+	var mutex sync.Mutex
+	served := make(map[string]int)
+
+	for path := range sequences {
+		path := path
+		server.handle(path, func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+
+			mutex.Lock()
+			var candidates []Fixture
+			for _, fixture := range sequences[path] {
+				if fixture.Method == r.Method {
+					candidates = append(candidates, fixture)
+				}
+			}
+			key := r.Method + " " + path
+			index := served[key]
+			if index >= len(candidates) {
+				index = len(candidates) - 1
+			}
+			served[key]++
+			mutex.Unlock()
+
+			if index < 0 {
+				http.Error(w, fmt.Sprintf("aucune fixture pour %s %s", r.Method, path), http.StatusNotFound)
+				return
+			}
+
+			fixture := candidates[index]
+			if fixture.ContentType != "" {
+				w.Header().Set("Content-Type", fixture.ContentType)
+			}
+			w.WriteHeader(fixture.Status)
+			io.WriteString(w, strings.ReplaceAll(fixture.Body, serverPlaceholder, server.URL))
+		})
+	}
+
+	return server
+}
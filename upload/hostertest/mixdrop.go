@@ -0,0 +1,322 @@
+package hostertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"media-upload-system/upload"
+)
+
+// Identifiants acceptés par le faux serveur MixDrop
+const (
+	MixDropEmail  = "test@example.com"
+	MixDropAPIKey = "mixdrop-test-key"
+)
+
+// MixDropServer simule l'API et le serveur d'upload de MixDrop
+type MixDropServer struct {
+	*Server
+
+	mutex        sync.Mutex
+	files        map[string]*File
+	folders      map[string]*Folder
+	remoteJobs   map[string]string // ID du remote upload -> fileref
+	nextID       int
+	StorageLimit int64 // limite retournée par accountinfo (0 = illimité)
+}
+
+// NewMixDrop démarre un faux serveur MixDrop
+func NewMixDrop() *MixDropServer {
+	m := &MixDropServer{
+		Server:     newServer(`{"success":false,"result":{"msg":"request rejected"}}`),
+		files:      make(map[string]*File),
+		folders:    make(map[string]*Folder),
+		remoteJobs: make(map[string]string),
+	}
+
+	m.handle("/upload", m.handleUpload)
+	m.handle("/remoteupload", m.authenticated(m.handleRemoteUpload))
+	m.handle("/remotestatus", m.authenticated(m.handleRemoteStatus))
+	m.handle("/fileinfo2", m.authenticated(m.handleFileInfo))
+	m.handle("/accountinfo", m.authenticated(m.handleAccountInfo))
+	m.handle("/removefile", m.authenticated(m.handleRemoveFile))
+	m.handle("/filerename", m.authenticated(m.handleRenameFile))
+	m.handle("/filemove", m.authenticated(m.handleMoveFile))
+	m.handle("/folderlist", m.authenticated(m.handleFolderList))
+	m.handle("/foldercreate", m.authenticated(m.handleFolderCreate))
+	m.handle("/addsubtitle", m.handleAddSubtitle)
+
+	return m
+}
+
+// Uploader retourne un uploader MixDrop configuré pour ce serveur
+func (m *MixDropServer) Uploader() *upload.MixDropUploader {
+	return upload.NewMixDropUploader(MixDropEmail, MixDropAPIKey, true).SetBaseURLs(m.URL+"/upload", m.URL, m.URL)
+}
+
+// Files retourne une copie des fichiers du compte
+func (m *MixDropServer) Files() []File {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	files := make([]File, 0, len(m.files))
+	for _, file := range m.files {
+		files = append(files, *file)
+	}
+	return files
+}
+
+// AddFile ajoute un fichier au compte, par exemple pour tester la vérification des liens
+func (m *MixDropServer) AddFile(title string, size int64) File {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return *m.addFile(title, title, size)
+}
+
+// DeleteFile marque un fichier comme supprimé, comme le ferait MixDrop après un signalement
+func (m *MixDropServer) DeleteFile(fileRef string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if file, exists := m.files[fileRef]; exists {
+		file.Deleted = true
+	}
+}
+
+// addFile crée un fichier (le mutex doit être verrouillé)
+func (m *MixDropServer) addFile(title, name string, size int64) *File {
+	m.nextID++
+	file := &File{
+		Code:  fmt.Sprintf("md%06d", m.nextID),
+		Title: title,
+		Name:  name,
+		Size:  size,
+	}
+	file.EmbedCode = file.Code
+	m.files[file.Code] = file
+	return file
+}
+
+// authenticated refuse les requêtes sans les identifiants de test
+func (m *MixDropServer) authenticated(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("email") != MixDropEmail || r.FormValue("key") != MixDropAPIKey {
+			writeJSON(w, map[string]interface{}{"success": false, "result": map[string]string{"msg": "invalid credentials"}})
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// success envoie une réponse réussie de l'API MixDrop
+func (m *MixDropServer) success(w http.ResponseWriter, result interface{}) {
+	writeJSON(w, map[string]interface{}{"success": true, "result": result})
+}
+
+// failure envoie un refus de l'API MixDrop
+func (m *MixDropServer) failure(w http.ResponseWriter, message string) {
+	writeJSON(w, map[string]interface{}{"success": false, "result": map[string]string{"msg": message}})
+}
+
+func (m *MixDropServer) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fields, fileName, size, err := multipartUpload(r, "file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if fields["email"] != MixDropEmail || fields["key"] != MixDropAPIKey {
+		m.failure(w, "invalid credentials")
+		return
+	}
+
+	m.mutex.Lock()
+	_, folderExists := m.folders[fields["folder"]]
+	var file *File
+	if folderExists || fields["folder"] == "" {
+		file = m.addFile(fields["title"], fileName, size)
+		file.FolderID = fields["folder"]
+	}
+	m.mutex.Unlock()
+
+	if file == nil {
+		m.failure(w, "folder not found")
+		return
+	}
+
+	m.success(w, map[string]string{
+		"fileref": file.Code,
+		"title":   file.Title,
+		"status":  "OK",
+	})
+}
+
+func (m *MixDropServer) handleRemoteUpload(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	if _, exists := m.folders[r.FormValue("folder")]; !exists && r.FormValue("folder") != "" {
+		m.mutex.Unlock()
+		m.failure(w, "folder not found")
+		return
+	}
+	file := m.addFile(r.FormValue("name"), r.FormValue("url"), 0)
+	file.FolderID = r.FormValue("folder")
+	jobID := strconv.Itoa(m.nextID)
+	m.remoteJobs[jobID] = file.Code
+	m.mutex.Unlock()
+
+	m.success(w, map[string]interface{}{"id": jobID, "status": "Pending"})
+}
+
+func (m *MixDropServer) handleRemoteStatus(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	fileRef, exists := m.remoteJobs[r.FormValue("id")]
+	m.mutex.Unlock()
+
+	if !exists {
+		m.failure(w, "remote upload not found")
+		return
+	}
+
+	m.success(w, map[string]interface{}{"id": r.FormValue("id"), "fileref": fileRef, "status": "Completed"})
+}
+
+func (m *MixDropServer) handleFileInfo(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	result := make(map[string]interface{})
+	for _, ref := range r.Form["ref[]"] {
+		file, exists := m.files[ref]
+		if !exists {
+			continue
+		}
+		result[ref] = map[string]interface{}{
+			"fileref": file.Code,
+			"title":   file.Title,
+			"status":  "OK",
+			"deleted": file.Deleted,
+		}
+	}
+
+	if len(result) == 0 {
+		m.failure(w, "file not found")
+		return
+	}
+
+	m.success(w, result)
+}
+
+func (m *MixDropServer) handleAccountInfo(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	var used int64
+	for _, file := range m.files {
+		if !file.Deleted {
+			used += file.Size
+		}
+	}
+	m.mutex.Unlock()
+
+	m.success(w, map[string]int64{"storage_used": used, "storage_limit": m.StorageLimit})
+}
+
+// withFile applique une modification à un fichier existant et répond selon le résultat
+func (m *MixDropServer) withFile(w http.ResponseWriter, ref string, update func(*File)) {
+	m.mutex.Lock()
+	file, exists := m.files[ref]
+	found := exists && !file.Deleted
+	if found {
+		update(file)
+	}
+	m.mutex.Unlock()
+
+	if !found {
+		m.failure(w, "file not found")
+		return
+	}
+	m.success(w, map[string]string{"fileref": ref})
+}
+
+func (m *MixDropServer) handleRemoveFile(w http.ResponseWriter, r *http.Request) {
+	m.withFile(w, r.FormValue("ref"), func(file *File) {
+		file.Deleted = true
+	})
+}
+
+func (m *MixDropServer) handleRenameFile(w http.ResponseWriter, r *http.Request) {
+	m.withFile(w, r.FormValue("ref"), func(file *File) {
+		file.Title = r.FormValue("title")
+	})
+}
+
+func (m *MixDropServer) handleMoveFile(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	_, exists := m.folders[r.FormValue("folder")]
+	m.mutex.Unlock()
+
+	if !exists {
+		m.failure(w, "folder not found")
+		return
+	}
+
+	m.withFile(w, r.FormValue("ref"), func(file *File) {
+		file.FolderID = r.FormValue("folder")
+	})
+}
+
+func (m *MixDropServer) handleFolderList(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	folders := []map[string]string{}
+	for _, folder := range m.folders {
+		if folder.ParentID == r.FormValue("id") {
+			folders = append(folders, map[string]string{"id": folder.ID, "title": folder.Name})
+		}
+	}
+
+	m.success(w, map[string]interface{}{"folders": folders})
+}
+
+func (m *MixDropServer) handleFolderCreate(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	m.nextID++
+	folder := &Folder{
+		ID:       strconv.Itoa(m.nextID),
+		Name:     r.FormValue("title"),
+		ParentID: r.FormValue("parent"),
+	}
+	m.folders[folder.ID] = folder
+	m.mutex.Unlock()
+
+	m.success(w, map[string]string{"id": folder.ID})
+}
+
+func (m *MixDropServer) handleAddSubtitle(w http.ResponseWriter, r *http.Request) {
+	fields, _, _, err := multipartUpload(r, "file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if fields["email"] != MixDropEmail || fields["key"] != MixDropAPIKey {
+		m.failure(w, "invalid credentials")
+		return
+	}
+
+	m.withFile(w, fields["ref"], func(file *File) {
+		file.Subtitles = append(file.Subtitles, fields["lang"])
+	})
+}
+
+// writeJSON envoie une réponse JSON avec le code 200, comme les APIs des hébergeurs
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,287 @@
+package hostertest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"media-upload-system/upload"
+)
+
+// Clé d'API acceptée par le faux serveur Netu
+const NetuAPIKey = "netu-test-key"
+
+// NetuServer simule l'API de Netu et son serveur d'upload
+type NetuServer struct {
+	*Server
+
+	mutex       sync.Mutex
+	files       map[string]*File
+	folders     map[string]*Folder
+	uploaded    map[string]int64 // fichiers reçus par le serveur d'upload, en attente de file/add
+	nextID      int
+	StorageLeft int64 // espace restant retourné par account/info (0 = non communiqué)
+}
+
+// NewNetu démarre un faux serveur Netu. Le serveur d'upload est servi sur /upload.
+func NewNetu() *NetuServer {
+	n := &NetuServer{
+		Server:   newServer(`{"status":403,"msg":"request rejected","success":"no"}`),
+		files:    make(map[string]*File),
+		folders:  make(map[string]*Folder),
+		uploaded: make(map[string]int64),
+	}
+
+	n.handle("/api/file/upload_server", n.authenticated(n.handleUploadServer))
+	n.handle("/upload", n.handleUpload)
+	n.handle("/api/file/add", n.authenticated(n.handleFileAdd))
+	n.handle("/api/file/info", n.authenticated(n.handleFileInfo))
+	n.handle("/api/account/info", n.authenticated(n.handleAccountInfo))
+	n.handle("/api/file/delete", n.authenticated(n.handleFileDelete))
+	n.handle("/api/file/rename", n.authenticated(n.handleFileRename))
+	n.handle("/api/file/set_folder", n.authenticated(n.handleSetFolder))
+	n.handle("/api/folder/list", n.authenticated(n.handleFolderList))
+	n.handle("/api/folder/create", n.authenticated(n.handleFolderCreate))
+
+	return n
+}
+
+// Uploader retourne un uploader Netu configuré pour ce serveur
+func (n *NetuServer) Uploader() *upload.NetuUploader {
+	return upload.NewNetuUploader(NetuAPIKey, true).SetBaseURLs(n.URL+"/api", n.URL)
+}
+
+// Files retourne une copie des fichiers du compte
+func (n *NetuServer) Files() []File {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	files := make([]File, 0, len(n.files))
+	for _, file := range n.files {
+		files = append(files, *file)
+	}
+	return files
+}
+
+// AddFile ajoute un fichier au compte, par exemple pour tester la vérification des liens
+func (n *NetuServer) AddFile(title string, size int64) File {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return *n.addFile(title, title, size)
+}
+
+// DeleteFile marque un fichier comme supprimé, comme le ferait Netu après un signalement
+func (n *NetuServer) DeleteFile(fileCode string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if file, exists := n.files[fileCode]; exists {
+		file.Deleted = true
+	}
+}
+
+// addFile crée un fichier (le mutex doit être verrouillé)
+func (n *NetuServer) addFile(title, name string, size int64) *File {
+	n.nextID++
+	file := &File{
+		Code:      fmt.Sprintf("nt%06d", n.nextID),
+		EmbedCode: fmt.Sprintf("ne%06d", n.nextID),
+		Title:     title,
+		Name:      name,
+		Size:      size,
+		FolderID:  "0",
+	}
+	n.files[file.Code] = file
+	return file
+}
+
+// authenticated refuse les requêtes sans la clé d'API de test
+func (n *NetuServer) authenticated(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("key") != NetuAPIKey {
+			n.failure(w, http.StatusForbidden, "invalid key")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// success envoie une réponse réussie de l'API Netu
+func (n *NetuServer) success(w http.ResponseWriter, result interface{}) {
+	writeJSON(w, map[string]interface{}{"status": 200, "msg": "OK", "result": result})
+}
+
+// failure envoie un refus de l'API Netu, dont le statut est dans le corps
+func (n *NetuServer) failure(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, map[string]interface{}{"status": status, "msg": message})
+}
+
+func (n *NetuServer) handleUploadServer(w http.ResponseWriter, r *http.Request) {
+	n.success(w, map[string]interface{}{
+		"upload_server": n.URL + "/upload",
+		"server_id":     "1",
+		"hash":          "test-hash",
+		"time_hash":     1700000000,
+		"userid":        "1",
+		"key_hash":      "test-key-hash",
+	})
+}
+
+func (n *NetuServer) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fields, fileName, size, err := multipartUpload(r, "Filedata")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if fields["hash"] != "test-hash" || fields["key_hash"] != "test-key-hash" {
+		writeJSON(w, map[string]string{"success": "no", "msg": "invalid hash"})
+		return
+	}
+
+	n.mutex.Lock()
+	n.nextID++
+	storedName := fmt.Sprintf("%d_%s", n.nextID, fileName)
+	n.uploaded[storedName] = size
+	n.mutex.Unlock()
+
+	writeJSON(w, map[string]string{"success": "yes", "file_name": storedName})
+}
+
+func (n *NetuServer) handleFileAdd(w http.ResponseWriter, r *http.Request) {
+	folderID := r.FormValue("fld_id")
+	n.mutex.Lock()
+	_, folderExists := n.folders[folderID]
+	if !folderExists && folderID != "" && folderID != "0" {
+		n.mutex.Unlock()
+		n.failure(w, http.StatusNotFound, "folder not found")
+		return
+	}
+	size, exists := n.uploaded[r.FormValue("file_name")]
+	var file *File
+	if exists {
+		delete(n.uploaded, r.FormValue("file_name"))
+		file = n.addFile(r.FormValue("name"), r.FormValue("file_name"), size)
+		if folderID != "" {
+			file.FolderID = folderID
+		}
+	}
+	n.mutex.Unlock()
+
+	if !exists {
+		n.failure(w, http.StatusNotFound, "uploaded file not found")
+		return
+	}
+
+	n.success(w, map[string]string{
+		"file_code":       file.Code,
+		"folder_id":       file.FolderID,
+		"file_code_embed": file.EmbedCode,
+	})
+}
+
+func (n *NetuServer) handleFileInfo(w http.ResponseWriter, r *http.Request) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	code := r.FormValue("file_code")
+	status := http.StatusNotFound
+	if file, exists := n.files[code]; exists && !file.Deleted {
+		status = http.StatusOK
+	}
+
+	n.success(w, []map[string]interface{}{
+		{"file_code": code, "status": status},
+	})
+}
+
+func (n *NetuServer) handleAccountInfo(w http.ResponseWriter, r *http.Request) {
+	n.mutex.Lock()
+	var used int64
+	for _, file := range n.files {
+		if !file.Deleted {
+			used += file.Size
+		}
+	}
+	n.mutex.Unlock()
+
+	n.success(w, map[string]int64{"storage_used": used, "storage_left": n.StorageLeft})
+}
+
+// withFile applique une modification à un fichier existant et répond selon le résultat
+func (n *NetuServer) withFile(w http.ResponseWriter, code string, update func(*File)) {
+	n.mutex.Lock()
+	file, exists := n.files[code]
+	found := exists && !file.Deleted
+	if found {
+		update(file)
+	}
+	n.mutex.Unlock()
+
+	if !found {
+		n.failure(w, http.StatusNotFound, "file not found")
+		return
+	}
+	n.success(w, map[string]string{"file_code": code})
+}
+
+func (n *NetuServer) handleFileDelete(w http.ResponseWriter, r *http.Request) {
+	n.withFile(w, r.FormValue("file_code"), func(file *File) {
+		file.Deleted = true
+	})
+}
+
+func (n *NetuServer) handleFileRename(w http.ResponseWriter, r *http.Request) {
+	n.withFile(w, r.FormValue("file_code"), func(file *File) {
+		file.Title = r.FormValue("title")
+	})
+}
+
+func (n *NetuServer) handleSetFolder(w http.ResponseWriter, r *http.Request) {
+	n.mutex.Lock()
+	_, exists := n.folders[r.FormValue("fld_id")]
+	n.mutex.Unlock()
+
+	if !exists && r.FormValue("fld_id") != "0" {
+		n.failure(w, http.StatusNotFound, "folder not found")
+		return
+	}
+
+	n.withFile(w, r.FormValue("file_code"), func(file *File) {
+		file.FolderID = r.FormValue("fld_id")
+	})
+}
+
+func (n *NetuServer) handleFolderList(w http.ResponseWriter, r *http.Request) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	parentID := r.FormValue("fld_id")
+	folders := []map[string]string{}
+	for _, folder := range n.folders {
+		if folder.ParentID == parentID {
+			folders = append(folders, map[string]string{"fld_id": folder.ID, "name": folder.Name})
+		}
+	}
+
+	n.success(w, map[string]interface{}{"folders": folders})
+}
+
+func (n *NetuServer) handleFolderCreate(w http.ResponseWriter, r *http.Request) {
+	n.mutex.Lock()
+	n.nextID++
+	folder := &Folder{
+		ID:       strconv.Itoa(n.nextID),
+		Name:     r.FormValue("name"),
+		ParentID: r.FormValue("parent_id"),
+	}
+	n.folders[folder.ID] = folder
+	n.mutex.Unlock()
+
+	n.success(w, map[string]string{"fld_id": folder.ID})
+}
@@ -0,0 +1,235 @@
+// Package hostertest fournit de faux serveurs MixDrop et Netu, des pannes configurables et
+// la relecture de réponses enregistrées, pour faire tourner les uploaders sans réseau.
+//
+// Utilisation typique:
+//
+//	server := hostertest.NewMixDrop()
+//	defer server.Close()
+//	server.Fail("/upload", hostertest.FailServerError, 1) // la première tentative échoue
+//	result, err := server.Uploader().UploadFile("film.mkv", "Film")
+package hostertest
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+)
+
+// FailureMode représente une panne simulée par un faux serveur
+type FailureMode string
+
+// Pannes simulées
+const (
+	FailTimeout       FailureMode = "timeout"        // aucune réponse jusqu'à l'abandon du client
+	FailServerError   FailureMode = "server-error"   // réponse HTTP 503
+	FailMalformedJSON FailureMode = "malformed-json" // réponse HTTP 200 dont le JSON est tronqué
+	FailPartialWrite  FailureMode = "partial-write"  // connexion coupée au milieu de la réponse
+	FailRejected      FailureMode = "rejected"       // réponse HTTP 200 qui signale un échec dans le corps
+)
+
+// failure représente une panne programmée sur un chemin. remaining vaut 0 pour une panne permanente.
+type failure struct {
+	mode      FailureMode
+	remaining int
+}
+
+// Server est un serveur HTTP local qui route les requêtes par chemin et peut simuler des pannes
+type Server struct {
+	*httptest.Server
+
+	mutex     sync.Mutex
+	handlers  map[string]http.HandlerFunc
+	failures  map[string]*failure
+	requests  map[string]int
+	rejection string // corps retourné pour FailRejected
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+// newServer démarre un serveur sans route. rejection est le corps JSON d'un refus de l'API.
+func newServer(rejection string) *Server {
+	s := &Server{
+		handlers:  make(map[string]http.HandlerFunc),
+		failures:  make(map[string]*failure),
+		requests:  make(map[string]int),
+		rejection: rejection,
+		closing:   make(chan struct{}),
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// handle enregistre le handler d'un chemin
+func (s *Server) handle(path string, handler http.HandlerFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.handlers[path] = handler
+}
+
+// Fail programme une panne sur un chemin pour les count prochaines requêtes (0 = toujours)
+func (s *Server) Fail(path string, mode FailureMode, count int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures[path] = &failure{mode: mode, remaining: count}
+}
+
+// Reset supprime toutes les pannes programmées
+func (s *Server) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures = make(map[string]*failure)
+}
+
+// Requests retourne le nombre de requêtes reçues sur un chemin
+func (s *Server) Requests(path string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests[path]
+}
+
+// Close libère les requêtes bloquées par FailTimeout puis arrête le serveur
+func (s *Server) Close() {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+	s.Server.Close()
+}
+
+// ServeHTTP route la requête, en appliquant la panne programmée sur son chemin
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	s.requests[r.URL.Path]++
+	handler, exists := s.handlers[r.URL.Path]
+	mode := s.nextFailure(r.URL.Path)
+	s.mutex.Unlock()
+
+	if !exists {
+		http.Error(w, fmt.Sprintf("chemin inconnu: %s", r.URL.Path), http.StatusNotFound)
+		return
+	}
+
+	switch mode {
+	case FailTimeout:
+		// Lire la requête comme le ferait un serveur lent, puis ne jamais répondre
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-s.closing:
+		}
+	case FailServerError:
+		io.Copy(io.Discard, r.Body)
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+	case FailMalformedJSON:
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"success": tru`)
+	case FailRejected:
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, s.rejection)
+	case FailPartialWrite:
+		recorder := httptest.NewRecorder()
+		handler(recorder, r)
+		body := recorder.Body.Bytes()
+
+		// Annoncer la taille complète puis couper la connexion après la moitié du corps
+		for key, values := range recorder.Header() {
+			w.Header()[key] = values
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(recorder.Code)
+		w.Write(body[:len(body)/2])
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		panic(http.ErrAbortHandler)
+	default:
+		handler(w, r)
+	}
+}
+
+// nextFailure retourne la panne à appliquer à une requête et la décompte (le mutex doit être verrouillé)
+func (s *Server) nextFailure(path string) FailureMode {
+	programmed, exists := s.failures[path]
+	if !exists {
+		return ""
+	}
+
+	if programmed.remaining > 0 {
+		programmed.remaining--
+		if programmed.remaining == 0 {
+			delete(s.failures, path)
+		}
+	}
+
+	log.Printf("hostertest: panne %s simulée sur %s", programmed.mode, path)
+	return programmed.mode
+}
+
+// multipartUpload lit un formulaire multipart en flux et retourne ses champs, le nom et
+// la taille du fichier, sans le garder en mémoire
+func multipartUpload(r *http.Request, fileField string) (map[string]string, string, int64, error) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("Content-Type invalide: %w", err)
+	}
+
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	fields := make(map[string]string)
+	fileName := ""
+	var size int64
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("corps multipart invalide: %w", err)
+		}
+
+		if part.FormName() == fileField {
+			fileName = part.FileName()
+			size, err = io.Copy(io.Discard, part)
+		} else {
+			var value []byte
+			value, err = io.ReadAll(part)
+			fields[part.FormName()] = string(value)
+		}
+		part.Close()
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("erreur lors de la lecture du formulaire: %w", err)
+		}
+	}
+
+	if fileName == "" {
+		return nil, "", 0, fmt.Errorf("champ %s manquant", fileField)
+	}
+
+	return fields, fileName, size, nil
+}
+
+// File représente un fichier stocké par un faux hébergeur
+type File struct {
+	Code      string
+	EmbedCode string
+	Title     string
+	Name      string
+	Size      int64
+	FolderID  string
+	Deleted   bool
+	Subtitles []string // langues des sous-titres attachés
+}
+
+// Folder représente un dossier d'un faux hébergeur
+type Folder struct {
+	ID       string
+	Name     string
+	ParentID string
+}
@@ -0,0 +1,54 @@
+package upload
+
+import "sync"
+
+// HosterLimiter limite le nombre d'uploads simultanés vers chaque hébergeur
+type HosterLimiter struct {
+	mutex  sync.Mutex
+	limits map[string]int
+	slots  map[string]chan struct{}
+}
+
+// NewHosterLimiter crée un limiteur avec une limite par hébergeur (1 par défaut)
+func NewHosterLimiter(limits map[string]int) *HosterLimiter {
+	return &HosterLimiter{
+		limits: limits,
+		slots:  make(map[string]chan struct{}),
+	}
+}
+
+// Acquire attend qu'un emplacement soit libre pour l'hébergeur et retourne la fonction de libération
+func (l *HosterLimiter) Acquire(hoster string) func() {
+	slot := l.slot(hoster)
+	slot <- struct{}{}
+	return func() { <-slot }
+}
+
+// TryAcquire réserve un emplacement uniquement s'il est libre immédiatement
+func (l *HosterLimiter) TryAcquire(hoster string) (func(), bool) {
+	slot := l.slot(hoster)
+	select {
+	case slot <- struct{}{}:
+		return func() { <-slot }, true
+	default:
+		return nil, false
+	}
+}
+
+// slot retourne le sémaphore associé à un hébergeur
+func (l *HosterLimiter) slot(hoster string) chan struct{} {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	slot, exists := l.slots[hoster]
+	if !exists {
+		limit := l.limits[hoster]
+		if limit <= 0 {
+			limit = 1
+		}
+		slot = make(chan struct{}, limit)
+		l.slots[hoster] = slot
+	}
+
+	return slot
+}
@@ -0,0 +1,49 @@
+package upload
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrFolderNotFound est retournée quand l'hébergeur ne connaît pas le dossier demandé
+var ErrFolderNotFound = errors.New("dossier introuvable chez l'hébergeur")
+
+// Deleter est implémenté par les uploaders qui peuvent supprimer un fichier hébergé
+type Deleter interface {
+	Uploader
+	DeleteFile(fileCode string) error
+}
+
+// Renamer est implémenté par les uploaders qui peuvent renommer un fichier hébergé
+type Renamer interface {
+	Uploader
+	RenameFile(fileCode, title string) error
+}
+
+// Mover est implémenté par les uploaders qui peuvent ranger un fichier dans un dossier
+type Mover interface {
+	Uploader
+	MoveFile(fileCode, folderID string) error
+}
+
+// FolderManager est implémenté par les uploaders qui peuvent créer des dossiers
+type FolderManager interface {
+	Uploader
+	// EnsureFolder retourne l'ID du dossier name dans parentID (vide pour la racine),
+	// en le créant s'il n'existe pas
+	EnsureFolder(name, parentID string) (string, error)
+}
+
+// FolderUploader est implémenté par les uploaders qui peuvent envoyer un fichier directement
+// dans un dossier, sans le déplacer après l'upload
+type FolderUploader interface {
+	FolderManager
+	// SetUploadFolder range les prochains uploads dans folderID (vide pour la racine)
+	SetUploadFolder(folderID string)
+}
+
+// folderNotFound indique si le message d'erreur d'un hébergeur signale un dossier inexistant
+func folderNotFound(message string) bool {
+	message = strings.ToLower(message)
+	return strings.Contains(message, "folder") && strings.Contains(message, "not found")
+}
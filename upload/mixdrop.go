@@ -1,15 +1,15 @@
 package upload
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
-	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -18,8 +18,21 @@ type MixDropUploader struct {
 	Email   string
 	ApiKey  string
 	Enabled bool
+	// URLs de base de MixDrop, modifiables pour utiliser un serveur de test
+	UploadURL string // endpoint d'upload des fichiers
+	APIURL    string // API de gestion du compte et des fichiers
+	PublicURL string // domaine des liens de téléchargement et d'embed
+	// FolderID est le dossier dans lequel les fichiers sont uploadés (vide pour la racine)
+	FolderID string
 }
 
+// URLs de base par défaut de MixDrop
+const (
+	MixDropUploadURL = "https://ul.mixdrop.ag/api"
+	MixDropAPIURL    = "https://api.mixdrop.ag"
+	MixDropPublicURL = "https://mixdrop.ag"
+)
+
 // MixDropResponse représente la réponse de l'API MixDrop
 type MixDropResponse struct {
 	Success bool `json:"success"`
@@ -30,13 +43,40 @@ type MixDropResponse struct {
 	} `json:"result"`
 }
 
+// MixDropRemoteResponse représente la réponse de l'API MixDrop pour un remote upload
+type MixDropRemoteResponse struct {
+	Success bool `json:"success"`
+	Result  struct {
+		ID      json.Number `json:"id"`
+		FileRef string      `json:"fileref"`
+		Status  string      `json:"status"`
+	} `json:"result"`
+}
+
 // NewMixDropUploader crée un nouvel uploader MixDrop
 func NewMixDropUploader(email, apiKey string, enabled bool) *MixDropUploader {
 	return &MixDropUploader{
-		Email:   email,
-		ApiKey:  apiKey,
-		Enabled: enabled,
+		Email:     email,
+		ApiKey:    apiKey,
+		Enabled:   enabled,
+		UploadURL: MixDropUploadURL,
+		APIURL:    MixDropAPIURL,
+		PublicURL: MixDropPublicURL,
+	}
+}
+
+// SetBaseURLs remplace les URLs de base non vides, par exemple par celles d'un serveur de test
+func (m *MixDropUploader) SetBaseURLs(uploadURL, apiURL, publicURL string) *MixDropUploader {
+	if uploadURL != "" {
+		m.UploadURL = uploadURL
+	}
+	if apiURL != "" {
+		m.APIURL = strings.TrimSuffix(apiURL, "/")
 	}
+	if publicURL != "" {
+		m.PublicURL = strings.TrimSuffix(publicURL, "/")
+	}
+	return m
 }
 
 // Name retourne le nom de l'uploader
@@ -54,9 +94,13 @@ func (m *MixDropUploader) UploadFile(filePath, title string) (*UploadResult, err
 	log.Printf("Upload du fichier %s vers MixDrop...", filePath)
 
 	// Vérifier si le fichier existe
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
 		return nil, fmt.Errorf("le fichier n'existe pas: %s", filePath)
 	}
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la lecture des informations du fichier: %w", err)
+	}
 
 	// Ouvrir le fichier
 	file, err := os.Open(filePath)
@@ -65,46 +109,35 @@ func (m *MixDropUploader) UploadFile(filePath, title string) (*UploadResult, err
 	}
 	defer file.Close()
 
-	// Créer un buffer pour le corps de la requête
-	var requestBody bytes.Buffer
-	writer := multipart.NewWriter(&requestBody)
+	return m.UploadStream(file, filepath.Base(filePath), info.Size(), title)
+}
 
-	// Ajouter les champs du formulaire
-	if err := writer.WriteField("email", m.Email); err != nil {
-		return nil, fmt.Errorf("erreur lors de l'ajout du champ email: %w", err)
+// UploadStream upload un flux vers MixDrop sans charger le fichier en mémoire
+func (m *MixDropUploader) UploadStream(content io.Reader, fileName string, size int64, title string) (*UploadResult, error) {
+	// Construire le corps de la requête
+	fields := []formField{
+		{name: "email", value: m.Email},
+		{name: "key", value: m.ApiKey},
+		{name: "title", value: title},
 	}
-
-	if err := writer.WriteField("key", m.ApiKey); err != nil {
-		return nil, fmt.Errorf("erreur lors de l'ajout du champ key: %w", err)
+	if m.FolderID != "" {
+		fields = append(fields, formField{name: "folder", value: m.FolderID})
 	}
 
-	if err := writer.WriteField("title", title); err != nil {
-		return nil, fmt.Errorf("erreur lors de l'ajout du champ title: %w", err)
-	}
-
-	// Ajouter le fichier
-	part, err := writer.CreateFormFile("file", filepath.Base(filePath))
+	requestBody, contentType, contentLength, err := multipartBody("mixdrop", fields, "file", fileName, content, size)
 	if err != nil {
-		return nil, fmt.Errorf("erreur lors de la création du champ file: %w", err)
-	}
-
-	if _, err := io.Copy(part, file); err != nil {
-		return nil, fmt.Errorf("erreur lors de la copie du fichier: %w", err)
-	}
-
-	// Fermer le writer
-	if err := writer.Close(); err != nil {
-		return nil, fmt.Errorf("erreur lors de la fermeture du writer: %w", err)
+		return nil, err
 	}
 
 	// Créer la requête
 	log.Printf("Envoi de la requête à MixDrop...")
-	req, err := http.NewRequest("POST", "https://ul.mixdrop.ag/api", &requestBody)
+	req, err := http.NewRequest("POST", m.UploadURL, requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("erreur lors de la création de la requête: %w", err)
 	}
 
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Content-Type", contentType)
+	req.ContentLength = contentLength
 
 	// Envoyer la requête
 	client := &http.Client{
@@ -123,6 +156,10 @@ func (m *MixDropUploader) UploadFile(filePath, title string) (*UploadResult, err
 		return nil, fmt.Errorf("erreur lors de la lecture de la réponse: %w", err)
 	}
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
 	// Décoder la réponse JSON
 	var response MixDropResponse
 	if err := json.Unmarshal(body, &response); err != nil {
@@ -131,14 +168,17 @@ func (m *MixDropUploader) UploadFile(filePath, title string) (*UploadResult, err
 
 	// Vérifier si l'upload a réussi
 	if !response.Success {
+		if folderNotFound(string(body)) {
+			return nil, fmt.Errorf("l'upload a échoué: %w: %s", ErrFolderNotFound, string(body))
+		}
 		return nil, fmt.Errorf("l'upload a échoué: %s", string(body))
 	}
 
 	log.Printf("Fichier uploadé avec succès sur MixDrop, fileref: %s", response.Result.FileRef)
 
 	// Construire les URLs avec le nouveau domaine mixdrop.ag
-	directURL := fmt.Sprintf("https://mixdrop.ag/f/%s", response.Result.FileRef)
-	embedURL := fmt.Sprintf("https://mixdrop.ag/e/%s", response.Result.FileRef)
+	directURL := fmt.Sprintf("%s/f/%s", m.PublicURL, response.Result.FileRef)
+	embedURL := fmt.Sprintf("%s/e/%s", m.PublicURL, response.Result.FileRef)
 
 	// Créer le résultat
 	result := &UploadResult{
@@ -151,3 +191,333 @@ func (m *MixDropUploader) UploadFile(filePath, title string) (*UploadResult, err
 
 	return result, nil
 }
+
+// StartRemoteUpload demande à MixDrop d'importer un fichier depuis une URL
+func (m *MixDropUploader) StartRemoteUpload(sourceURL, title string) (string, error) {
+	params := url.Values{}
+	params.Add("email", m.Email)
+	params.Add("key", m.ApiKey)
+	params.Add("url", sourceURL)
+	params.Add("name", title)
+	if m.FolderID != "" {
+		params.Add("folder", m.FolderID)
+	}
+
+	response, err := m.callRemoteAPI(m.APIURL + "/remoteupload?" + params.Encode())
+	if err != nil {
+		return "", err
+	}
+
+	if response.Result.ID == "" {
+		return "", fmt.Errorf("l'API a retourné un identifiant de remote upload vide")
+	}
+
+	return response.Result.ID.String(), nil
+}
+
+// CheckRemoteUpload interroge MixDrop sur l'état d'un remote upload
+func (m *MixDropUploader) CheckRemoteUpload(jobID string) (*RemoteUploadStatus, error) {
+	params := url.Values{}
+	params.Add("email", m.Email)
+	params.Add("key", m.ApiKey)
+	params.Add("id", jobID)
+
+	response, err := m.callRemoteAPI(m.APIURL + "/remotestatus?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	status := &RemoteUploadStatus{Message: response.Result.Status}
+
+	switch strings.ToLower(response.Result.Status) {
+	case "completed", "finished":
+		if response.Result.FileRef == "" {
+			return nil, fmt.Errorf("remote upload terminé sans fileref")
+		}
+		status.Done = true
+		status.Result = &UploadResult{
+			Success:  true,
+			Hoster:   "mixdrop",
+			FileCode: response.Result.FileRef,
+			URL:      fmt.Sprintf("%s/f/%s", m.PublicURL, response.Result.FileRef),
+			Embed:    fmt.Sprintf("%s/e/%s", m.PublicURL, response.Result.FileRef),
+		}
+	case "failed", "error":
+		status.Failed = true
+	}
+
+	return status, nil
+}
+
+// callRemoteAPI appelle un endpoint de remote upload MixDrop et décode la réponse
+func (m *MixDropUploader) callRemoteAPI(apiURL string) (*MixDropRemoteResponse, error) {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	resp, err := client.Get(apiURL)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la requête HTTP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la lecture de la réponse: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("le serveur a retourné un code non-200: %d, réponse: %s", resp.StatusCode, string(body))
+	}
+
+	var response MixDropRemoteResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("erreur lors du décodage de la réponse JSON: %w, réponse: %s", err, string(body))
+	}
+
+	if !response.Success {
+		return nil, fmt.Errorf("l'API a refusé la requête: %s", string(body))
+	}
+
+	return &response, nil
+}
+
+// MixDropFileInfoResponse représente la réponse de l'API MixDrop pour les informations d'un fichier.
+// Result est un objet indexé par fileref en cas de succès, et un message d'erreur sinon.
+type MixDropFileInfoResponse struct {
+	Success bool            `json:"success"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// MixDropFileInfo représente les informations d'un fichier retournées par fileinfo2
+type MixDropFileInfo struct {
+	FileRef string `json:"fileref"`
+	Status  string `json:"status"`
+	Deleted bool   `json:"deleted"`
+}
+
+// CheckFile vérifie via l'API fileinfo que le fichier est toujours en ligne sur MixDrop
+func (m *MixDropUploader) CheckFile(fileCode string) (bool, error) {
+	params := url.Values{}
+	params.Add("email", m.Email)
+	params.Add("key", m.ApiKey)
+	params.Add("ref[]", fileCode)
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	resp, err := client.Get(m.APIURL + "/fileinfo2?" + params.Encode())
+	if err != nil {
+		return false, fmt.Errorf("erreur lors de la requête HTTP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("erreur lors de la lecture de la réponse: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("le serveur a retourné un code non-200: %d, réponse: %s", resp.StatusCode, string(body))
+	}
+
+	var response MixDropFileInfoResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return false, fmt.Errorf("erreur lors du décodage de la réponse JSON: %w, réponse: %s", err, string(body))
+	}
+
+	// MixDrop répond aussi success=false pour des identifiants invalides ou une limite de
+	// requêtes atteinte: seul un fichier introuvable est considéré comme supprimé
+	if !response.Success {
+		var failure struct {
+			Msg string `json:"msg"`
+		}
+		json.Unmarshal(response.Result, &failure)
+		if strings.Contains(strings.ToLower(failure.Msg), "not found") {
+			return false, nil
+		}
+		return false, fmt.Errorf("l'API a refusé la requête: %s", string(body))
+	}
+
+	var files map[string]MixDropFileInfo
+	if err := json.Unmarshal(response.Result, &files); err != nil {
+		return false, fmt.Errorf("erreur lors du décodage du résultat: %w, réponse: %s", err, string(body))
+	}
+
+	info, exists := files[fileCode]
+	if !exists || info.Deleted {
+		return false, nil
+	}
+
+	return strings.EqualFold(info.Status, "OK"), nil
+}
+
+// MixDropAccountResponse représente la réponse de l'API MixDrop pour les informations du compte
+type MixDropAccountResponse struct {
+	Success bool `json:"success"`
+	Result  struct {
+		StorageUsed  json.Number `json:"storage_used"`
+		StorageLimit json.Number `json:"storage_limit"`
+	} `json:"result"`
+}
+
+// AccountUsage récupère l'espace de stockage utilisé par le compte MixDrop
+func (m *MixDropUploader) AccountUsage() (*AccountUsage, error) {
+	params := url.Values{}
+	params.Add("email", m.Email)
+	params.Add("key", m.ApiKey)
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	resp, err := client.Get(m.APIURL + "/accountinfo?" + params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la requête HTTP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la lecture de la réponse: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var response MixDropAccountResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("erreur lors du décodage de la réponse JSON: %w, réponse: %s", err, string(body))
+	}
+
+	if !response.Success {
+		return nil, fmt.Errorf("l'API a retourné une erreur: %s", string(body))
+	}
+
+	used, _ := response.Result.StorageUsed.Int64()
+	limit, _ := response.Result.StorageLimit.Int64()
+
+	return &AccountUsage{StorageUsed: used, StorageLimit: limit}, nil
+}
+
+// DeleteFile supprime un fichier du compte MixDrop
+func (m *MixDropUploader) DeleteFile(fileCode string) error {
+	params := url.Values{}
+	params.Add("ref", fileCode)
+	return m.callFileAPI("removefile", params, nil)
+}
+
+// RenameFile modifie le titre d'un fichier sur MixDrop
+func (m *MixDropUploader) RenameFile(fileCode, title string) error {
+	params := url.Values{}
+	params.Add("ref", fileCode)
+	params.Add("title", title)
+	return m.callFileAPI("filerename", params, nil)
+}
+
+// MoveFile déplace un fichier dans un dossier MixDrop
+func (m *MixDropUploader) MoveFile(fileCode, folderID string) error {
+	params := url.Values{}
+	params.Add("ref", fileCode)
+	params.Add("folder", folderID)
+	return m.callFileAPI("filemove", params, nil)
+}
+
+// SetUploadFolder range les prochains uploads, directs ou distants, dans folderID
+func (m *MixDropUploader) SetUploadFolder(folderID string) {
+	m.FolderID = folderID
+}
+
+// EnsureFolder retourne l'ID du dossier MixDrop nommé name dans parentID, en le créant s'il n'existe pas
+func (m *MixDropUploader) EnsureFolder(name, parentID string) (string, error) {
+	params := url.Values{}
+	if parentID != "" {
+		params.Add("id", parentID)
+	}
+
+	var list struct {
+		Folders []struct {
+			ID    json.Number `json:"id"`
+			Title string      `json:"title"`
+		} `json:"folders"`
+	}
+	if err := m.callFileAPI("folderlist", params, &list); err != nil {
+		return "", fmt.Errorf("erreur lors de la liste des dossiers: %w", err)
+	}
+
+	for _, folder := range list.Folders {
+		if folder.Title == name {
+			return folder.ID.String(), nil
+		}
+	}
+
+	params = url.Values{}
+	params.Add("title", name)
+	if parentID != "" {
+		params.Add("parent", parentID)
+	}
+
+	var created struct {
+		ID json.Number `json:"id"`
+	}
+	if err := m.callFileAPI("foldercreate", params, &created); err != nil {
+		return "", fmt.Errorf("erreur lors de la création du dossier: %w", err)
+	}
+
+	if created.ID == "" {
+		return "", fmt.Errorf("l'API a retourné un ID de dossier vide")
+	}
+
+	return created.ID.String(), nil
+}
+
+// callFileAPI appelle un endpoint de gestion des fichiers MixDrop et décode le champ
+// result de la réponse dans result, s'il n'est pas nil
+func (m *MixDropUploader) callFileAPI(endpoint string, params url.Values, result interface{}) error {
+	params.Set("email", m.Email)
+	params.Set("key", m.ApiKey)
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	resp, err := client.Get(m.APIURL + "/" + endpoint + "?" + params.Encode())
+	if err != nil {
+		return fmt.Errorf("erreur lors de la requête HTTP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la lecture de la réponse: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var response struct {
+		Success bool            `json:"success"`
+		Result  json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("erreur lors du décodage de la réponse JSON: %w, réponse: %s", err, string(body))
+	}
+
+	if !response.Success {
+		if folderNotFound(string(body)) {
+			return fmt.Errorf("l'API a refusé la requête: %w: %s", ErrFolderNotFound, string(body))
+		}
+		return fmt.Errorf("l'API a refusé la requête: %s", string(body))
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return fmt.Errorf("erreur lors du décodage du résultat: %w, réponse: %s", err, string(body))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package upload_test
+
+import (
+	"strings"
+	"testing"
+
+	"media-upload-system/upload"
+	"media-upload-system/upload/hostertest"
+)
+
+func TestMixDropUpload(t *testing.T) {
+	server := hostertest.NewMixDrop()
+	defer server.Close()
+
+	result, err := server.Uploader().UploadFile(writeTestFile(t, 4096), "Film (2020)")
+	if err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+
+	files := server.Files()
+	if len(files) != 1 || files[0].Code != result.FileCode || files[0].Title != "Film (2020)" || files[0].Size != 4096 {
+		t.Fatalf("fichier reçu inattendu: %+v, résultat %+v", files, result)
+	}
+	if result.Hoster != "mixdrop" || result.Embed != server.URL+"/e/"+result.FileCode {
+		t.Fatalf("résultat inattendu: %+v", result)
+	}
+}
+
+func TestMixDropFailureModes(t *testing.T) {
+	server := hostertest.NewMixDrop()
+	defer server.Close()
+
+	testFailureModes(t, server.Server, server.Uploader(), []string{"/upload"})
+}
+
+func TestMixDropTimeout(t *testing.T) {
+	server := hostertest.NewMixDrop()
+	defer server.Close()
+
+	testTimeout(t, server.Server, server.Uploader(), "/upload")
+}
+
+func TestMixDropCheckFile(t *testing.T) {
+	server := hostertest.NewMixDrop()
+	defer server.Close()
+	uploader := server.Uploader()
+
+	online := server.AddFile("En ligne", 10)
+	deleted := server.AddFile("Supprimé", 10)
+	server.DeleteFile(deleted.Code)
+
+	tests := []struct {
+		name     string
+		fileCode string
+		alive    bool
+	}{
+		{"en ligne", online.Code, true},
+		{"supprimé", deleted.Code, false},
+		// MixDrop répond {"success":false,"result":{"msg":"..."}} pour un fichier inconnu
+		{"inconnu", "inconnu", false},
+	}
+
+	for _, test := range tests {
+		alive, err := uploader.CheckFile(test.fileCode)
+		if err != nil {
+			t.Fatalf("%s: erreur inattendue: %v", test.name, err)
+		}
+		if alive != test.alive {
+			t.Fatalf("%s: en ligne = %v, attendu %v", test.name, alive, test.alive)
+		}
+	}
+
+	// Les pannes et les refus autres qu'un fichier introuvable restent indéterminés
+	for _, mode := range failureModes {
+		server.Fail("/fileinfo2", mode, 1)
+		if _, err := uploader.CheckFile(online.Code); err == nil {
+			t.Fatalf("%s: erreur attendue", mode)
+		}
+	}
+}
+
+func TestMixDropCheckFileInvalidCredentials(t *testing.T) {
+	server := hostertest.NewMixDrop()
+	defer server.Close()
+
+	online := server.AddFile("En ligne", 10)
+	uploader := upload.NewMixDropUploader(hostertest.MixDropEmail, "mauvaise-cle", true).SetBaseURLs(server.URL+"/upload", server.URL, server.URL)
+
+	// Des identifiants invalides ne doivent pas faire passer le lien pour mort
+	if alive, err := uploader.CheckFile(online.Code); err == nil {
+		t.Fatalf("erreur attendue, obtenu en ligne = %v", alive)
+	}
+}
+
+func TestMixDropFixtures(t *testing.T) {
+	server := hostertest.NewMixDrop()
+	defer server.Close()
+	path := writeTestFile(t, 4096)
+
+	var recorded *upload.UploadResult
+	fixtures := recordFixtures(t, server.URL, func(proxyURL string) {
+		uploader := upload.NewMixDropUploader(hostertest.MixDropEmail, hostertest.MixDropAPIKey, true).
+			SetBaseURLs(proxyURL+"/upload", proxyURL, proxyURL)
+
+		var err error
+		if recorded, err = uploader.UploadFile(path, "Film"); err != nil {
+			t.Fatalf("UploadFile: %v", err)
+		}
+		if alive, err := uploader.CheckFile(recorded.FileCode); err != nil || !alive {
+			t.Fatalf("CheckFile: %v, %v", alive, err)
+		}
+	})
+
+	for _, fixture := range fixtures {
+		if fixture.Query != "" && (strings.Contains(fixture.Query, hostertest.MixDropAPIKey) || strings.Contains(fixture.Query, "email=")) {
+			t.Fatalf("identifiants enregistrés dans la fixture %s: %s", fixture.Path, fixture.Query)
+		}
+	}
+
+	replay := hostertest.NewReplay(fixtures)
+	defer replay.Close()
+	uploader := upload.NewMixDropUploader("", "", true).SetBaseURLs(replay.URL+"/upload", replay.URL, replay.URL)
+
+	result, err := uploader.UploadFile(path, "Film")
+	if err != nil || result.FileCode != recorded.FileCode || result.Embed != replay.URL+"/e/"+recorded.FileCode {
+		t.Fatalf("UploadFile rejoué: %+v, %v, attendu le fileref %s", result, err, recorded.FileCode)
+	}
+	if alive, err := uploader.CheckFile(recorded.FileCode); err != nil || !alive {
+		t.Fatalf("CheckFile rejoué: %v, %v", alive, err)
+	}
+
+	replay.Fail("/upload", hostertest.FailMalformedJSON, 1)
+	if _, err := uploader.UploadFile(path, "Film"); err == nil {
+		t.Fatalf("erreur attendue sur une fixture corrompue")
+	}
+}
@@ -1,17 +1,16 @@
 package upload
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
-	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -19,8 +18,19 @@ import (
 type NetuUploader struct {
 	ApiKey  string
 	Enabled bool
+	// URLs de base de Netu, modifiables pour utiliser un serveur de test
+	APIURL    string // API de Netu
+	PlayerURL string // domaine des liens de lecture et d'embed
+	// FolderID est le dossier dans lequel les fichiers sont ajoutés (vide pour la racine)
+	FolderID string
 }
 
+// URLs de base par défaut de Netu
+const (
+	NetuAPIURL    = "https://netu.tv/api"
+	NetuPlayerURL = "https://player1.streameo.me"
+)
+
 // NetuUploadServerResponse représente la réponse de l'API pour obtenir le serveur d'upload
 type NetuUploadServerResponse struct {
 	Status int    `json:"status"`
@@ -55,9 +65,22 @@ type NetuUploadFileResponse struct {
 // NewNetuUploader crée un nouvel uploader Netu.tv
 func NewNetuUploader(apiKey string, enabled bool) *NetuUploader {
 	return &NetuUploader{
-		ApiKey:  apiKey,
-		Enabled: enabled,
+		ApiKey:    apiKey,
+		Enabled:   enabled,
+		APIURL:    NetuAPIURL,
+		PlayerURL: NetuPlayerURL,
+	}
+}
+
+// SetBaseURLs remplace les URLs de base non vides, par exemple par celles d'un serveur de test
+func (n *NetuUploader) SetBaseURLs(apiURL, playerURL string) *NetuUploader {
+	if apiURL != "" {
+		n.APIURL = strings.TrimSuffix(apiURL, "/")
+	}
+	if playerURL != "" {
+		n.PlayerURL = strings.TrimSuffix(playerURL, "/")
 	}
+	return n
 }
 
 // Name retourne le nom de l'uploader
@@ -72,6 +95,27 @@ func (n *NetuUploader) IsEnabled() bool {
 
 // UploadFile upload un fichier vers Netu.tv
 func (n *NetuUploader) UploadFile(filePath, title string) (*UploadResult, error) {
+	// Vérifier si le fichier existe
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("le fichier n'existe pas: %s", filePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la lecture des informations du fichier: %w", err)
+	}
+
+	// Ouvrir le fichier
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de l'ouverture du fichier: %w", err)
+	}
+	defer file.Close()
+
+	return n.UploadStream(file, filepath.Base(filePath), info.Size(), title)
+}
+
+// UploadStream upload un flux vers Netu.tv sans charger le fichier en mémoire
+func (n *NetuUploader) UploadStream(content io.Reader, fileName string, size int64, title string) (*UploadResult, error) {
 	// Étape 1: Obtenir le serveur d'upload
 	log.Printf("Étape 1: Obtention du serveur d'upload...")
 	serverInfo, err := n.getUploadServer()
@@ -81,14 +125,14 @@ func (n *NetuUploader) UploadFile(filePath, title string) (*UploadResult, error)
 
 	// Étape 2: Uploader le fichier sur le serveur
 	log.Printf("Étape 2: Upload du fichier sur le serveur %s...", serverInfo.UploadServer)
-	fileName, err := n.uploadToServer(filePath, serverInfo)
+	uploadedName, err := n.uploadToServer(content, fileName, size, serverInfo)
 	if err != nil {
 		return nil, fmt.Errorf("échec de l'upload du fichier: %w", err)
 	}
 
 	// Étape 3: Finaliser l'upload
 	log.Printf("Étape 3: Finalisation de l'upload...")
-	fileCode, fileCodeEmbed, err := n.finalizeUpload(fileName, title, serverInfo)
+	fileCode, fileCodeEmbed, err := n.finalizeUpload(uploadedName, title, serverInfo)
 	if err != nil {
 		return nil, fmt.Errorf("échec de la finalisation de l'upload: %w", err)
 	}
@@ -96,8 +140,8 @@ func (n *NetuUploader) UploadFile(filePath, title string) (*UploadResult, error)
 	log.Printf("Upload terminé avec succès, code du fichier: %s, code embed: %s", fileCode, fileCodeEmbed)
 
 	// Construire les URLs avec le nouveau format pour l'embed
-	directURL := fmt.Sprintf("https://player1.streameo.me/watch/%s", fileCode)
-	embedURL := fmt.Sprintf("https://player1.streameo.me/e/%s", fileCodeEmbed)
+	directURL := fmt.Sprintf("%s/watch/%s", n.PlayerURL, fileCode)
+	embedURL := fmt.Sprintf("%s/e/%s", n.PlayerURL, fileCodeEmbed)
 
 	// Créer le résultat
 	result := &UploadResult{
@@ -124,7 +168,7 @@ type ServerInfo struct {
 // getUploadServer obtient l'URL du serveur d'upload
 func (n *NetuUploader) getUploadServer() (*ServerInfo, error) {
 	// Utiliser netu.tv pour les appels API
-	apiURL := fmt.Sprintf("https://netu.tv/api/file/upload_server?key=%s", n.ApiKey)
+	apiURL := fmt.Sprintf("%s/file/upload_server?key=%s", n.APIURL, n.ApiKey)
 
 	resp, err := http.Get(apiURL)
 	if err != nil {
@@ -133,7 +177,7 @@ func (n *NetuUploader) getUploadServer() (*ServerInfo, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("le serveur a retourné un code non-200: %d", resp.StatusCode)
+		return nil, &HTTPError{StatusCode: resp.StatusCode}
 	}
 
 	// Lire le corps de la réponse
@@ -168,63 +212,30 @@ func (n *NetuUploader) getUploadServer() (*ServerInfo, error) {
 	return serverInfo, nil
 }
 
-// uploadToServer upload le fichier sur le serveur
-func (n *NetuUploader) uploadToServer(filePath string, serverInfo *ServerInfo) (string, error) {
-	// Vérifier si le fichier existe
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return "", fmt.Errorf("le fichier n'existe pas: %s", filePath)
+// uploadToServer upload le flux sur le serveur
+func (n *NetuUploader) uploadToServer(content io.Reader, fileName string, size int64, serverInfo *ServerInfo) (string, error) {
+	// Construire le corps de la requête
+	fields := []formField{
+		{name: "hash", value: serverInfo.Hash},
+		{name: "time_hash", value: strconv.FormatInt(serverInfo.TimeHash, 10)},
+		{name: "userid", value: serverInfo.UserID},
+		{name: "key_hash", value: serverInfo.KeyHash},
+		{name: "upload", value: "1"},
 	}
 
-	// Ouvrir le fichier
-	file, err := os.Open(filePath)
-	if err != nil {
-		return "", fmt.Errorf("erreur lors de l'ouverture du fichier: %w", err)
-	}
-	defer file.Close()
-
-	// Créer un buffer pour le corps de la requête
-	var requestBody bytes.Buffer
-	writer := multipart.NewWriter(&requestBody)
-
-	// Ajouter les champs du formulaire
-	if err := writer.WriteField("hash", serverInfo.Hash); err != nil {
-		return "", fmt.Errorf("erreur lors de l'ajout du champ hash: %w", err)
-	}
-	if err := writer.WriteField("time_hash", strconv.FormatInt(serverInfo.TimeHash, 10)); err != nil {
-		return "", fmt.Errorf("erreur lors de l'ajout du champ time_hash: %w", err)
-	}
-	if err := writer.WriteField("userid", serverInfo.UserID); err != nil {
-		return "", fmt.Errorf("erreur lors de l'ajout du champ userid: %w", err)
-	}
-	if err := writer.WriteField("key_hash", serverInfo.KeyHash); err != nil {
-		return "", fmt.Errorf("erreur lors de l'ajout du champ key_hash: %w", err)
-	}
-	if err := writer.WriteField("upload", "1"); err != nil {
-		return "", fmt.Errorf("erreur lors de l'ajout du champ upload: %w", err)
-	}
-
-	// Ajouter le fichier
-	part, err := writer.CreateFormFile("Filedata", filepath.Base(filePath))
+	requestBody, contentType, contentLength, err := multipartBody("netu", fields, "Filedata", fileName, content, size)
 	if err != nil {
-		return "", fmt.Errorf("erreur lors de la création du champ Filedata: %w", err)
-	}
-
-	if _, err := io.Copy(part, file); err != nil {
-		return "", fmt.Errorf("erreur lors de la copie du fichier: %w", err)
-	}
-
-	// Fermer le writer
-	if err := writer.Close(); err != nil {
-		return "", fmt.Errorf("erreur lors de la fermeture du writer: %w", err)
+		return "", err
 	}
 
 	// Créer la requête
-	req, err := http.NewRequest("POST", serverInfo.UploadServer, &requestBody)
+	req, err := http.NewRequest("POST", serverInfo.UploadServer, requestBody)
 	if err != nil {
 		return "", fmt.Errorf("erreur lors de la création de la requête: %w", err)
 	}
 
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Content-Type", contentType)
+	req.ContentLength = contentLength
 
 	// Envoyer la requête
 	client := &http.Client{
@@ -245,6 +256,10 @@ func (n *NetuUploader) uploadToServer(filePath string, serverInfo *ServerInfo) (
 
 	log.Printf("Réponse du serveur pour l'upload: %s", string(body))
 
+	if resp.StatusCode != http.StatusOK {
+		return "", &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
 	// Décoder la réponse JSON
 	var response NetuUploadFileResponse
 	if err := json.Unmarshal(body, &response); err != nil {
@@ -272,9 +287,12 @@ func (n *NetuUploader) finalizeUpload(fileName, title string, serverInfo *Server
 	params.Add("name", title)
 	params.Add("server", serverInfo.ServerID) // Utiliser server_id au lieu de l'URL complète
 	params.Add("file_name", fileName)
+	if n.FolderID != "" {
+		params.Add("fld_id", n.FolderID)
+	}
 
 	// Utiliser netu.tv pour les appels API
-	apiURL := "https://netu.tv/api/file/add?" + params.Encode()
+	apiURL := n.APIURL + "/file/add?" + params.Encode()
 
 	log.Printf("URL de finalisation: %s", apiURL)
 
@@ -293,7 +311,7 @@ func (n *NetuUploader) finalizeUpload(fileName, title string, serverInfo *Server
 	log.Printf("Réponse du serveur pour finaliser l'upload: %s", string(body))
 
 	if resp.StatusCode != http.StatusOK {
-		return "", "", fmt.Errorf("le serveur a retourné un code non-200: %d, réponse: %s", resp.StatusCode, string(body))
+		return "", "", &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// Décoder la réponse JSON
@@ -304,6 +322,9 @@ func (n *NetuUploader) finalizeUpload(fileName, title string, serverInfo *Server
 
 	// Vérifier si le statut est 200 (OK)
 	if response.Status != 200 {
+		if folderNotFound(response.Msg) {
+			return "", "", fmt.Errorf("l'API a retourné un statut non-200: %d: %w", response.Status, ErrFolderNotFound)
+		}
 		return "", "", fmt.Errorf("l'API a retourné un statut non-200: %d", response.Status)
 	}
 
@@ -319,3 +340,230 @@ func (n *NetuUploader) finalizeUpload(fileName, title string, serverInfo *Server
 
 	return response.Result.FileCode, response.Result.FileCodeEmbed, nil
 }
+
+// NetuFileInfoResponse représente la réponse de l'API Netu pour les informations d'un fichier
+type NetuFileInfoResponse struct {
+	Status int    `json:"status"`
+	Msg    string `json:"msg"`
+	Result []struct {
+		FileCode string `json:"file_code"`
+		Status   int    `json:"status"`
+	} `json:"result"`
+}
+
+// CheckFile vérifie via l'API file/info que le fichier est toujours en ligne sur Netu
+func (n *NetuUploader) CheckFile(fileCode string) (bool, error) {
+	params := url.Values{}
+	params.Add("key", n.ApiKey)
+	params.Add("file_code", fileCode)
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	resp, err := client.Get(n.APIURL + "/file/info?" + params.Encode())
+	if err != nil {
+		return false, fmt.Errorf("erreur lors de la requête HTTP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("erreur lors de la lecture de la réponse: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("le serveur a retourné un code non-200: %d, réponse: %s", resp.StatusCode, string(body))
+	}
+
+	var response NetuFileInfoResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return false, fmt.Errorf("erreur lors du décodage de la réponse JSON: %w", err)
+	}
+
+	if response.Status != 200 {
+		return false, fmt.Errorf("l'API a retourné un statut non-200: %d", response.Status)
+	}
+
+	for _, file := range response.Result {
+		if file.FileCode == fileCode {
+			return file.Status == 200, nil
+		}
+	}
+
+	return false, nil
+}
+
+// NetuAccountResponse représente la réponse de l'API Netu pour les informations du compte
+type NetuAccountResponse struct {
+	Status int    `json:"status"`
+	Msg    string `json:"msg"`
+	Result struct {
+		StorageUsed json.Number `json:"storage_used"`
+		StorageLeft json.Number `json:"storage_left"`
+	} `json:"result"`
+}
+
+// AccountUsage récupère l'espace de stockage utilisé par le compte Netu
+func (n *NetuUploader) AccountUsage() (*AccountUsage, error) {
+	params := url.Values{}
+	params.Add("key", n.ApiKey)
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	resp, err := client.Get(n.APIURL + "/account/info?" + params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la requête HTTP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la lecture de la réponse: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var response NetuAccountResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("erreur lors du décodage de la réponse JSON: %w, réponse: %s", err, string(body))
+	}
+
+	if response.Status != 200 {
+		return nil, fmt.Errorf("l'API a retourné un statut non-200: %d, message: %s", response.Status, response.Msg)
+	}
+
+	used, _ := response.Result.StorageUsed.Int64()
+	left, _ := response.Result.StorageLeft.Int64()
+
+	usage := &AccountUsage{StorageUsed: used}
+	if left > 0 {
+		usage.StorageLimit = used + left
+	}
+
+	return usage, nil
+}
+
+// DeleteFile supprime un fichier du compte Netu
+func (n *NetuUploader) DeleteFile(fileCode string) error {
+	params := url.Values{}
+	params.Add("file_code", fileCode)
+	return n.callFileAPI("file/delete", params, nil)
+}
+
+// RenameFile modifie le titre d'un fichier sur Netu
+func (n *NetuUploader) RenameFile(fileCode, title string) error {
+	params := url.Values{}
+	params.Add("file_code", fileCode)
+	params.Add("title", title)
+	return n.callFileAPI("file/rename", params, nil)
+}
+
+// MoveFile déplace un fichier dans un dossier Netu
+func (n *NetuUploader) MoveFile(fileCode, folderID string) error {
+	params := url.Values{}
+	params.Add("file_code", fileCode)
+	params.Add("fld_id", folderID)
+	return n.callFileAPI("file/set_folder", params, nil)
+}
+
+// SetUploadFolder ajoute les prochains uploads dans folderID
+func (n *NetuUploader) SetUploadFolder(folderID string) {
+	n.FolderID = folderID
+}
+
+// EnsureFolder retourne l'ID du dossier Netu nommé name dans parentID, en le créant s'il n'existe pas
+func (n *NetuUploader) EnsureFolder(name, parentID string) (string, error) {
+	if parentID == "" {
+		parentID = "0" // dossier racine du compte
+	}
+
+	params := url.Values{}
+	params.Add("fld_id", parentID)
+
+	var list struct {
+		Folders []struct {
+			ID   json.Number `json:"fld_id"`
+			Name string      `json:"name"`
+		} `json:"folders"`
+	}
+	if err := n.callFileAPI("folder/list", params, &list); err != nil {
+		return "", fmt.Errorf("erreur lors de la liste des dossiers: %w", err)
+	}
+
+	for _, folder := range list.Folders {
+		if folder.Name == name {
+			return folder.ID.String(), nil
+		}
+	}
+
+	params = url.Values{}
+	params.Add("name", name)
+	params.Add("parent_id", parentID)
+
+	var created struct {
+		ID json.Number `json:"fld_id"`
+	}
+	if err := n.callFileAPI("folder/create", params, &created); err != nil {
+		return "", fmt.Errorf("erreur lors de la création du dossier: %w", err)
+	}
+
+	if created.ID == "" {
+		return "", fmt.Errorf("l'API a retourné un ID de dossier vide")
+	}
+
+	return created.ID.String(), nil
+}
+
+// callFileAPI appelle un endpoint de gestion des fichiers Netu et décode le champ
+// result de la réponse dans result, s'il n'est pas nil
+func (n *NetuUploader) callFileAPI(endpoint string, params url.Values, result interface{}) error {
+	params.Set("key", n.ApiKey)
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	resp, err := client.Get(n.APIURL + "/" + endpoint + "?" + params.Encode())
+	if err != nil {
+		return fmt.Errorf("erreur lors de la requête HTTP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la lecture de la réponse: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var response struct {
+		Status int             `json:"status"`
+		Msg    string          `json:"msg"`
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("erreur lors du décodage de la réponse JSON: %w, réponse: %s", err, string(body))
+	}
+
+	if response.Status != 200 {
+		if folderNotFound(response.Msg) {
+			return fmt.Errorf("l'API a retourné un statut non-200: %d: %w", response.Status, ErrFolderNotFound)
+		}
+		return fmt.Errorf("l'API a retourné un statut non-200: %d, message: %s", response.Status, response.Msg)
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return fmt.Errorf("erreur lors du décodage du résultat: %w, réponse: %s", err, string(body))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package upload_test
+
+import (
+	"errors"
+	"path"
+	"testing"
+
+	"media-upload-system/upload"
+	"media-upload-system/upload/hostertest"
+)
+
+// netuUploadPaths sont les trois étapes d'un upload Netu
+var netuUploadPaths = []string{"/api/file/upload_server", "/upload", "/api/file/add"}
+
+func TestNetuUpload(t *testing.T) {
+	server := hostertest.NewNetu()
+	defer server.Close()
+
+	result, err := server.Uploader().UploadFile(writeTestFile(t, 4096), "Film (2020)")
+	if err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+
+	files := server.Files()
+	if len(files) != 1 || files[0].Code != result.FileCode || files[0].Title != "Film (2020)" || files[0].Size != 4096 {
+		t.Fatalf("fichier reçu inattendu: %+v, résultat %+v", files, result)
+	}
+	if result.Hoster != "netu" || result.Embed != server.URL+"/e/"+files[0].EmbedCode {
+		t.Fatalf("résultat inattendu: %+v", result)
+	}
+}
+
+func TestNetuFailureModes(t *testing.T) {
+	server := hostertest.NewNetu()
+	defer server.Close()
+
+	testFailureModes(t, server.Server, server.Uploader(), netuUploadPaths)
+}
+
+func TestNetuTimeout(t *testing.T) {
+	for _, path := range netuUploadPaths {
+		server := hostertest.NewNetu()
+		testTimeout(t, server.Server, server.Uploader(), path)
+	}
+}
+
+func TestNetuCheckFile(t *testing.T) {
+	server := hostertest.NewNetu()
+	defer server.Close()
+	uploader := server.Uploader()
+
+	online := server.AddFile("En ligne", 10)
+	deleted := server.AddFile("Supprimé", 10)
+	server.DeleteFile(deleted.Code)
+
+	for code, want := range map[string]bool{online.Code: true, deleted.Code: false, "inconnu": false} {
+		alive, err := uploader.CheckFile(code)
+		if err != nil || alive != want {
+			t.Fatalf("CheckFile(%s): %v, %v, attendu %v", code, alive, err, want)
+		}
+	}
+
+	// Netu signale ses erreurs par le champ status du corps, qui reste indéterminé
+	for _, mode := range failureModes {
+		server.Fail("/api/file/info", mode, 1)
+		if _, err := uploader.CheckFile(online.Code); err == nil {
+			t.Fatalf("%s: erreur attendue", mode)
+		}
+	}
+}
+
+func TestNetuFixtures(t *testing.T) {
+	server := hostertest.NewNetu()
+	defer server.Close()
+	filePath := writeTestFile(t, 4096)
+
+	var recorded *upload.UploadResult
+	fixtures := recordFixtures(t, server.URL, func(proxyURL string) {
+		uploader := upload.NewNetuUploader(hostertest.NetuAPIKey, true).SetBaseURLs(proxyURL+"/api", proxyURL)
+
+		var err error
+		if recorded, err = uploader.UploadFile(filePath, "Film"); err != nil {
+			t.Fatalf("UploadFile: %v", err)
+		}
+	})
+
+	paths := make(map[string]bool)
+	for _, fixture := range fixtures {
+		paths[fixture.Path] = true
+	}
+	for _, step := range netuUploadPaths {
+		if !paths[step] {
+			t.Fatalf("étape %s non enregistrée: %+v", step, fixtures)
+		}
+	}
+
+	replay := hostertest.NewReplay(fixtures)
+	defer replay.Close()
+	uploader := upload.NewNetuUploader("", true).SetBaseURLs(replay.URL+"/api", replay.URL)
+
+	result, err := uploader.UploadFile(filePath, "Film")
+	if err != nil || result.FileCode != recorded.FileCode || result.Embed != replay.URL+"/e/"+path.Base(recorded.Embed) {
+		t.Fatalf("UploadFile rejoué: %+v, %v, attendu %+v", result, err, recorded)
+	}
+
+	// Le serveur d'upload annoncé par la fixture pointe vers le serveur de relecture
+	if replay.Requests("/upload") != 1 {
+		t.Fatalf("upload rejoué hors du serveur de relecture: %d requêtes", replay.Requests("/upload"))
+	}
+
+	replay.Fail("/api/file/add", hostertest.FailRejected, 1)
+	if _, err := uploader.UploadFile(filePath, "Film"); err == nil {
+		t.Fatalf("erreur attendue sur un refus de l'API")
+	}
+}
+
+// TestNetuUploadIntoFolder vérifie que Netu ajoute le fichier directement dans le dossier
+func TestNetuUploadIntoFolder(t *testing.T) {
+	server := hostertest.NewNetu()
+	defer server.Close()
+
+	uploader := server.Uploader()
+	folderID, err := uploader.EnsureFolder("Film (10)", "")
+	if err != nil {
+		t.Fatalf("EnsureFolder: %v", err)
+	}
+	uploader.SetUploadFolder(folderID)
+
+	filePath := writeTestFile(t, 4096)
+	if _, err := uploader.UploadFile(filePath, "Film"); err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+	if files := server.Files(); len(files) != 1 || files[0].FolderID != folderID {
+		t.Fatalf("fichier hors du dossier %s: %+v", folderID, files)
+	}
+
+	uploader.SetUploadFolder("999")
+	if _, err := uploader.UploadFile(filePath, "Film"); err == nil || !errors.Is(err, upload.ErrFolderNotFound) {
+		t.Fatalf("ErrFolderNotFound attendue, obtenu %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package upload
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// RemoteUploader est implémenté par les uploaders capables d'importer un fichier
+// depuis une URL (remote upload) au lieu de recevoir le fichier directement
+type RemoteUploader interface {
+	Uploader
+	StartRemoteUpload(sourceURL, title string) (string, error)
+	CheckRemoteUpload(jobID string) (*RemoteUploadStatus, error)
+}
+
+// SourceProvider est implémenté par les uploaders dont le résultat peut exposer une URL de
+// téléchargement direct (UploadResult.DownloadURL), utilisable par les remote uploads
+type SourceProvider interface {
+	Uploader
+	ProvidesDownloadURL() bool
+}
+
+// RemoteUploadStatus représente l'état d'un remote upload chez l'hébergeur
+type RemoteUploadStatus struct {
+	Done    bool
+	Failed  bool
+	Message string
+	Result  *UploadResult
+}
+
+// WaitRemoteUpload lance un remote upload puis interroge l'hébergeur jusqu'à ce que le fichier soit disponible
+func WaitRemoteUpload(uploader RemoteUploader, sourceURL, title string, pollInterval, timeout time.Duration) (*UploadResult, error) {
+	log.Printf("Remote upload vers %s depuis %s...", uploader.Name(), sourceURL)
+
+	jobID, err := uploader.StartRemoteUpload(sourceURL, title)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors du lancement du remote upload: %w", err)
+	}
+
+	log.Printf("Remote upload %s lancé sur %s, attente de la fin du transfert", jobID, uploader.Name())
+
+	deadline := time.Now().Add(timeout)
+	for {
+		status, err := uploader.CheckRemoteUpload(jobID)
+		if err != nil {
+			// Une erreur ponctuelle ne doit pas interrompre le suivi
+			log.Printf("Erreur lors de la vérification du remote upload %s: %v", jobID, err)
+		} else if status.Failed {
+			return nil, fmt.Errorf("le remote upload %s a échoué: %s", jobID, status.Message)
+		} else if status.Done {
+			log.Printf("Remote upload %s terminé sur %s", jobID, uploader.Name())
+			return status.Result, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("délai dépassé pour le remote upload %s", jobID)
+		}
+
+		time.Sleep(pollInterval)
+	}
+}
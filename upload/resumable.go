@@ -0,0 +1,127 @@
+package upload
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// DefaultChunkSize est la taille de bloc utilisée quand l'hébergeur n'en impose pas
+const DefaultChunkSize int64 = 64 * 1024 * 1024
+
+// ResumableUploader est implémenté par les uploaders dont l'API permet d'envoyer
+// un fichier par blocs et de reprendre un envoi interrompu
+type ResumableUploader interface {
+	Uploader
+	UploadResumable(filePath, title string, store SessionStore, counter *ByteCounter) (*UploadResult, error)
+}
+
+// UploadSession représente l'état d'un upload reprenable
+type UploadSession struct {
+	SessionID string
+	FilePath  string
+	Size      int64
+	// ModTime est la date de modification du fichier au début de l'envoi
+	ModTime   time.Time
+	Offset    int64
+	ChunkSize int64
+	// Data contient les informations propres à l'hébergeur (URL d'upload, identifiants...)
+	Data string
+}
+
+// SessionStore persiste l'état d'un upload reprenable entre deux tentatives
+type SessionStore interface {
+	Load() (*UploadSession, error)
+	Save(session *UploadSession) error
+	Clear() error
+}
+
+// ChunkSender envoie un bloc à partir d'un offset et retourne l'offset confirmé par le serveur.
+// Le bloc peut être relu, par exemple pour en calculer la somme de contrôle; seuls les octets
+// envoyés au serveur sont ajoutés au compteur.
+type ChunkSender func(session *UploadSession, offset int64, chunk *io.SectionReader, counter *ByteCounter) (int64, error)
+
+// LoadSession retourne la session enregistrée si elle correspond toujours au fichier (même
+// chemin, même taille et même date de modification à la seconde près), sinon la supprime et
+// retourne nil
+func LoadSession(store SessionStore, filePath string, size int64, modTime time.Time) (*UploadSession, error) {
+	session, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil {
+		return nil, nil
+	}
+
+	if session.FilePath != filePath || session.Size != size || session.ModTime.Unix() != modTime.Unix() {
+		log.Printf("Session d'upload %s obsolète (fichier modifié), nouvel envoi complet", session.SessionID)
+		if err := store.Clear(); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	return session, nil
+}
+
+// UploadChunks envoie le fichier bloc par bloc à partir de l'offset de la session.
+// Chaque bloc confirmé est enregistré pour qu'une nouvelle tentative reprenne à cet endroit.
+func UploadChunks(file *os.File, session *UploadSession, store SessionStore, counter *ByteCounter, send ChunkSender) error {
+	chunkSize := session.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	if session.Offset > 0 {
+		log.Printf("Reprise de l'upload %s à %d/%d octets", session.SessionID, session.Offset, session.Size)
+	}
+
+	for session.Offset < session.Size {
+		length := chunkSize
+		if remaining := session.Size - session.Offset; remaining < length {
+			length = remaining
+		}
+
+		chunk := io.NewSectionReader(file, session.Offset, length)
+		offset, err := send(session, session.Offset, chunk, counter)
+		if err != nil {
+			return fmt.Errorf("erreur lors de l'envoi du bloc à l'offset %d: %w", session.Offset, err)
+		}
+
+		if offset <= session.Offset {
+			return fmt.Errorf("le serveur n'a pas confirmé le bloc à l'offset %d", session.Offset)
+		}
+
+		session.Offset = offset
+		if err := store.Save(session); err != nil {
+			return fmt.Errorf("erreur lors de l'enregistrement de la progression: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// memorySessionStore conserve la session en mémoire, pour un envoi par blocs sans reprise possible
+type memorySessionStore struct {
+	session *UploadSession
+}
+
+// Load retourne la session en mémoire
+func (s *memorySessionStore) Load() (*UploadSession, error) {
+	return s.session, nil
+}
+
+// Save conserve la session en mémoire
+func (s *memorySessionStore) Save(session *UploadSession) error {
+	s.session = session
+	return nil
+}
+
+// Clear oublie la session
+func (s *memorySessionStore) Clear() error {
+	s.session = nil
+	return nil
+}
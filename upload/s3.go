@@ -0,0 +1,481 @@
+package upload
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Uploader gère les uploads multipart vers un stockage compatible S3 (AWS, MinIO, R2...).
+// Chaque partie confirmée est enregistrée dans la session pour reprendre un envoi interrompu.
+type S3Uploader struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Prefix    string
+	PartSize  int64
+	PublicURL string
+	Enabled   bool
+	client    *http.Client
+}
+
+// s3SessionData contient la clé de l'objet et les parties déjà confirmées d'un upload multipart
+type s3SessionData struct {
+	Key   string   `json:"key"`
+	Parts []s3Part `json:"parts"`
+}
+
+// s3Part est une partie confirmée par le serveur
+type s3Part struct {
+	PartNumber int    `xml:"PartNumber" json:"number"`
+	ETag       string `xml:"ETag" json:"etag"`
+}
+
+// NewS3Uploader crée un nouvel uploader S3 (URLs de style chemin: endpoint/bucket/clé).
+// publicURL est un modèle où %s est remplacé par la clé de l'objet.
+func NewS3Uploader(endpoint, region, bucket, accessKey, secretKey, prefix string, partSize int64, publicURL string, enabled bool) *S3Uploader {
+	if partSize <= 0 {
+		partSize = DefaultChunkSize
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &S3Uploader{
+		Endpoint:  strings.TrimSuffix(endpoint, "/"),
+		Region:    region,
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Prefix:    prefix,
+		PartSize:  partSize,
+		PublicURL: publicURL,
+		Enabled:   enabled,
+		client: &http.Client{
+			Timeout: 1 * time.Hour, // Timeout par partie
+		},
+	}
+}
+
+// Name retourne le nom de l'uploader
+func (s *S3Uploader) Name() string {
+	return "s3"
+}
+
+// IsEnabled indique si l'uploader est activé
+func (s *S3Uploader) IsEnabled() bool {
+	return s.Enabled
+}
+
+// ProvidesDownloadURL indique si les fichiers uploadés sont téléchargeables depuis une URL publique
+func (s *S3Uploader) ProvidesDownloadURL() bool {
+	return s.PublicURL != ""
+}
+
+// UploadFile upload un fichier vers le bucket, sans reprise entre deux tentatives
+func (s *S3Uploader) UploadFile(filePath, title string) (*UploadResult, error) {
+	return s.UploadResumable(filePath, title, &memorySessionStore{}, nil)
+}
+
+// UploadResumable upload un fichier vers le bucket en reprenant l'upload multipart enregistré s'il existe
+func (s *S3Uploader) UploadResumable(filePath, title string, store SessionStore, counter *ByteCounter) (*UploadResult, error) {
+	log.Printf("Upload du fichier %s vers le bucket S3 %s...", filePath, s.Bucket)
+
+	// Vérifier si le fichier existe
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("le fichier n'existe pas: %s", filePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la lecture des informations du fichier: %w", err)
+	}
+	if info.Size() == 0 {
+		return nil, fmt.Errorf("le fichier est vide: %s", filePath)
+	}
+
+	// Ouvrir le fichier
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de l'ouverture du fichier: %w", err)
+	}
+	defer file.Close()
+
+	// Reprendre l'upload multipart existant si le serveur le connaît encore
+	session, err := LoadSession(store, filePath, info.Size(), info.ModTime())
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors du chargement de la session: %w", err)
+	}
+
+	if session != nil {
+		if err := s.resume(session); err != nil {
+			log.Printf("Upload multipart %s inutilisable, nouvel envoi complet: %v", session.SessionID, err)
+			if err := store.Clear(); err != nil {
+				return nil, err
+			}
+			session = nil
+		}
+	}
+
+	// Démarrer un nouvel upload multipart
+	if session == nil {
+		key := s.Prefix + time.Now().UTC().Format("20060102150405") + "/" + filepath.Base(filePath)
+		uploadID, err := s.initiate(key)
+		if err != nil {
+			return nil, fmt.Errorf("échec de la création de l'upload multipart: %w", err)
+		}
+
+		data, err := json.Marshal(s3SessionData{Key: key})
+		if err != nil {
+			return nil, fmt.Errorf("erreur lors de l'encodage de la session: %w", err)
+		}
+
+		session = &UploadSession{
+			SessionID: uploadID,
+			FilePath:  filePath,
+			Size:      info.Size(),
+			ModTime:   info.ModTime(),
+			ChunkSize: s.PartSize,
+			Data:      string(data),
+		}
+		if err := store.Save(session); err != nil {
+			return nil, fmt.Errorf("erreur lors de l'enregistrement de la session: %w", err)
+		}
+	}
+
+	// Envoyer les parties restantes
+	if err := UploadChunks(file, session, store, counter, s.uploadPart); err != nil {
+		return nil, err
+	}
+
+	var data s3SessionData
+	if err := json.Unmarshal([]byte(session.Data), &data); err != nil {
+		return nil, fmt.Errorf("session S3 invalide: %w", err)
+	}
+
+	if err := s.complete(data.Key, session.SessionID, data.Parts); err != nil {
+		return nil, fmt.Errorf("échec de la finalisation de l'upload multipart: %w", err)
+	}
+
+	if err := store.Clear(); err != nil {
+		log.Printf("Erreur lors de la suppression de la session S3: %v", err)
+	}
+
+	log.Printf("Fichier uploadé avec succès sur le bucket S3, clé: %s", data.Key)
+
+	result := &UploadResult{
+		Success:  true,
+		Hoster:   "s3",
+		FileCode: data.Key,
+		URL:      s.objectURL(data.Key, nil),
+	}
+	if s.PublicURL != "" {
+		result.URL = fmt.Sprintf(s.PublicURL, s3EscapePath(data.Key))
+		result.DownloadURL = result.URL
+	}
+	result.Embed = result.URL
+
+	return result, nil
+}
+
+// resume interroge le serveur sur les parties déjà reçues et aligne la session sur sa réponse
+func (s *S3Uploader) resume(session *UploadSession) error {
+	var data s3SessionData
+	if err := json.Unmarshal([]byte(session.Data), &data); err != nil {
+		return fmt.Errorf("session S3 invalide: %w", err)
+	}
+
+	parts, err := s.listParts(data.Key, session.SessionID)
+	if err != nil {
+		return err
+	}
+
+	// Seules les parties contiguës depuis la première permettent de reprendre
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	data.Parts = nil
+	for i, part := range parts {
+		if part.PartNumber != i+1 {
+			break
+		}
+		data.Parts = append(data.Parts, part)
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'encodage de la session: %w", err)
+	}
+
+	session.Data = string(encoded)
+	session.Offset = int64(len(data.Parts)) * session.ChunkSize
+	if session.Offset > session.Size {
+		session.Offset = session.Size
+	}
+
+	return nil
+}
+
+// initiate démarre un upload multipart (POST ?uploads) et retourne son identifiant
+func (s *S3Uploader) initiate(key string) (string, error) {
+	resp, err := s.do("POST", key, url.Values{"uploads": {""}}, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("erreur lors du décodage de la réponse: %w", err)
+	}
+	if result.UploadID == "" {
+		return "", fmt.Errorf("le serveur n'a pas retourné d'identifiant d'upload")
+	}
+
+	log.Printf("Upload multipart S3 créé: %s", result.UploadID)
+	return result.UploadID, nil
+}
+
+// uploadPart envoie une partie (PUT ?partNumber&uploadId) et retourne le nouvel offset confirmé
+func (s *S3Uploader) uploadPart(session *UploadSession, offset int64, chunk *io.SectionReader, counter *ByteCounter) (int64, error) {
+	var data s3SessionData
+	if err := json.Unmarshal([]byte(session.Data), &data); err != nil {
+		return 0, fmt.Errorf("session S3 invalide: %w", err)
+	}
+
+	// La signature porte sur l'empreinte SHA-256 de la partie
+	hash := sha256.New()
+	if _, err := io.Copy(hash, chunk); err != nil {
+		return 0, fmt.Errorf("erreur lors du calcul de l'empreinte de la partie: %w", err)
+	}
+	if _, err := chunk.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("erreur lors du retour au début de la partie: %w", err)
+	}
+
+	partNumber := len(data.Parts) + 1
+	query := url.Values{
+		"partNumber": {strconv.Itoa(partNumber)},
+		"uploadId":   {session.SessionID},
+	}
+
+	resp, err := s.do("PUT", data.Key, query, throttle(s.Name(), chunk, counter), chunk.Size(), hex.EncodeToString(hash.Sum(nil)))
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return 0, fmt.Errorf("le serveur n'a pas retourné d'ETag pour la partie %d", partNumber)
+	}
+
+	data.Parts = append(data.Parts, s3Part{PartNumber: partNumber, ETag: etag})
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors de l'encodage de la session: %w", err)
+	}
+	session.Data = string(encoded)
+
+	return offset + chunk.Size(), nil
+}
+
+// listParts retourne les parties déjà reçues par le serveur (GET ?uploadId)
+func (s *S3Uploader) listParts(key, uploadID string) ([]s3Part, error) {
+	var parts []s3Part
+	marker := ""
+
+	for {
+		query := url.Values{"uploadId": {uploadID}}
+		if marker != "" {
+			query.Set("part-number-marker", marker)
+		}
+
+		resp, err := s.do("GET", key, query, nil, 0, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Parts                []s3Part `xml:"Part"`
+			IsTruncated          bool     `xml:"IsTruncated"`
+			NextPartNumberMarker string   `xml:"NextPartNumberMarker"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("erreur lors du décodage de la réponse: %w", err)
+		}
+
+		parts = append(parts, result.Parts...)
+		if !result.IsTruncated || result.NextPartNumberMarker == "" {
+			return parts, nil
+		}
+		marker = result.NextPartNumberMarker
+	}
+}
+
+// complete assemble les parties envoyées (POST ?uploadId)
+func (s *S3Uploader) complete(key, uploadID string, parts []s3Part) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return fmt.Errorf("erreur lors de l'encodage de la requête: %w", err)
+	}
+
+	hash := sha256.Sum256(body)
+	resp, err := s.do("POST", key, url.Values{"uploadId": {uploadID}}, bytes.NewReader(body), int64(len(body)), hex.EncodeToString(hash[:]))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// S3 peut signaler un échec dans une réponse 200
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la lecture de la réponse: %w", err)
+	}
+	if bytes.Contains(responseBody, []byte("<Error>")) {
+		return &HTTPError{StatusCode: resp.StatusCode, Body: string(responseBody)}
+	}
+
+	return nil
+}
+
+// emptyPayloadHash est l'empreinte SHA-256 d'un corps vide
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// do envoie une requête signée (AWS Signature V4) et retourne la réponse si elle est en 2xx
+func (s *S3Uploader) do(method, key string, query url.Values, body io.Reader, length int64, payloadHash string) (*http.Response, error) {
+	requestURL := s.objectURL(key, query)
+	req, err := http.NewRequest(method, requestURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la création de la requête: %w", err)
+	}
+	req.ContentLength = length
+
+	s.sign(req, payloadHash, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de l'envoi de la requête: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		responseBody, _ := io.ReadAll(resp.Body)
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: string(responseBody)}
+	}
+
+	return resp, nil
+}
+
+// objectURL retourne l'URL d'un objet, avec le chemin et la requête sous leur forme canonique
+func (s *S3Uploader) objectURL(key string, query url.Values) string {
+	objectURL := s.Endpoint + "/" + s3Escape(s.Bucket) + "/" + s3EscapePath(key)
+	if len(query) > 0 {
+		objectURL += "?" + s3CanonicalQuery(query)
+	}
+	return objectURL
+}
+
+// sign ajoute à la requête les en-têtes de signature AWS Signature V4
+func (s *S3Uploader) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	signingKey = hmacSHA256(signingKey, s.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 calcule le HMAC-SHA256 d'un message
+func hmacSHA256(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+// s3CanonicalQuery encode les paramètres triés par nom, comme l'exige la signature V4
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			pairs = append(pairs, s3Escape(key)+"="+s3Escape(value))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// s3EscapePath encode chaque segment d'une clé en conservant les séparateurs
+func s3EscapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3Escape encode tous les caractères hors de l'ensemble non réservé de la RFC 3986
+func s3Escape(value string) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			builder.WriteByte(c)
+		} else {
+			fmt.Fprintf(&builder, "%%%02X", c)
+		}
+	}
+	return builder.String()
+}
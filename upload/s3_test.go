@@ -0,0 +1,272 @@
+package upload_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"media-upload-system/upload"
+)
+
+// s3PartSize est la taille des parties utilisée par les tests
+const s3PartSize = 4096
+
+// fakeS3 simule les requêtes multipart d'un stockage S3
+type fakeS3 struct {
+	mutex       sync.Mutex
+	uploads     map[string]map[int][]byte
+	objects     map[string][]byte
+	initiations int
+	partsSent   map[int]int
+	failPart    int
+}
+
+// newFakeS3 démarre un serveur S3 factice
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	fake := &fakeS3{
+		uploads:   make(map[string]map[int][]byte),
+		objects:   make(map[string][]byte),
+		partsSent: make(map[int]int),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// La requête doit être signée et porter l'empreinte de son corps
+	hash := sha256.Sum256(body)
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") ||
+		r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(hash[:]) {
+		http.Error(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>", http.StatusForbidden)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	query := r.URL.Query()
+	uploadID := query.Get("uploadId")
+
+	switch {
+	case r.Method == "POST" && query.Has("uploads"):
+		f.initiations++
+		uploadID = fmt.Sprintf("upload-%d", f.initiations)
+		f.uploads[uploadID] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", key, uploadID)
+
+	case r.Method == "PUT":
+		parts, ok := f.uploads[uploadID]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchUpload</Code></Error>", http.StatusNotFound)
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		f.partsSent[number]++
+		if number == f.failPart {
+			f.failPart = 0
+			http.Error(w, "indisponible", http.StatusServiceUnavailable)
+			return
+		}
+		parts[number] = body
+		w.Header().Set("ETag", fmt.Sprintf("\"etag-%d\"", number))
+
+	case r.Method == "GET":
+		parts, ok := f.uploads[uploadID]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchUpload</Code></Error>", http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "<ListPartsResult>")
+		for number := range parts {
+			fmt.Fprintf(w, "<Part><PartNumber>%d</PartNumber><ETag>\"etag-%d\"</ETag></Part>", number, number)
+		}
+		fmt.Fprint(w, "<IsTruncated>false</IsTruncated></ListPartsResult>")
+
+	case r.Method == "POST":
+		parts, ok := f.uploads[uploadID]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchUpload</Code></Error>", http.StatusNotFound)
+			return
+		}
+		var request struct {
+			Parts []struct {
+				PartNumber int
+				ETag       string
+			} `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var object []byte
+		for i, part := range request.Parts {
+			if part.PartNumber != i+1 || part.ETag != fmt.Sprintf("\"etag-%d\"", part.PartNumber) || parts[part.PartNumber] == nil {
+				fmt.Fprint(w, "<Error><Code>InvalidPart</Code></Error>")
+				return
+			}
+			object = append(object, parts[part.PartNumber]...)
+		}
+		f.objects[key] = object
+		delete(f.uploads, uploadID)
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Key>%s</Key></CompleteMultipartUploadResult>", key)
+
+	default:
+		http.Error(w, "requête inattendue", http.StatusBadRequest)
+	}
+}
+
+// object retourne le seul objet assemblé par le serveur
+func (f *fakeS3) object(t *testing.T) (string, []byte) {
+	t.Helper()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if len(f.objects) != 1 {
+		t.Fatalf("un objet attendu, obtenu %d", len(f.objects))
+	}
+	for key, object := range f.objects {
+		return key, object
+	}
+	return "", nil
+}
+
+// initiationCount retourne le nombre d'uploads multipart créés
+func (f *fakeS3) initiationCount() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.initiations
+}
+
+// sentParts retourne les numéros de partie reçus, avec le nombre d'envois de chacune
+func (f *fakeS3) sentParts() map[int]int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	sent := make(map[int]int, len(f.partsSent))
+	for number, count := range f.partsSent {
+		sent[number] = count
+	}
+	return sent
+}
+
+func newTestS3Uploader(server *httptest.Server) *upload.S3Uploader {
+	return upload.NewS3Uploader(server.URL, "eu-west-3", "bucket", "key", "secret", "films/", s3PartSize, "https://media.example.com/%s", true)
+}
+
+func TestS3Upload(t *testing.T) {
+	fake, server := newFakeS3(t)
+	uploader := newTestS3Uploader(server)
+	path, content := writeRandomFile(t, "Mon film.mkv", 2*s3PartSize+100)
+
+	store := &sessionStore{}
+	result, err := uploader.UploadResumable(path, "Film", store, nil)
+	if err != nil || !result.Success {
+		t.Fatalf("UploadResumable: %+v, %v", result, err)
+	}
+
+	key, object := fake.object(t)
+	if !bytes.Equal(object, content) {
+		t.Fatalf("objet assemblé différent du fichier (%d octets au lieu de %d)", len(object), len(content))
+	}
+	if !strings.HasPrefix(key, "films/") || !strings.HasSuffix(key, "/Mon film.mkv") || result.FileCode != key {
+		t.Fatalf("clé inattendue: %q (résultat %q)", key, result.FileCode)
+	}
+	if !strings.HasSuffix(result.DownloadURL, "/Mon%20film.mkv") || result.URL != result.DownloadURL {
+		t.Fatalf("URL publique inattendue: %+v", result)
+	}
+	if store.session != nil {
+		t.Fatalf("session conservée après un upload réussi: %+v", store.session)
+	}
+}
+
+func TestS3Resume(t *testing.T) {
+	fake, server := newFakeS3(t)
+	uploader := newTestS3Uploader(server)
+	path, content := writeRandomFile(t, "film.mkv", 3*s3PartSize)
+
+	// La deuxième partie échoue: la session doit s'arrêter après la première
+	fake.failPart = 2
+	store := &sessionStore{}
+	if _, err := uploader.UploadResumable(path, "Film", store, nil); err == nil || upload.StatusCode(err) != 503 {
+		t.Fatalf("erreur 503 attendue, obtenu %v", err)
+	}
+	if store.session == nil || store.session.Offset != s3PartSize {
+		t.Fatalf("session après l'échec: %+v", store.session)
+	}
+
+	counter := &upload.ByteCounter{}
+	result, err := uploader.UploadResumable(path, "Film", store, counter)
+	if err != nil || !result.Success {
+		t.Fatalf("reprise: %+v, %v", result, err)
+	}
+	if counter.Sent() != 2*s3PartSize {
+		t.Fatalf("octets comptés pour la reprise: %d, attendu %d", counter.Sent(), 2*s3PartSize)
+	}
+
+	if fake.initiationCount() != 1 {
+		t.Fatalf("la reprise a créé un nouvel upload multipart (%d)", fake.initiationCount())
+	}
+	sent := fake.sentParts()
+	if sent[1] != 1 || sent[2] != 2 || sent[3] != 1 {
+		t.Fatalf("parties envoyées: %v", sent)
+	}
+	if _, object := fake.object(t); !bytes.Equal(object, content) {
+		t.Fatal("objet assemblé différent du fichier")
+	}
+}
+
+func TestS3ResumeModifiedFile(t *testing.T) {
+	fake, server := newFakeS3(t)
+	uploader := newTestS3Uploader(server)
+	path, _ := writeRandomFile(t, "film.mkv", 3*s3PartSize)
+
+	fake.failPart = 2
+	store := &sessionStore{}
+	if _, err := uploader.UploadResumable(path, "Film", store, nil); err == nil {
+		t.Fatal("erreur attendue")
+	}
+
+	// Même taille, contenu remplacé: seule la date de modification change
+	content := bytes.Repeat([]byte{7}, 3*s3PartSize)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	modTime := store.session.ModTime.Add(time.Hour)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	result, err := uploader.UploadResumable(path, "Film", store, nil)
+	if err != nil || !result.Success {
+		t.Fatalf("nouvel envoi: %+v, %v", result, err)
+	}
+
+	if fake.initiationCount() != 2 {
+		t.Fatalf("un nouvel upload multipart était attendu (%d)", fake.initiationCount())
+	}
+	if sent := fake.sentParts(); sent[1] != 2 {
+		t.Fatalf("la première partie aurait dû être renvoyée: %v", sent)
+	}
+	if _, object := fake.object(t); !bytes.Equal(object, content) {
+		t.Fatal("l'objet ne correspond pas au fichier modifié")
+	}
+}
@@ -0,0 +1,95 @@
+package upload
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+)
+
+// StreamUploader est implémenté par les uploaders capables d'envoyer un flux
+// au lieu d'ouvrir eux-mêmes le fichier, ce qui permet de ne lire le fichier
+// qu'une seule fois pour plusieurs hébergeurs
+type StreamUploader interface {
+	Uploader
+	UploadStream(content io.Reader, fileName string, size int64, title string) (*UploadResult, error)
+}
+
+// Transfer envoie un fichier à un hébergeur et compte dans counter les octets envoyés
+// pendant la tentative: par blocs reprenables enregistrés dans store si l'hébergeur le
+// permet, sinon en flux
+func Transfer(uploader Uploader, filePath, title string, store SessionStore, counter *ByteCounter) (*UploadResult, error) {
+	if resumableUploader, ok := uploader.(ResumableUploader); ok {
+		return resumableUploader.UploadResumable(filePath, title, store, counter)
+	}
+
+	streamUploader, ok := uploader.(StreamUploader)
+	if !ok {
+		return uploader.UploadFile(filePath, title)
+	}
+
+	log.Printf("Upload du fichier %s vers %s...", filePath, uploader.Name())
+
+	// Vérifier si le fichier existe
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("le fichier n'existe pas: %s", filePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la lecture des informations du fichier: %w", err)
+	}
+
+	// Ouvrir le fichier
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de l'ouverture du fichier: %w", err)
+	}
+	defer file.Close()
+
+	return streamUploader.UploadStream(counter.Reader(file), filepath.Base(filePath), info.Size(), title)
+}
+
+// formField représente un champ texte d'un formulaire multipart
+type formField struct {
+	name  string
+	value string
+}
+
+// multipartBody construit un corps multipart dont le fichier est lu en flux,
+// au débit autorisé pour l'hébergeur. La taille totale est calculée à l'avance
+// pour éviter l'encodage chunked, que certains hébergeurs refusent.
+func multipartBody(hoster string, fields []formField, fileField, fileName string, content io.Reader, size int64) (io.Reader, string, int64, error) {
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+
+	// Ajouter les champs du formulaire
+	for _, field := range fields {
+		if err := writer.WriteField(field.name, field.value); err != nil {
+			return nil, "", 0, fmt.Errorf("erreur lors de l'ajout du champ %s: %w", field.name, err)
+		}
+	}
+
+	// Ajouter l'en-tête du fichier
+	if _, err := writer.CreateFormFile(fileField, fileName); err != nil {
+		return nil, "", 0, fmt.Errorf("erreur lors de la création du champ %s: %w", fileField, err)
+	}
+	headerLength := buffer.Len()
+
+	// Fermer le writer pour obtenir la fin du formulaire
+	if err := writer.Close(); err != nil {
+		return nil, "", 0, fmt.Errorf("erreur lors de la fermeture du writer: %w", err)
+	}
+
+	data := buffer.Bytes()
+	header := data[:headerLength]
+	footer := data[headerLength:]
+
+	fileContent := throttle(hoster, io.LimitReader(content, size), nil)
+	body := io.MultiReader(bytes.NewReader(header), fileContent, bytes.NewReader(footer))
+	length := int64(len(header)) + size + int64(len(footer))
+
+	return body, writer.FormDataContentType(), length, nil
+}
@@ -0,0 +1,70 @@
+package upload_test
+
+import (
+	"sync"
+	"testing"
+
+	"media-upload-system/upload"
+	"media-upload-system/upload/hostertest"
+)
+
+func TestTransferCountsAttemptBytes(t *testing.T) {
+	server := hostertest.NewMixDrop()
+	defer server.Close()
+
+	// Deux tentatives simultanées vers le même hébergeur sont comptées séparément
+	sizes := []int{4096, 10000}
+	counters := make([]*upload.ByteCounter, len(sizes))
+
+	var wg sync.WaitGroup
+	for i, size := range sizes {
+		path := writeTestFile(t, size)
+		counters[i] = &upload.ByteCounter{}
+
+		wg.Add(1)
+		go func(counter *upload.ByteCounter) {
+			defer wg.Done()
+			if _, err := upload.Transfer(server.Uploader(), path, "Film", &sessionStore{}, counter); err != nil {
+				t.Errorf("Transfer: %v", err)
+			}
+		}(counters[i])
+	}
+	wg.Wait()
+
+	for i, size := range sizes {
+		if counters[i].Sent() != int64(size) {
+			t.Fatalf("tentative %d: %d octets comptés, attendu %d", i, counters[i].Sent(), size)
+		}
+	}
+}
+
+func TestFanOutCountsBytes(t *testing.T) {
+	mixdrop := hostertest.NewMixDrop()
+	defer mixdrop.Close()
+	netu := hostertest.NewNetu()
+	defer netu.Close()
+
+	// Netu échoue avant d'obtenir son serveur d'upload: aucun octet ne lui est attribué
+	netu.Fail(netuUploadPaths[0], hostertest.FailRejected, 1)
+
+	path := writeTestFile(t, 8192)
+	uploaders := []upload.StreamUploader{mixdrop.Uploader(), netu.Uploader()}
+
+	sent := make(map[string]int64)
+	var mutex sync.Mutex
+	err := upload.FanOut(path, "Film", uploaders, func(hoster string, result *upload.UploadResult, bytes int64, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		sent[hoster] = bytes
+	})
+	if err != nil {
+		t.Fatalf("FanOut: %v", err)
+	}
+
+	if sent["mixdrop"] != 8192 {
+		t.Fatalf("octets comptés pour mixdrop: %d", sent["mixdrop"])
+	}
+	if bytes, ok := sent["netu"]; !ok || bytes != 0 {
+		t.Fatalf("octets comptés pour netu: %v", sent)
+	}
+}
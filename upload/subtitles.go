@@ -0,0 +1,98 @@
+package upload
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SubtitleUploader est implémenté par les uploaders qui peuvent héberger un fichier de
+// sous-titres pour une vidéo déjà uploadée. L'URL retournée est vide quand les sous-titres
+// sont attachés directement au fichier vidéo et servis par le lecteur de l'hébergeur.
+type SubtitleUploader interface {
+	Uploader
+	UploadSubtitle(fileCode, filePath, language string) (string, error)
+}
+
+// UploadSubtitle attache un fichier de sous-titres à une vidéo MixDrop
+func (m *MixDropUploader) UploadSubtitle(fileCode, filePath, language string) (string, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", fmt.Errorf("erreur lors de la lecture des informations du fichier: %w", err)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("erreur lors de l'ouverture du fichier: %w", err)
+	}
+	defer file.Close()
+
+	fields := []formField{
+		{name: "email", value: m.Email},
+		{name: "key", value: m.ApiKey},
+		{name: "ref", value: fileCode},
+		{name: "lang", value: language},
+	}
+
+	requestBody, contentType, contentLength, err := multipartBody("mixdrop", fields, "file", filepath.Base(filePath), file, info.Size())
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("POST", m.APIURL+"/addsubtitle", requestBody)
+	if err != nil {
+		return "", fmt.Errorf("erreur lors de la création de la requête: %w", err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	req.ContentLength = contentLength
+
+	client := &http.Client{
+		Timeout: 5 * time.Minute,
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("erreur lors de l'envoi de la requête: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("erreur lors de la lecture de la réponse: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var response struct {
+		Success bool `json:"success"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("erreur lors du décodage de la réponse JSON: %w, réponse: %s", err, string(body))
+	}
+
+	if !response.Success {
+		return "", fmt.Errorf("l'API a refusé les sous-titres: %s", string(body))
+	}
+
+	log.Printf("Sous-titres %s attachés au fichier MixDrop %s", language, fileCode)
+	return "", nil
+}
+
+// UploadSubtitle envoie le fichier de sous-titres sur le serveur tus, à côté de la vidéo,
+// et retourne son URL publique
+func (t *TusUploader) UploadSubtitle(fileCode, filePath, language string) (string, error) {
+	result, err := t.UploadFile(filePath, fmt.Sprintf("%s.%s", fileCode, language))
+	if err != nil {
+		return "", err
+	}
+
+	return result.URL, nil
+}
@@ -0,0 +1,200 @@
+package upload
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// throttleReadSize limite la taille de chaque lecture pour lisser le débit
+const throttleReadSize = 32 * 1024
+
+// RateLimiter limite un débit en octets par seconde. Le débit peut être modifié
+// à tout moment, y compris pendant un upload en cours.
+type RateLimiter struct {
+	mutex  sync.Mutex
+	rate   int64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter crée un limiteur de débit (0 = illimité)
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	return &RateLimiter{rate: bytesPerSecond}
+}
+
+// SetRate modifie le débit autorisé (0 = illimité)
+func (l *RateLimiter) SetRate(bytesPerSecond int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.rate != bytesPerSecond {
+		l.rate = bytesPerSecond
+		l.tokens = 0
+		l.last = time.Now()
+	}
+}
+
+// Rate retourne le débit autorisé (0 = illimité)
+func (l *RateLimiter) Rate() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.rate
+}
+
+// Wait attend que n octets puissent être envoyés
+func (l *RateLimiter) Wait(n int) {
+	l.mutex.Lock()
+
+	if l.rate <= 0 {
+		l.mutex.Unlock()
+		return
+	}
+
+	// Recharger les jetons, avec au plus une seconde de rafale
+	now := time.Now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+		if l.tokens > float64(l.rate) {
+			l.tokens = float64(l.rate)
+		}
+	}
+	l.last = now
+
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / float64(l.rate) * float64(time.Second))
+	}
+
+	l.mutex.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// Bandwidth regroupe le limiteur global et les limiteurs par hébergeur
+type Bandwidth struct {
+	global  *RateLimiter
+	mutex   sync.Mutex
+	hosters map[string]*RateLimiter
+}
+
+// NewBandwidth crée un gestionnaire de bande passante sans limite
+func NewBandwidth() *Bandwidth {
+	return &Bandwidth{
+		global:  NewRateLimiter(0),
+		hosters: make(map[string]*RateLimiter),
+	}
+}
+
+// SetLimits applique de nouvelles limites (octets par seconde, 0 = illimité).
+// Les hébergeurs absents de la liste ne sont plus limités.
+func (b *Bandwidth) SetLimits(global int64, hosters map[string]int64) {
+	b.global.SetRate(global)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for name, limiter := range b.hosters {
+		if _, exists := hosters[name]; !exists {
+			limiter.SetRate(0)
+		}
+	}
+
+	for name, rate := range hosters {
+		b.hoster(name).SetRate(rate)
+	}
+}
+
+// Reader enveloppe un flux pour qu'il respecte la limite globale et celle de l'hébergeur
+func (b *Bandwidth) Reader(hoster string, reader io.Reader) io.Reader {
+	b.mutex.Lock()
+	limiter := b.hoster(hoster)
+	b.mutex.Unlock()
+
+	return &throttledReader{
+		reader:   reader,
+		limiters: []*RateLimiter{b.global, limiter},
+	}
+}
+
+// hoster retourne le limiteur d'un hébergeur (le mutex doit être verrouillé)
+func (b *Bandwidth) hoster(name string) *RateLimiter {
+	limiter, exists := b.hosters[name]
+	if !exists {
+		limiter = NewRateLimiter(0)
+		b.hosters[name] = limiter
+	}
+	return limiter
+}
+
+// throttledReader applique des limites de débit à chaque lecture
+type throttledReader struct {
+	reader   io.Reader
+	limiters []*RateLimiter
+}
+
+// Read lit au plus throttleReadSize octets puis attend l'autorisation des limiteurs
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleReadSize {
+		p = p[:throttleReadSize]
+	}
+
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		for _, limiter := range r.limiters {
+			limiter.Wait(n)
+		}
+	}
+
+	return n, err
+}
+
+// bandwidth est le gestionnaire de bande passante appliqué à tous les uploads
+var bandwidth = NewBandwidth()
+
+// SetBandwidth définit le gestionnaire de bande passante utilisé par les uploaders
+func SetBandwidth(b *Bandwidth) {
+	bandwidth = b
+}
+
+// ByteCounter compte les octets envoyés pendant une tentative d'upload
+type ByteCounter struct {
+	sent int64
+}
+
+// Sent retourne le nombre d'octets envoyés depuis la création du compteur
+func (c *ByteCounter) Sent() int64 {
+	return atomic.LoadInt64(&c.sent)
+}
+
+// Reader enveloppe un flux pour ajouter au compteur les octets lus. Un compteur nil
+// retourne le flux tel quel.
+func (c *ByteCounter) Reader(reader io.Reader) io.Reader {
+	if c == nil {
+		return reader
+	}
+	return &countingReader{reader: reader, counter: &c.sent}
+}
+
+// countingReader ajoute les octets lus à un compteur
+type countingReader struct {
+	reader  io.Reader
+	counter *int64
+}
+
+// Read lit le flux et met à jour le compteur
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	atomic.AddInt64(r.counter, int64(n))
+	return n, err
+}
+
+// throttle applique la limite de bande passante à un corps de requête
+// et ajoute les octets envoyés au compteur de la tentative
+func throttle(hoster string, reader io.Reader, counter *ByteCounter) io.Reader {
+	return counter.Reader(bandwidth.Reader(hoster, reader))
+}
@@ -0,0 +1,309 @@
+package upload
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TusVersion est la version du protocole tus utilisée
+const TusVersion = "1.0.0"
+
+// TusUploader gère les uploads vers un serveur tus 1.0 (ingest du CDN)
+type TusUploader struct {
+	Endpoint  string
+	Headers   map[string]string
+	ChunkSize int64
+	Checksum  bool
+	PublicURL string
+	EmbedURL  string
+	Enabled   bool
+	client    *http.Client
+}
+
+// NewTusUploader crée un nouvel uploader tus.
+// publicURL et embedURL sont des modèles où %s est remplacé par l'identifiant de l'upload.
+func NewTusUploader(endpoint string, headers map[string]string, chunkSize int64, checksum bool, publicURL, embedURL string, enabled bool) *TusUploader {
+	return &TusUploader{
+		Endpoint:  endpoint,
+		Headers:   headers,
+		ChunkSize: chunkSize,
+		Checksum:  checksum,
+		PublicURL: publicURL,
+		EmbedURL:  embedURL,
+		Enabled:   enabled,
+		client: &http.Client{
+			Timeout: 1 * time.Hour, // Timeout par bloc
+		},
+	}
+}
+
+// Name retourne le nom de l'uploader
+func (t *TusUploader) Name() string {
+	return "tus"
+}
+
+// IsEnabled indique si l'uploader est activé
+func (t *TusUploader) IsEnabled() bool {
+	return t.Enabled
+}
+
+// ProvidesDownloadURL indique si les fichiers uploadés sont téléchargeables depuis une URL publique
+func (t *TusUploader) ProvidesDownloadURL() bool {
+	return t.PublicURL != ""
+}
+
+// UploadFile upload un fichier vers le serveur tus, sans reprise entre deux tentatives
+func (t *TusUploader) UploadFile(filePath, title string) (*UploadResult, error) {
+	return t.UploadResumable(filePath, title, &memorySessionStore{}, nil)
+}
+
+// UploadResumable upload un fichier vers le serveur tus en reprenant l'envoi enregistré s'il existe
+func (t *TusUploader) UploadResumable(filePath, title string, store SessionStore, counter *ByteCounter) (*UploadResult, error) {
+	log.Printf("Upload du fichier %s vers le serveur tus...", filePath)
+
+	// Vérifier si le fichier existe
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("le fichier n'existe pas: %s", filePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la lecture des informations du fichier: %w", err)
+	}
+
+	// Ouvrir le fichier
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de l'ouverture du fichier: %w", err)
+	}
+	defer file.Close()
+
+	// Reprendre la session existante si le serveur la connaît encore
+	session, err := LoadSession(store, filePath, info.Size(), info.ModTime())
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors du chargement de la session: %w", err)
+	}
+
+	if session != nil {
+		offset, err := t.getOffset(session.SessionID)
+		if err != nil {
+			log.Printf("Session tus %s inutilisable, nouvel envoi complet: %v", session.SessionID, err)
+			if err := store.Clear(); err != nil {
+				return nil, err
+			}
+			session = nil
+		} else {
+			session.Offset = offset
+		}
+	}
+
+	// Créer un nouvel upload sur le serveur
+	if session == nil {
+		location, err := t.create(filepath.Base(filePath), title, info.Size())
+		if err != nil {
+			return nil, fmt.Errorf("échec de la création de l'upload tus: %w", err)
+		}
+
+		session = &UploadSession{
+			SessionID: location,
+			FilePath:  filePath,
+			Size:      info.Size(),
+			ModTime:   info.ModTime(),
+			ChunkSize: t.ChunkSize,
+		}
+		if err := store.Save(session); err != nil {
+			return nil, fmt.Errorf("erreur lors de l'enregistrement de la session: %w", err)
+		}
+	}
+
+	// Envoyer les blocs restants
+	if err := UploadChunks(file, session, store, counter, t.patch); err != nil {
+		return nil, err
+	}
+
+	if err := store.Clear(); err != nil {
+		log.Printf("Erreur lors de la suppression de la session tus: %v", err)
+	}
+
+	fileCode := path.Base(session.SessionID)
+	log.Printf("Fichier uploadé avec succès sur le serveur tus, identifiant: %s", fileCode)
+
+	result := &UploadResult{
+		Success:  true,
+		Hoster:   "tus",
+		FileCode: fileCode,
+		URL:      session.SessionID,
+		Embed:    session.SessionID,
+	}
+
+	if t.PublicURL != "" {
+		result.URL = fmt.Sprintf(t.PublicURL, fileCode)
+		result.DownloadURL = result.URL
+	}
+	if t.EmbedURL != "" {
+		result.Embed = fmt.Sprintf(t.EmbedURL, fileCode)
+	}
+
+	return result, nil
+}
+
+// create crée l'upload sur le serveur (POST) et retourne son URL
+func (t *TusUploader) create(fileName, title string, size int64) (string, error) {
+	req, err := t.newRequest("POST", t.Endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Upload-Length", strconv.FormatInt(size, 10))
+	req.Header.Set("Upload-Metadata", encodeTusMetadata(map[string]string{
+		"filename": fileName,
+		"title":    title,
+	}))
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("erreur lors de l'envoi de la requête: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return "", &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", fmt.Errorf("le serveur n'a pas retourné d'en-tête Location")
+	}
+
+	// L'URL retournée peut être relative à l'endpoint
+	base, err := url.Parse(t.Endpoint)
+	if err != nil {
+		return "", fmt.Errorf("endpoint tus invalide: %w", err)
+	}
+	resolved, err := base.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("en-tête Location invalide: %w", err)
+	}
+
+	log.Printf("Upload tus créé: %s", resolved.String())
+	return resolved.String(), nil
+}
+
+// getOffset interroge le serveur (HEAD) sur l'offset déjà reçu
+func (t *TusUploader) getOffset(uploadURL string) (int64, error) {
+	req, err := t.newRequest("HEAD", uploadURL, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors de l'envoi de la requête: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return 0, &HTTPError{StatusCode: resp.StatusCode}
+	}
+
+	return parseTusOffset(resp)
+}
+
+// patch envoie un bloc (PATCH) et retourne le nouvel offset confirmé
+func (t *TusUploader) patch(session *UploadSession, offset int64, chunk *io.SectionReader, counter *ByteCounter) (int64, error) {
+	// Extension checksum: le serveur rejette le bloc s'il a été altéré
+	checksum := ""
+	if t.Checksum {
+		hash := sha1.New()
+		if _, err := io.Copy(hash, chunk); err != nil {
+			return 0, fmt.Errorf("erreur lors du calcul de la somme de contrôle: %w", err)
+		}
+		if _, err := chunk.Seek(0, io.SeekStart); err != nil {
+			return 0, fmt.Errorf("erreur lors du retour au début du bloc: %w", err)
+		}
+		checksum = "sha1 " + base64.StdEncoding.EncodeToString(hash.Sum(nil))
+	}
+
+	req, err := t.newRequest("PATCH", session.SessionID, throttle(t.Name(), chunk, counter))
+	if err != nil {
+		return 0, err
+	}
+
+	req.ContentLength = chunk.Size()
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	if checksum != "" {
+		req.Header.Set("Upload-Checksum", checksum)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors de l'envoi de la requête: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return parseTusOffset(resp)
+	case 460:
+		return 0, fmt.Errorf("somme de contrôle refusée par le serveur")
+	case http.StatusConflict:
+		return 0, fmt.Errorf("offset %d refusé par le serveur", offset)
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return 0, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+}
+
+// newRequest crée une requête tus avec les en-têtes communs
+func (t *TusUploader) newRequest(method, requestURL string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, requestURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la création de la requête: %w", err)
+	}
+
+	req.Header.Set("Tus-Resumable", TusVersion)
+	for name, value := range t.Headers {
+		req.Header.Set(name, value)
+	}
+
+	return req, nil
+}
+
+// parseTusOffset lit l'en-tête Upload-Offset d'une réponse
+func parseTusOffset(resp *http.Response) (int64, error) {
+	offset, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("en-tête Upload-Offset invalide: %w", err)
+	}
+
+	return offset, nil
+}
+
+// encodeTusMetadata encode les métadonnées au format de l'en-tête Upload-Metadata
+func encodeTusMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(metadata[key])))
+	}
+
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,276 @@
+package upload_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"media-upload-system/upload"
+)
+
+// tusChunkSize est la taille des blocs utilisée par les tests
+const tusChunkSize = 4096
+
+// fakeTus simule un serveur tus 1.0 avec les extensions creation et checksum
+type fakeTus struct {
+	mutex     sync.Mutex
+	uploads   map[string][]byte
+	lengths   map[string]int64
+	metadata  map[string]string
+	created   int
+	checksums int
+	// interruptAfter coupe la connexion du prochain PATCH après ce nombre d'octets reçus
+	interruptAfter int
+	// rejectChecksum refuse la somme de contrôle du prochain PATCH
+	rejectChecksum bool
+}
+
+// newFakeTus démarre un serveur tus factice dont l'endpoint est /files/
+func newFakeTus(t *testing.T) (*fakeTus, *httptest.Server) {
+	fake := &fakeTus{
+		uploads:  make(map[string][]byte),
+		lengths:  make(map[string]int64),
+		metadata: make(map[string]string),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeTus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if r.Header.Get("Tus-Resumable") != upload.TusVersion {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.created++
+		id := "upload-" + strconv.Itoa(f.created)
+		f.uploads[id] = nil
+		f.lengths[id] = length
+		f.metadata[id] = r.Header.Get("Upload-Metadata")
+		// Location relative à l'endpoint
+		w.Header().Set("Location", id)
+		w.WriteHeader(http.StatusCreated)
+
+	case "HEAD":
+		data, ok := f.uploads[strings.TrimPrefix(r.URL.Path, "/files/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+
+	case "PATCH":
+		id := strings.TrimPrefix(r.URL.Path, "/files/")
+		data, ok := f.uploads[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Upload-Offset") != strconv.Itoa(len(data)) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+
+		// Connexion coupée en plein envoi: le serveur conserve les octets déjà reçus
+		if f.interruptAfter > 0 {
+			received := make([]byte, f.interruptAfter)
+			n, _ := io.ReadFull(r.Body, received)
+			f.uploads[id] = append(data, received[:n]...)
+			f.interruptAfter = 0
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if checksum := r.Header.Get("Upload-Checksum"); checksum != "" {
+			f.checksums++
+			hash := sha1.Sum(body)
+			if f.rejectChecksum || checksum != "sha1 "+base64.StdEncoding.EncodeToString(hash[:]) {
+				f.rejectChecksum = false
+				w.WriteHeader(460)
+				return
+			}
+		}
+
+		f.uploads[id] = append(data, body...)
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(f.uploads[id])))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// upload retourne les octets reçus pour un upload
+func (f *fakeTus) upload(id string) []byte {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.uploads[id]
+}
+
+// createdCount retourne le nombre d'uploads créés
+func (f *fakeTus) createdCount() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.created
+}
+
+func newTestTusUploader(server *httptest.Server, checksum bool) *upload.TusUploader {
+	return upload.NewTusUploader(server.URL+"/files/", map[string]string{"Authorization": "Bearer token"}, tusChunkSize, checksum,
+		"https://cdn.example.com/files/%s", "https://cdn.example.com/embed/%s", true)
+}
+
+func TestTusUpload(t *testing.T) {
+	fake, server := newFakeTus(t)
+	uploader := newTestTusUploader(server, false)
+	path, content := writeRandomFile(t, "film.mkv", 2*tusChunkSize+100)
+
+	result, err := uploader.UploadResumable(path, "Film", &sessionStore{}, nil)
+	if err != nil || !result.Success {
+		t.Fatalf("UploadResumable: %+v, %v", result, err)
+	}
+
+	if !bytes.Equal(fake.upload("upload-1"), content) {
+		t.Fatal("données reçues différentes du fichier")
+	}
+	fake.mutex.Lock()
+	length, metadata := fake.lengths["upload-1"], fake.metadata["upload-1"]
+	fake.mutex.Unlock()
+	if length != int64(len(content)) {
+		t.Fatalf("Upload-Length: %d", length)
+	}
+	if want := "filename " + base64.StdEncoding.EncodeToString([]byte("film.mkv")); !strings.Contains(metadata, want) {
+		t.Fatalf("Upload-Metadata: %q", metadata)
+	}
+	if result.FileCode != "upload-1" || result.DownloadURL != "https://cdn.example.com/files/upload-1" ||
+		result.Embed != "https://cdn.example.com/embed/upload-1" {
+		t.Fatalf("résultat inattendu: %+v", result)
+	}
+}
+
+func TestTusRelativeLocation(t *testing.T) {
+	_, server := newFakeTus(t)
+	uploader := newTestTusUploader(server, false)
+	path, _ := writeRandomFile(t, "film.mkv", tusChunkSize)
+
+	// Le serveur répond "upload-1": l'URL du bloc doit être résolue par rapport à l'endpoint
+	uploader.PublicURL = ""
+	result, err := uploader.UploadResumable(path, "Film", &sessionStore{}, nil)
+	if err != nil {
+		t.Fatalf("UploadResumable: %v", err)
+	}
+	if want := server.URL + "/files/upload-1"; result.URL != want {
+		t.Fatalf("URL %q, attendu %q", result.URL, want)
+	}
+}
+
+func TestTusResumeAfterInterruptedPatch(t *testing.T) {
+	fake, server := newFakeTus(t)
+	uploader := newTestTusUploader(server, false)
+	path, content := writeRandomFile(t, "film.mkv", 3*tusChunkSize)
+
+	// Le deuxième bloc est coupé après 1000 octets: la reprise doit partir de l'offset du serveur
+	store := &sessionStore{}
+	first := &interruptingStore{sessionStore: store, fake: fake, interruptAfter: 1000}
+	if _, err := uploader.UploadResumable(path, "Film", first, nil); err == nil {
+		t.Fatal("erreur attendue après la coupure de connexion")
+	}
+	if store.session == nil || store.session.Offset != tusChunkSize {
+		t.Fatalf("session après la coupure: %+v", store.session)
+	}
+	if received := len(fake.upload("upload-1")); received != tusChunkSize+1000 {
+		t.Fatalf("octets reçus par le serveur: %d", received)
+	}
+
+	counter := &upload.ByteCounter{}
+	result, err := uploader.UploadResumable(path, "Film", store, counter)
+	if err != nil || !result.Success {
+		t.Fatalf("reprise: %+v, %v", result, err)
+	}
+	if want := int64(2*tusChunkSize - 1000); counter.Sent() != want {
+		t.Fatalf("octets comptés pour la reprise: %d, attendu %d", counter.Sent(), want)
+	}
+	if fake.createdCount() != 1 {
+		t.Fatalf("la reprise a créé un nouvel upload (%d)", fake.createdCount())
+	}
+	if !bytes.Equal(fake.upload("upload-1"), content) {
+		t.Fatal("données reçues différentes du fichier")
+	}
+}
+
+func TestTusChecksum(t *testing.T) {
+	fake, server := newFakeTus(t)
+	uploader := newTestTusUploader(server, true)
+	path, content := writeRandomFile(t, "film.mkv", 2*tusChunkSize)
+
+	// Somme de contrôle refusée (460): l'upload échoue et reprend au bloc refusé
+	fake.mutex.Lock()
+	fake.rejectChecksum = true
+	fake.mutex.Unlock()
+	store := &sessionStore{}
+	if _, err := uploader.UploadResumable(path, "Film", store, nil); err == nil || !strings.Contains(err.Error(), "somme de contrôle") {
+		t.Fatalf("erreur de somme de contrôle attendue, obtenu %v", err)
+	}
+	if store.session == nil || store.session.Offset != 0 {
+		t.Fatalf("session après le refus: %+v", store.session)
+	}
+
+	result, err := uploader.UploadResumable(path, "Film", store, nil)
+	if err != nil || !result.Success {
+		t.Fatalf("nouvelle tentative: %+v, %v", result, err)
+	}
+	if !bytes.Equal(fake.upload("upload-1"), content) {
+		t.Fatal("données reçues différentes du fichier")
+	}
+	fake.mutex.Lock()
+	checksums := fake.checksums
+	fake.mutex.Unlock()
+	if checksums != 3 {
+		t.Fatalf("sommes de contrôle vérifiées: %d", checksums)
+	}
+}
+
+// interruptingStore programme la coupure du bloc suivant dès que le premier bloc est confirmé
+type interruptingStore struct {
+	*sessionStore
+	fake           *fakeTus
+	interruptAfter int
+}
+
+// Save enregistre la session et programme la coupure après le premier bloc
+func (s *interruptingStore) Save(session *upload.UploadSession) error {
+	if session.Offset > 0 && s.interruptAfter > 0 {
+		s.fake.mutex.Lock()
+		s.fake.interruptAfter = s.interruptAfter
+		s.fake.mutex.Unlock()
+		s.interruptAfter = 0
+	}
+	return s.sessionStore.Save(session)
+}
@@ -1,5 +1,10 @@
 package upload
 
+import (
+	"errors"
+	"fmt"
+)
+
 // Uploader définit l'interface pour les services d'upload
 type Uploader interface {
 	Name() string
@@ -14,4 +19,29 @@ type UploadResult struct {
 	FileCode string
 	URL      string
 	Embed    string
+	// DownloadURL est une URL de téléchargement direct du fichier, utilisable
+	// comme source d'un remote upload vers les autres hébergeurs
+	DownloadURL string
+}
+
+// HTTPError est retournée quand un hébergeur répond avec un code HTTP inattendu
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("le serveur a retourné un code inattendu: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("le serveur a retourné un code inattendu: %d, réponse: %s", e.StatusCode, e.Body)
+}
+
+// StatusCode retourne le code HTTP d'une erreur d'upload, ou 0 si l'erreur n'en porte pas
+func StatusCode(err error) int {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode
+	}
+	return 0
 }
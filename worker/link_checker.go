@@ -0,0 +1,174 @@
+package worker
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"media-upload-system/storage"
+	"media-upload-system/upload"
+)
+
+// LinkChecker vérifie périodiquement que les liens hébergés sont toujours en ligne
+type LinkChecker struct {
+	db               storage.LinkStore
+	checkers         map[string]upload.FileChecker
+	interval         time.Duration
+	recheckAfter     time.Duration
+	failureThreshold int
+	batchSize        int
+	onDead           func(link storage.HostedLink)
+	onStillDead      func(link storage.HostedLink)
+	stopChan         chan struct{}
+	wg               sync.WaitGroup
+}
+
+// NewLinkChecker crée un vérificateur de liens. Les hébergeurs absents de checkers
+// sont vérifiés par une requête HEAD sur l'URL d'embed.
+func NewLinkChecker(db storage.LinkStore, checkers map[string]upload.FileChecker, interval, recheckAfter time.Duration, failureThreshold, batchSize int) *LinkChecker {
+	return &LinkChecker{
+		db:               db,
+		checkers:         checkers,
+		interval:         interval,
+		recheckAfter:     recheckAfter,
+		failureThreshold: failureThreshold,
+		batchSize:        batchSize,
+		onDead:           func(storage.HostedLink) {},
+		onStillDead:      func(storage.HostedLink) {},
+		stopChan:         make(chan struct{}),
+	}
+}
+
+// OnDead enregistre la fonction appelée quand un lien passe à l'état mort
+func (lc *LinkChecker) OnDead(handler func(link storage.HostedLink)) {
+	lc.onDead = handler
+}
+
+// OnStillDead enregistre la fonction appelée à chaque vérification pour les liens morts qui
+// n'ont pas encore été remplacés, par exemple pour relancer un réupload reporté
+func (lc *LinkChecker) OnStillDead(handler func(link storage.HostedLink)) {
+	lc.onStillDead = handler
+}
+
+// Start démarre la vérification périodique
+func (lc *LinkChecker) Start() {
+	lc.wg.Add(1)
+	go lc.loop()
+	log.Printf("Vérificateur de liens démarré (intervalle: %v)", lc.interval)
+}
+
+// Stop arrête la vérification périodique
+func (lc *LinkChecker) Stop() {
+	close(lc.stopChan)
+	lc.wg.Wait()
+	log.Printf("Vérificateur de liens arrêté")
+}
+
+// loop exécute une vérification à chaque intervalle
+func (lc *LinkChecker) loop() {
+	defer lc.wg.Done()
+
+	ticker := time.NewTicker(lc.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lc.stopChan:
+			return
+		case <-ticker.C:
+			lc.CheckLinks()
+		}
+	}
+}
+
+// CheckLinks vérifie un lot de liens qui n'ont pas été contrôlés récemment, puis
+// repasse les liens morts qui n'ont pas encore été remplacés
+func (lc *LinkChecker) CheckLinks() {
+	lc.checkBatch()
+	lc.revisitDeadLinks()
+}
+
+// checkBatch vérifie un lot de liens actifs qui n'ont pas été contrôlés récemment
+func (lc *LinkChecker) checkBatch() {
+	links, err := lc.db.GetLinksToCheck(time.Now().Add(-lc.recheckAfter), lc.batchSize)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des liens à vérifier: %v", err)
+		return
+	}
+
+	if len(links) == 0 {
+		return
+	}
+
+	log.Printf("Vérification de %d liens hébergés", len(links))
+
+	for _, link := range links {
+		select {
+		case <-lc.stopChan:
+			return
+		default:
+		}
+
+		lc.checkLink(link)
+	}
+}
+
+// revisitDeadLinks transmet à onStillDead les liens morts qui n'ont pas encore été remplacés
+func (lc *LinkChecker) revisitDeadLinks() {
+	links, err := lc.db.GetDeadLinks(lc.batchSize)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des liens morts: %v", err)
+		return
+	}
+
+	for _, link := range links {
+		select {
+		case <-lc.stopChan:
+			return
+		default:
+		}
+
+		lc.onStillDead(link)
+	}
+}
+
+// checkLink vérifie un lien et enregistre le résultat
+func (lc *LinkChecker) checkLink(link storage.HostedLink) {
+	var alive bool
+	var err error
+
+	if checker, exists := lc.checkers[link.Hoster]; exists {
+		alive, err = checker.CheckFile(link.FileCode)
+	} else {
+		alive, err = upload.CheckURL(link.Embed)
+	}
+
+	failureCount := link.FailureCount
+	status := link.Status
+
+	switch {
+	case err != nil:
+		// Résultat indéterminé: les échecs consécutifs ne comptent que les réponses négatives
+		log.Printf("Erreur lors de la vérification du lien %s/%s: %v", link.Hoster, link.FileCode, err)
+	case alive:
+		failureCount = 0
+		status = storage.LinkStatusActive
+	default:
+		failureCount++
+		if failureCount >= lc.failureThreshold {
+			status = storage.LinkStatusDead
+		}
+	}
+
+	if err := lc.db.UpdateLinkCheck(link.ID, status, failureCount); err != nil {
+		log.Printf("Erreur lors de l'enregistrement de la vérification du lien %d: %v", link.ID, err)
+		return
+	}
+
+	if status == storage.LinkStatusDead && link.Status != storage.LinkStatusDead {
+		log.Printf("Lien mort détecté: %s/%s (upload %d)", link.Hoster, link.FileCode, link.UploadID)
+		link.Status = status
+		link.FailureCount = failureCount
+		lc.onDead(link)
+	}
+}
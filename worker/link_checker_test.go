@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"media-upload-system/storage"
+	"media-upload-system/upload"
+)
+
+// fakeChecker retourne les résultats programmés dans l'ordre. Seul CheckFile est appelé.
+type fakeChecker struct {
+	upload.Uploader
+	results []error // nil pour un fichier absent, une erreur pour un résultat indéterminé
+}
+
+func (c *fakeChecker) CheckFile(fileCode string) (bool, error) {
+	err := c.results[0]
+	c.results = c.results[1:]
+	return false, err
+}
+
+func TestCheckLinkIgnoresErrors(t *testing.T) {
+	db, err := storage.NewDatabase(filepath.Join(t.TempDir(), "uploads.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	defer db.Close()
+	if err := db.MigrateToLatest(); err != nil {
+		t.Fatalf("MigrateToLatest: %v", err)
+	}
+
+	uploadID, err := db.AddUpload(&storage.Upload{TmdbID: 10, Title: "Film", Type: storage.TypeMovie, FilePath: "/media/film.mkv"})
+	if err != nil {
+		t.Fatalf("AddUpload: %v", err)
+	}
+	if err := db.AddUploadLink(uploadID, storage.HostedLink{Hoster: "mixdrop", FileCode: "abc", Embed: "https://mixdrop.example/e/abc"}); err != nil {
+		t.Fatalf("AddUploadLink: %v", err)
+	}
+
+	unavailable := errors.New("service indisponible")
+	checker := &fakeChecker{results: []error{unavailable, unavailable, nil, nil}}
+	lc := NewLinkChecker(db, map[string]upload.FileChecker{"mixdrop": checker}, time.Hour, time.Hour, 2, 10)
+
+	dead := 0
+	lc.OnDead(func(storage.HostedLink) { dead++ })
+
+	check := func() storage.HostedLink {
+		links, err := db.GetUploadLinks(uploadID)
+		if err != nil || len(links) != 1 {
+			t.Fatalf("GetUploadLinks: %v, %v", links, err)
+		}
+		lc.checkLink(links[0])
+
+		links, _ = db.GetUploadLinks(uploadID)
+		return links[0]
+	}
+
+	// Les erreurs ne rapprochent pas le lien du seuil
+	for i := 0; i < 2; i++ {
+		if link := check(); link.FailureCount != 0 || link.Status == storage.LinkStatusDead {
+			t.Fatalf("erreur comptée comme un échec: %+v", link)
+		}
+	}
+
+	if link := check(); link.FailureCount != 1 || link.Status == storage.LinkStatusDead {
+		t.Fatalf("lien mort après un seul échec: %+v", link)
+	}
+	if link := check(); link.Status != storage.LinkStatusDead || dead != 1 {
+		t.Fatalf("lien mort attendu après deux échecs: %+v, %d notifications", link, dead)
+	}
+}
@@ -8,9 +8,16 @@ import (
 	"media-upload-system/storage"
 )
 
+const (
+	// stuckTaskDelay est la durée après laquelle une tâche en traitement est considérée comme bloquée
+	stuckTaskDelay = 30 * time.Minute
+	// completedTaskRetention est la durée de conservation des tâches terminées
+	completedTaskRetention = 7 * 24 * time.Hour
+)
+
 // QueueManager gère la queue de tâches
 type QueueManager struct {
-	db            *storage.Database
+	db            storage.QueueStore
 	pool          *Pool
 	isRunning     bool
 	stopChan      chan struct{}
@@ -18,10 +25,13 @@ type QueueManager struct {
 	pollInterval  time.Duration
 	cleanupPeriod time.Duration
 	handlers      map[string]func(payload []byte) error
+	windowsMutex  sync.Mutex
+	uploadWindows []TimeWindow
+	uploadTypes   []string
 }
 
 // NewQueueManager crée un nouveau gestionnaire de queue
-func NewQueueManager(db *storage.Database, pool *Pool) *QueueManager {
+func NewQueueManager(db storage.QueueStore, pool *Pool) *QueueManager {
 	return &QueueManager{
 		db:            db,
 		pool:          pool,
@@ -38,6 +48,35 @@ func (qm *QueueManager) RegisterHandler(taskType string, handler func(payload []
 	qm.handlers[taskType] = handler
 }
 
+// SetUploadWindows restreint les tâches des types donnés aux plages horaires autorisées.
+// En dehors de ces plages, elles restent dans la queue jusqu'à la prochaine plage.
+// Sans plage, les tâches sont traitées à toute heure.
+func (qm *QueueManager) SetUploadWindows(windows []TimeWindow, taskTypes ...string) {
+	qm.windowsMutex.Lock()
+	defer qm.windowsMutex.Unlock()
+
+	qm.uploadWindows = windows
+	qm.uploadTypes = taskTypes
+}
+
+// deferredTypes retourne les types de tâches à différer à l'instant donné
+func (qm *QueueManager) deferredTypes(now time.Time) []string {
+	qm.windowsMutex.Lock()
+	defer qm.windowsMutex.Unlock()
+
+	if len(qm.uploadWindows) == 0 {
+		return nil
+	}
+
+	for _, window := range qm.uploadWindows {
+		if window.Contains(now) {
+			return nil
+		}
+	}
+
+	return qm.uploadTypes
+}
+
 // Start démarre le gestionnaire de queue
 func (qm *QueueManager) Start() {
 	if qm.isRunning {
@@ -48,7 +87,7 @@ func (qm *QueueManager) Start() {
 	qm.wg.Add(1)
 
 	// Réinitialiser les tâches bloquées au démarrage
-	count, err := qm.db.ResetStuckQueueItems()
+	count, err := qm.db.ResetStuckQueueItems(time.Now().Add(-stuckTaskDelay))
 	if err != nil {
 		log.Printf("Erreur lors de la réinitialisation des tâches bloquées: %v", err)
 	} else if count > 0 {
@@ -109,7 +148,7 @@ func (qm *QueueManager) cleanupLoop() {
 			return
 		case <-ticker.C:
 			// Nettoyer les tâches terminées de plus de 7 jours
-			count, err := qm.db.CleanupOldCompletedItems(7)
+			count, err := qm.db.CleanupOldCompletedItems(time.Now().Add(-completedTaskRetention))
 			if err != nil {
 				log.Printf("Erreur lors du nettoyage des tâches anciennes: %v", err)
 			} else if count > 0 {
@@ -121,7 +160,9 @@ func (qm *QueueManager) cleanupLoop() {
 
 // processNextTask traite la prochaine tâche dans la queue
 func (qm *QueueManager) processNextTask() {
-	item, err := qm.db.GetNextQueueItem()
+	// Hors des plages autorisées, les uploads restent en attente
+	// La tâche est réservée et marquée en cours de traitement en une seule opération
+	item, err := qm.db.ClaimNextQueueItem(qm.deferredTypes(time.Now())...)
 	if err != nil {
 		log.Printf("Erreur lors de la récupération de la prochaine tâche: %v", err)
 		return
@@ -132,12 +173,6 @@ func (qm *QueueManager) processNextTask() {
 		return
 	}
 
-	// Marquer la tâche comme étant en cours de traitement
-	if err := qm.db.MarkQueueItemProcessing(item.ID); err != nil {
-		log.Printf("Erreur lors du marquage de la tâche %d: %v", item.ID, err)
-		return
-	}
-
 	log.Printf("Traitement de la tâche %d de type %s (tentative %d/%d)",
 		item.ID, item.Type, item.Attempts, item.MaxAttempts)
 
@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeWindow représente une plage horaire quotidienne. Une plage dont la fin
+// précède le début passe minuit (par exemple 23:00-06:00).
+type TimeWindow struct {
+	Start int // minutes depuis minuit
+	End   int // minutes depuis minuit
+}
+
+// ParseTimeWindow crée une plage horaire à partir d'heures au format "HH:MM"
+func ParseTimeWindow(start, end string) (TimeWindow, error) {
+	startMinutes, err := parseClock(start)
+	if err != nil {
+		return TimeWindow{}, err
+	}
+
+	endMinutes, err := parseClock(end)
+	if err != nil {
+		return TimeWindow{}, err
+	}
+
+	return TimeWindow{Start: startMinutes, End: endMinutes}, nil
+}
+
+// Contains indique si l'heure donnée se trouve dans la plage
+func (w TimeWindow) Contains(t time.Time) bool {
+	minutes := t.Hour()*60 + t.Minute()
+
+	if w.Start == w.End {
+		return true
+	}
+	if w.Start < w.End {
+		return minutes >= w.Start && minutes < w.End
+	}
+	return minutes >= w.Start || minutes < w.End
+}
+
+// parseClock convertit une heure "HH:MM" en minutes depuis minuit
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("heure invalide %q (format attendu HH:MM): %w", value, err)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}